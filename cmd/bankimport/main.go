@@ -0,0 +1,103 @@
+// Command bankimport imports the collection account bank statement
+// in MT940 or camt.054 format, credits incoming transfers to client accounts
+// and manages the review queue of transfers which are not matched.
+//
+// Usage:
+//
+//	bankimport -file statement.sta
+//	bankimport -review
+//	bankimport -credit <line ID> -account <account ID>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+	"github.com/palchukovsky/elefantpay-aws/elefant/bankimport"
+	"github.com/palchukovsky/elefantpay-aws/lambda/api"
+)
+
+func main() {
+	file := flag.String("file", "", "bank statement file to import")
+	review := flag.Bool("review", false, "print lines which have to be reviewed")
+	credit := flag.String("credit", "", "ID of the reviewed line to credit")
+	account := flag.String("account", "", "ID of the account to credit")
+	flag.Parse()
+
+	elefant.InitProductLog("backend", "bankimport", "BankImport")
+	defer elefant.Log.CheckExit()
+
+	rand.Seed(time.Now().UnixNano())
+
+	var err error
+	switch {
+	case *file != "":
+		err = importFile(*file)
+	case *review:
+		err = printReview()
+	case *credit != "":
+		err = creditLine(*credit, *account)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		elefant.Log.Error(`Bank import failed: "%v".`, err)
+		os.Exit(1)
+	}
+}
+
+func importFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines, err := bankimport.Parse(data)
+	if err != nil {
+		return fmt.Errorf(`failed to parse "%s": "%v"`, path, err)
+	}
+	result, err := api.NewBankImport().Import(lines)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credited: %d, to review: %d, duplicated: %d.\n",
+		result.Credited, result.Review, result.Duplicated)
+	return nil
+}
+
+func printReview() error {
+	lines, err := api.NewBankImport().GetReview()
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Printf("%s\t%s\t%s %s\t%s\t%s (%s)\t%q\t%s\n",
+			line.ID, line.Time.Format("2006-01-02"), line.Value,
+			line.Value.GetCurrency().GetISO(), line.Reference, line.Debtor,
+			line.DebtorAccount, line.Remittance, line.Reason)
+	}
+	return nil
+}
+
+func creditLine(lineSource, accSource string) error {
+	lineID, err := elefant.ParseBankLineID(lineSource)
+	if err != nil {
+		return fmt.Errorf(`line ID "%s" has invalid format: "%v"`, lineSource, err)
+	}
+	accID, err := elefant.ParseAccountID(accSource)
+	if err != nil {
+		return fmt.Errorf(`account ID "%s" has invalid format: "%v"`,
+			accSource, err)
+	}
+	trans, err := api.NewBankImport().Credit(lineID, accID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Credited by transaction %s.\n", trans.ID)
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	aws "github.com/aws/aws-lambda-go/lambda"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+	"github.com/palchukovsky/elefantpay-aws/lambda/api"
+)
+
+type request struct{}
+type response struct{}
+
+var balanceInterest api.BalanceInterest
+
+func init() {
+	elefant.InitProductLog("backend", "interest", "Interest")
+	defer elefant.Log.CheckExit()
+
+	rand.Seed(time.Now().UnixNano())
+
+	balanceInterest = api.NewBalanceInterest()
+}
+
+func handle(*request) (*response, error) {
+	if err := balanceInterest.Run(); err != nil {
+		return nil, err
+	}
+	return &response{}, nil
+}
+
+func main() {
+	defer elefant.Log.CheckExit()
+	aws.Start(handle)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	aws "github.com/aws/aws-lambda-go/lambda"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+	"github.com/palchukovsky/elefantpay-aws/lambda/api"
+)
+
+type request struct{}
+type response struct{}
+
+var overdraftInterest api.OverdraftInterest
+
+func init() {
+	elefant.InitProductLog("backend", "overdraft", "Overdraft")
+	defer elefant.Log.CheckExit()
+
+	rand.Seed(time.Now().UnixNano())
+
+	overdraftInterest = api.NewOverdraftInterest()
+}
+
+func handle(*request) (*response, error) {
+	if err := overdraftInterest.Run(); err != nil {
+		return nil, err
+	}
+	return &response{}, nil
+}
+
+func main() {
+	defer elefant.Log.CheckExit()
+	aws.Start(handle)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	aws "github.com/aws/aws-lambda-go/lambda"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+	"github.com/palchukovsky/elefantpay-aws/lambda/api"
+)
+
+type request struct {
+	// DryRun only checks the accounts and logs alerts without storing
+	// the report.
+	DryRun bool `json:"dryRun"`
+}
+
+type response struct {
+	Run           string `json:"run"`
+	Accounts      int    `json:"accounts"`
+	Discrepancies int    `json:"discrepancies"`
+}
+
+var reconciliation api.Reconciliation
+
+func init() {
+	elefant.InitProductLog("backend", "reconcile", "Reconcile")
+	defer elefant.Log.CheckExit()
+
+	rand.Seed(time.Now().UnixNano())
+
+	reconciliation = api.NewReconciliation()
+}
+
+func handle(request *request) (*response, error) {
+	result, err := reconciliation.Run(request.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return &response{
+			Run:           result.Run.String(),
+			Accounts:      result.NumberOfAccounts,
+			Discrepancies: result.Discrepancies},
+		nil
+}
+
+func main() {
+	defer elefant.Log.CheckExit()
+	aws.Start(handle)
+}
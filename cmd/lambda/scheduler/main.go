@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	aws "github.com/aws/aws-lambda-go/lambda"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+	"github.com/palchukovsky/elefantpay-aws/lambda/api"
+)
+
+type request struct{}
+type response struct{}
+
+var scheduler api.Scheduler
+
+func init() {
+	elefant.InitProductLog("backend", "scheduler", "Scheduler")
+	defer elefant.Log.CheckExit()
+
+	rand.Seed(time.Now().UnixNano())
+
+	scheduler = api.NewScheduler()
+}
+
+func handle(*request) (*response, error) {
+	if err := scheduler.Run(); err != nil {
+		return nil, err
+	}
+	return &response{}, nil
+}
+
+func main() {
+	defer elefant.Log.CheckExit()
+	aws.Start(handle)
+}
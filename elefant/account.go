@@ -1,6 +1,10 @@
 package elefant
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+
 	"github.com/google/uuid"
 )
 
@@ -14,36 +18,204 @@ func ParseAccountID(source string) (AccountID, error) {
 	return uuid.Parse(source)
 }
 
+type nullAccountID struct {
+	AccountID AccountID
+	Valid     bool
+}
+
+// Scan implements the Scanner interface.
+func (n *nullAccountID) Scan(value interface{}) error {
+	var err error
+	n.AccountID, n.Valid, err = scanNullUUID(value)
+	return err
+}
+
+// Value implements the driver Valuer interface.
+func (n nullAccountID) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.AccountID.String(), nil
+}
+
+// AccountStatus is an account state, which defines allowed balance changes.
+type AccountStatus int16
+
+const (
+	// AccountStatusActive is a status of account without restrictions.
+	AccountStatusActive AccountStatus = 1
+	// AccountStatusFrozenDebit is a status of account from which funds could
+	// not be withdrawn, but could be deposited.
+	AccountStatusFrozenDebit AccountStatus = 2
+	// AccountStatusFrozenAll is a status of account which balance could not be
+	// changed by client operations.
+	AccountStatusFrozenAll AccountStatus = 3
+	// AccountStatusClosed is a final status of account, its balance is zero
+	// and could not be changed.
+	AccountStatusClosed AccountStatus = 4
+)
+
+func parseAccountStatusFromDB(source int16) (AccountStatus, error) {
+	switch AccountStatus(source) {
+	case AccountStatusActive,
+		AccountStatusFrozenDebit,
+		AccountStatusFrozenAll,
+		AccountStatusClosed:
+		return AccountStatus(source), nil
+	default:
+		return 0, fmt.Errorf(`failed to parse account status from DB-value "%v"`,
+			source)
+	}
+}
+
+func (status AccountStatus) String() string {
+	switch status {
+	case AccountStatusActive:
+		return "active"
+	case AccountStatusFrozenDebit:
+		return "frozen-debit"
+	case AccountStatusFrozenAll:
+		return "frozen-all"
+	case AccountStatusClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// CheckPosting returns error if the posting with the value is not allowed
+// for the account in the status. Charges are house postings, like fees and
+// interest, which are applied to frozen accounts too.
+func (status AccountStatus) CheckPosting(
+	acc AccountID, value Money, isCharge bool) error {
+	isAllowed := true
+	switch status {
+	case AccountStatusFrozenDebit:
+		isAllowed = isCharge || !value.IsNegative()
+	case AccountStatusFrozenAll:
+		isAllowed = isCharge
+	case AccountStatusClosed:
+		isAllowed = false
+	}
+	if isAllowed {
+		return nil
+	}
+	return &AccountStatusError{Account: acc, Status: status}
+}
+
+// AccountStatusError is returned if the account balance could not be changed
+// because of the account status.
+type AccountStatusError struct {
+	Account AccountID
+	Status  AccountStatus
+}
+
+func (err *AccountStatusError) Error() string {
+	switch err.Status {
+	case AccountStatusFrozenDebit:
+		return fmt.Sprintf(`account "%s" is frozen for withdrawals`, err.Account)
+	case AccountStatusClosed:
+		return fmt.Sprintf(`account "%s" is closed`, err.Account)
+	default:
+		return fmt.Sprintf(`account "%s" is frozen`, err.Account)
+	}
+}
+
 // Account describes account.
 type Account interface {
 	GetID() AccountID
 	GetClientID() ClientID
 	GetCurrency() Currency
-	GetBalance() float64
+	GetBalance() Money
+	// GetOverdraft returns the max value by which the balance could be
+	// negative.
+	GetOverdraft() Money
+	// GetHeld returns the value reserved by authorized holds, which is not
+	// withdrawn from the balance yet.
+	GetHeld() Money
+	// GetAvailableBalance returns the value which could be withdrawn from
+	// the account, including the unused overdraft and excluding the held
+	// value.
+	GetAvailableBalance() Money
 	GetRevision() int64
+	GetStatus() AccountStatus
 }
 
 func newAccount(
 	id AccountID, client ClientID, currency Currency,
-	balance float64, revision int64) Account {
+	balance Money, overdraft Money, held Money, revision int64,
+	status AccountStatus) Account {
 	return &account{
-		id:       id,
-		client:   client,
-		currency: currency,
-		balance:  balance,
-		revision: revision}
+		id:        id,
+		client:    client,
+		currency:  currency,
+		balance:   balance,
+		overdraft: overdraft,
+		held:      held,
+		revision:  revision,
+		status:    status}
+}
+
+func newAccountFromDB(
+	id AccountID, client ClientID, currencyISO string,
+	balance int64, overdraft int64, held int64, revision int64,
+	status int16) (Account, error) {
+	currency, err := newCurrencyFromDB(currencyISO)
+	if err != nil {
+		return nil, err
+	}
+	statusValue, err := parseAccountStatusFromDB(status)
+	if err != nil {
+		return nil, err
+	}
+	return newAccount(id, client, currency, NewMoney(balance, currency),
+		NewMoney(overdraft, currency), NewMoney(held, currency), revision,
+		statusValue), nil
 }
 
 type account struct {
-	id       AccountID
-	client   ClientID
-	currency Currency
-	balance  float64
-	revision int64
+	id        AccountID
+	client    ClientID
+	currency  Currency
+	balance   Money
+	overdraft Money
+	held      Money
+	revision  int64
+	status    AccountStatus
 }
 
 func (account *account) GetID() AccountID      { return account.id }
 func (account *account) GetClientID() ClientID { return account.client }
 func (account *account) GetCurrency() Currency { return account.currency }
-func (account *account) GetBalance() float64   { return account.balance }
+func (account *account) GetBalance() Money     { return account.balance }
+func (account *account) GetOverdraft() Money   { return account.overdraft }
+func (account *account) GetHeld() Money        { return account.held }
 func (account *account) GetRevision() int64    { return account.revision }
+func (account *account) GetStatus() AccountStatus {
+	return account.status
+}
+func (account *account) GetAvailableBalance() Money {
+	return account.balance.Add(account.overdraft).Sub(account.held)
+}
+
+// CalcOverdraftInterest returns the interest for one day of the used
+// overdraft, or zero if the balance is not negative.
+func CalcOverdraftInterest(balance Money) Money {
+	if !balance.IsNegative() {
+		return NewMoney(0, balance.GetCurrency())
+	}
+	dailyRate := big.NewRat(OverdraftAnnualInterestPercent, 100*365)
+	return balance.Neg().MulRat(dailyRate, RoundHalfEven)
+}
+
+// CalcDailyInterest returns the exact interest for one day of the positive
+// end-of-day balance by the annual rate in percents, in the currency major
+// units. Returns zero if the balance is not positive. The interest is not
+// rounded, as it's accrued daily and paid monthly.
+func CalcDailyInterest(balance Money, annualPercent *big.Rat) *big.Rat {
+	if !balance.IsPositive() {
+		return new(big.Rat)
+	}
+	dailyRate := new(big.Rat).Quo(annualPercent, big.NewRat(100*365, 1))
+	return dailyRate.Mul(dailyRate, balance.GetRat())
+}
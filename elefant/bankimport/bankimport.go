@@ -0,0 +1,63 @@
+// Package bankimport reads incoming transfers from the collection account
+// bank statements and matches them with client accounts.
+package bankimport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Parse parses bank statement in MT940 or camt.054 format, the format is
+// detected by the content. Returns only credit lines, as only incoming
+// transfers are deposits.
+func Parse(data []byte) ([]*elefant.BankLine, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return ParseCamt054(data)
+	}
+	return ParseMT940(data)
+}
+
+// newValue parses positive statement amount, which has comma or dot as
+// decimal separator.
+func newValue(source string, currency string) (elefant.Money, error) {
+	valueCurrency, err := elefant.NewCurrency(currency)
+	if err != nil {
+		return elefant.Money{}, err
+	}
+	source = strings.Replace(strings.TrimSpace(source), ",", ".", 1)
+	// MT940 allows amount without fractional digits, but with the separator.
+	source = strings.TrimSuffix(source, ".")
+	result, err := elefant.ParseMoney(source, valueCurrency)
+	if err != nil {
+		return elefant.Money{}, err
+	}
+	if !result.IsPositive() {
+		return elefant.Money{}, fmt.Errorf(`amount "%s" is not positive`, source)
+	}
+	return result, nil
+}
+
+// joinText joins the text parts into one line without extra spaces.
+func joinText(parts ...string) string {
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// parseDate parses the booking date in one of the layouts, the date without
+// time zone is in UTC.
+func parseDate(source string, layouts ...string) (time.Time, error) {
+	source = strings.TrimSpace(source)
+	for _, layout := range layouts {
+		if result, err := time.Parse(layout, source); err == nil {
+			return result.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(`date "%s" has unknown format`, source)
+}
+
+////////////////////////////////////////////////////////////////////////////////
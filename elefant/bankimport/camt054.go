@@ -0,0 +1,175 @@
+package bankimport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// camt.054 elements are read without namespace, so all message versions
+// (camt.054.001.02 and later) are supported.
+type camt054Document struct {
+	Notifications []*camt054Notification `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+}
+
+type camt054Notification struct {
+	Account camt054AccountID `xml:"Acct>Id"`
+	Entries []*camt054Entry  `xml:"Ntry"`
+}
+
+type camt054AccountID struct {
+	IBAN  string `xml:"IBAN"`
+	Other string `xml:"Othr>Id"`
+}
+
+func (id camt054AccountID) String() string {
+	if id.IBAN != "" {
+		return strings.TrimSpace(id.IBAN)
+	}
+	return strings.TrimSpace(id.Other)
+}
+
+type camt054Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camt054Entry struct {
+	Amount    camt054Amount `xml:"Amt"`
+	Indicator string        `xml:"CdtDbtInd"`
+	Reversal  bool          `xml:"RvslInd"`
+	// Status is a text in camt.054.001.02-07 and a code in later versions.
+	Status struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate struct {
+		Date     string `xml:"Dt"`
+		DateTime string `xml:"DtTm"`
+	} `xml:"BookgDt"`
+	ServicerRef string                `xml:"AcctSvcrRef"`
+	Details     []*camt054Transaction `xml:"NtryDtls>TxDtls"`
+}
+
+type camt054Transaction struct {
+	Refs struct {
+		ServicerRef string `xml:"AcctSvcrRef"`
+		EndToEndID  string `xml:"EndToEndId"`
+	} `xml:"Refs"`
+	// Amount is the transaction amount in camt.054.001.03 and later, previous
+	// versions have only the amount details.
+	Amount        *camt054Amount `xml:"Amt"`
+	AmountDetails *camt054Amount `xml:"AmtDtls>TxAmt>Amt"`
+	Indicator     string         `xml:"CdtDbtInd"`
+	Parties       struct {
+		// Debtor name is in the party element since camt.054.001.08.
+		Debtor        string           `xml:"Dbtr>Nm"`
+		DebtorParty   string           `xml:"Dbtr>Pty>Nm"`
+		DebtorAccount camt054AccountID `xml:"DbtrAcct>Id"`
+	} `xml:"RltdPties"`
+	Remittance struct {
+		Unstructured []string `xml:"Ustrd"`
+		Structured   []string `xml:"Strd>CdtrRefInf>Ref"`
+	} `xml:"RmtInf"`
+}
+
+// ParseCamt054 parses ISO 20022 camt.054 (bank to customer debit credit
+// notification) XML. Entries with several transactions give a line for each
+// transaction. Returns only booked credit lines, which are not reversals.
+func ParseCamt054(data []byte) ([]*elefant.BankLine, error) {
+	var document camt054Document
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf(`failed to parse camt.054: "%v"`, err)
+	}
+	result := []*elefant.BankLine{}
+	for _, notification := range document.Notifications {
+		statementAccount := notification.Account.String()
+		for _, entry := range notification.Entries {
+			lines, err := parseCamt054Entry(entry, statementAccount)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, lines...)
+		}
+	}
+	return result, nil
+}
+
+func parseCamt054Entry(
+	entry *camt054Entry,
+	statementAccount string) ([]*elefant.BankLine, error) {
+	status := strings.TrimSpace(entry.Status.Value)
+	if entry.Status.Code != "" {
+		status = strings.TrimSpace(entry.Status.Code)
+	}
+	if entry.Indicator != "CRDT" || entry.Reversal ||
+		(status != "" && status != "BOOK") {
+		return nil, nil
+	}
+
+	date := entry.BookingDate.DateTime
+	if date == "" {
+		date = entry.BookingDate.Date
+	}
+	bookingTime, err := parseDate(date,
+		"2006-01-02", "2006-01-02T15:04:05", "2006-01-02T15:04:05Z07:00")
+	if err != nil {
+		return nil, fmt.Errorf(`camt.054 entry "%s" has invalid booking date: "%v"`,
+			entry.ServicerRef, err)
+	}
+
+	details := entry.Details
+	if len(details) == 0 {
+		// Entry without details has only the entry reference.
+		details = []*camt054Transaction{{}}
+	}
+	result := make([]*elefant.BankLine, 0, len(details))
+	for _, transaction := range details {
+		if transaction.Indicator != "" && transaction.Indicator != "CRDT" {
+			continue
+		}
+		amount := entry.Amount
+		if transaction.Amount != nil {
+			amount = *transaction.Amount
+		} else if transaction.AmountDetails != nil {
+			amount = *transaction.AmountDetails
+		} else if len(details) > 1 {
+			return nil, fmt.Errorf(
+				`camt.054 entry "%s" has transaction without amount`,
+				entry.ServicerRef)
+		}
+		value, err := newValue(amount.Value, amount.Currency)
+		if err != nil {
+			return nil, fmt.Errorf(`camt.054 entry "%s" has invalid amount: "%v"`,
+				entry.ServicerRef, err)
+		}
+
+		reference := transaction.Refs.ServicerRef
+		if reference == "" {
+			reference = entry.ServicerRef
+		}
+		if len(details) > 1 && transaction.Refs.ServicerRef == "" {
+			// Batch entry reference is the same for all transactions.
+			reference += "/" + transaction.Refs.EndToEndID
+		}
+		debtor := transaction.Parties.Debtor
+		if debtor == "" {
+			debtor = transaction.Parties.DebtorParty
+		}
+		remittance := append(
+			append([]string{}, transaction.Remittance.Structured...),
+			transaction.Remittance.Unstructured...)
+
+		result = append(result, elefant.NewBankLine(statementAccount,
+			bookingTime, value, strings.TrimSpace(reference),
+			joinText(remittance...), joinText(debtor),
+			transaction.Parties.DebtorAccount.String()))
+	}
+	return result, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
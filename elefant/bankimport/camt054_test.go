@@ -0,0 +1,119 @@
+package bankimport
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseCamt054(t *testing.T) {
+	data := readBankTestFile(t, "notification.camt054.xml")
+	lines, err := ParseCamt054(data)
+	if err != nil {
+		t.Fatalf(`Failed to parse camt.054: "%v".`, err)
+	}
+	// Debit, reversal and pending entries are skipped.
+	checkBankLines(t, lines, []expectedBankLine{
+		{
+			// Amount in the details and the remittance in several parts.
+			statementAccount: "DE89370400440532013000",
+			time:             time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+			value:            "150.00",
+			currency:         "EUR",
+			reference:        "BK-0001",
+			remittance:       "Elefantpay 5b7c1a2e-0f3d-4c1e- 9a7b-1234567890ab",
+			debtor:           "Max Mustermann",
+			debtorAccount:    "DE02120300000000202051"},
+		{
+			// Batch entry transactions.
+			statementAccount: "DE89370400440532013000",
+			time:             time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+			value:            "100.50",
+			currency:         "EUR",
+			reference:        "BK-0005/E2E-1",
+			remittance:       "c0ffee00-1111-2222-3333-444455556666"},
+		{
+			statementAccount: "DE89370400440532013000",
+			time:             time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+			value:            "200.00",
+			currency:         "EUR",
+			reference:        "BK-0005/E2E-2",
+			remittance:       "Invoice 42"},
+		{
+			// Newer version with the status code, the booking time with time
+			// zone and the debtor party, the amount is only in the entry.
+			statementAccount: "0532013001",
+			time:             time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC),
+			value:            "99.99",
+			currency:         "USD",
+			reference:        "BK-0006-1",
+			debtor:           "ACME Corp"},
+	})
+
+	// The format is detected by the content.
+	if detected, err := Parse(data); err != nil || len(detected) != len(lines) {
+		t.Errorf(`camt.054 is not detected: %d lines, "%v".`, len(detected), err)
+	}
+}
+
+func TestParseCamt054Invalid(t *testing.T) {
+	newDocument := func(entries ...string) string {
+		return `<Document><BkToCstmrDbtCdtNtfctn><Ntfctn>` +
+			`<Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>` +
+			strings.Join(entries, "") +
+			`</Ntfctn></BkToCstmrDbtCdtNtfctn></Document>`
+	}
+	newEntry := func(amount, date, details string) string {
+		return `<Ntry>` + amount + `<CdtDbtInd>CRDT</CdtDbtInd>` +
+			`<Sts>BOOK</Sts><BookgDt><Dt>` + date + `</Dt></BookgDt>` +
+			`<AcctSvcrRef>BK-1</AcctSvcrRef>` + details + `</Ntry>`
+	}
+	const amount = `<Amt Ccy="EUR">10.00</Amt>`
+	for _, test := range []struct {
+		name   string
+		source string
+	}{
+		{name: "not XML", source: `<Document><BkToCstmrDbtCdtNtfctn>`},
+		{
+			name:   "invalid date",
+			source: newDocument(newEntry(amount, "16.10.2026", ""))},
+		{
+			name:   "without date",
+			source: newDocument(newEntry(amount, "", ""))},
+		{
+			name: "zero amount",
+			source: newDocument(
+				newEntry(`<Amt Ccy="EUR">0.00</Amt>`, "2026-10-16", ""))},
+		{
+			name: "negative amount",
+			source: newDocument(
+				newEntry(`<Amt Ccy="EUR">-1.00</Amt>`, "2026-10-16", ""))},
+		{
+			name: "amount with too many digits",
+			source: newDocument(
+				newEntry(`<Amt Ccy="EUR">1.001</Amt>`, "2026-10-16", ""))},
+		{
+			name: "unknown currency",
+			source: newDocument(
+				newEntry(`<Amt Ccy="EURO">1.00</Amt>`, "2026-10-16", ""))},
+		{
+			name: "batch transaction without amount",
+			source: newDocument(newEntry(amount, "2026-10-16",
+				`<NtryDtls><TxDtls><Amt Ccy="EUR">4.00</Amt></TxDtls>`+
+					`<TxDtls></TxDtls></NtryDtls>`))},
+	} {
+		if lines, err := ParseCamt054([]byte(test.source)); err == nil {
+			t.Errorf(`camt.054 with %s is parsed: %d lines.`, test.name, len(lines))
+		}
+	}
+
+	// The document without notifications doesn't have lines.
+	lines, err := ParseCamt054([]byte(`<Document></Document>`))
+	if err != nil || len(lines) != 0 {
+		t.Errorf(`Empty camt.054 is parsed as %d lines, "%v".`, len(lines), err)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
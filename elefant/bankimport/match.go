@@ -0,0 +1,83 @@
+package bankimport
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// accountReference matches client account ID with or without dashes, which
+// is not a part of a longer hexadecimal string.
+var accountReference = regexp.MustCompile(
+	`(?i)(?:^|[^0-9a-f])([0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?` +
+		`[0-9a-f]{12})(?:$|[^0-9a-f])`)
+
+// findAccountReferences returns unique account IDs from the remittance.
+func findAccountReferences(remittance string) []elefant.AccountID {
+	result := []elefant.AccountID{}
+	has := map[elefant.AccountID]bool{}
+	for start := 0; start < len(remittance); {
+		match := accountReference.FindStringSubmatchIndex(remittance[start:])
+		if match == nil {
+			break
+		}
+		reference := remittance[start+match[2] : start+match[3]]
+		// The next search starts from the separator after the reference, as it
+		// could be the separator before the next reference.
+		start += match[3]
+		id, err := elefant.ParseAccountID(reference)
+		if err != nil || has[id] {
+			continue
+		}
+		has[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+// Match finds the client account for the line by the account reference in
+// the remittance. If there is no confident match - returns nil account and
+// the reason why the line has to be reviewed.
+func Match(
+	line *elefant.BankLine,
+	db elefant.DBTrans) (elefant.Account, string, error) {
+	references := findAccountReferences(line.Remittance)
+	if len(references) == 0 {
+		// Banks split the remittance into parts of fixed length, so
+		// the reference could be split by spaces.
+		references = findAccountReferences(
+			strings.Join(strings.Fields(line.Remittance), ""))
+	}
+	switch len(references) {
+	case 0:
+		return nil, "no account reference", nil
+	case 1:
+		break
+	default:
+		return nil, fmt.Sprintf("%d account references", len(references)), nil
+	}
+
+	acc, err := db.FindAccount(references[0])
+	if err != nil {
+		return nil, "", fmt.Errorf(`failed to find account "%s": "%v"`,
+			references[0], err)
+	}
+	if acc == nil {
+		return nil, fmt.Sprintf(`unknown account "%s"`, references[0]), nil
+	}
+	if acc.GetCurrency().GetISO() != line.Value.GetCurrency().GetISO() {
+		return nil, fmt.Sprintf(`account "%s" currency is %s`,
+			acc.GetID(), acc.GetCurrency().GetISO()), nil
+	}
+	if err := acc.GetStatus().CheckPosting(
+		acc.GetID(), line.Value, false); err != nil {
+		return nil, err.Error(), nil
+	}
+	return acc, "", nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
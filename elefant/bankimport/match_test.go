@@ -0,0 +1,156 @@
+package bankimport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// matchTestAccount has only ID, currency and status, other account methods
+// are not implemented.
+type matchTestAccount struct {
+	elefant.Account
+	id       elefant.AccountID
+	currency elefant.Currency
+	status   elefant.AccountStatus
+}
+
+func (acc *matchTestAccount) GetID() elefant.AccountID { return acc.id }
+func (acc *matchTestAccount) GetCurrency() elefant.Currency {
+	return acc.currency
+}
+func (acc *matchTestAccount) GetStatus() elefant.AccountStatus {
+	return acc.status
+}
+
+// matchTestDB finds accounts as the DB does, other DB methods are not
+// implemented.
+type matchTestDB struct {
+	elefant.DBTrans
+	accounts map[elefant.AccountID]elefant.Account
+}
+
+func (db *matchTestDB) FindAccount(
+	id elefant.AccountID) (elefant.Account, error) {
+	return db.accounts[id], nil
+}
+
+func newMatchTestCurrency(t *testing.T, iso string) elefant.Currency {
+	result, err := elefant.NewCurrency(iso)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestMatch(t *testing.T) {
+	eur := newMatchTestCurrency(t, "EUR")
+	db := &matchTestDB{accounts: map[elefant.AccountID]elefant.Account{}}
+	newAcc := func(
+		currency elefant.Currency, status elefant.AccountStatus) elefant.AccountID {
+		result := &matchTestAccount{
+			id: uuid.New(), currency: currency, status: status}
+		db.accounts[result.id] = result
+		return result.id
+	}
+	active := newAcc(eur, elefant.AccountStatusActive)
+	frozenDebit := newAcc(eur, elefant.AccountStatusFrozenDebit)
+	frozenAll := newAcc(eur, elefant.AccountStatusFrozenAll)
+	closed := newAcc(eur, elefant.AccountStatusClosed)
+	usd := newAcc(newMatchTestCurrency(t, "USD"), elefant.AccountStatusActive)
+	unknown := uuid.New()
+
+	noDashes := strings.ReplaceAll(active.String(), "-", "")
+	for _, test := range []struct {
+		name       string
+		remittance string
+		// expected is nil if the line has to be reviewed.
+		expected *elefant.AccountID
+	}{
+		{
+			name:       "reference",
+			remittance: "Elefantpay " + active.String() + " top-up",
+			expected:   &active},
+		{
+			name:       "only reference",
+			remittance: active.String(),
+			expected:   &active},
+		{
+			name:       "upper case reference",
+			remittance: "ELEFANTPAY " + strings.ToUpper(active.String()),
+			expected:   &active},
+		{
+			name:       "reference without dashes",
+			remittance: "Top-up " + noDashes,
+			expected:   &active},
+		{
+			name:       "reference split by bank",
+			remittance: "Top-up " + noDashes[:20] + " " + noDashes[20:] + " thanks",
+			expected:   &active},
+		{
+			name:       "reference with text without spaces",
+			remittance: "SVWZ+" + active.String() + "+EREF",
+			expected:   &active},
+		{
+			name:       "repeated reference",
+			remittance: active.String() + " / " + noDashes,
+			expected:   &active},
+		{
+			name:       "account frozen for debit",
+			remittance: frozenDebit.String(),
+			expected:   &frozenDebit},
+		{name: "without reference", remittance: "Invoice 42"},
+		{name: "empty remittance", remittance: ""},
+		{
+			name:       "part of longer hexadecimal",
+			remittance: "ab" + noDashes + "cd"},
+		{
+			name:       "truncated reference",
+			remittance: "Top-up " + noDashes[:31]},
+		{
+			name:       "two references",
+			remittance: active.String() + " " + frozenDebit.String()},
+		{name: "unknown account", remittance: unknown.String()},
+		{name: "other currency", remittance: usd.String()},
+		{name: "frozen account", remittance: frozenAll.String()},
+		{name: "closed account", remittance: closed.String()},
+	} {
+		value, err := elefant.ParseMoney("100.00", eur)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := elefant.NewBankLine("DE89370400440532013000",
+			time.Now(), value, "BK-1", test.remittance, "", "")
+		acc, reason, err := Match(line, db)
+		if err != nil {
+			t.Fatalf(`Failed to match line "%s": "%v".`, test.name, err)
+		}
+		if test.expected == nil {
+			if acc != nil {
+				t.Errorf(`Line "%s" is matched with account "%s".`,
+					test.name, acc.GetID())
+			}
+			if reason == "" {
+				t.Errorf(`Line "%s" is not matched without reason.`, test.name)
+			}
+			continue
+		}
+		if acc == nil {
+			t.Errorf(`Line "%s" is not matched: "%s".`, test.name, reason)
+			continue
+		}
+		if acc.GetID() != *test.expected || reason != "" {
+			t.Errorf(`Line "%s" is matched with "%s" ("%s"), but expected "%s".`,
+				test.name, acc.GetID(), reason, *test.expected)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
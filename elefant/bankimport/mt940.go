@@ -0,0 +1,175 @@
+package bankimport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// mt940Tag matches the field tag at the line start, like ":61:" or ":60F:".
+var mt940Tag = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):`)
+
+// mt940Line matches the statement line field :61: - value date, optional
+// entry date, debit-credit mark with optional funds code, amount,
+// transaction type, customer reference and optional bank reference.
+var mt940Line = regexp.MustCompile(
+	`^([0-9]{6})([0-9]{4})?(RC|RD|C|D)([A-Z])?([0-9]+,[0-9]*)` +
+		`[NSF][A-Z0-9]{3}([^/\n]*)(?://([^\n]*))?`)
+
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+// readMT940Fields reads message fields, field continuation lines are joined
+// with the field value by the new line, SWIFT block markers are skipped.
+func readMT940Fields(data []byte) ([]*mt940Field, error) {
+	result := []*mt940Field{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if match := mt940Tag.FindStringSubmatch(line); match != nil {
+			result = append(result, &mt940Field{
+				tag:   match[1],
+				value: line[len(match[0]):]})
+			continue
+		}
+		if line == "" || line == "-" || strings.HasPrefix(line, "{") ||
+			strings.HasPrefix(line, "-}") || len(result) == 0 {
+			continue
+		}
+		last := result[len(result)-1]
+		last.value += "\n" + line
+	}
+	return result, scanner.Err()
+}
+
+// ParseMT940 parses SWIFT MT940 customer statement, which could have several
+// messages. Returns only credit lines.
+func ParseMT940(data []byte) ([]*elefant.BankLine, error) {
+	fields, err := readMT940Fields(data)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read MT940: "%v"`, err)
+	}
+
+	result := []*elefant.BankLine{}
+	var statementAccount, currency string
+	var line *elefant.BankLine
+	for _, field := range fields {
+		switch field.tag {
+		case "20":
+			// New message.
+			statementAccount, currency, line = "", "", nil
+		case "25":
+			statementAccount = strings.TrimSpace(field.value)
+		case "60F", "60M":
+			// Opening balance: debit-credit mark, date, currency and amount.
+			if len(field.value) < 10 {
+				return nil, fmt.Errorf(`MT940 opening balance "%s" is invalid`,
+					field.value)
+			}
+			currency = field.value[7:10]
+		case "61":
+			line = nil
+			if statementAccount == "" || currency == "" {
+				return nil, fmt.Errorf(
+					`MT940 statement line "%s" is not preceded by account and balance`,
+					field.value)
+			}
+			if line, err = parseMT940Line(
+				field.value, statementAccount, currency); err != nil {
+				return nil, err
+			}
+			if line != nil {
+				result = append(result, line)
+			}
+		case "86":
+			// Information to account owner belongs to the previous statement line,
+			// the key is recalculated as it includes the remittance.
+			if line == nil {
+				continue
+			}
+			remittance, debtor, debtorAccount := parseMT940Info(field.value)
+			updated := elefant.NewBankLine(statementAccount, line.Time, line.Value,
+				line.Reference, remittance, debtor, debtorAccount)
+			*line = *updated
+			line = nil
+		}
+	}
+	return result, nil
+}
+
+// parseMT940Line parses statement line, returns nil for debit lines.
+func parseMT940Line(
+	source string,
+	statementAccount string,
+	currency string) (*elefant.BankLine, error) {
+	match := mt940Line.FindStringSubmatch(source)
+	if match == nil {
+		return nil, fmt.Errorf(`MT940 statement line "%s" is invalid`, source)
+	}
+	if match[3] != "C" {
+		return nil, nil
+	}
+	date, err := parseDate(match[1], "060102")
+	if err != nil {
+		return nil, fmt.Errorf(`MT940 statement line "%s" has invalid date: "%v"`,
+			source, err)
+	}
+	value, err := newValue(match[5], currency)
+	if err != nil {
+		return nil, fmt.Errorf(`MT940 statement line "%s" has invalid amount: "%v"`,
+			source, err)
+	}
+	// The bank reference identifies the transfer better, the customer
+	// reference often is "NONREF".
+	reference := strings.TrimSpace(match[7])
+	if reference == "" {
+		reference = strings.TrimSpace(match[6])
+	}
+	return elefant.NewBankLine(
+		statementAccount, date, value, reference, "", "", ""), nil
+}
+
+// parseMT940Info parses information to account owner field :86:. The field
+// could be structured by "?NN" subfields (German banks standard), then
+// the remittance is in subfields 20-29 and 60-63, the debtor name - in 32-33
+// and the debtor account - in 31. Otherwise, the whole field is
+// the remittance.
+func parseMT940Info(source string) (string, string, string) {
+	if len(source) < 4 || source[3] != '?' {
+		// Free text lines are separated by spaces, the matcher finds
+		// the reference split by the line end too.
+		return joinText(source), "", ""
+	}
+	source = strings.ReplaceAll(source, "\n", "")
+	var remittance, debtor []string
+	var debtorAccount string
+	for _, subfield := range strings.Split(source[4:], "?") {
+		if len(subfield) < 2 {
+			continue
+		}
+		value := subfield[2:]
+		switch code := subfield[:2]; {
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			remittance = append(remittance, value)
+		case code == "32", code == "33":
+			debtor = append(debtor, value)
+		case code == "31":
+			debtorAccount = strings.TrimSpace(value)
+		}
+	}
+	// Remittance subfields are parts of one text, which is split by the field
+	// length, so they are joined without separator.
+	return joinText(strings.Join(remittance, "")),
+		joinText(strings.Join(debtor, "")),
+		debtorAccount
+}
+
+////////////////////////////////////////////////////////////////////////////////
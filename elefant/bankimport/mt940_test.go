@@ -0,0 +1,195 @@
+package bankimport
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// expectedBankLine is a line which has to be parsed from a statement.
+type expectedBankLine struct {
+	statementAccount string
+	time             time.Time
+	value            string
+	currency         string
+	reference        string
+	remittance       string
+	debtor           string
+	debtorAccount    string
+}
+
+func readBankTestFile(t *testing.T, name string) []byte {
+	result, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf(`Failed to read test file "%s": "%v".`, name, err)
+	}
+	return result
+}
+
+func checkBankLines(
+	t *testing.T, lines []*elefant.BankLine, expected []expectedBankLine) {
+	if len(lines) != len(expected) {
+		t.Fatalf("Parsed %d lines, but expected %d.", len(lines), len(expected))
+	}
+	keys := map[string]bool{}
+	for i, line := range lines {
+		test := expected[i]
+		if line.Value.GetCurrency().GetISO() != test.currency ||
+			line.Value.String() != test.value {
+			t.Errorf(`Line %d value is %s %s, but expected %s %s.`, i,
+				line.Value, line.Value.GetCurrency().GetISO(),
+				test.value, test.currency)
+		}
+		if !line.Time.Equal(test.time) {
+			t.Errorf(`Line %d time is %s, but expected %s.`, i, line.Time, test.time)
+		}
+		for _, field := range []struct {
+			name     string
+			value    string
+			expected string
+		}{
+			{"reference", line.Reference, test.reference},
+			{"remittance", line.Remittance, test.remittance},
+			{"debtor", line.Debtor, test.debtor},
+			{"debtor account", line.DebtorAccount, test.debtorAccount},
+		} {
+			if field.value != field.expected {
+				t.Errorf(`Line %d %s is "%s", but expected "%s".`,
+					i, field.name, field.value, field.expected)
+			}
+		}
+		// The key has to be the same as for the line which is created with
+		// all fields at once.
+		key := elefant.NewBankLine(test.statementAccount, line.Time, line.Value,
+			line.Reference, line.Remittance, line.Debtor, line.DebtorAccount).Key
+		if line.Key != key {
+			t.Errorf(`Line %d key is "%s", but expected "%s".`, i, line.Key, key)
+		}
+		if keys[line.Key] {
+			t.Errorf(`Line %d key "%s" is not unique.`, i, line.Key)
+		}
+		keys[line.Key] = true
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseMT940(t *testing.T) {
+	data := readBankTestFile(t, "statement.mt940")
+	lines, err := ParseMT940(data)
+	if err != nil {
+		t.Fatalf(`Failed to parse MT940: "%v".`, err)
+	}
+	checkBankLines(t, lines, []expectedBankLine{
+		{
+			// Structured information to account owner with subfields split by
+			// lines.
+			statementAccount: "COBADEFFXXX/DE89370400440532013000",
+			time:             time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+			value:            "150.00",
+			currency:         "EUR",
+			reference:        "BK2610160001",
+			remittance: "EREF+NOTPROVIDEDSVWZ+ELEFANTPAY " +
+				"5B7C1A2E-0F3D-4C1E-9A7B-1234567890AB",
+			debtor:        "MAX MUSTERMANN",
+			debtorAccount: "DE02120300000000202051"},
+		{
+			// Free text information to account owner in several lines, amount
+			// without fractional digits, funds code and without bank reference.
+			statementAccount: "COBADEFFXXX/DE89370400440532013000",
+			time:             time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+			value:            "75.00",
+			currency:         "EUR",
+			reference:        "REF-003",
+			remittance: "Top-up for account 5b7c1a2e0f3d4c1e9a7b12345 " +
+				"67890ab thank you"},
+		{
+			// The second message, without information to account owner.
+			statementAccount: "COBADEFFXXX/DE44500105175407324931",
+			time:             time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+			value:            "1234.56",
+			currency:         "USD",
+			reference:        "BK2610170001"},
+	})
+
+	// The format is detected by the content.
+	if detected, err := Parse(data); err != nil || len(detected) != len(lines) {
+		t.Errorf(`MT940 is not detected: %d lines, "%v".`, len(detected), err)
+	}
+}
+
+func TestParseMT940Invalid(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		source string
+	}{
+		{
+			name:   "line without account",
+			source: ":20:1\n:60F:C261015EUR0,00\n:61:261016C1,00NTRFREF\n"},
+		{
+			name:   "line without balance",
+			source: ":20:1\n:25:ACC\n:61:261016C1,00NTRFREF\n"},
+		{
+			name: "account of previous message",
+			source: ":20:1\n:25:ACC\n:60F:C261015EUR0,00\n" +
+				":20:2\n:61:261016C1,00NTRFREF\n"},
+		{
+			name:   "short balance",
+			source: ":20:1\n:25:ACC\n:60F:C2610\n"},
+		{
+			name:   "invalid line",
+			source: ":20:1\n:25:ACC\n:60F:C261015EUR0,00\n:61:261016X1,00NTRFREF\n"},
+		{
+			name:   "line without amount",
+			source: ":20:1\n:25:ACC\n:60F:C261015EUR0,00\n:61:261016CNTRFREF\n"},
+		{
+			name:   "invalid date",
+			source: ":20:1\n:25:ACC\n:60F:C261015EUR0,00\n:61:261332C1,00NTRFREF\n"},
+		{
+			name:   "zero amount",
+			source: ":20:1\n:25:ACC\n:60F:C261015EUR0,00\n:61:261016C0,00NTRFREF\n"},
+		{
+			name:   "amount with too many digits",
+			source: ":20:1\n:25:ACC\n:60F:C261015EUR0,00\n:61:261016C1,001NTRFREF\n"},
+		{
+			name:   "unknown currency",
+			source: ":20:1\n:25:ACC\n:60F:C261015XXX0,00\n:61:261016C1,00NTRFREF\n"},
+	} {
+		if lines, err := ParseMT940([]byte(test.source)); err == nil {
+			t.Errorf(`MT940 with %s is parsed: %d lines.`, test.name, len(lines))
+		}
+	}
+}
+
+func TestParseMT940Info(t *testing.T) {
+	for _, test := range []struct {
+		source        string
+		remittance    string
+		debtor        string
+		debtorAccount string
+	}{
+		{source: "Payment\nfor order 1", remittance: "Payment for order 1"},
+		{source: "", remittance: ""},
+		{
+			source:        "166?00GUTSCHRIFT?20REF ?21PART 2?32JOHN?33 DOE?31 DE02",
+			remittance:    "REF PART 2",
+			debtor:        "JOHN DOE",
+			debtorAccount: "DE02"},
+		{
+			source:     "166?2\n0REF?60 END?",
+			remittance: "REF END"},
+	} {
+		remittance, debtor, debtorAccount := parseMT940Info(test.source)
+		if remittance != test.remittance || debtor != test.debtor ||
+			debtorAccount != test.debtorAccount {
+			t.Errorf(`Info "%s" is parsed as "%s", "%s", "%s".`,
+				test.source, remittance, debtor, debtorAccount)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,115 @@
+package elefant
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BankLineID is a bank statement line unique ID.
+type BankLineID = uuid.UUID
+
+func newBankLineID() BankLineID { return uuid.New() }
+
+// ParseBankLineID parses bank statement line ID in string.
+func ParseBankLineID(source string) (BankLineID, error) {
+	return uuid.Parse(source)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BankLineStatus is a bank statement line status.
+type BankLineStatus int16
+
+const (
+	// BankLineStatusReview is a status of the line which is not matched with
+	// a client account and has to be reviewed by hand.
+	BankLineStatusReview BankLineStatus = 1
+	// BankLineStatusCredited is a status of the line which is credited to
+	// a client account.
+	BankLineStatusCredited BankLineStatus = 2
+)
+
+func parseBankLineStatusFromDB(source int16) (BankLineStatus, error) {
+	switch BankLineStatus(source) {
+	case BankLineStatusReview, BankLineStatusCredited:
+		return BankLineStatus(source), nil
+	default:
+		return 0, fmt.Errorf(
+			`failed to parse bank line status from DB-value "%v"`, source)
+	}
+}
+
+func (status BankLineStatus) String() string {
+	switch status {
+	case BankLineStatusReview:
+		return "review"
+	case BankLineStatusCredited:
+		return "credited"
+	default:
+		return "unknown"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BankLine describes an incoming transfer from the collection account bank
+// statement.
+type BankLine struct {
+	ID BankLineID
+	// Key identifies the line in all imported statements, so the same line
+	// is not imported twice.
+	Key string
+	// Time is the booking time in the bank.
+	Time  time.Time
+	Value Money
+	// Reference is the bank reference of the transfer.
+	Reference string
+	// Remittance is the transfer remittance information, which should have
+	// the client account reference.
+	Remittance    string
+	Debtor        string
+	DebtorAccount string
+	Status        BankLineStatus
+	// Reason describes why the line has to be reviewed.
+	Reason string
+	// Account and Trans are set only for the credited line.
+	Account *AccountID
+	Trans   *TransID
+	// ImportTime is the time when the line is imported.
+	ImportTime time.Time
+}
+
+// NewBankLine creates new bank statement line, the statement account is
+// the collection account number in the bank, it makes the line key unique
+// across several collection accounts.
+func NewBankLine(
+	statementAccount string,
+	bookingTime time.Time,
+	value Money,
+	reference string,
+	remittance string,
+	debtor string,
+	debtorAccount string) *BankLine {
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s|%s|%s|%s",
+		statementAccount, bookingTime.UTC().Format(time.RFC3339), value.GetUnits(),
+		value.GetCurrency().GetISO(), reference, remittance, debtorAccount)))
+	return &BankLine{
+		ID:            newBankLineID(),
+		Key:           hex.EncodeToString(key[:]),
+		Time:          bookingTime.UTC(),
+		Value:         value,
+		Reference:     reference,
+		Remittance:    remittance,
+		Debtor:        debtor,
+		DebtorAccount: debtorAccount,
+		Status:        BankLineStatusReview,
+		ImportTime:    time.Now().UTC()}
+}
+
+////////////////////////////////////////////////////////////////////////////////
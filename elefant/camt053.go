@@ -0,0 +1,270 @@
+package elefant
+
+import (
+	"encoding/xml"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+	camtDateFormat   = "2006-01-02"
+	camtTimeFormat   = "2006-01-02T15:04:05Z"
+)
+
+type camt053Document struct {
+	XMLName   xml.Name         `xml:"Document"`
+	Namespace string           `xml:"xmlns,attr"`
+	Statement camt053Statement `xml:"BkToCstmrStmt"`
+}
+
+type camt053Statement struct {
+	Header struct {
+		MessageID    string `xml:"MsgId"`
+		CreationTime string `xml:"CreDtTm"`
+	} `xml:"GrpHdr"`
+	Statement struct {
+		ID           string `xml:"Id"`
+		CreationTime string `xml:"CreDtTm"`
+		Period       struct {
+			From string `xml:"FrDtTm"`
+			To   string `xml:"ToDtTm"`
+		} `xml:"FrToDt"`
+		Account struct {
+			ID       camtAccountID `xml:"Id"`
+			Currency string        `xml:"Ccy"`
+			Owner    camtParty     `xml:"Ownr"`
+		} `xml:"Acct"`
+		Balances []*camtBalance `xml:"Bal"`
+		Summary  struct {
+			Entries struct {
+				Number int `xml:"NbOfNtries"`
+			} `xml:"TtlNtries"`
+		} `xml:"TxsSummry"`
+		Entries []*camtEntry `xml:"Ntry"`
+	} `xml:"Stmt"`
+}
+
+// newCamtID returns the ID without hyphens, as IDs and references are up to
+// 35 symbols (account IDs - up to 34) and UUID has 36 symbols.
+func newCamtID(id uuid.UUID) string {
+	return strings.Replace(id.String(), "-", "", -1)
+}
+
+type camtAccountID struct {
+	ID string `xml:"Othr>Id"`
+}
+
+type camtParty struct {
+	Name string `xml:"Nm"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+func newCamtAmount(value Money) camtAmount {
+	return camtAmount{
+		Currency: value.GetCurrency().GetISO(),
+		Value:    value.Abs().String()}
+}
+
+// newCamtCreditDebit returns credit-debit indicator for the value sign, zero
+// value is credit.
+func newCamtCreditDebit(value Money) string {
+	if value.IsNegative() {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+// camtExchangeRateDigits is the max number of digits in the exchange rate.
+const camtExchangeRateDigits = 11
+
+// newCamtExchangeRate returns the positive rate with up to
+// exchangeRatePrecision fractional digits, which are cut if the rate has too
+// many digits.
+func newCamtExchangeRate(rate *big.Rat) string {
+	precision := camtExchangeRateDigits -
+		len(new(big.Int).Quo(rate.Num(), rate.Denom()).String())
+	if precision > exchangeRatePrecision {
+		precision = exchangeRatePrecision
+	} else if precision < 0 {
+		precision = 0
+	}
+	result := rate.FloatString(precision)
+	if strings.Contains(result, ".") {
+		result = strings.TrimRight(strings.TrimRight(result, "0"), ".")
+	}
+	return result
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+func newCamtBalance(
+	balanceType string, value Money, date time.Time) *camtBalance {
+	return &camtBalance{
+		Type:      balanceType,
+		Amount:    newCamtAmount(value),
+		Indicator: newCamtCreditDebit(value),
+		Date:      date.Format(camtDateFormat)}
+}
+
+type camtEntry struct {
+	Reference   string     `xml:"NtryRef"`
+	Amount      camtAmount `xml:"Amt"`
+	Indicator   string     `xml:"CdtDbtInd"`
+	Status      string     `xml:"Sts"`
+	BookingTime string     `xml:"BookgDt>DtTm"`
+	ValueTime   string     `xml:"ValDt>DtTm"`
+	ServicerRef string     `xml:"AcctSvcrRef"`
+	Code        string     `xml:"BkTxCd>Prtry>Cd"`
+	Details     struct {
+		Refs struct {
+			ServicerRef string `xml:"AcctSvcrRef"`
+		} `xml:"Refs"`
+		Amounts    *camtAmountDetails `xml:"AmtDtls,omitempty"`
+		Parties    *camtParties       `xml:"RltdPties,omitempty"`
+		Remittance *camtRemittance    `xml:"RmtInf,omitempty"`
+		Notes      string             `xml:"AddtlTxInf,omitempty"`
+	} `xml:"NtryDtls>TxDtls"`
+	Info string `xml:"AddtlNtryInf"`
+}
+
+type camtAmountDetails struct {
+	CounterValue struct {
+		Amount   camtAmount `xml:"Amt"`
+		Exchange struct {
+			Source string `xml:"SrcCcy"`
+			Target string `xml:"TrgtCcy"`
+			Rate   string `xml:"XchgRate"`
+		} `xml:"CcyXchg"`
+	} `xml:"CntrValAmt"`
+}
+
+// camtRemittance has a tax bill, a hold description or a bank transfer
+// reference.
+type camtRemittance struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+type camtParties struct {
+	Debtor          *camtParty     `xml:"Dbtr,omitempty"`
+	DebtorAccount   *camtAccountID `xml:"DbtrAcct>Id,omitempty"`
+	Creditor        *camtParty     `xml:"Cdtr,omitempty"`
+	CreditorAccount *camtAccountID `xml:"CdtrAcct>Id,omitempty"`
+}
+
+func newCamtEntry(trans *Trans) *camtEntry {
+	result := &camtEntry{
+		Reference:   newCamtID(trans.ID),
+		Amount:      newCamtAmount(trans.Value),
+		Indicator:   newCamtCreditDebit(trans.Value),
+		Status:      "BOOK",
+		BookingTime: trans.Time.UTC().Format(camtTimeFormat),
+		ValueTime:   trans.Time.UTC().Format(camtTimeFormat),
+		ServicerRef: newCamtID(trans.ID),
+		Code:        trans.Method.GetTypeName(),
+		Info:        trans.Method.GetName()}
+	result.Details.Refs.ServicerRef = result.ServicerRef
+
+	if trans.Exchange != nil {
+		details := &camtAmountDetails{}
+		details.CounterValue.Amount = newCamtAmount(trans.Exchange.CounterValue)
+		details.CounterValue.Exchange.Source =
+			trans.Value.GetCurrency().GetISO()
+		details.CounterValue.Exchange.Target =
+			trans.Exchange.CounterValue.GetCurrency().GetISO()
+		details.CounterValue.Exchange.Rate =
+			newCamtExchangeRate(trans.Exchange.Rate)
+		result.Details.Amounts = details
+	}
+
+	// The counterparty is the debtor for the income and the creditor for
+	// the outcome, bank card number is presented only by the masked name.
+	counterparty := &camtParty{Name: trans.Method.GetName()}
+	var counterpartyAccount *camtAccountID
+	switch method := trans.Method.(type) {
+	case AccountMethod:
+		counterparty.Name = method.GetEmail()
+		counterpartyAccount = &camtAccountID{ID: newCamtID(method.GetAccountID())}
+	case TaxMethod:
+		if name := method.GetAuthorityName(); name != "" {
+			counterparty.Name = name
+		}
+		result.Details.Remittance = &camtRemittance{
+			Unstructured: method.GetBill()}
+	case HoldMethod:
+		result.Details.Remittance = &camtRemittance{
+			Unstructured: method.GetName()}
+	case BankTransferMethod:
+		if debtor := method.GetDebtor(); debtor != "" {
+			counterparty.Name = debtor
+		}
+		result.Details.Remittance = &camtRemittance{
+			Unstructured: method.GetReference()}
+	}
+	if trans.Value.IsNegative() {
+		result.Details.Parties = &camtParties{
+			Creditor:        counterparty,
+			CreditorAccount: counterpartyAccount}
+	} else {
+		result.Details.Parties = &camtParties{
+			Debtor:        counterparty,
+			DebtorAccount: counterpartyAccount}
+	}
+
+	if trans.StatusReason != nil {
+		result.Details.Notes = *trans.StatusReason
+	}
+	return result
+}
+
+// MarshalCamt053 returns the statement in ISO 20022 camt.053 (bank to customer
+// statement) XML format.
+func (statement *Statement) MarshalCamt053() ([]byte, error) {
+	document := camt053Document{Namespace: camt053Namespace}
+	result := &document.Statement
+
+	result.Header.MessageID = newCamtID(statement.ID)
+	result.Header.CreationTime = statement.Time.Format(camtTimeFormat)
+
+	stmt := &result.Statement
+	stmt.ID = result.Header.MessageID
+	stmt.CreationTime = result.Header.CreationTime
+	stmt.Period.From = statement.From.UTC().Format(camtTimeFormat)
+	stmt.Period.To = statement.GetLastDay().UTC().Format(camtTimeFormat)
+	stmt.Account.ID.ID = newCamtID(statement.Account.GetID())
+	stmt.Account.Currency = statement.Account.GetCurrency().GetISO()
+	stmt.Account.Owner.Name = statement.Owner.GetName()
+	if stmt.Account.Owner.Name == "" {
+		stmt.Account.Owner.Name = statement.Owner.GetEmail()
+	}
+	stmt.Balances = []*camtBalance{
+		newCamtBalance("OPBD", statement.Opening, statement.From),
+		newCamtBalance("CLBD", statement.Closing, statement.GetLastDay())}
+	stmt.Summary.Entries.Number = len(statement.Trans)
+	stmt.Entries = make([]*camtEntry, len(statement.Trans))
+	for i, trans := range statement.Trans {
+		stmt.Entries[i] = newCamtEntry(trans)
+	}
+
+	body, err := xml.MarshalIndent(&document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
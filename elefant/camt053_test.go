@@ -0,0 +1,369 @@
+package elefant
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// camt053Element describes the element of the camt.053.001.02 schema which
+// is rendered by the statement: the children in the schema sequence order
+// and the required children.
+type camt053Element struct {
+	Children []string
+	Required []string
+}
+
+// camt053Schema has camt.053.001.02 elements by the path from Document,
+// the element which is not in the schema has to be a leaf.
+var camt053Schema = map[string]camt053Element{
+	"Document": {
+		Children: []string{"BkToCstmrStmt"},
+		Required: []string{"BkToCstmrStmt"}},
+	"Document/BkToCstmrStmt": {
+		Children: []string{"GrpHdr", "Stmt", "SplmtryData"},
+		Required: []string{"GrpHdr", "Stmt"}},
+	"Document/BkToCstmrStmt/GrpHdr": {
+		Children: []string{
+			"MsgId", "CreDtTm", "MsgRcpt", "MsgPgntn", "AddtlInf"},
+		Required: []string{"MsgId", "CreDtTm"}},
+	"Document/BkToCstmrStmt/Stmt": {
+		Children: []string{
+			"Id", "ElctrncSeqNb", "LglSeqNb", "CreDtTm", "FrToDt",
+			"CpyDplctInd", "RptgSrc", "Acct", "RltdAcct", "Intrst", "Bal",
+			"TxsSummry", "Ntry", "AddtlStmtInf"},
+		Required: []string{"Id", "CreDtTm", "Acct", "Bal"}},
+	"Document/BkToCstmrStmt/Stmt/FrToDt": {
+		Children: []string{"FrDtTm", "ToDtTm"},
+		Required: []string{"FrDtTm", "ToDtTm"}},
+	"Document/BkToCstmrStmt/Stmt/Acct": {
+		Children: []string{"Id", "Tp", "Ccy", "Nm", "Ownr", "Svcr"},
+		Required: []string{"Id"}},
+	"Document/BkToCstmrStmt/Stmt/Acct/Id": {
+		Children: []string{"IBAN", "Othr"}},
+	"Document/BkToCstmrStmt/Stmt/Acct/Id/Othr": {
+		Children: []string{"Id", "SchmeNm", "Issr"},
+		Required: []string{"Id"}},
+	"Document/BkToCstmrStmt/Stmt/Acct/Ownr": {
+		Children: []string{"Nm", "PstlAdr", "Id", "CtryOfRes", "CtctDtls"}},
+	"Document/BkToCstmrStmt/Stmt/Bal": {
+		Children: []string{"Tp", "CdtLine", "Amt", "CdtDbtInd", "Dt", "Avlbty"},
+		Required: []string{"Tp", "Amt", "CdtDbtInd", "Dt"}},
+	"Document/BkToCstmrStmt/Stmt/Bal/Tp": {
+		Children: []string{"CdOrPrtry", "SubTp"},
+		Required: []string{"CdOrPrtry"}},
+	"Document/BkToCstmrStmt/Stmt/Bal/Tp/CdOrPrtry": {
+		Children: []string{"Cd", "Prtry"}},
+	"Document/BkToCstmrStmt/Stmt/Bal/Dt": {
+		Children: []string{"Dt", "DtTm"}},
+	"Document/BkToCstmrStmt/Stmt/TxsSummry": {
+		Children: []string{
+			"TtlNtries", "TtlCdtNtries", "TtlDbtNtries", "TtlNtriesPerBkTxCd"}},
+	"Document/BkToCstmrStmt/Stmt/TxsSummry/TtlNtries": {
+		Children: []string{"NbOfNtries", "Sum", "TtlNetNtryAmt", "CdtDbtInd"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry": {
+		Children: []string{
+			"NtryRef", "Amt", "CdtDbtInd", "RvslInd", "Sts", "BookgDt", "ValDt",
+			"AcctSvcrRef", "Avlbty", "BkTxCd", "ComssnWvrInd", "AddtlInfInd",
+			"AmtDtls", "Chrgs", "TechInptChanl", "Intrst", "NtryDtls",
+			"AddtlNtryInf"},
+		Required: []string{"Amt", "CdtDbtInd", "Sts", "BkTxCd"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/BookgDt": {
+		Children: []string{"Dt", "DtTm"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/ValDt": {
+		Children: []string{"Dt", "DtTm"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/BkTxCd": {
+		Children: []string{"Domn", "Prtry"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/BkTxCd/Prtry": {
+		Children: []string{"Cd", "Issr"},
+		Required: []string{"Cd"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls": {
+		Children: []string{"Btch", "TxDtls"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls": {
+		Children: []string{
+			"Refs", "AmtDtls", "Avlbty", "BkTxCd", "Chrgs", "Intrst", "RltdPties",
+			"RltdAgts", "Purp", "RltdRmtInf", "RmtInf", "RltdDts", "RltdPric",
+			"RltdQties", "FinInstrmId", "Tax", "RtrInf", "CorpActn", "SfkpgAcct",
+			"CshDpst", "CardTx", "AddtlTxInf", "SplmtryData"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/Refs": {
+		Children: []string{
+			"MsgId", "AcctSvcrRef", "PmtInfId", "InstrId", "EndToEndId", "TxId",
+			"MndtId", "ChqNb", "ClrSysRef", "Prtry"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/AmtDtls": {
+		Children: []string{
+			"InstdAmt", "TxAmt", "CntrValAmt", "AnncdPstngAmt", "PrtryAmt"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/AmtDtls/CntrValAmt": {
+		Children: []string{"Amt", "CcyXchg"},
+		Required: []string{"Amt"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/AmtDtls/CntrValAmt/" +
+		"CcyXchg": {
+		Children: []string{
+			"SrcCcy", "TrgtCcy", "UnitCcy", "XchgRate", "CtrctId", "QtnDt"},
+		Required: []string{"SrcCcy", "XchgRate"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties": {
+		Children: []string{
+			"InitgPty", "Dbtr", "DbtrAcct", "UltmtDbtr", "Cdtr", "CdtrAcct",
+			"UltmtCdtr", "TradgPty", "Prtry"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/Dbtr": {
+		Children: []string{"Nm", "PstlAdr", "Id", "CtryOfRes", "CtctDtls"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/DbtrAcct": {
+		Children: []string{"Id", "Tp", "Ccy", "Nm"},
+		Required: []string{"Id"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/DbtrAcct/" +
+		"Id": {
+		Children: []string{"IBAN", "Othr"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/DbtrAcct/" +
+		"Id/Othr": {
+		Children: []string{"Id", "SchmeNm", "Issr"},
+		Required: []string{"Id"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/Cdtr": {
+		Children: []string{"Nm", "PstlAdr", "Id", "CtryOfRes", "CtctDtls"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/CdtrAcct": {
+		Children: []string{"Id", "Tp", "Ccy", "Nm"},
+		Required: []string{"Id"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/CdtrAcct/" +
+		"Id": {
+		Children: []string{"IBAN", "Othr"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RltdPties/CdtrAcct/" +
+		"Id/Othr": {
+		Children: []string{"Id", "SchmeNm", "Issr"},
+		Required: []string{"Id"}},
+	"Document/BkToCstmrStmt/Stmt/Ntry/NtryDtls/TxDtls/RmtInf": {
+		Children: []string{"Ustrd", "Strd"}},
+}
+
+// camt053Formats has the leaf value formats by the leaf name, the same names
+// have the same types in the rendered elements.
+var camt053Formats = map[string]*regexp.Regexp{
+	"MsgId":        regexp.MustCompile(`^.{1,35}$`),
+	"Id":           regexp.MustCompile(`^.{1,35}$`),
+	"NtryRef":      regexp.MustCompile(`^.{1,35}$`),
+	"AcctSvcrRef":  regexp.MustCompile(`^.{1,35}$`),
+	"Cd":           regexp.MustCompile(`^.{1,35}$`),
+	"Nm":           regexp.MustCompile(`^.{1,140}$`),
+	"Ustrd":        regexp.MustCompile(`^.{1,140}$`),
+	"AddtlTxInf":   regexp.MustCompile(`^.{1,500}$`),
+	"AddtlNtryInf": regexp.MustCompile(`^.{1,500}$`),
+	"CreDtTm": regexp.MustCompile(
+		`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`),
+	"FrDtTm":  regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`),
+	"ToDtTm":  regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`),
+	"DtTm":    regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`),
+	"Dt":      regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`),
+	"Ccy":     regexp.MustCompile(`^[A-Z]{3}$`),
+	"SrcCcy":  regexp.MustCompile(`^[A-Z]{3}$`),
+	"TrgtCcy": regexp.MustCompile(`^[A-Z]{3}$`),
+	// Amounts have up to 18 digits with up to 5 fractional digits, the rate
+	// has up to 10 fractional digits, its total digits are checked by
+	// TestNewCamtExchangeRate.
+	"Amt":        regexp.MustCompile(`^\d{1,13}(\.\d{1,5})?$`),
+	"XchgRate":   regexp.MustCompile(`^\d{1,11}(\.\d{1,10})?$`),
+	"CdtDbtInd":  regexp.MustCompile(`^(CRDT|DBIT)$`),
+	"Sts":        regexp.MustCompile(`^(BOOK|PDNG|INFO)$`),
+	"NbOfNtries": regexp.MustCompile(`^\d{1,15}$`),
+}
+
+// camt053Node is the parsed XML element.
+type camt053Node struct {
+	Name       xml.Name
+	Attributes []xml.Attr
+	Text       string
+	Children   []*camt053Node
+}
+
+func parseCamt053Node(t *testing.T, source []byte) *camt053Node {
+	decoder := xml.NewDecoder(bytes.NewReader(source))
+	var root *camt053Node
+	stack := []*camt053Node{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf(`Failed to parse XML: "%v".`, err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &camt053Node{Name: token.Name, Attributes: token.Attr}
+			if len(stack) == 0 {
+				if root != nil {
+					t.Fatalf(`XML has several roots.`)
+				}
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(token)
+			}
+		}
+	}
+	if root == nil {
+		t.Fatalf(`XML doesn't have root.`)
+	}
+	return root
+}
+
+// checkCamt053Node checks the element children by the schema, or the leaf
+// value format if the element is a leaf.
+func checkCamt053Node(t *testing.T, path string, node *camt053Node) {
+	if node.Name.Space != camt053Namespace {
+		t.Errorf(`Element "%s" has namespace "%s".`, path, node.Name.Space)
+	}
+
+	element, has := camt053Schema[path]
+	if !has {
+		if len(node.Children) != 0 {
+			t.Errorf(`Element "%s" is not in the schema or has to be a leaf.`,
+				path)
+			return
+		}
+		text := strings.TrimSpace(node.Text)
+		if format, has := camt053Formats[node.Name.Local]; has &&
+			!format.MatchString(text) {
+			t.Errorf(`Element "%s" has invalid value "%s".`, path, text)
+		}
+		return
+	}
+
+	order := map[string]int{}
+	for i, name := range element.Children {
+		order[name] = i
+	}
+	names := map[string]bool{}
+	prev := -1
+	for _, child := range node.Children {
+		name := child.Name.Local
+		index, has := order[name]
+		if !has {
+			t.Errorf(`Element "%s" could not have child "%s".`, path, name)
+			continue
+		}
+		if index < prev {
+			t.Errorf(`Element "%s" has child "%s" out of the sequence order.`,
+				path, name)
+		}
+		prev = index
+		names[name] = true
+		checkCamt053Node(t, path+"/"+name, child)
+	}
+	for _, name := range element.Required {
+		if !names[name] {
+			t.Errorf(`Element "%s" doesn't have required child "%s".`, path, name)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func newCamt053TestStatement(t *testing.T) *Statement {
+	eur, err := NewCurrency("EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	czk, err := NewCurrency("CZK")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientID := newClientID()
+	acc := newAccount(newAccountID(), clientID, eur, NewMoney(123456, eur),
+		NewMoney(0, eur), NewMoney(0, eur), 10, AccountStatusActive)
+	owner := newClient(clientID, "owner@example.com", "Account Owner")
+	from := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := from.Add(36 * time.Hour)
+
+	authority := &TaxAuthority{ID: uuid.New(), Name: "Tax Office"}
+	reason := "Payment notes"
+	return NewStatement(acc, owner, from, from.AddDate(0, 1, 0),
+		NewMoney(-1500, eur), NewMoney(123456, eur),
+		[]*Trans{
+			newTrans(newTransID(), NewMoney(-2000, eur), at,
+				newAccountMethod(newMethodID(), clientID, eur, newAccountID(),
+					newAccountMethodArg("receiver@example.com")),
+				acc, TransStatusSuccess, &reason,
+				NewTransExchange(big.NewRat(251234567891, 10000000000),
+					NewMoney(50247, czk))),
+			newTrans(newTransID(), NewMoney(5000, eur), at,
+				newAccountMethod(newMethodID(), clientID, eur, newAccountID(),
+					newAccountMethodArg("payer@example.com")),
+				acc, TransStatusSuccess, nil, nil),
+			newTrans(newTransID(), NewMoney(-1000, eur), at,
+				newTaxMethod(newMethodID(), clientID, eur,
+					newTaxMethodInfo(authority), newTaxMethodArg("RF18539007547034")),
+				acc, TransStatusSuccess, nil, nil),
+			newTrans(newTransID(), NewMoney(120000, eur), at,
+				newBankTransferMethod(newMethodID(), clientID, eur,
+					bankTransferMethodArg{
+						Reference: "BANK-REF-1",
+						Debtor:    "Bank Debtor"}),
+				acc, TransStatusSuccess, nil, nil),
+			newTrans(newTransID(), NewMoney(-3, eur), at,
+				newFeeMethod(newMethodID(), clientID, eur),
+				acc, TransStatusSuccess, nil, nil),
+		})
+}
+
+func TestStatementMarshalCamt053(t *testing.T) {
+	statement := newCamt053TestStatement(t)
+	result, err := statement.MarshalCamt053()
+	if err != nil {
+		t.Fatalf(`Failed to marshal statement: "%v".`, err)
+	}
+	if !bytes.HasPrefix(result, []byte(xml.Header)) {
+		t.Errorf(`Statement doesn't have XML header.`)
+	}
+
+	root := parseCamt053Node(t, result)
+	if root.Name.Local != "Document" {
+		t.Fatalf(`Root element is "%s".`, root.Name.Local)
+	}
+	checkCamt053Node(t, "Document", root)
+
+	stmt := root.Children[0].Children[1]
+	entries := 0
+	for _, child := range stmt.Children {
+		if child.Name.Local == "Ntry" {
+			entries++
+		}
+	}
+	if entries != len(statement.Trans) {
+		t.Errorf(`Statement has %d entries, but %d transactions.`,
+			entries, len(statement.Trans))
+	}
+}
+
+func TestNewCamtExchangeRate(t *testing.T) {
+	for _, test := range []struct {
+		rate     *big.Rat
+		expected string
+	}{
+		{rate: big.NewRat(11234567891, 10000000000), expected: "1.1234567891"},
+		{rate: big.NewRat(251234567891, 10000000000), expected: "25.123456789"},
+		{rate: big.NewRat(3, 2), expected: "1.5"},
+		{rate: big.NewRat(120, 1), expected: "120"},
+		{rate: big.NewRat(1, 3), expected: "0.3333333333"},
+	} {
+		if result := newCamtExchangeRate(test.rate); result != test.expected {
+			t.Errorf(`Rate %s is rendered as "%s", but expected "%s".`,
+				test.rate, result, test.expected)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -1,11 +1,244 @@
 package elefant
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Currency describes currency interface.
-type Currency interface{ GetISO() string }
+type Currency interface {
+	// GetISO returns ISO 4217 alphabetic code.
+	GetISO() string
+	// GetNumericCode returns ISO 4217 numeric code (3 digits with leading
+	// zeros).
+	GetNumericCode() string
+	// GetMinorUnits returns number of digits after the decimal separator.
+	GetMinorUnits() int
+	// GetSymbol returns currency display symbol.
+	GetSymbol() string
+}
+
+// NewCurrency returns currency from the registry by ISO 4217 alphabetic code.
+// Returns error if the currency is unknown.
+func NewCurrency(iso string) (Currency, error) {
+	result, has := currencies[strings.ToUpper(strings.TrimSpace(iso))]
+	if !has {
+		return nil, fmt.Errorf(`currency "%s" is unknown`, iso)
+	}
+	return result, nil
+}
+
+// newCurrencyFromDB returns currency from the registry, including withdrawn
+// currencies of the accounts which are opened before the withdrawal.
+func newCurrencyFromDB(iso string) (Currency, error) {
+	if result, has := withdrawnCurrencies[strings.TrimSpace(iso)]; has {
+		return result, nil
+	}
+	result, err := NewCurrency(iso)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read currency from DB-value: "%v"`, err)
+	}
+	return result, nil
+}
+
+type currency struct {
+	iso        string
+	numeric    string
+	minorUnits int
+	symbol     string
+}
+
+func (currency *currency) GetISO() string         { return currency.iso }
+func (currency *currency) GetNumericCode() string { return currency.numeric }
+func (currency *currency) GetMinorUnits() int     { return currency.minorUnits }
+func (currency *currency) GetSymbol() string {
+	if currency.symbol == "" {
+		// Most currencies don't have a widely known symbol.
+		return currency.iso
+	}
+	return currency.symbol
+}
 
-// NewCurrency creates new currency instance with given ISO code.
-func NewCurrency(iso string) Currency { return &currency{iso: iso} }
+// currencies are ISO 4217 active currencies, which have minor units, so
+// precious metals, SDR and testing codes are not supported.
+var currencies = newCurrencyRegistry([]*currency{
+	{iso: "AED", numeric: "784", minorUnits: 2},
+	{iso: "AFN", numeric: "971", minorUnits: 2},
+	{iso: "ALL", numeric: "008", minorUnits: 2},
+	{iso: "AMD", numeric: "051", minorUnits: 2},
+	{iso: "AOA", numeric: "973", minorUnits: 2},
+	{iso: "ARS", numeric: "032", minorUnits: 2},
+	{iso: "AUD", numeric: "036", minorUnits: 2, symbol: "A$"},
+	{iso: "AWG", numeric: "533", minorUnits: 2},
+	{iso: "AZN", numeric: "944", minorUnits: 2, symbol: "₼"},
+	{iso: "BAM", numeric: "977", minorUnits: 2, symbol: "KM"},
+	{iso: "BBD", numeric: "052", minorUnits: 2},
+	{iso: "BDT", numeric: "050", minorUnits: 2, symbol: "৳"},
+	{iso: "BHD", numeric: "048", minorUnits: 3, symbol: "BD"},
+	{iso: "BIF", numeric: "108", minorUnits: 0},
+	{iso: "BMD", numeric: "060", minorUnits: 2},
+	{iso: "BND", numeric: "096", minorUnits: 2},
+	{iso: "BOB", numeric: "068", minorUnits: 2, symbol: "Bs"},
+	{iso: "BOV", numeric: "984", minorUnits: 2},
+	{iso: "BRL", numeric: "986", minorUnits: 2, symbol: "R$"},
+	{iso: "BSD", numeric: "044", minorUnits: 2},
+	{iso: "BTN", numeric: "064", minorUnits: 2},
+	{iso: "BWP", numeric: "072", minorUnits: 2},
+	{iso: "BYN", numeric: "933", minorUnits: 2, symbol: "Br"},
+	{iso: "BZD", numeric: "084", minorUnits: 2},
+	{iso: "CAD", numeric: "124", minorUnits: 2, symbol: "C$"},
+	{iso: "CDF", numeric: "976", minorUnits: 2},
+	{iso: "CHE", numeric: "947", minorUnits: 2},
+	{iso: "CHF", numeric: "756", minorUnits: 2, symbol: "Fr."},
+	{iso: "CHW", numeric: "948", minorUnits: 2},
+	{iso: "CLF", numeric: "990", minorUnits: 4},
+	{iso: "CLP", numeric: "152", minorUnits: 0},
+	{iso: "CNY", numeric: "156", minorUnits: 2, symbol: "¥"},
+	{iso: "COP", numeric: "170", minorUnits: 2},
+	{iso: "COU", numeric: "970", minorUnits: 2},
+	{iso: "CRC", numeric: "188", minorUnits: 2, symbol: "₡"},
+	{iso: "CUP", numeric: "192", minorUnits: 2},
+	{iso: "CVE", numeric: "132", minorUnits: 2},
+	{iso: "CZK", numeric: "203", minorUnits: 2, symbol: "Kč"},
+	{iso: "DJF", numeric: "262", minorUnits: 0},
+	{iso: "DKK", numeric: "208", minorUnits: 2, symbol: "kr."},
+	{iso: "DOP", numeric: "214", minorUnits: 2},
+	{iso: "DZD", numeric: "012", minorUnits: 2},
+	{iso: "EGP", numeric: "818", minorUnits: 2},
+	{iso: "ERN", numeric: "232", minorUnits: 2},
+	{iso: "ETB", numeric: "230", minorUnits: 2},
+	{iso: "EUR", numeric: "978", minorUnits: 2, symbol: "€"},
+	{iso: "FJD", numeric: "242", minorUnits: 2},
+	{iso: "FKP", numeric: "238", minorUnits: 2},
+	{iso: "GBP", numeric: "826", minorUnits: 2, symbol: "£"},
+	{iso: "GEL", numeric: "981", minorUnits: 2, symbol: "₾"},
+	{iso: "GHS", numeric: "936", minorUnits: 2, symbol: "₵"},
+	{iso: "GIP", numeric: "292", minorUnits: 2},
+	{iso: "GMD", numeric: "270", minorUnits: 2},
+	{iso: "GNF", numeric: "324", minorUnits: 0},
+	{iso: "GTQ", numeric: "320", minorUnits: 2},
+	{iso: "GYD", numeric: "328", minorUnits: 2},
+	{iso: "HKD", numeric: "344", minorUnits: 2, symbol: "HK$"},
+	{iso: "HNL", numeric: "340", minorUnits: 2},
+	{iso: "HTG", numeric: "332", minorUnits: 2},
+	{iso: "HUF", numeric: "348", minorUnits: 2, symbol: "Ft"},
+	{iso: "IDR", numeric: "360", minorUnits: 2, symbol: "Rp"},
+	{iso: "ILS", numeric: "376", minorUnits: 2, symbol: "₪"},
+	{iso: "INR", numeric: "356", minorUnits: 2, symbol: "₹"},
+	{iso: "IQD", numeric: "368", minorUnits: 3},
+	{iso: "IRR", numeric: "364", minorUnits: 2},
+	{iso: "ISK", numeric: "352", minorUnits: 0, symbol: "kr"},
+	{iso: "JMD", numeric: "388", minorUnits: 2},
+	{iso: "JOD", numeric: "400", minorUnits: 3},
+	{iso: "JPY", numeric: "392", minorUnits: 0, symbol: "¥"},
+	{iso: "KES", numeric: "404", minorUnits: 2},
+	{iso: "KGS", numeric: "417", minorUnits: 2},
+	{iso: "KHR", numeric: "116", minorUnits: 2},
+	{iso: "KMF", numeric: "174", minorUnits: 0},
+	{iso: "KPW", numeric: "408", minorUnits: 2},
+	{iso: "KRW", numeric: "410", minorUnits: 0, symbol: "₩"},
+	{iso: "KWD", numeric: "414", minorUnits: 3, symbol: "KD"},
+	{iso: "KYD", numeric: "136", minorUnits: 2},
+	{iso: "KZT", numeric: "398", minorUnits: 2, symbol: "₸"},
+	{iso: "LAK", numeric: "418", minorUnits: 2, symbol: "₭"},
+	{iso: "LBP", numeric: "422", minorUnits: 2},
+	{iso: "LKR", numeric: "144", minorUnits: 2},
+	{iso: "LRD", numeric: "430", minorUnits: 2},
+	{iso: "LSL", numeric: "426", minorUnits: 2},
+	{iso: "LYD", numeric: "434", minorUnits: 3},
+	{iso: "MAD", numeric: "504", minorUnits: 2},
+	{iso: "MDL", numeric: "498", minorUnits: 2},
+	{iso: "MGA", numeric: "969", minorUnits: 2},
+	{iso: "MKD", numeric: "807", minorUnits: 2},
+	{iso: "MMK", numeric: "104", minorUnits: 2},
+	{iso: "MNT", numeric: "496", minorUnits: 2, symbol: "₮"},
+	{iso: "MOP", numeric: "446", minorUnits: 2},
+	{iso: "MRU", numeric: "929", minorUnits: 2},
+	{iso: "MUR", numeric: "480", minorUnits: 2},
+	{iso: "MVR", numeric: "462", minorUnits: 2},
+	{iso: "MWK", numeric: "454", minorUnits: 2},
+	{iso: "MXN", numeric: "484", minorUnits: 2, symbol: "Mex$"},
+	{iso: "MXV", numeric: "979", minorUnits: 2},
+	{iso: "MYR", numeric: "458", minorUnits: 2, symbol: "RM"},
+	{iso: "MZN", numeric: "943", minorUnits: 2},
+	{iso: "NAD", numeric: "516", minorUnits: 2},
+	{iso: "NGN", numeric: "566", minorUnits: 2, symbol: "₦"},
+	{iso: "NIO", numeric: "558", minorUnits: 2},
+	{iso: "NOK", numeric: "578", minorUnits: 2, symbol: "kr"},
+	{iso: "NPR", numeric: "524", minorUnits: 2},
+	{iso: "NZD", numeric: "554", minorUnits: 2, symbol: "NZ$"},
+	{iso: "OMR", numeric: "512", minorUnits: 3},
+	{iso: "PAB", numeric: "590", minorUnits: 2},
+	{iso: "PEN", numeric: "604", minorUnits: 2},
+	{iso: "PGK", numeric: "598", minorUnits: 2},
+	{iso: "PHP", numeric: "608", minorUnits: 2, symbol: "₱"},
+	{iso: "PKR", numeric: "586", minorUnits: 2},
+	{iso: "PLN", numeric: "985", minorUnits: 2, symbol: "zł"},
+	{iso: "PYG", numeric: "600", minorUnits: 0, symbol: "₲"},
+	{iso: "QAR", numeric: "634", minorUnits: 2},
+	{iso: "RON", numeric: "946", minorUnits: 2, symbol: "lei"},
+	{iso: "RSD", numeric: "941", minorUnits: 2},
+	{iso: "RUB", numeric: "643", minorUnits: 2, symbol: "₽"},
+	{iso: "RWF", numeric: "646", minorUnits: 0},
+	{iso: "SAR", numeric: "682", minorUnits: 2},
+	{iso: "SBD", numeric: "090", minorUnits: 2},
+	{iso: "SCR", numeric: "690", minorUnits: 2},
+	{iso: "SDG", numeric: "938", minorUnits: 2},
+	{iso: "SEK", numeric: "752", minorUnits: 2, symbol: "kr"},
+	{iso: "SGD", numeric: "702", minorUnits: 2, symbol: "S$"},
+	{iso: "SHP", numeric: "654", minorUnits: 2},
+	{iso: "SLE", numeric: "925", minorUnits: 2},
+	{iso: "SOS", numeric: "706", minorUnits: 2},
+	{iso: "SRD", numeric: "968", minorUnits: 2},
+	{iso: "SSP", numeric: "728", minorUnits: 2},
+	{iso: "STN", numeric: "930", minorUnits: 2},
+	{iso: "SVC", numeric: "222", minorUnits: 2},
+	{iso: "SYP", numeric: "760", minorUnits: 2},
+	{iso: "SZL", numeric: "748", minorUnits: 2},
+	{iso: "THB", numeric: "764", minorUnits: 2, symbol: "฿"},
+	{iso: "TJS", numeric: "972", minorUnits: 2},
+	{iso: "TMT", numeric: "934", minorUnits: 2},
+	{iso: "TND", numeric: "788", minorUnits: 3},
+	{iso: "TOP", numeric: "776", minorUnits: 2},
+	{iso: "TRY", numeric: "949", minorUnits: 2, symbol: "₺"},
+	{iso: "TTD", numeric: "780", minorUnits: 2},
+	{iso: "TWD", numeric: "901", minorUnits: 2, symbol: "NT$"},
+	{iso: "TZS", numeric: "834", minorUnits: 2},
+	{iso: "UAH", numeric: "980", minorUnits: 2, symbol: "₴"},
+	{iso: "UGX", numeric: "800", minorUnits: 0},
+	{iso: "USD", numeric: "840", minorUnits: 2, symbol: "$"},
+	{iso: "USN", numeric: "997", minorUnits: 2},
+	{iso: "UYI", numeric: "940", minorUnits: 0},
+	{iso: "UYU", numeric: "858", minorUnits: 2},
+	{iso: "UYW", numeric: "927", minorUnits: 4},
+	{iso: "UZS", numeric: "860", minorUnits: 2},
+	{iso: "VED", numeric: "926", minorUnits: 2},
+	{iso: "VES", numeric: "928", minorUnits: 2},
+	{iso: "VND", numeric: "704", minorUnits: 0, symbol: "₫"},
+	{iso: "VUV", numeric: "548", minorUnits: 0},
+	{iso: "WST", numeric: "882", minorUnits: 2},
+	{iso: "XAF", numeric: "950", minorUnits: 0},
+	{iso: "XCD", numeric: "951", minorUnits: 2},
+	{iso: "XCG", numeric: "532", minorUnits: 2},
+	{iso: "XOF", numeric: "952", minorUnits: 0},
+	{iso: "XPF", numeric: "953", minorUnits: 0},
+	{iso: "YER", numeric: "886", minorUnits: 2},
+	{iso: "ZAR", numeric: "710", minorUnits: 2, symbol: "R"},
+	{iso: "ZMW", numeric: "967", minorUnits: 2},
+	{iso: "ZWG", numeric: "924", minorUnits: 2},
+})
 
-type currency struct{ iso string }
+// withdrawnCurrencies are not accepted for new accounts and payments, but
+// accounts which are opened before the withdrawal still have to be read.
+var withdrawnCurrencies = newCurrencyRegistry([]*currency{
+	{iso: "BGN", numeric: "975", minorUnits: 2, symbol: "лв"},
+	{iso: "HRK", numeric: "191", minorUnits: 2, symbol: "kn"},
+})
 
-func (currency *currency) GetISO() string { return currency.iso }
+func newCurrencyRegistry(list []*currency) map[string]Currency {
+	result := make(map[string]Currency, len(list))
+	for _, currency := range list {
+		result[currency.iso] = currency
+	}
+	return result
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 	"time"
 
@@ -47,35 +48,295 @@ type DBTrans interface {
 	RecreateAuth(AuthTokenID) (*AuthTokenID, *ClientID, error)
 	RevokeClientAuth(AuthTokenID, ClientID) (bool, error)
 
+	// CreateAccount creates new client account in the currency. If the client
+	// already has account in this currency - returns nil without error.
 	CreateAccount(Currency, ClientID) (Account, error)
 	GetAccounts(ClientID) ([]Account, error)
+	// FindAccount tries to find account by ID. If there is no error but
+	// account is not fined - returns nil.
+	FindAccount(AccountID) (Account, error)
+	// FindClientAccount tries to find account by ID only if the account
+	// belongs to the client. If there is no error but account is not fined -
+	// returns nil.
+	FindClientAccount(AccountID, ClientID) (Account, error)
 	FindAccountByEmail(email string, currency Currency) (*AccountID, error)
+	// FindAccountUpdate returns the account if it has revision after
+	// fromRevision, with the last transactions, not more than historyLimit.
 	FindAccountUpdate(
 		id AccountID,
 		client ClientID,
-		fromRevision int64) (Account, []*Trans, error)
-	UpdateClientAccountBalance(
-		accID AccountID, clientID ClientID, delta float64) (Client, Account, error)
-	UpdateAccountBalance(accID AccountID, delta float64) (Client, Account, error)
+		fromRevision int64,
+		historyLimit int) (Account, []*Trans, error)
+	// PostJournal stores the balanced journal and applies its postings to the
+	// account balances, the account balance is only a projection of the
+	// postings. Returns updated client accounts from the journal, or
+	// AccountStatusError if the account status doesn't allow the posting, then
+	// the DB transaction has to be rolled back.
+	PostJournal(*Journal) (map[AccountID]Account, error)
+	// CloseAccount closes active account with zero balance and without
+	// authorized holds. Returns false if the account is not active, has not
+	// zero balance or has authorized holds.
+	CloseAccount(AccountID) (bool, error)
+	// LockOverdraftAccount tries to find and lock not closed account, which
+	// overdraft interest is not accrued for the last day yet, accounts with
+	// the earliest accrual are the first. The account is skipped if it's
+	// already locked by another DB transaction or if it's in the skip list.
+	// Returns the account with the next day to accrue, which is the last day
+	// for the account without accruals. If there is no error but account is
+	// not fined - returns nil.
+	LockOverdraftAccount(
+		lastDay time.Time, skip []AccountID) (Account, time.Time, error)
+	// SetAccountOverdraftAccrued stores that the overdraft interest is
+	// accrued for the day.
+	SetAccountOverdraftAccrued(acc AccountID, day time.Time) error
+	// LockInterestAccount tries to find and lock not closed account, which
+	// interest is not accrued for the last day yet, accounts with the earliest
+	// accrual are the first. The account is skipped if it's already locked by
+	// another DB transaction or if it's in the skip list. Returns the account
+	// with the next day to accrue, which is the last day for the account
+	// without accruals. If there is no error but account is not fined -
+	// returns nil.
+	LockInterestAccount(
+		lastDay time.Time, skip []AccountID) (Account, time.Time, error)
+	// FindAccountInterestRate returns the annual interest rate in percents for
+	// the account currency and the client tier. Returns nil if there is no
+	// interest for the account.
+	FindAccountInterestRate(Account) (*big.Rat, error)
+	// AccrueAccountInterest adds the interest for the day to the account
+	// pending interest and stores that the interest is accrued for the day.
+	// Returns the pending interest.
+	AccrueAccountInterest(
+		acc AccountID, day time.Time, interest *big.Rat) (*big.Rat, error)
+	// CapitalizeAccountInterest subtracts the interest, which is paid to
+	// the account, from the account pending interest.
+	CapitalizeAccountInterest(acc AccountID, paid Money) error
 
 	GetBankCardMethod(Account, *BankCard) (BankCardMethod, error)
 	GetAccountMethod(
 		acc Account,
 		receiverAcc AccountID,
 		receiverEmail string) (AccountMethod, error)
-	GetTaxMethod(account Account, bill string) (TaxMethod, error)
+	// GetTaxMethod returns the tax method for the authority bill, authority is
+	// nil only for the bill of the standing order, which is stored before
+	// the authority registry.
+	GetTaxMethod(
+		account Account, authority *TaxAuthority, bill string) (TaxMethod, error)
+	GetFeeMethod(Account) (FeeMethod, error)
+	GetOverdraftMethod(Account) (OverdraftMethod, error)
+	GetHoldMethod(Account, *Hold) (HoldMethod, error)
+	GetBankTransferMethod(Account, *BankLine) (BankTransferMethod, error)
+	GetInterestMethod(Account) (InterestMethod, error)
 
+	// FindTaxAuthority tries to find tax authority by ID. If there is no
+	// error but authority is not fined - returns nil.
+	FindTaxAuthority(TaxAuthorityID) (*TaxAuthority, error)
+	// GetTaxAuthorities returns tax authorities which accept payments in
+	// the currency, sorted by name.
+	GetTaxAuthorities(Currency) ([]*TaxAuthority, error)
+	// GetTaxPayees returns the account client tax methods with the authority
+	// in the account currency, the last used method is the first.
+	GetTaxPayees(Account) ([]TaxMethod, error)
+
+	// FindFeeRule tries to find fee rule for the operation with the value
+	// in the rule amount band. If there is no error but rule is not fined -
+	// returns nil, so the operation is free.
+	FindFeeRule(FeeOperation, Money) (*FeeRule, error)
+
+	// StoreTrans stores new account transaction for the posted journal,
+	// exchange has to be set only if the transaction converts currency.
 	StoreTrans(
 		status TransStatus,
+		journal JournalID,
 		acc Account,
 		method Method,
-		value float64) (*Trans, error)
+		value Money,
+		exchange *TransExchange) (*Trans, error)
 	StoreTransWithReason(
 		status TransStatus,
 		statusReason string,
 		acc Account,
 		method Method,
-		value float64) (*Trans, error)
+		value Money,
+		exchange *TransExchange) (*Trans, error)
+	// UpdateTransStatus moves transaction into the new status and stores
+	// the change in the transaction status history. Returns error if
+	// the transaction state machine doesn't allow such transition.
+	UpdateTransStatus(
+		id TransID, status TransStatus, statusReason *string) error
+	GetTransStatusHistory(TransID) ([]*TransStatusChange, error)
+	// FindClientTrans tries to find account transaction only if the account
+	// belongs to the client. If there is no error but transaction is not
+	// fined - returns nil.
+	FindClientTrans(TransID, AccountID, ClientID) (*Trans, error)
+	// GetAccountHistory returns not more than limit account transactions
+	// before the cursor, or the last transactions if the cursor is nil, in
+	// reverse order (the newest first). Returns true if the account has more
+	// transactions before the returned. The filter is optional.
+	GetAccountHistory(
+		acc AccountID,
+		client ClientID,
+		filter *TransFilter,
+		before *TransCursor,
+		limit int) ([]*Trans, bool, error)
+	// FindJournalCounterTrans tries to find transaction from the same journal
+	// for the counterpart account. If there is no error but transaction is not
+	// fined - returns nil.
+	FindJournalCounterTrans(*Trans) (*Trans, error)
+	// GetAccountStatementTrans returns account transactions posted in
+	// the period, in chronological order. The period end is excluded.
+	GetAccountStatementTrans(acc AccountID, from, to time.Time) ([]*Trans, error)
+	// ForEachAccountExportTrans calls the callback for each account
+	// transaction posted in the period, ordered by the transaction time and ID,
+	// starting after the cursor, but not more than the limit, without loading
+	// all transactions at once. The cursor is optional. Returns true if
+	// the period has more transactions after the limit. The callback could not
+	// use the DB transaction.
+	ForEachAccountExportTrans(
+		acc AccountID,
+		from time.Time,
+		to time.Time,
+		after *TransCursor,
+		limit int,
+		callback func(*Trans) error) (bool, error)
+	// GetAccountBalanceAt returns the account booked balance at the time,
+	// calculated by the postings before it.
+	GetAccountBalanceAt(acc Account, at time.Time) (Money, error)
+	// GetLinkedTrans returns transactions of the same account which are
+	// refunds or fees of the transaction, in chronological order.
+	GetLinkedTrans(*Trans) ([]*Trans, error)
+	// GetTransRefunded returns total value of successful refunds for
+	// the transaction, in the transaction sign.
+	GetTransRefunded(*Trans) (Money, error)
+	// SetTransRefundOf links refund transaction with the original transaction.
+	SetTransRefundOf(refund, original TransID) error
+	// SetTransFeeOf links fee transaction with the charged transaction.
+	SetTransFeeOf(fee, charged TransID) error
+	// LockTrans locks transaction row until the end of the DB transaction.
+	LockTrans(TransID) error
+
+	// CreateExchangeQuote locks exchange rate for the payment between accounts
+	// for the live time.
+	CreateExchangeQuote(
+		from Account,
+		to Account,
+		value Money,
+		rate *ExchangeRate,
+		liveTime time.Duration) (*ExchangeQuote, error)
+	// AcceptExchangeQuote returns the quote and removes it, so it could be used
+	// only once. If there is no error but the quote is not fined or already
+	// expired - returns nil.
+	AcceptExchangeQuote(ExchangeQuoteID, ClientID) (*ExchangeQuote, error)
+
+	CreateSchedule(*Schedule) error
+	// UpdateSchedule stores standing order changes. Returns false if
+	// the standing order is not existent.
+	UpdateSchedule(*Schedule) (bool, error)
+	// DeleteSchedule deletes the client standing order. Returns false if
+	// the client does not have such standing order.
+	DeleteSchedule(ScheduleID, AccountID, ClientID) (bool, error)
+	// DeleteAccountSchedules deletes all standing orders of the account.
+	DeleteAccountSchedules(AccountID) error
+	GetAccountSchedules(AccountID, ClientID) ([]*Schedule, error)
+	// FindClientSchedule tries to find the client standing order. If there is
+	// no error but standing order is not fined - returns nil.
+	FindClientSchedule(ScheduleID, AccountID, ClientID) (*Schedule, error)
+	// LockDueSchedule tries to find and lock standing order which has to be
+	// executed by the time, the standing order is skipped if it's already
+	// locked by another DB transaction. If there is no error but standing
+	// order is not fined - returns nil.
+	LockDueSchedule(time.Time) (*Schedule, error)
+	// CompleteSchedule stores the standing order run, which is completed by
+	// Schedule.Complete. Returns false if the run with the previous next time
+	// is already stored.
+	CompleteSchedule(schedule *Schedule, prevNextTime time.Time) (bool, error)
+
+	// GetAccountLimits returns the account spending limits, or nil if
+	// the account limits are not set, and the account client tier.
+	GetAccountLimits(Account) (*SpendingLimits, ClientTier, error)
+	SetAccountLimits(AccountID, SpendingLimits) error
+	// GetAccountSpending returns values withdrawn from the account since
+	// the beginning of the day and the month of the time.
+	GetAccountSpending(Account, time.Time) (Spending, error)
+	// CreateAccountLimitsRequest stores the limits change which has to be
+	// confirmed by the confirmation.
+	CreateAccountLimitsRequest(
+		ConfirmationID, AccountID, SpendingLimits) error
+	// LockAccountLimitsRequest tries to find and lock the limits change for
+	// the confirmation. If there is no error but the change is not fined -
+	// returns nil.
+	LockAccountLimitsRequest(
+		ConfirmationID, Account) (*SpendingLimits, error)
+
+	CreateMoneyRequest(*MoneyRequest) error
+	// GetClientMoneyRequests returns money requests in which the client is
+	// the payee or the payer.
+	GetClientMoneyRequests(ClientID) ([]*MoneyRequest, error)
+	// LockClientMoneyRequest tries to find and lock money request in which
+	// the client is the payee or the payer. If there is no error but money
+	// request is not fined - returns nil.
+	LockClientMoneyRequest(MoneyRequestID, ClientID) (*MoneyRequest, error)
+	UpdateMoneyRequestStatus(MoneyRequestID, MoneyRequestStatus) error
+
+	// AuthorizeHold stores new hold and reserves its value on the account.
+	// Returns updated account, or AccountStatusError if the account status
+	// doesn't allow withdrawals, then the DB transaction has to be rolled back.
+	AuthorizeHold(*Hold) (Account, error)
+	GetAccountHolds(AccountID) ([]*Hold, error)
+	// LockClientHold tries to find and lock the client account hold. If there
+	// is no error but hold is not fined - returns nil.
+	LockClientHold(HoldID, AccountID, ClientID) (*Hold, error)
+	// CaptureHold marks authorized hold as captured by the value and releases
+	// the reserved value, the captured value has to be withdrawn by a journal
+	// in the same DB transaction. Returns false if the hold is not authorized.
+	CaptureHold(id HoldID, captured Money) (bool, error)
+	// VoidHold releases authorized hold. Returns false if the hold is not
+	// authorized.
+	VoidHold(HoldID) (bool, error)
+	// ExpireHolds releases all authorized holds which have passed expiry time.
+	// Returns the number of expired holds.
+	ExpireHolds(now time.Time) (int64, error)
+
+	// StoreBankLine stores new bank statement line. If the line with the same
+	// key is already stored - returns false without error.
+	StoreBankLine(*BankLine) (bool, error)
+	// CreditBankLine marks the line which is in review as credited to
+	// the account by the transaction. Returns false if the line is not in
+	// review.
+	CreditBankLine(id BankLineID, acc AccountID, trans TransID) (bool, error)
+	// GetBankLinesForReview returns lines which have to be reviewed by hand,
+	// in chronological order.
+	GetBankLinesForReview() ([]*BankLine, error)
+	// LockBankLine tries to find and lock bank statement line. If there is no
+	// error but line is not fined - returns nil.
+	LockBankLine(BankLineID) (*BankLine, error)
+
+	// GetAccountReconciliations returns reconciliations for the next accounts
+	// after the account in the ID order, or from the first account if after is
+	// nil.
+	GetAccountReconciliations(
+		after *AccountID, limit int) ([]*AccountReconciliation, error)
+	// StoreReconcileDiscrepancy stores the account discrepancy found by
+	// the reconciliation run in the report.
+	StoreReconcileDiscrepancy(
+		run ReconcileRunID, acc *AccountReconciliation, reason string) error
+
+	// FindIdempotentResponse tries to find stored response for the client
+	// request with the idempotency key. If there is no error but response is
+	// not fined - returns nil.
+	FindIdempotentResponse(
+		client ClientID, key string) (*IdempotentResponse, error)
+	// ReserveIdempotencyKey stores pending response for the client request
+	// with the idempotency key before the request execution. If the key
+	// already has response (pending too) - returns false without error.
+	ReserveIdempotencyKey(*IdempotentResponse) (bool, error)
+	// StoreIdempotentResponse stores response for the client request with
+	// the idempotency key, or replaces the pending response of the same
+	// request. If the key already has not pending response - returns false
+	// without error.
+	StoreIdempotentResponse(*IdempotentResponse) (bool, error)
+	// PurgeIdempotentResponses deletes responses stored before the time,
+	// returns the number of deleted responses.
+	PurgeIdempotentResponses(before time.Time) (int64, error)
 }
 
 var dbName string     // set by builder
@@ -375,21 +636,28 @@ func (t *dbTrans) CreateAccount(
 		INSERT INTO acc(id, client, currency, time, balance, revision)
 		VALUES($1, $2, $3, $4, $5, $6)`
 	id := newAccountID()
-	balance := .0
+	balance := NewMoney(0, currency)
 	revision := int64(1)
 	result, err := t.tx.Exec(
-		query, id, client, currency.GetISO(), time.Now().UTC(), balance, revision)
+		query, id, client, currency.GetISO(), time.Now().UTC(),
+		balance.GetUnits(), revision)
 	if err != nil {
+		if t.isDuplicateErr(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	if err := t.checkAffectedRows(result); err != nil {
 		return nil, err
 	}
-	return newAccount(id, client, currency, balance, revision), nil
+	return newAccount(id, client, currency, balance, NewMoney(0, currency),
+		NewMoney(0, currency), revision, AccountStatusActive), nil
 }
 
 func (t *dbTrans) GetAccounts(client ClientID) ([]Account, error) {
-	query := "SELECT id, currency, balance, revision FROM acc WHERE client = $1"
+	query := `
+		SELECT id, currency, balance, overdraft, held, revision, status FROM acc
+		WHERE client = $1`
 	rows, err := t.tx.Query(query, client)
 	if err != nil {
 		return nil, err
@@ -400,32 +668,91 @@ func (t *dbTrans) GetAccounts(client ClientID) ([]Account, error) {
 	for rows.Next() {
 		var id AccountID
 		var currency string
-		var balance float64
+		var balance int64
+		var overdraft int64
+		var held int64
 		var revision int64
-		if err := rows.Scan(&id, &currency, &balance, &revision); err != nil {
+		var status int16
+		err := rows.Scan(
+			&id, &currency, &balance, &overdraft, &held, &revision, &status)
+		if err != nil {
 			return nil, err
 		}
-		result = append(result,
-			newAccount(id, client, NewCurrency(currency), balance, revision))
+		acc, err := newAccountFromDB(
+			id, client, currency, balance, overdraft, held, revision, status)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, acc)
 	}
 	return result, nil
 }
 
+func (t *dbTrans) FindAccount(id AccountID) (Account, error) {
+	query := `
+		SELECT client, currency, balance, overdraft, held, revision, status FROM acc
+		WHERE id = $1`
+	var client ClientID
+	var currency string
+	var balance int64
+	var overdraft int64
+	var held int64
+	var revision int64
+	var status int16
+	switch err := t.tx.QueryRow(query, id).Scan(
+		&client, &currency, &balance, &overdraft, &held, &revision, &status); {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return newAccountFromDB(
+		id, client, currency, balance, overdraft, held, revision, status)
+}
+
+func (t *dbTrans) FindClientAccount(
+	id AccountID, client ClientID) (Account, error) {
+	query := `
+		SELECT currency, balance, overdraft, held, revision, status FROM acc
+		WHERE id = $1 AND client = $2`
+	var currency string
+	var balance int64
+	var overdraft int64
+	var held int64
+	var revision int64
+	var status int16
+	switch err := t.tx.QueryRow(query, id, client).
+		Scan(&currency, &balance, &overdraft, &held, &revision, &status); {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return newAccountFromDB(
+		id, client, currency, balance, overdraft, held, revision, status)
+}
+
 func (t *dbTrans) FindAccountUpdate(
-	id AccountID, client ClientID, revision int64) (Account, []*Trans, error) {
+	id AccountID,
+	client ClientID,
+	revision int64,
+	historyLimit int) (Account, []*Trans, error) {
 
 	query := `
 		SELECT
-			acc.currency, acc.balance, acc.revision,
+			acc.currency, acc.balance, acc.overdraft, acc.held, acc.revision,
+			acc.status,
 				trans.id, trans.value, trans.time, trans.status, trans.status_reason,
-				trans.method_arg, method.id, method.info, method.type, method.currency
+				trans.method_arg, method.id, method.info, method.type, method.currency,
+				trans.exchange_rate, trans.counter_value, trans.counter_currency,
+				trans.refund_of, trans.fee_of
 		FROM acc
 			LEFT JOIN trans ON trans.acc = acc.id
 			LEFT JOIN method ON method.id = trans.method
 		WHERE acc.id = $1 AND acc.client = $2 AND acc.revision > $3
-		ORDER BY trans.time DESC
-		LIMIT 5`
-	rows, err := t.tx.Query(query, id, client, revision)
+		ORDER BY trans.time DESC, trans.id DESC
+		LIMIT $4`
+	rows, err := t.tx.Query(query, id, client, revision, historyLimit)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -436,9 +763,12 @@ func (t *dbTrans) FindAccountUpdate(
 	for rows.Next() {
 
 		var currency string
-		var balance float64
+		var balance int64
+		var overdraft int64
+		var held int64
+		var status int16
 		var transID nullTransID
-		var transValue sql.NullFloat64
+		var transValue sql.NullInt64
 		var transTime sql.NullTime
 		var transStatus nullTransStatus
 		var transStatusReason sql.NullString
@@ -447,47 +777,58 @@ func (t *dbTrans) FindAccountUpdate(
 		var methodInfo sql.NullString
 		var methodType nullMethodType
 		var methodCurrency sql.NullString
-		err := rows.Scan(&currency, &balance, &revision,
+		var exchangeRate sql.NullString
+		var counterValue sql.NullInt64
+		var counterCurrency sql.NullString
+		var refundOf nullTransID
+		var feeOf nullTransID
+		err := rows.Scan(&currency, &balance, &overdraft, &held, &revision, &status,
 			&transID, &transValue, &transTime, &transStatus, &transStatusReason,
-			&methodArg, &methodID, &methodInfo, &methodType, &methodCurrency)
+			&methodArg, &methodID, &methodInfo, &methodType, &methodCurrency,
+			&exchangeRate, &counterValue, &counterCurrency, &refundOf,
+			&feeOf)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		var method Method
 		if methodID.Valid && transID.Valid {
-			method, err = newMethodByType(methodType.MethodType, methodID.MethodID,
-				client, NewCurrency(methodCurrency.String),
-				func(result interface{}) error {
-					if !methodArg.Valid {
-						return errors.New("method arg is not set")
-					}
-					return json.Unmarshal([]byte(methodArg.String), result)
-				},
-				func(result interface{}) error {
-					if !methodInfo.Valid {
-						return errors.New("method info is not set")
-					}
-					return json.Unmarshal([]byte(methodInfo.String), result)
-				})
+			method, err = newMethodFromDB(methodType.MethodType, methodID.MethodID,
+				client, methodCurrency.String, methodArg, methodInfo)
 			if err != nil {
-				return nil, nil, fmt.Errorf(
-					`failed to create method "%v" instance: "%v"`, methodID, err)
+				return nil, nil, err
 			}
 		}
 
 		if account == nil {
-			account = newAccount(id, client, NewCurrency(currency), balance, revision)
+			account, err = newAccountFromDB(
+				id, client, currency, balance, overdraft, held, revision, status)
+			if err != nil {
+				return nil, nil, err
+			}
 		}
 		if method != nil {
 			var transStatusReasonValue *string
 			if transStatusReason.Valid {
 				transStatusReasonValue = &transStatusReason.String
 			}
-			trans = append(trans,
-				newTrans(transID.TransID, transValue.Float64,
-					transTime.Time, method, account,
-					transStatus.TransStatus, transStatusReasonValue))
+			var exchange *TransExchange
+			exchange, err = newTransExchangeFromDB(
+				exchangeRate, counterValue, counterCurrency)
+			if err != nil {
+				return nil, nil, err
+			}
+			record := newTrans(transID.TransID,
+				NewMoney(transValue.Int64, account.GetCurrency()),
+				transTime.Time, method, account,
+				transStatus.TransStatus, transStatusReasonValue, exchange)
+			if refundOf.Valid {
+				record.RefundOf = &refundOf.TransID
+			}
+			if feeOf.Valid {
+				record.FeeOf = &feeOf.TransID
+			}
+			trans = append(trans, record)
 		}
 
 	}
@@ -501,11 +842,11 @@ func (t *dbTrans) FindAccountByEmail(
 		SELECT acc.id
 		FROM client
 			LEFT JOIN acc ON acc.client = client.id
-		WHERE client.email = $1 AND acc.currency = $2
+		WHERE client.email = $1 AND acc.currency = $2 AND acc.status != $3
 		LIMIT 1`
 	var accID AccountID
-	switch err := t.tx.QueryRow(query, strings.ToLower(email), currency.GetISO()).
-		Scan(&accID); {
+	switch err := t.tx.QueryRow(query, strings.ToLower(email), currency.GetISO(),
+		AccountStatusClosed).Scan(&accID); {
 	case err == sql.ErrNoRows:
 		return nil, nil
 	case err != nil:
@@ -514,56 +855,306 @@ func (t *dbTrans) FindAccountByEmail(
 	return &accID, nil
 }
 
-func (t *dbTrans) UpdateClientAccountBalance(
-	id AccountID, clientID ClientID, delta float64) (Client, Account, error) {
+func (t *dbTrans) PostJournal(
+	journal *Journal) (map[AccountID]Account, error) {
+	if err := journal.Validate(); err != nil {
+		return nil, err
+	}
+
+	result, err := t.tx.Exec(
+		`INSERT INTO journal(id, "time") VALUES($1, $2)`, journal.ID, journal.Time)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkAffectedRows(result); err != nil {
+		return nil, err
+	}
+
+	accounts := map[AccountID]Account{}
+	query := `
+		INSERT INTO posting(id, journal, acc, system_acc, value, currency)
+		VALUES($1, $2, $3, $4, $5, $6)`
+	for _, posting := range journal.Postings {
+		var accID nullAccountID
+		var systemAccID nullSystemAccountID
+		if posting.Account != nil {
+			acc, err := t.applyAccountPosting(
+				*posting.Account, posting.Value, journal.IsCharge)
+			if err != nil {
+				return nil, err
+			}
+			accounts[acc.GetID()] = acc
+			accID = nullAccountID{AccountID: acc.GetID(), Valid: true}
+		} else if posting.SystemID != nil {
+			err := t.applySystemAccountPosting(*posting.SystemID, posting.Value)
+			if err != nil {
+				return nil, err
+			}
+			systemAccID = nullSystemAccountID{
+				SystemAccountID: *posting.SystemID, Valid: true}
+		} else {
+			systemAccID.SystemAccountID, err = t.applySystemPosting(
+				posting.System, posting.Value)
+			if err != nil {
+				return nil, err
+			}
+			systemAccID.Valid = true
+		}
+		result, err := t.tx.Exec(query, newPostingID(), journal.ID,
+			accID, systemAccID,
+			posting.Value.GetUnits(), posting.Value.GetCurrency().GetISO())
+		if err != nil {
+			return nil, err
+		}
+		if err := t.checkAffectedRows(result); err != nil {
+			return nil, err
+		}
+	}
+
+	return accounts, nil
+}
+
+func (t *dbTrans) CloseAccount(id AccountID) (bool, error) {
 	query := `
 		UPDATE acc
-		SET balance = balance + $3, revision = revision + 1
-		FROM client
-		WHERE acc.client = $2 AND acc.id = $1 AND client.id = acc.client
-		RETURNING
-			acc.currency, acc.balance, acc.revision, client.email, client.name`
+		SET status = $2, revision = revision + 1
+		WHERE id = $1 AND status = $3 AND balance = 0 AND held = 0`
+	result, err := t.tx.Exec(
+		query, id, AccountStatusClosed, AccountStatusActive)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (t *dbTrans) LockOverdraftAccount(
+	lastDay time.Time, skip []AccountID) (Account, time.Time, error) {
+	query := `
+		SELECT
+			id, client, currency, balance, overdraft, held, revision, status,
+			COALESCE(overdraft_accrued + 1, $1)
+		FROM acc
+		WHERE
+			status <> $2
+			AND (overdraft_accrued IS NULL OR overdraft_accrued < $1)
+			AND NOT (id = ANY($3::uuid[]))
+		ORDER BY overdraft_accrued NULLS LAST
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	var id AccountID
+	var client ClientID
 	var currency string
-	var balance float64
+	var balance int64
+	var overdraft int64
+	var held int64
 	var revision int64
-	var email string
-	var clientName string
-	switch err := t.tx.QueryRow(query, id, clientID, delta).
-		Scan(&currency, &balance, &revision, &email, &clientName); {
+	var status int16
+	var day time.Time
+	switch err := t.tx.QueryRow(
+		query, lastDay, AccountStatusClosed, newAccountIDArray(skip)).Scan(
+		&id, &client, &currency, &balance, &overdraft, &held, &revision, &status,
+		&day); {
 	case err == sql.ErrNoRows:
-		return nil, nil, nil
+		return nil, time.Time{}, nil
 	case err != nil:
-		return nil, nil, err
+		return nil, time.Time{}, err
+	}
+	acc, err := newAccountFromDB(
+		id, client, currency, balance, overdraft, held, revision, status)
+	return acc, day, err
+}
+
+// newAccountIDArray converts account IDs into the DB array.
+func newAccountIDArray(source []AccountID) pq.StringArray {
+	result := make(pq.StringArray, len(source))
+	for i, id := range source {
+		result[i] = id.String()
+	}
+	return result
+}
+
+func (t *dbTrans) SetAccountOverdraftAccrued(
+	acc AccountID, day time.Time) error {
+	query := "UPDATE acc SET overdraft_accrued = $2 WHERE id = $1"
+	result, err := t.tx.Exec(query, acc, day)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+// interestPendingScale is the number of fractional digits of the account
+// pending interest in the DB.
+const interestPendingScale = 12
+
+func (t *dbTrans) LockInterestAccount(
+	lastDay time.Time, skip []AccountID) (Account, time.Time, error) {
+	query := `
+		SELECT
+			id, client, currency, balance, overdraft, held, revision, status,
+			COALESCE(interest_accrued + 1, $1)
+		FROM acc
+		WHERE
+			status <> $2
+			AND (interest_accrued IS NULL OR interest_accrued < $1)
+			AND NOT (id = ANY($3::uuid[]))
+		ORDER BY interest_accrued NULLS LAST
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`
+	var id AccountID
+	var client ClientID
+	var currency string
+	var balance int64
+	var overdraft int64
+	var held int64
+	var revision int64
+	var status int16
+	var day time.Time
+	switch err := t.tx.QueryRow(
+		query, lastDay, AccountStatusClosed, newAccountIDArray(skip)).Scan(
+		&id, &client, &currency, &balance, &overdraft, &held, &revision, &status,
+		&day); {
+	case err == sql.ErrNoRows:
+		return nil, time.Time{}, nil
+	case err != nil:
+		return nil, time.Time{}, err
+	}
+	acc, err := newAccountFromDB(
+		id, client, currency, balance, overdraft, held, revision, status)
+	return acc, day, err
+}
+
+func (t *dbTrans) FindAccountInterestRate(acc Account) (*big.Rat, error) {
+	query := `
+		SELECT interest_rate.percent
+		FROM acc
+			JOIN client ON client.id = acc.client
+			JOIN interest_rate
+				ON interest_rate.currency = acc.currency
+				AND interest_rate.tier = client.tier
+		WHERE acc.id = $1`
+	var percent string
+	switch err := t.tx.QueryRow(query, acc.GetID()).Scan(&percent); {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	result, isParsed := new(big.Rat).SetString(percent)
+	if !isParsed {
+		return nil, fmt.Errorf(`failed to parse interest rate "%s"`, percent)
+	}
+	return result, nil
+}
+
+func (t *dbTrans) AccrueAccountInterest(
+	acc AccountID, day time.Time, interest *big.Rat) (*big.Rat, error) {
+	query := `
+		UPDATE acc
+		SET interest_pending = interest_pending + $3, interest_accrued = $2
+		WHERE id = $1
+		RETURNING interest_pending`
+	var pending string
+	err := t.tx.QueryRow(
+		query, acc, day, interest.FloatString(interestPendingScale)).
+		Scan(&pending)
+	if err != nil {
+		return nil, err
+	}
+	result, isParsed := new(big.Rat).SetString(pending)
+	if !isParsed {
+		return nil, fmt.Errorf(`failed to parse pending interest "%s"`, pending)
+	}
+	return result, nil
+}
+
+func (t *dbTrans) CapitalizeAccountInterest(acc AccountID, paid Money) error {
+	query := `
+		UPDATE acc SET interest_pending = interest_pending - $2 WHERE id = $1`
+	result, err := t.tx.Exec(
+		query, acc, paid.GetRat().FloatString(interestPendingScale))
+	if err != nil {
+		return err
 	}
-	return newClient(clientID, email, clientName),
-		newAccount(id, clientID, NewCurrency(currency), balance, revision), nil
+	return t.checkAffectedRows(result)
 }
 
-func (t *dbTrans) UpdateAccountBalance(
-	id AccountID, delta float64) (Client, Account, error) {
+func (t *dbTrans) applyAccountPosting(
+	id AccountID, delta Money, isCharge bool) (Account, error) {
 	query := `
 		UPDATE acc
 		SET balance = balance + $2, revision = revision + 1
-		FROM client
-		WHERE acc.id = $1 AND client.id = acc.client
-		RETURNING
-			acc.currency, acc.balance, acc.revision,
-			client.id, client.email, client.name`
+		WHERE id = $1 AND currency = $3
+		RETURNING client, currency, balance, overdraft, held, revision, status`
+	var clientID ClientID
 	var currency string
-	var balance float64
+	var balance int64
+	var overdraft int64
+	var held int64
 	var revision int64
-	var clientID ClientID
-	var email string
-	var clientName string
-	switch err := t.tx.QueryRow(query, id, delta).
-		Scan(&currency, &balance, &revision, &clientID, &email, &clientName); {
+	var status int16
+	switch err := t.tx.QueryRow(
+		query, id, delta.GetUnits(), delta.GetCurrency().GetISO()).Scan(
+		&clientID, &currency, &balance, &overdraft, &held, &revision, &status); {
 	case err == sql.ErrNoRows:
-		return nil, nil, nil
+		return nil, fmt.Errorf(`account "%s" in "%s" is not existent`,
+			id, delta.GetCurrency().GetISO())
 	case err != nil:
-		return nil, nil, err
+		return nil, err
+	}
+	acc, err := newAccountFromDB(
+		id, clientID, currency, balance, overdraft, held, revision, status)
+	if err != nil {
+		return nil, err
+	}
+	// The DB transaction has to be rolled back if the posting is not allowed.
+	if err := acc.GetStatus().CheckPosting(id, delta, isCharge); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (t *dbTrans) applySystemPosting(
+	accType SystemAccountType, delta Money) (SystemAccountID, error) {
+	if accType == SystemAccountTaxPayable {
+		// The type doesn't identify the account as each tax authority has its
+		// own one.
+		return SystemAccountID{}, fmt.Errorf(
+			`system account "%s" has to be posted by ID`, accType)
+	}
+	query := `
+		INSERT INTO system_acc(id, type, currency, balance, revision)
+		VALUES($1, $2, $3, $4, 1)
+		ON CONFLICT (type, currency) WHERE type <> 2
+			DO UPDATE SET
+				balance = system_acc.balance + $4, revision = system_acc.revision + 1
+		RETURNING id`
+	var result SystemAccountID
+	err := t.tx.QueryRow(query, newSystemAccountID(), accType,
+		delta.GetCurrency().GetISO(), delta.GetUnits()).Scan(&result)
+	return result, err
+}
+
+func (t *dbTrans) applySystemAccountPosting(
+	id SystemAccountID, delta Money) error {
+	query := `
+		UPDATE system_acc
+		SET balance = balance + $2, revision = revision + 1
+		WHERE id = $1 AND currency = $3`
+	result, err := t.tx.Exec(
+		query, id, delta.GetUnits(), delta.GetCurrency().GetISO())
+	if err != nil {
+		return err
+	}
+	if err := t.checkAffectedRows(result); err != nil {
+		return fmt.Errorf(`failed to post system account "%s" in "%s": "%v"`,
+			id, delta.GetCurrency().GetISO(), err)
 	}
-	return newClient(clientID, email, clientName),
-		newAccount(id, clientID, NewCurrency(currency), balance, revision), nil
+	return nil
 }
 
 func (t *dbTrans) insertMethod(
@@ -617,71 +1208,1688 @@ func (t *dbTrans) GetAccountMethod(
 	}, acc)
 }
 
-func (t *dbTrans) GetTaxMethod(acc Account, bill string) (TaxMethod, error) {
+func (t *dbTrans) GetTaxMethod(
+	acc Account, authority *TaxAuthority, bill string) (TaxMethod, error) {
 	var result TaxMethod
 	return result, t.insertMethod(func(id MethodID, client ClientID) Method {
-		result = newTaxMethod(id, client, acc.GetCurrency(), newTaxMethodArg(bill))
+		result = newTaxMethod(id, client, acc.GetCurrency(),
+			newTaxMethodInfo(authority), newTaxMethodArg(bill))
 		return result
 	}, acc)
 }
 
-func (t *dbTrans) storeTrans(
-	status TransStatus,
-	statusReason sql.NullString,
-	acc Account,
-	method Method,
-	value float64) (*Trans, error) {
+const taxAuthorityQuery = `
+	SELECT id, name, currency, bill_format, check_digit, system_acc
+	FROM tax_authority`
 
-	var methodArg sql.NullString
-	methodArgObj := method.GetArg()
-	if methodArgObj != nil {
-		methodArgByte, err := json.Marshal(methodArgObj)
+func (t *dbTrans) queryTaxAuthorities(
+	query string, args ...interface{}) ([]*TaxAuthority, error) {
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*TaxAuthority{}
+	for rows.Next() {
+		record := &TaxAuthority{}
+		var currency string
+		var billFormat string
+		var checkDigit sql.NullInt32
+		err := rows.Scan(&record.ID, &record.Name, &currency, &billFormat,
+			&checkDigit, &record.Account)
 		if err != nil {
 			return nil, err
 		}
-		methodArg.String = string(methodArgByte)
-		methodArg.Valid = true
+		if record.Currency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		if record.BillFormat, err = newTaxAuthorityBillFormat(
+			billFormat); err != nil {
+			return nil, fmt.Errorf(
+				`failed to read tax authority "%s" bill format from DB-value: "%v"`,
+				record.ID, err)
+		}
+		if checkDigit.Valid {
+			value, err := parseTaxBillCheckDigitFromDB(int16(checkDigit.Int32))
+			if err != nil {
+				return nil, err
+			}
+			record.CheckDigit = &value
+		}
+		result = append(result, record)
 	}
+	return result, rows.Err()
+}
 
-	query := `
-		INSERT INTO trans(
-			id, method, acc, value, time, status, status_reason, method_arg)
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
-	time := time.Now().UTC()
-	id := newTransID()
-	result, err := t.tx.Exec(query, id, method.GetID(), acc.GetID(),
-		value, time, status, statusReason, methodArg)
-	if err != nil {
+func (t *dbTrans) FindTaxAuthority(
+	id TaxAuthorityID) (*TaxAuthority, error) {
+	result, err := t.queryTaxAuthorities(taxAuthorityQuery+` WHERE id = $1`, id)
+	if err != nil || len(result) == 0 {
 		return nil, err
 	}
-	err = t.checkAffectedRows(result)
+	return result[0], nil
+}
+
+func (t *dbTrans) GetTaxAuthorities(
+	currency Currency) ([]*TaxAuthority, error) {
+	return t.queryTaxAuthorities(
+		taxAuthorityQuery+` WHERE currency = $1 ORDER BY name, id`,
+		currency.GetISO())
+}
+
+func (t *dbTrans) GetTaxPayees(acc Account) ([]TaxMethod, error) {
+	query := `
+		SELECT id, info
+		FROM method
+		WHERE client = $1 AND type = $2 AND currency = $3 AND key <> ''
+		ORDER BY usage DESC, id`
+	rows, err := t.tx.Query(query, acc.GetClientID(), methodTypeTax,
+		acc.GetCurrency().GetISO())
 	if err != nil {
 		return nil, err
 	}
-
-	var statusReasonPtr *string
-	if statusReason.Valid {
-		statusReasonPtr = &statusReason.String
+	defer rows.Close()
+	result := []TaxMethod{}
+	for rows.Next() {
+		var id MethodID
+		var info string
+		if err := rows.Scan(&id, &info); err != nil {
+			return nil, err
+		}
+		// Payee doesn't have the bill, each payment has its own bill.
+		payee := &taxMethodInfo{}
+		if err := json.Unmarshal([]byte(info), payee); err != nil {
+			return nil, fmt.Errorf(
+				`failed to read tax method "%s" info from DB-value: "%v"`, id, err)
+		}
+		result = append(result, newTaxMethod(id, acc.GetClientID(),
+			acc.GetCurrency(), payee, taxMethodArg{}))
 	}
+	return result, rows.Err()
+}
 
-	return newTrans(id, value, time, method, acc, status, statusReasonPtr), nil
+func (t *dbTrans) GetFeeMethod(acc Account) (FeeMethod, error) {
+	var result FeeMethod
+	return result, t.insertMethod(func(id MethodID, client ClientID) Method {
+		result = newFeeMethod(id, client, acc.GetCurrency())
+		return result
+	}, acc)
 }
 
-func (t *dbTrans) StoreTrans(
-	status TransStatus,
-	acc Account,
-	method Method,
-	value float64) (*Trans, error) {
-	return t.storeTrans(status, sql.NullString{}, acc, method, value)
+func (t *dbTrans) GetOverdraftMethod(acc Account) (OverdraftMethod, error) {
+	var result OverdraftMethod
+	return result, t.insertMethod(func(id MethodID, client ClientID) Method {
+		result = newOverdraftMethod(id, client, acc.GetCurrency())
+		return result
+	}, acc)
 }
 
-func (t *dbTrans) StoreTransWithReason(
-	status TransStatus,
-	statusReason string,
-	acc Account,
-	method Method,
-	value float64) (*Trans, error) {
-	return t.storeTrans(
-		status, sql.NullString{String: statusReason, Valid: true},
-		acc, method, value)
+func (t *dbTrans) GetHoldMethod(acc Account, hold *Hold) (HoldMethod, error) {
+	var result HoldMethod
+	return result, t.insertMethod(func(id MethodID, client ClientID) Method {
+		result = newHoldMethod(id, client, acc.GetCurrency(),
+			newHoldMethodArg(hold))
+		return result
+	}, acc)
+}
+
+func (t *dbTrans) GetBankTransferMethod(
+	acc Account, line *BankLine) (BankTransferMethod, error) {
+	var result BankTransferMethod
+	return result, t.insertMethod(func(id MethodID, client ClientID) Method {
+		result = newBankTransferMethod(id, client, acc.GetCurrency(),
+			newBankTransferMethodArg(line))
+		return result
+	}, acc)
+}
+
+func (t *dbTrans) GetInterestMethod(acc Account) (InterestMethod, error) {
+	var result InterestMethod
+	return result, t.insertMethod(func(id MethodID, client ClientID) Method {
+		result = newInterestMethod(id, client, acc.GetCurrency())
+		return result
+	}, acc)
+}
+
+func (t *dbTrans) FindFeeRule(
+	operation FeeOperation, value Money) (*FeeRule, error) {
+	// The band is selected by findFeeRule, the operation has a few rules.
+	query := `
+		SELECT min_value, max_value, fixed, percent, min_fee, max_fee
+		FROM fee_rule
+		WHERE method = $1 AND direction = $2 AND currency = $3`
+	rows, err := t.tx.Query(query, operation.MethodType, operation.Direction,
+		value.GetCurrency().GetISO())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	currency := value.GetCurrency()
+	newNullMoney := func(source sql.NullInt64) *Money {
+		if !source.Valid {
+			return nil
+		}
+		result := NewMoney(source.Int64, currency)
+		return &result
+	}
+	rules := []*FeeRule{}
+	for rows.Next() {
+		var minValue int64
+		var maxValue sql.NullInt64
+		var fixed int64
+		var percent string
+		var minFee int64
+		var maxFee sql.NullInt64
+		err := rows.Scan(&minValue, &maxValue, &fixed, &percent, &minFee, &maxFee)
+		if err != nil {
+			return nil, err
+		}
+		rule := &FeeRule{
+			MinValue: NewMoney(minValue, currency),
+			MaxValue: newNullMoney(maxValue),
+			Fixed:    NewMoney(fixed, currency),
+			Min:      NewMoney(minFee, currency),
+			Max:      newNullMoney(maxFee)}
+		var isParsed bool
+		if rule.Percent, isParsed = new(big.Rat).SetString(percent); !isParsed {
+			return nil, fmt.Errorf(`failed to parse fee percent "%s"`, percent)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return findFeeRule(rules, value), nil
+}
+
+func newMethodFromDB(
+	typeID MethodType,
+	id MethodID,
+	client ClientID,
+	currency string,
+	arg sql.NullString,
+	info sql.NullString) (Method, error) {
+	currencyValue, err := newCurrencyFromDB(currency)
+	if err != nil {
+		return nil, err
+	}
+	result, err := newMethodByType(typeID, id, client, currencyValue,
+		func(result interface{}) error {
+			if !arg.Valid {
+				return errors.New("method arg is not set")
+			}
+			return json.Unmarshal([]byte(arg.String), result)
+		},
+		func(result interface{}) error {
+			if !info.Valid {
+				return errors.New("method info is not set")
+			}
+			return json.Unmarshal([]byte(info.String), result)
+		})
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to create method "%v" instance: "%v"`, id, err)
+	}
+	return result, nil
+}
+
+func newTransExchangeFromDB(
+	rate sql.NullString,
+	counterValue sql.NullInt64,
+	counterCurrency sql.NullString) (*TransExchange, error) {
+	if !rate.Valid {
+		return nil, nil
+	}
+	rateValue, err := parseExchangeRate(rate.String)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := newCurrencyFromDB(counterCurrency.String)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransExchange(
+		rateValue, NewMoney(counterValue.Int64, currency)), nil
+}
+
+func (t *dbTrans) storeTrans(
+	status TransStatus,
+	statusReason sql.NullString,
+	journal nullJournalID,
+	acc Account,
+	method Method,
+	value Money,
+	exchange *TransExchange) (*Trans, error) {
+
+	var methodArg sql.NullString
+	methodArgObj := method.GetArg()
+	if methodArgObj != nil {
+		methodArgByte, err := json.Marshal(methodArgObj)
+		if err != nil {
+			return nil, err
+		}
+		methodArg.String = string(methodArgByte)
+		methodArg.Valid = true
+	}
+
+	var exchangeRate sql.NullString
+	var counterValue sql.NullInt64
+	var counterCurrency sql.NullString
+	if exchange != nil {
+		exchangeRate.String = FormatExchangeRate(exchange.Rate)
+		exchangeRate.Valid = true
+		counterValue.Int64 = exchange.CounterValue.GetUnits()
+		counterValue.Valid = true
+		counterCurrency.String = exchange.CounterValue.GetCurrency().GetISO()
+		counterCurrency.Valid = true
+	}
+
+	query := `
+		INSERT INTO trans(
+			id, method, acc, value, time, status, status_reason, method_arg,
+			exchange_rate, counter_value, counter_currency, journal)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	time := time.Now().UTC()
+	id := newTransID()
+	result, err := t.tx.Exec(query, id, method.GetID(), acc.GetID(),
+		value.GetUnits(), time, status, statusReason, methodArg,
+		exchangeRate, counterValue, counterCurrency, journal)
+	if err != nil {
+		return nil, err
+	}
+	err = t.checkAffectedRows(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.storeTransStatusChange(
+		id, status, statusReason, time); err != nil {
+		return nil, err
+	}
+
+	var statusReasonPtr *string
+	if statusReason.Valid {
+		statusReasonPtr = &statusReason.String
+	}
+
+	trans := newTrans(
+		id, value, time, method, acc, status, statusReasonPtr, exchange)
+	if journal.Valid {
+		trans.Journal = &journal.JournalID
+	}
+	return trans, nil
+}
+
+func (t *dbTrans) StoreTrans(
+	status TransStatus,
+	journal JournalID,
+	acc Account,
+	method Method,
+	value Money,
+	exchange *TransExchange) (*Trans, error) {
+	return t.storeTrans(status, sql.NullString{},
+		nullJournalID{JournalID: journal, Valid: true},
+		acc, method, value, exchange)
+}
+
+func (t *dbTrans) StoreTransWithReason(
+	status TransStatus,
+	statusReason string,
+	acc Account,
+	method Method,
+	value Money,
+	exchange *TransExchange) (*Trans, error) {
+	return t.storeTrans(
+		status, sql.NullString{String: statusReason, Valid: true},
+		nullJournalID{}, acc, method, value, exchange)
+}
+
+func (t *dbTrans) storeTransStatusChange(
+	trans TransID,
+	status TransStatus,
+	statusReason sql.NullString,
+	time time.Time) error {
+	query := `
+		INSERT INTO trans_status(trans, status, status_reason, "time")
+		VALUES($1, $2, $3, $4)`
+	result, err := t.tx.Exec(query, trans, status, statusReason, time)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) UpdateTransStatus(
+	id TransID, status TransStatus, statusReason *string) error {
+	query := "SELECT status, acc FROM trans WHERE id = $1 FOR UPDATE"
+	var current nullTransStatus
+	var acc AccountID
+	if err := t.tx.QueryRow(query, id).Scan(&current, &acc); err != nil {
+		return err
+	}
+	if err := current.TransStatus.CheckTransition(status); err != nil {
+		return err
+	}
+
+	var statusReasonValue sql.NullString
+	if statusReason != nil {
+		statusReasonValue.String = *statusReason
+		statusReasonValue.Valid = true
+	}
+
+	query = "UPDATE trans SET status = $2, status_reason = $3 WHERE id = $1"
+	result, err := t.tx.Exec(query, id, status, statusReasonValue)
+	if err != nil {
+		return err
+	}
+	if err := t.checkAffectedRows(result); err != nil {
+		return err
+	}
+
+	// Account revision is changed to deliver new status to the client.
+	query = "UPDATE acc SET revision = revision + 1 WHERE id = $1"
+	if result, err = t.tx.Exec(query, acc); err != nil {
+		return err
+	}
+	if err := t.checkAffectedRows(result); err != nil {
+		return err
+	}
+
+	return t.storeTransStatusChange(
+		id, status, statusReasonValue, time.Now().UTC())
+}
+
+func (t *dbTrans) GetTransStatusHistory(
+	id TransID) ([]*TransStatusChange, error) {
+	query := `
+		SELECT status, status_reason, "time" FROM trans_status
+		WHERE trans = $1
+		ORDER BY "time", id`
+	rows, err := t.tx.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*TransStatusChange{}
+	for rows.Next() {
+		var status nullTransStatus
+		var statusReason sql.NullString
+		record := &TransStatusChange{}
+		if err := rows.Scan(&status, &statusReason, &record.Time); err != nil {
+			return nil, err
+		}
+		record.Status = status.TransStatus
+		if statusReason.Valid {
+			record.StatusReason = &statusReason.String
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+// transQuery selects transactions with methods and accounts, the result has
+// to be read by readTrans.
+const transQuery = `
+	SELECT
+		trans.id, trans.value, trans.time, trans.status, trans.status_reason,
+		trans.method_arg, method.id, method.info, method.type, method.currency,
+		trans.exchange_rate, trans.counter_value, trans.counter_currency,
+		trans.journal, trans.refund_of, trans.fee_of,
+		acc.id, acc.client, acc.currency, acc.balance, acc.overdraft,
+		acc.held, acc.revision, acc.status
+	FROM trans
+		JOIN acc ON acc.id = trans.acc
+		JOIN method ON method.id = trans.method`
+
+func (t *dbTrans) readTrans(rows *sql.Rows) ([]*Trans, error) {
+	result := []*Trans{}
+	err := t.forEachTrans(rows, func(trans *Trans) error {
+		result = append(result, trans)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// forEachTrans reads transactions one by one and calls the callback for each,
+// so the transactions are not loaded all at once.
+func (t *dbTrans) forEachTrans(
+	rows *sql.Rows, callback func(*Trans) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		var id TransID
+		var value int64
+		var transTime time.Time
+		var status nullTransStatus
+		var statusReason sql.NullString
+		var methodArg sql.NullString
+		var methodID MethodID
+		var methodInfo sql.NullString
+		var methodType nullMethodType
+		var methodCurrency string
+		var exchangeRate sql.NullString
+		var counterValue sql.NullInt64
+		var counterCurrency sql.NullString
+		var journal nullJournalID
+		var refundOf nullTransID
+		var feeOf nullTransID
+		var accID AccountID
+		var client ClientID
+		var currency string
+		var balance int64
+		var overdraft int64
+		var held int64
+		var revision int64
+		var accStatus int16
+		err := rows.Scan(&id, &value, &transTime, &status, &statusReason,
+			&methodArg, &methodID, &methodInfo, &methodType, &methodCurrency,
+			&exchangeRate, &counterValue, &counterCurrency,
+			&journal, &refundOf, &feeOf,
+			&accID, &client, &currency, &balance, &overdraft, &held, &revision,
+			&accStatus)
+		if err != nil {
+			return err
+		}
+
+		acc, err := newAccountFromDB(
+			accID, client, currency, balance, overdraft, held, revision, accStatus)
+		if err != nil {
+			return err
+		}
+		method, err := newMethodFromDB(methodType.MethodType, methodID,
+			client, methodCurrency, methodArg, methodInfo)
+		if err != nil {
+			return err
+		}
+		exchange, err := newTransExchangeFromDB(
+			exchangeRate, counterValue, counterCurrency)
+		if err != nil {
+			return err
+		}
+		var statusReasonValue *string
+		if statusReason.Valid {
+			statusReasonValue = &statusReason.String
+		}
+
+		record := newTrans(id, NewMoney(value, acc.GetCurrency()), transTime,
+			method, acc, status.TransStatus, statusReasonValue, exchange)
+		if journal.Valid {
+			record.Journal = &journal.JournalID
+		}
+		if refundOf.Valid {
+			record.RefundOf = &refundOf.TransID
+		}
+		if feeOf.Valid {
+			record.FeeOf = &feeOf.TransID
+		}
+		if err := callback(record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (t *dbTrans) FindClientTrans(
+	id TransID, acc AccountID, client ClientID) (*Trans, error) {
+	query := transQuery + `
+		WHERE trans.id = $1 AND acc.id = $2 AND acc.client = $3`
+	rows, err := t.tx.Query(query, id, acc, client)
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.readTrans(rows)
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func (t *dbTrans) GetAccountHistory(
+	acc AccountID,
+	client ClientID,
+	filter *TransFilter,
+	before *TransCursor,
+	limit int) ([]*Trans, bool, error) {
+	query := transQuery + `
+		WHERE acc.id = $1 AND acc.client = $2`
+	// One more transaction is selected to know if there are more transactions.
+	args := []interface{}{acc, client, limit + 1}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if before != nil {
+		query += fmt.Sprintf(` AND (trans.time, trans.id) < (%s, %s)`,
+			arg(before.Time), arg(before.ID))
+	}
+	if filter != nil {
+		query += filterTransQuery(filter, arg)
+	}
+	query += `
+		ORDER BY trans.time DESC, trans.id DESC
+		LIMIT $3`
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	result, err := t.readTrans(rows)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(result) > limit {
+		return result[:limit], true, nil
+	}
+	return result, false, nil
+}
+
+// filterTransQuery returns the query conditions for the filter, arg adds
+// the query argument and returns its placeholder.
+func filterTransQuery(
+	filter *TransFilter, arg func(value interface{}) string) string {
+	result := ""
+	if filter.From != nil {
+		result += " AND trans.time >= " + arg(*filter.From)
+	}
+	if filter.To != nil {
+		result += " AND trans.time < " + arg(*filter.To)
+	}
+	if filter.MinValue != nil {
+		result += " AND ABS(trans.value) >= " + arg(filter.MinValue.GetUnits())
+	}
+	if filter.MaxValue != nil {
+		result += " AND ABS(trans.value) <= " + arg(filter.MaxValue.GetUnits())
+	}
+	if filter.Direction != nil {
+		if *filter.Direction == FeeDirectionIn {
+			result += " AND trans.value > 0"
+		} else {
+			result += " AND trans.value < 0"
+		}
+	}
+	if filter.MethodType != nil {
+		result += " AND method.type = " + arg(*filter.MethodType)
+	}
+	if filter.Status != nil {
+		result += " AND trans.status = " + arg(*filter.Status)
+	}
+	if filter.Query != "" {
+		result += " AND trans.search @@ plainto_tsquery('simple', " +
+			arg(filter.Query) + ")"
+	}
+	return result
+}
+
+func (t *dbTrans) FindJournalCounterTrans(trans *Trans) (*Trans, error) {
+	if trans.Journal == nil {
+		return nil, nil
+	}
+	query := transQuery + `
+		WHERE trans.journal = $1 AND trans.id != $2 AND trans.acc != $3`
+	rows, err := t.tx.Query(query, *trans.Journal, trans.ID,
+		trans.Account.GetID())
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.readTrans(rows)
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	if len(result) > 1 {
+		return nil, fmt.Errorf(
+			`journal "%s" has %d counterpart transactions for transaction "%s"`,
+			*trans.Journal, len(result), trans.ID)
+	}
+	return result[0], nil
+}
+
+const accountStatementTransQuery = transQuery + `
+	JOIN journal ON journal.id = trans.journal
+	WHERE trans.acc = $1 AND journal.time >= $2 AND journal.time < $3
+	ORDER BY journal.time, trans.time, trans.id`
+
+func (t *dbTrans) GetAccountStatementTrans(
+	acc AccountID, from, to time.Time) ([]*Trans, error) {
+	rows, err := t.tx.Query(accountStatementTransQuery, acc, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return t.readTrans(rows)
+}
+
+func (t *dbTrans) ForEachAccountExportTrans(
+	acc AccountID,
+	from time.Time,
+	to time.Time,
+	after *TransCursor,
+	limit int,
+	callback func(*Trans) error) (bool, error) {
+	query := transQuery + `
+		JOIN journal ON journal.id = trans.journal
+		WHERE trans.acc = $1 AND journal.time >= $2 AND journal.time < $3`
+	// One more transaction is selected to know if there are more transactions.
+	args := []interface{}{acc, from, to, limit + 1}
+	if after != nil {
+		query += ` AND (trans.time, trans.id) > ($5, $6)`
+		args = append(args, after.Time, after.ID)
+	}
+	query += `
+		ORDER BY trans.time, trans.id
+		LIMIT $4`
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return false, err
+	}
+	number := 0
+	hasMore := false
+	err = t.forEachTrans(rows, func(trans *Trans) error {
+		if number == limit {
+			hasMore = true
+			return nil
+		}
+		number++
+		return callback(trans)
+	})
+	return hasMore, err
+}
+
+func (t *dbTrans) GetAccountBalanceAt(
+	acc Account, at time.Time) (Money, error) {
+	query := `
+		SELECT COALESCE(SUM(posting.value), 0) FROM posting
+			JOIN journal ON journal.id = posting.journal
+		WHERE posting.acc = $1 AND journal.time < $2`
+	var value int64
+	if err := t.tx.QueryRow(query, acc.GetID(), at).Scan(&value); err != nil {
+		return Money{}, err
+	}
+	return NewMoney(value, acc.GetCurrency()), nil
+}
+
+func (t *dbTrans) GetLinkedTrans(trans *Trans) ([]*Trans, error) {
+	query := transQuery + `
+		WHERE (trans.refund_of = $1 OR trans.fee_of = $1) AND trans.acc = $2
+		ORDER BY trans.time, trans.id`
+	rows, err := t.tx.Query(query, trans.ID, trans.Account.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return t.readTrans(rows)
+}
+
+func (t *dbTrans) GetTransRefunded(trans *Trans) (Money, error) {
+	query := `
+		SELECT COALESCE(SUM(value), 0) FROM trans
+		WHERE refund_of = $1 AND status = $2`
+	var value int64
+	err := t.tx.QueryRow(query, trans.ID, TransStatusSuccess).Scan(&value)
+	if err != nil {
+		return Money{}, err
+	}
+	// Refunds have the opposite sign.
+	return NewMoney(-value, trans.Value.GetCurrency()), nil
+}
+
+func (t *dbTrans) LockTrans(id TransID) error {
+	query := "SELECT id FROM trans WHERE id = $1 FOR UPDATE"
+	return t.tx.QueryRow(query, id).Scan(&id)
+}
+
+func (t *dbTrans) SetTransRefundOf(refund, original TransID) error {
+	query := "UPDATE trans SET refund_of = $2 WHERE id = $1"
+	result, err := t.tx.Exec(query, refund, original)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) SetTransFeeOf(fee, charged TransID) error {
+	query := "UPDATE trans SET fee_of = $2 WHERE id = $1"
+	result, err := t.tx.Exec(query, fee, charged)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) CreateExchangeQuote(
+	from Account,
+	to Account,
+	value Money,
+	rate *ExchangeRate,
+	liveTime time.Duration) (*ExchangeQuote, error) {
+	query := `
+		INSERT INTO exchange_quote(
+			id, client, acc_from, acc_to, value, currency,
+			counter_value, counter_currency, rate, "time", expiry)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	now := time.Now().UTC()
+	result := &ExchangeQuote{
+		ID:           newExchangeQuoteID(),
+		Client:       from.GetClientID(),
+		From:         from.GetID(),
+		To:           to.GetID(),
+		Value:        value,
+		CounterValue: rate.Convert(value),
+		Rate:         rate.Rate,
+		Expiry:       now.Add(liveTime)}
+	sqlResult, err := t.tx.Exec(query, result.ID, result.Client,
+		result.From, result.To,
+		result.Value.GetUnits(), result.Value.GetCurrency().GetISO(),
+		result.CounterValue.GetUnits(),
+		result.CounterValue.GetCurrency().GetISO(),
+		FormatExchangeRate(result.Rate), now, result.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkAffectedRows(sqlResult); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t *dbTrans) AcceptExchangeQuote(
+	id ExchangeQuoteID, client ClientID) (*ExchangeQuote, error) {
+	query := `
+		DELETE FROM exchange_quote
+		WHERE expiry < $1 OR (id = $2 AND client = $3)
+		RETURNING
+			expiry < $1, id, acc_from, acc_to, value, currency,
+			counter_value, counter_currency, rate, expiry`
+	rows, err := t.tx.Query(query, time.Now().UTC(), id, client)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result *ExchangeQuote
+	for rows.Next() {
+		var isExpired bool
+		quote := &ExchangeQuote{Client: client}
+		var value int64
+		var currency string
+		var counterValue int64
+		var counterCurrency string
+		var rate string
+		err := rows.Scan(&isExpired, &quote.ID, &quote.From, &quote.To,
+			&value, &currency, &counterValue, &counterCurrency, &rate,
+			&quote.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		if isExpired {
+			continue
+		}
+		var valueCurrency Currency
+		if valueCurrency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		quote.Value = NewMoney(value, valueCurrency)
+		var counterValueCurrency Currency
+		if counterValueCurrency, err = newCurrencyFromDB(
+			counterCurrency); err != nil {
+			return nil, err
+		}
+		quote.CounterValue = NewMoney(counterValue, counterValueCurrency)
+		if quote.Rate, err = parseExchangeRate(rate); err != nil {
+			return nil, err
+		}
+		result = quote
+	}
+
+	return result, rows.Err()
+}
+
+func (t *dbTrans) FindIdempotentResponse(
+	client ClientID, key string) (*IdempotentResponse, error) {
+	query := `
+		SELECT request_hash, status_code, body, "time" FROM idempotency_key
+		WHERE client = $1 AND key = $2`
+	result := &IdempotentResponse{Client: client, Key: key}
+	var statusCode sql.NullInt64
+	switch err := t.tx.QueryRow(query, client, key).Scan(
+		&result.RequestHash, &statusCode, &result.Body, &result.Time); {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	result.IsPending = !statusCode.Valid
+	result.StatusCode = int(statusCode.Int64)
+	return result, nil
+}
+
+func (t *dbTrans) ReserveIdempotencyKey(
+	response *IdempotentResponse) (bool, error) {
+	query := `
+		INSERT INTO idempotency_key(
+			client, key, request_hash, status_code, body, "time")
+		VALUES($1, $2, $3, NULL, $4, $5)
+		ON CONFLICT (client, key) DO NOTHING`
+	result, err := t.tx.Exec(query, response.Client, response.Key,
+		response.RequestHash, response.Body, response.Time)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (t *dbTrans) StoreIdempotentResponse(
+	response *IdempotentResponse) (bool, error) {
+	query := `
+		INSERT INTO idempotency_key(
+			client, key, request_hash, status_code, body, "time")
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (client, key) DO UPDATE
+		SET
+			status_code = EXCLUDED.status_code,
+			body = EXCLUDED.body,
+			"time" = EXCLUDED."time"
+		WHERE
+			idempotency_key.status_code IS NULL
+			AND idempotency_key.request_hash = EXCLUDED.request_hash`
+	statusCode := sql.NullInt64{
+		Int64: int64(response.StatusCode),
+		Valid: !response.IsPending}
+	result, err := t.tx.Exec(query, response.Client, response.Key,
+		response.RequestHash, statusCode, response.Body, response.Time)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (t *dbTrans) PurgeIdempotentResponses(before time.Time) (int64, error) {
+	result, err := t.tx.Exec(
+		`DELETE FROM idempotency_key WHERE "time" < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const scheduleQuery = `
+	SELECT
+		schedule.id, schedule.client, schedule.acc, schedule.target,
+		schedule.receiver, schedule.bill, schedule.tax_authority, schedule.value,
+		acc.currency, schedule.recurrence, schedule.next_time,
+		schedule.last_run_time
+	FROM schedule
+		JOIN acc ON acc.id = schedule.acc`
+
+func (t *dbTrans) readSchedules(rows *sql.Rows) ([]*Schedule, error) {
+	defer rows.Close()
+	result := []*Schedule{}
+	for rows.Next() {
+		record := &Schedule{}
+		var target int16
+		var receiver nullAccountID
+		var bill sql.NullString
+		var taxAuthority nullTaxAuthorityID
+		var value int64
+		var currency string
+		var recurrence sql.NullString
+		var nextTime sql.NullTime
+		var lastRunTime sql.NullTime
+		err := rows.Scan(&record.ID, &record.Client, &record.Account, &target,
+			&receiver, &bill, &taxAuthority, &value,
+			&currency, &recurrence, &nextTime, &lastRunTime)
+		if err != nil {
+			return nil, err
+		}
+		if record.Target, err = parseScheduleTargetFromDB(target); err != nil {
+			return nil, err
+		}
+		if receiver.Valid {
+			record.Receiver = &receiver.AccountID
+		}
+		record.Bill = bill.String
+		if taxAuthority.Valid {
+			record.TaxAuthority = &taxAuthority.TaxAuthorityID
+		}
+		var valueCurrency Currency
+		if valueCurrency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		record.Value = NewMoney(value, valueCurrency)
+		if recurrence.Valid {
+			record.Recurrence, err = ParseScheduleRecurrence(recurrence.String)
+			if err != nil {
+				return nil, fmt.Errorf(
+					`failed to read recurrence from DB-value: "%v"`, err)
+			}
+		}
+		if nextTime.Valid {
+			record.NextTime = &nextTime.Time
+		}
+		if lastRunTime.Valid {
+			record.LastRunTime = &lastRunTime.Time
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (t *dbTrans) querySchedule(
+	query string, args ...interface{}) (*Schedule, error) {
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	result, err := t.readSchedules(rows)
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func newScheduleFieldsForDB(schedule *Schedule) (
+	nullAccountID, sql.NullString, nullTaxAuthorityID, sql.NullString) {
+	var receiver nullAccountID
+	if schedule.Receiver != nil {
+		receiver = nullAccountID{AccountID: *schedule.Receiver, Valid: true}
+	}
+	var bill sql.NullString
+	if schedule.Bill != "" {
+		bill = sql.NullString{String: schedule.Bill, Valid: true}
+	}
+	var recurrence sql.NullString
+	if schedule.Recurrence != nil {
+		recurrence = sql.NullString{
+			String: schedule.Recurrence.String(),
+			Valid:  true}
+	}
+	var taxAuthority nullTaxAuthorityID
+	if schedule.TaxAuthority != nil {
+		taxAuthority = nullTaxAuthorityID{
+			TaxAuthorityID: *schedule.TaxAuthority,
+			Valid:          true}
+	}
+	return receiver, bill, taxAuthority, recurrence
+}
+
+func (t *dbTrans) CreateSchedule(schedule *Schedule) error {
+	query := `
+		INSERT INTO schedule(
+			id, client, acc, target, receiver, bill, tax_authority, value,
+			recurrence, next_time, last_run_time, "time")
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	receiver, bill, taxAuthority, recurrence := newScheduleFieldsForDB(schedule)
+	result, err := t.tx.Exec(query, schedule.ID, schedule.Client,
+		schedule.Account, schedule.Target, receiver, bill, taxAuthority,
+		schedule.Value.GetUnits(), recurrence, schedule.NextTime,
+		schedule.LastRunTime, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) UpdateSchedule(schedule *Schedule) (bool, error) {
+	query := `
+		UPDATE schedule
+		SET
+			target = $2, receiver = $3, bill = $4, tax_authority = $5, value = $6,
+			recurrence = $7, next_time = $8, last_run_time = $9
+		WHERE id = $1`
+	receiver, bill, taxAuthority, recurrence := newScheduleFieldsForDB(schedule)
+	result, err := t.tx.Exec(query, schedule.ID, schedule.Target, receiver, bill,
+		taxAuthority, schedule.Value.GetUnits(), recurrence, schedule.NextTime,
+		schedule.LastRunTime)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (t *dbTrans) CompleteSchedule(
+	schedule *Schedule, prevNextTime time.Time) (bool, error) {
+	query := `
+		UPDATE schedule
+		SET next_time = $2, last_run_time = $3
+		WHERE id = $1 AND next_time = $4`
+	result, err := t.tx.Exec(query, schedule.ID, schedule.NextTime,
+		schedule.LastRunTime, prevNextTime)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (t *dbTrans) DeleteSchedule(
+	id ScheduleID, acc AccountID, client ClientID) (bool, error) {
+	query := "DELETE FROM schedule WHERE id = $1 AND acc = $2 AND client = $3"
+	result, err := t.tx.Exec(query, id, acc, client)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (t *dbTrans) DeleteAccountSchedules(acc AccountID) error {
+	_, err := t.tx.Exec("DELETE FROM schedule WHERE acc = $1", acc)
+	return err
+}
+
+func (t *dbTrans) GetAccountSchedules(
+	acc AccountID, client ClientID) ([]*Schedule, error) {
+	query := scheduleQuery + `
+		WHERE schedule.acc = $1 AND schedule.client = $2
+		ORDER BY schedule.next_time NULLS LAST, schedule.time`
+	rows, err := t.tx.Query(query, acc, client)
+	if err != nil {
+		return nil, err
+	}
+	return t.readSchedules(rows)
+}
+
+func (t *dbTrans) FindClientSchedule(
+	id ScheduleID, acc AccountID, client ClientID) (*Schedule, error) {
+	return t.querySchedule(scheduleQuery+`
+		WHERE schedule.id = $1 AND schedule.acc = $2 AND schedule.client = $3`,
+		id, acc, client)
+}
+
+func (t *dbTrans) LockDueSchedule(now time.Time) (*Schedule, error) {
+	return t.querySchedule(scheduleQuery+`
+		WHERE schedule.next_time <= $1
+		ORDER BY schedule.next_time
+		LIMIT 1
+		FOR UPDATE OF schedule SKIP LOCKED`,
+		now)
+}
+
+const moneyRequestQuery = `
+	SELECT
+		money_request.id,
+		money_request.payee, payee.email, money_request.payee_acc,
+		money_request.payer, payer.email, money_request.payer_acc,
+		money_request.value, acc.currency, money_request.description,
+		money_request.status, money_request."time", money_request.expiry
+	FROM money_request
+		JOIN acc ON acc.id = money_request.payee_acc
+		JOIN client AS payee ON payee.id = money_request.payee
+		JOIN client AS payer ON payer.id = money_request.payer`
+
+func (t *dbTrans) queryMoneyRequests(
+	query string, args ...interface{}) ([]*MoneyRequest, error) {
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*MoneyRequest{}
+	for rows.Next() {
+		record := &MoneyRequest{}
+		var value int64
+		var currency string
+		var description sql.NullString
+		var status int16
+		err := rows.Scan(&record.ID,
+			&record.Payee, &record.PayeeEmail, &record.PayeeAccount,
+			&record.Payer, &record.PayerEmail, &record.PayerAccount,
+			&value, &currency, &description,
+			&status, &record.Time, &record.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		var valueCurrency Currency
+		if valueCurrency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		record.Value = NewMoney(value, valueCurrency)
+		record.Description = description.String
+		if record.Status, err = parseMoneyRequestStatusFromDB(status); err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (t *dbTrans) CreateMoneyRequest(request *MoneyRequest) error {
+	query := `
+		INSERT INTO money_request(
+			id, payee, payee_acc, payer, payer_acc, value, description, status,
+			"time", expiry)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	var description sql.NullString
+	if request.Description != "" {
+		description = sql.NullString{String: request.Description, Valid: true}
+	}
+	result, err := t.tx.Exec(query, request.ID,
+		request.Payee, request.PayeeAccount, request.Payer, request.PayerAccount,
+		request.Value.GetUnits(), description, request.Status,
+		request.Time, request.Expiry)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) GetClientMoneyRequests(
+	client ClientID) ([]*MoneyRequest, error) {
+	return t.queryMoneyRequests(moneyRequestQuery+`
+		WHERE money_request.payee = $1 OR money_request.payer = $1
+		ORDER BY money_request."time" DESC`,
+		client)
+}
+
+func (t *dbTrans) LockClientMoneyRequest(
+	id MoneyRequestID, client ClientID) (*MoneyRequest, error) {
+	result, err := t.queryMoneyRequests(moneyRequestQuery+`
+		WHERE
+			money_request.id = $1
+			AND (money_request.payee = $2 OR money_request.payer = $2)
+		FOR UPDATE OF money_request`,
+		id, client)
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func (t *dbTrans) UpdateMoneyRequestStatus(
+	id MoneyRequestID, status MoneyRequestStatus) error {
+	result, err := t.tx.Exec(
+		"UPDATE money_request SET status = $2 WHERE id = $1", id, status)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+const holdQuery = `
+	SELECT
+		hold.id, hold.acc, hold.client, hold.value, acc.currency, hold.captured,
+		hold.description, hold.status, hold."time", hold.expiry
+	FROM hold
+		JOIN acc ON acc.id = hold.acc`
+
+func (t *dbTrans) queryHolds(
+	query string, args ...interface{}) ([]*Hold, error) {
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*Hold{}
+	for rows.Next() {
+		record := &Hold{}
+		var value int64
+		var currency string
+		var captured sql.NullInt64
+		var description sql.NullString
+		var status int16
+		err := rows.Scan(&record.ID, &record.Account, &record.Client,
+			&value, &currency, &captured,
+			&description, &status, &record.Time, &record.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		var valueCurrency Currency
+		if valueCurrency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		record.Value = NewMoney(value, valueCurrency)
+		if captured.Valid {
+			capturedValue := NewMoney(captured.Int64, valueCurrency)
+			record.Captured = &capturedValue
+		}
+		record.Description = description.String
+		if record.Status, err = parseHoldStatusFromDB(status); err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (t *dbTrans) AuthorizeHold(hold *Hold) (Account, error) {
+	query := `
+		INSERT INTO hold(
+			id, acc, client, value, description, status, "time", expiry)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+	var description sql.NullString
+	if hold.Description != "" {
+		description = sql.NullString{String: hold.Description, Valid: true}
+	}
+	result, err := t.tx.Exec(query, hold.ID, hold.Account, hold.Client,
+		hold.Value.GetUnits(), description, hold.Status, hold.Time, hold.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkAffectedRows(result); err != nil {
+		return nil, err
+	}
+
+	query = `
+		UPDATE acc
+		SET held = held + $2, revision = revision + 1
+		WHERE id = $1 AND currency = $3
+		RETURNING client, currency, balance, overdraft, held, revision, status`
+	var clientID ClientID
+	var currency string
+	var balance int64
+	var overdraft int64
+	var held int64
+	var revision int64
+	var status int16
+	err = t.tx.QueryRow(query, hold.Account, hold.Value.GetUnits(),
+		hold.Value.GetCurrency().GetISO()).
+		Scan(&clientID, &currency, &balance, &overdraft, &held, &revision, &status)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := newAccountFromDB(hold.Account, clientID, currency, balance,
+		overdraft, held, revision, status)
+	if err != nil {
+		return nil, err
+	}
+	// The hold reserves funds for a withdrawal, so it is allowed only if the
+	// account status allows withdrawals.
+	err = acc.GetStatus().CheckPosting(hold.Account, hold.Value.Neg(), false)
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (t *dbTrans) GetAccountHolds(acc AccountID) ([]*Hold, error) {
+	return t.queryHolds(holdQuery+`
+		WHERE hold.acc = $1
+		ORDER BY hold."time" DESC`,
+		acc)
+}
+
+func (t *dbTrans) LockClientHold(
+	id HoldID, acc AccountID, client ClientID) (*Hold, error) {
+	result, err := t.queryHolds(holdQuery+`
+		WHERE hold.id = $1 AND hold.acc = $2 AND hold.client = $3
+		FOR UPDATE OF hold`,
+		id, acc, client)
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+// releaseHolds changes status of authorized holds selected by the condition
+// and releases their values reserved on accounts.
+func (t *dbTrans) releaseHolds(
+	status HoldStatus,
+	captured *int64,
+	condition string,
+	args ...interface{}) (int64, error) {
+	query := `
+		WITH released AS (
+			UPDATE hold SET status = $1, captured = $2
+			WHERE status = $3 AND ` + condition + `
+			RETURNING acc, value),
+		total AS (
+			SELECT acc, SUM(value) AS value, COUNT(*) AS number
+			FROM released
+			GROUP BY acc),
+		updated AS (
+			UPDATE acc SET held = acc.held - total.value, revision = revision + 1
+			FROM total
+			WHERE acc.id = total.acc)
+		SELECT COALESCE(SUM(number), 0) FROM total`
+	var result int64
+	err := t.tx.QueryRow(
+		query,
+		append([]interface{}{status, captured, HoldStatusAuthorized}, args...)...).
+		Scan(&result)
+	return result, err
+}
+
+func (t *dbTrans) CaptureHold(id HoldID, captured Money) (bool, error) {
+	capturedUnits := captured.GetUnits()
+	number, err := t.releaseHolds(
+		HoldStatusCaptured, &capturedUnits, "id = $4", id)
+	return number > 0, err
+}
+
+func (t *dbTrans) VoidHold(id HoldID) (bool, error) {
+	number, err := t.releaseHolds(HoldStatusVoided, nil, "id = $4", id)
+	return number > 0, err
+}
+
+func (t *dbTrans) ExpireHolds(now time.Time) (int64, error) {
+	return t.releaseHolds(HoldStatusExpired, nil, "expiry <= $4", now)
+}
+
+func (t *dbTrans) GetAccountLimits(
+	acc Account) (*SpendingLimits, ClientTier, error) {
+	query := `
+		SELECT client.tier, acc_limit.per_trans, acc_limit.daily, acc_limit.monthly
+		FROM acc
+			JOIN client ON client.id = acc.client
+			LEFT JOIN acc_limit ON acc_limit.acc = acc.id
+		WHERE acc.id = $1`
+	var tier int16
+	var perTrans sql.NullInt64
+	var daily sql.NullInt64
+	var monthly sql.NullInt64
+	err := t.tx.QueryRow(query, acc.GetID()).
+		Scan(&tier, &perTrans, &daily, &monthly)
+	if err != nil {
+		return nil, 0, err
+	}
+	clientTier, err := parseClientTierFromDB(tier)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !perTrans.Valid {
+		return nil, clientTier, nil
+	}
+	currency := acc.GetCurrency()
+	return &SpendingLimits{
+			PerTrans: NewMoney(perTrans.Int64, currency),
+			Daily:    NewMoney(daily.Int64, currency),
+			Monthly:  NewMoney(monthly.Int64, currency)},
+		clientTier,
+		nil
+}
+
+func (t *dbTrans) SetAccountLimits(acc AccountID, limits SpendingLimits) error {
+	query := `
+		INSERT INTO acc_limit(acc, per_trans, daily, monthly, "time")
+		VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (acc) DO UPDATE
+		SET
+			per_trans = EXCLUDED.per_trans,
+			daily = EXCLUDED.daily,
+			monthly = EXCLUDED.monthly,
+			"time" = EXCLUDED."time"`
+	result, err := t.tx.Exec(query, acc, limits.PerTrans.GetUnits(),
+		limits.Daily.GetUnits(), limits.Monthly.GetUnits(), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) GetAccountSpending(
+	acc Account, now time.Time) (Spending, error) {
+	// Refunds and fees are not spending, and failed transactions don't
+	// withdraw.
+	query := `
+		SELECT
+			COALESCE(-SUM(value) FILTER (WHERE "time" >= $2), 0),
+			COALESCE(-SUM(value), 0)
+		FROM trans
+		WHERE
+			acc = $1 AND "time" >= $3 AND value < 0
+			AND refund_of IS NULL AND fee_of IS NULL
+			AND status IN ($4, $5, $6)`
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var daily int64
+	var monthly int64
+	err := t.tx.QueryRow(query, acc.GetID(), dayStart, monthStart,
+		TransStatusSuccess, TransStatusPending, TransStatusProcessing).
+		Scan(&daily, &monthly)
+	if err != nil {
+		return Spending{}, err
+	}
+	return Spending{
+			Daily:   NewMoney(daily, acc.GetCurrency()),
+			Monthly: NewMoney(monthly, acc.GetCurrency())},
+		nil
+}
+
+func (t *dbTrans) CreateAccountLimitsRequest(
+	confirmation ConfirmationID, acc AccountID, limits SpendingLimits) error {
+	query := `
+		INSERT INTO acc_limit_request(
+			confirmation, acc, per_trans, daily, monthly, "time")
+		VALUES($1, $2, $3, $4, $5, $6)`
+	result, err := t.tx.Exec(query, confirmation, acc,
+		limits.PerTrans.GetUnits(), limits.Daily.GetUnits(),
+		limits.Monthly.GetUnits(), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
+}
+
+func (t *dbTrans) LockAccountLimitsRequest(
+	confirmation ConfirmationID, acc Account) (*SpendingLimits, error) {
+	query := `
+		SELECT per_trans, daily, monthly
+		FROM acc_limit_request
+		WHERE confirmation = $1 AND acc = $2
+		FOR UPDATE`
+	var perTrans int64
+	var daily int64
+	var monthly int64
+	switch err := t.tx.QueryRow(query, confirmation, acc.GetID()).
+		Scan(&perTrans, &daily, &monthly); {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	currency := acc.GetCurrency()
+	return &SpendingLimits{
+			PerTrans: NewMoney(perTrans, currency),
+			Daily:    NewMoney(daily, currency),
+			Monthly:  NewMoney(monthly, currency)},
+		nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (t *dbTrans) StoreBankLine(line *BankLine) (bool, error) {
+	query := `
+		INSERT INTO bank_line(
+			id, key, "time", value, currency, reference, remittance, debtor,
+			debtor_acc, status, reason, acc, trans, import_time)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT ON CONSTRAINT "bank-line-key_unq" DO NOTHING`
+	var reason sql.NullString
+	if line.Reason != "" {
+		reason = sql.NullString{String: line.Reason, Valid: true}
+	}
+	var acc nullAccountID
+	if line.Account != nil {
+		acc = nullAccountID{AccountID: *line.Account, Valid: true}
+	}
+	var trans nullTransID
+	if line.Trans != nil {
+		trans = nullTransID{TransID: *line.Trans, Valid: true}
+	}
+	result, err := t.tx.Exec(query, line.ID, line.Key, line.Time,
+		line.Value.GetUnits(), line.Value.GetCurrency().GetISO(), line.Reference,
+		line.Remittance, line.Debtor, line.DebtorAccount, line.Status, reason,
+		acc, trans, line.ImportTime)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (t *dbTrans) CreditBankLine(
+	id BankLineID, acc AccountID, trans TransID) (bool, error) {
+	query := `
+		UPDATE bank_line SET status = $2, acc = $3, trans = $4, reason = NULL
+		WHERE id = $1 AND status = $5`
+	result, err := t.tx.Exec(query, id, BankLineStatusCredited, acc, trans,
+		BankLineStatusReview)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+const bankLineQuery = `
+	SELECT
+		id, key, "time", value, currency, reference, remittance, debtor,
+		debtor_acc, status, reason, acc, trans, import_time
+	FROM bank_line`
+
+func (t *dbTrans) GetBankLinesForReview() ([]*BankLine, error) {
+	return t.queryBankLines(bankLineQuery+`
+		WHERE status = $1
+		ORDER BY "time", id`,
+		BankLineStatusReview)
+}
+
+func (t *dbTrans) LockBankLine(id BankLineID) (*BankLine, error) {
+	result, err := t.queryBankLines(bankLineQuery+`
+		WHERE id = $1
+		FOR UPDATE`,
+		id)
+	if err != nil || len(result) == 0 {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func (t *dbTrans) queryBankLines(
+	query string, args ...interface{}) ([]*BankLine, error) {
+	rows, err := t.tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*BankLine{}
+	for rows.Next() {
+		record := &BankLine{}
+		var value int64
+		var currency string
+		var status int16
+		var reason sql.NullString
+		var acc nullAccountID
+		var trans nullTransID
+		err := rows.Scan(&record.ID, &record.Key, &record.Time, &value,
+			&currency, &record.Reference, &record.Remittance, &record.Debtor,
+			&record.DebtorAccount, &status, &reason, &acc, &trans,
+			&record.ImportTime)
+		if err != nil {
+			return nil, err
+		}
+		var valueCurrency Currency
+		if valueCurrency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		record.Value = NewMoney(value, valueCurrency)
+		if record.Status, err = parseBankLineStatusFromDB(status); err != nil {
+			return nil, err
+		}
+		record.Reason = reason.String
+		if acc.Valid {
+			record.Account = &acc.AccountID
+		}
+		if trans.Valid {
+			record.Trans = &trans.TransID
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func (t *dbTrans) GetAccountReconciliations(
+	after *AccountID, limit int) ([]*AccountReconciliation, error) {
+	// All values are read by one statement, so they are consistent with each
+	// other even if the accounts are changed at the same time.
+	query := `
+		SELECT
+			acc.id, acc.currency, acc.balance, acc.revision,
+			COALESCE(
+				(SELECT SUM(posting.value) FROM posting WHERE posting.acc = acc.id),
+				0),
+			COALESCE(trans.value, 0), COALESCE(trans.number, 0),
+			now() AT TIME ZONE 'UTC'
+		FROM acc
+			LEFT JOIN LATERAL (
+				SELECT SUM(trans.value) AS value, COUNT(*) AS number
+				FROM trans
+				WHERE trans.acc = acc.id AND trans.journal IS NOT NULL
+			) trans ON TRUE
+		WHERE $1::uuid IS NULL OR acc.id > $1
+		ORDER BY acc.id
+		LIMIT $2`
+	var afterID nullAccountID
+	if after != nil {
+		afterID = nullAccountID{AccountID: *after, Valid: true}
+	}
+	rows, err := t.tx.Query(query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []*AccountReconciliation{}
+	for rows.Next() {
+		record := &AccountReconciliation{}
+		var currency string
+		var balance int64
+		var posted int64
+		var transValue int64
+		err := rows.Scan(&record.Account, &currency, &balance, &record.Revision,
+			&posted, &transValue, &record.NumberOfTrans, &record.Time)
+		if err != nil {
+			return nil, err
+		}
+		var accCurrency Currency
+		if accCurrency, err = newCurrencyFromDB(currency); err != nil {
+			return nil, err
+		}
+		record.Balance = NewMoney(balance, accCurrency)
+		record.Posted = NewMoney(posted, accCurrency)
+		record.TransValue = NewMoney(transValue, accCurrency)
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (t *dbTrans) StoreReconcileDiscrepancy(
+	run ReconcileRunID, acc *AccountReconciliation, reason string) error {
+	query := `
+		INSERT INTO reconcile_report(
+			run, acc, "time", currency, balance, posted, trans_value, revision,
+			trans_number, reason)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	result, err := t.tx.Exec(query, run, acc.Account, acc.Time,
+		acc.Balance.GetCurrency().GetISO(), acc.Balance.GetUnits(),
+		acc.Posted.GetUnits(), acc.TransValue.GetUnits(), acc.Revision,
+		acc.NumberOfTrans, reason)
+	if err != nil {
+		return err
+	}
+	return t.checkAffectedRows(result)
 }
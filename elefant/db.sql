@@ -30,6 +30,45 @@ CREATE EXTENSION IF NOT EXISTS pgcrypto WITH SCHEMA public;
 COMMENT ON EXTENSION pgcrypto IS 'cryptographic functions';
 
 
+--
+-- Name: check_journal_balance(); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.check_journal_balance() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM public.posting
+        WHERE posting.journal = NEW.journal
+        GROUP BY posting.currency
+        HAVING SUM(posting.value) <> 0) THEN
+        RAISE EXCEPTION 'journal % is not balanced', NEW.journal
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$;
+
+
+--
+-- Name: set_trans_search(); Type: FUNCTION; Schema: public; Owner: -
+--
+
+CREATE FUNCTION public.set_trans_search() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    NEW.search := to_tsvector('simple',
+        COALESCE(NEW.method_arg ->> 'e', '') || ' ' ||
+        COALESCE(NEW.method_arg ->> 'b', '') || ' ' ||
+        COALESCE(NEW.method_arg ->> 'd', '') || ' ' ||
+        COALESCE(NEW.status_reason, ''));
+    RETURN NEW;
+END;
+$$;
+
+
 SET default_tablespace = '';
 
 --
@@ -40,9 +79,48 @@ CREATE TABLE public.acc (
     id uuid NOT NULL,
     client uuid NOT NULL,
     "time" timestamp without time zone NOT NULL,
-    balance double precision NOT NULL,
+    balance bigint NOT NULL,
     currency character(3) NOT NULL,
-    revision bigint NOT NULL
+    revision bigint NOT NULL,
+    overdraft bigint DEFAULT 0 NOT NULL,
+    overdraft_accrued date,
+    status smallint DEFAULT 1 NOT NULL,
+    held bigint DEFAULT 0 NOT NULL,
+    interest_pending numeric(30,12) DEFAULT 0 NOT NULL,
+    interest_accrued date,
+    CONSTRAINT "acc-held_chk" CHECK ((held >= 0)),
+    CONSTRAINT "acc-interest-pending_chk" CHECK ((interest_pending >= (0)::numeric)),
+    CONSTRAINT "acc-overdraft_chk" CHECK ((overdraft >= 0)),
+    CONSTRAINT "acc-status_chk" CHECK (((status >= 1) AND (status <= 4)))
+);
+
+
+--
+-- Name: acc_limit; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.acc_limit (
+    acc uuid NOT NULL,
+    per_trans bigint NOT NULL,
+    daily bigint NOT NULL,
+    monthly bigint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    CONSTRAINT "acc-limit-value_chk" CHECK (((per_trans > 0) AND (per_trans <= daily) AND (daily <= monthly)))
+);
+
+
+--
+-- Name: acc_limit_request; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.acc_limit_request (
+    confirmation uuid NOT NULL,
+    acc uuid NOT NULL,
+    per_trans bigint NOT NULL,
+    daily bigint NOT NULL,
+    monthly bigint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    CONSTRAINT "acc-limit-request-value_chk" CHECK (((per_trans > 0) AND (per_trans <= daily) AND (daily <= monthly)))
 );
 
 
@@ -81,6 +159,31 @@ CREATE SEQUENCE public."auth-token_id_seq"
 ALTER SEQUENCE public."auth-token_id_seq" OWNED BY public.auth_token.id;
 
 
+--
+-- Name: bank_line; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.bank_line (
+    id uuid NOT NULL,
+    key character(64) NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    value bigint NOT NULL,
+    currency character(3) NOT NULL,
+    reference character varying(255) NOT NULL,
+    remittance text NOT NULL,
+    debtor character varying(255) NOT NULL,
+    debtor_acc character varying(255) NOT NULL,
+    status smallint NOT NULL,
+    reason character varying(255),
+    acc uuid,
+    trans uuid,
+    import_time timestamp without time zone NOT NULL,
+    CONSTRAINT "bank-line-credited_chk" CHECK (((status <> 2) OR ((acc IS NOT NULL) AND (trans IS NOT NULL)))),
+    CONSTRAINT "bank-line-status_chk" CHECK (((status >= 1) AND (status <= 2))),
+    CONSTRAINT "bank-line-value_chk" CHECK ((value > 0))
+);
+
+
 --
 -- Name: client; Type: TABLE; Schema: public; Owner: -
 --
@@ -92,7 +195,8 @@ CREATE TABLE public.client (
     "time" timestamp without time zone NOT NULL,
     request json,
     confirmed boolean NOT NULL,
-    name text NOT NULL
+    name text NOT NULL,
+    tier smallint DEFAULT 1 NOT NULL
 );
 
 
@@ -108,6 +212,103 @@ CREATE TABLE public.client_confirm (
 );
 
 
+--
+-- Name: exchange_quote; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.exchange_quote (
+    id uuid NOT NULL,
+    client uuid NOT NULL,
+    acc_from uuid NOT NULL,
+    acc_to uuid NOT NULL,
+    value bigint NOT NULL,
+    currency character(3) NOT NULL,
+    counter_value bigint NOT NULL,
+    counter_currency character(3) NOT NULL,
+    rate numeric(20,10) NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    expiry timestamp without time zone NOT NULL
+);
+
+
+--
+-- Name: fee_rule; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.fee_rule (
+    id uuid NOT NULL,
+    method smallint NOT NULL,
+    direction smallint NOT NULL,
+    currency character(3) NOT NULL,
+    min_value bigint DEFAULT 0 NOT NULL,
+    max_value bigint,
+    fixed bigint DEFAULT 0 NOT NULL,
+    percent numeric(9,4) DEFAULT 0 NOT NULL,
+    min_fee bigint DEFAULT 0 NOT NULL,
+    max_fee bigint,
+    CONSTRAINT "fee-rule-direction_chk" CHECK (((direction >= 1) AND (direction <= 2))),
+    CONSTRAINT "fee-rule-fee_chk" CHECK (((fixed >= 0) AND (percent >= (0)::numeric) AND (min_fee >= 0) AND ((max_fee IS NULL) OR (max_fee >= min_fee)))),
+    CONSTRAINT "fee-rule-value_chk" CHECK (((min_value >= 0) AND ((max_value IS NULL) OR (max_value > min_value))))
+);
+
+
+--
+-- Name: hold; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.hold (
+    id uuid NOT NULL,
+    acc uuid NOT NULL,
+    client uuid NOT NULL,
+    value bigint NOT NULL,
+    captured bigint,
+    description character varying(255),
+    status smallint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    expiry timestamp without time zone NOT NULL,
+    CONSTRAINT "hold-captured_chk" CHECK (((captured IS NULL) OR ((captured > 0) AND (captured <= value)))),
+    CONSTRAINT "hold-status_chk" CHECK (((status >= 1) AND (status <= 4))),
+    CONSTRAINT "hold-value_chk" CHECK ((value > 0))
+);
+
+
+--
+-- Name: idempotency_key; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.idempotency_key (
+    client uuid NOT NULL,
+    key character varying(255) NOT NULL,
+    request_hash character(64) NOT NULL,
+    status_code smallint,
+    body text NOT NULL,
+    "time" timestamp without time zone NOT NULL
+);
+
+
+--
+-- Name: interest_rate; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.interest_rate (
+    currency character(3) NOT NULL,
+    tier smallint NOT NULL,
+    percent numeric(9,4) NOT NULL,
+    CONSTRAINT "interest-rate-percent_chk" CHECK ((percent >= (0)::numeric)),
+    CONSTRAINT "interest-rate-tier_chk" CHECK (((tier >= 1) AND (tier <= 3)))
+);
+
+
+--
+-- Name: journal; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.journal (
+    id uuid NOT NULL,
+    "time" timestamp without time zone NOT NULL
+);
+
+
 --
 -- Name: method; Type: TABLE; Schema: public; Owner: -
 --
@@ -124,6 +325,108 @@ CREATE TABLE public.method (
 );
 
 
+--
+-- Name: money_request; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.money_request (
+    id uuid NOT NULL,
+    payee uuid NOT NULL,
+    payee_acc uuid NOT NULL,
+    payer uuid NOT NULL,
+    payer_acc uuid NOT NULL,
+    value bigint NOT NULL,
+    description character varying(255),
+    status smallint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    expiry timestamp without time zone NOT NULL,
+    CONSTRAINT "money-request-status_chk" CHECK (((status >= 1) AND (status <= 4))),
+    CONSTRAINT "money-request-value_chk" CHECK ((value > 0))
+);
+
+
+--
+-- Name: posting; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.posting (
+    id uuid NOT NULL,
+    journal uuid NOT NULL,
+    acc uuid,
+    system_acc uuid,
+    value bigint NOT NULL,
+    currency character(3) NOT NULL,
+    CONSTRAINT "posting-target_chk" CHECK (((acc IS NULL) <> (system_acc IS NULL)))
+);
+
+
+--
+-- Name: reconcile_report; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.reconcile_report (
+    run uuid NOT NULL,
+    acc uuid NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    currency character(3) NOT NULL,
+    balance bigint NOT NULL,
+    posted bigint NOT NULL,
+    trans_value bigint NOT NULL,
+    revision bigint NOT NULL,
+    trans_number bigint NOT NULL,
+    reason text NOT NULL
+);
+
+
+--
+-- Name: schedule; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.schedule (
+    id uuid NOT NULL,
+    client uuid NOT NULL,
+    acc uuid NOT NULL,
+    target smallint NOT NULL,
+    receiver uuid,
+    bill text,
+    tax_authority uuid,
+    value bigint NOT NULL,
+    recurrence character varying(255),
+    next_time timestamp without time zone,
+    last_run_time timestamp without time zone,
+    "time" timestamp without time zone NOT NULL,
+    CONSTRAINT "schedule-target_chk" CHECK ((((target = 1) AND (receiver IS NOT NULL) AND (bill IS NULL) AND (tax_authority IS NULL)) OR ((target = 2) AND (receiver IS NULL) AND (bill IS NOT NULL)))),
+    CONSTRAINT "schedule-value_chk" CHECK ((value > 0))
+);
+
+
+--
+-- Name: system_acc; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.system_acc (
+    id uuid NOT NULL,
+    type smallint NOT NULL,
+    currency character(3) NOT NULL,
+    balance bigint NOT NULL,
+    revision bigint NOT NULL
+);
+
+
+--
+-- Name: tax_authority; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.tax_authority (
+    id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    currency character(3) NOT NULL,
+    bill_format character varying(255) NOT NULL,
+    check_digit smallint,
+    system_acc uuid NOT NULL
+);
+
+
 --
 -- Name: trans; Type: TABLE; Schema: public; Owner: -
 --
@@ -132,14 +435,54 @@ CREATE TABLE public.trans (
     id uuid NOT NULL,
     method uuid NOT NULL,
     acc uuid NOT NULL,
-    value double precision NOT NULL,
+    value bigint NOT NULL,
     "time" timestamp without time zone NOT NULL,
     status smallint NOT NULL,
     status_reason text,
-    method_arg json
+    method_arg json,
+    exchange_rate numeric(20,10),
+    counter_value bigint,
+    counter_currency character(3),
+    journal uuid,
+    refund_of uuid,
+    fee_of uuid,
+    search tsvector,
+    CONSTRAINT "trans-exchange_chk" CHECK ((((exchange_rate IS NULL) AND (counter_value IS NULL) AND (counter_currency IS NULL)) OR ((exchange_rate IS NOT NULL) AND (counter_value IS NOT NULL) AND (counter_currency IS NOT NULL))))
+);
+
+
+--
+-- Name: trans_status; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.trans_status (
+    id bigint NOT NULL,
+    trans uuid NOT NULL,
+    status smallint NOT NULL,
+    status_reason text,
+    "time" timestamp without time zone NOT NULL
 );
 
 
+--
+-- Name: trans-status_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public."trans-status_id_seq"
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: trans-status_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public."trans-status_id_seq" OWNED BY public.trans_status.id;
+
+
 --
 -- Name: auth_token id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -148,11 +491,10 @@ ALTER TABLE ONLY public.auth_token ALTER COLUMN id SET DEFAULT nextval('public."
 
 
 --
--- Name: acc acc-currency_unq; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: trans_status id; Type: DEFAULT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.acc
-    ADD CONSTRAINT "acc-currency_unq" UNIQUE (client, currency);
+ALTER TABLE ONLY public.trans_status ALTER COLUMN id SET DEFAULT nextval('public."trans-status_id_seq"'::regclass);
 
 
 --
@@ -163,6 +505,22 @@ ALTER TABLE ONLY public.acc
     ADD CONSTRAINT account_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: acc_limit acc-limit_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.acc_limit
+    ADD CONSTRAINT "acc-limit_pkey" PRIMARY KEY (acc);
+
+
+--
+-- Name: acc_limit_request acc-limit-request_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.acc_limit_request
+    ADD CONSTRAINT "acc-limit-request_pkey" PRIMARY KEY (confirmation);
+
+
 --
 -- Name: auth_token auth-token-client_unq; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -203,6 +561,22 @@ ALTER TABLE ONLY public.auth_token
     ADD CONSTRAINT "auth-token_unq" UNIQUE (token);
 
 
+--
+-- Name: bank_line bank-line-key_unq; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line-key_unq" UNIQUE (key);
+
+
+--
+-- Name: bank_line bank-line_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line_pkey" PRIMARY KEY (id);
+
+
 --
 -- Name: client client-email_unq; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -235,6 +609,54 @@ ALTER TABLE ONLY public.client_confirm
     ADD CONSTRAINT confirmation_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: exchange_quote exchange-quote_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.exchange_quote
+    ADD CONSTRAINT "exchange-quote_pkey" PRIMARY KEY (id);
+
+
+--
+-- Name: fee_rule fee-rule_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.fee_rule
+    ADD CONSTRAINT "fee-rule_pkey" PRIMARY KEY (id);
+
+
+--
+-- Name: hold hold_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.hold
+    ADD CONSTRAINT hold_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: idempotency_key idempotency-key_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.idempotency_key
+    ADD CONSTRAINT "idempotency-key_pkey" PRIMARY KEY (client, key);
+
+
+--
+-- Name: interest_rate interest-rate_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.interest_rate
+    ADD CONSTRAINT "interest-rate_pkey" PRIMARY KEY (currency, tier);
+
+
+--
+-- Name: journal journal_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.journal
+    ADD CONSTRAINT journal_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: method method-unique-unq; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -252,108 +674,593 @@ ALTER TABLE ONLY public.method
 
 
 --
--- Name: trans trans_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: money_request money-request_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.trans
-    ADD CONSTRAINT trans_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request_pkey" PRIMARY KEY (id);
 
 
 --
--- Name: acc-client-rev_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: posting posting_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE INDEX "acc-client-rev_idx" ON public.acc USING btree (client, revision);
+ALTER TABLE ONLY public.posting
+    ADD CONSTRAINT posting_pkey PRIMARY KEY (id);
 
 
 --
--- Name: acc-client_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: reconcile_report reconcile-report_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE UNIQUE INDEX "acc-client_idx" ON public.acc USING btree (client, id);
+ALTER TABLE ONLY public.reconcile_report
+    ADD CONSTRAINT "reconcile-report_pkey" PRIMARY KEY (run, acc);
 
 
 --
--- Name: client-confirmed-id_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: schedule schedule_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE INDEX "client-confirmed-id_idx" ON public.client USING btree (confirmed, id);
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT schedule_pkey PRIMARY KEY (id);
 
 
 --
--- Name: client-email-confirmed_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: system_acc system-acc_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE INDEX "client-email-confirmed_idx" ON public.client USING btree (confirmed, email);
+ALTER TABLE ONLY public.system_acc
+    ADD CONSTRAINT "system-acc_pkey" PRIMARY KEY (id);
 
 
 --
--- Name: confirmation-time_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: tax_authority tax-authority-name-currency_unq; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE INDEX "confirmation-time_idx" ON public.client_confirm USING btree ("time");
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority-name-currency_unq" UNIQUE (name, currency);
 
 
 --
--- Name: method-usage_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: tax_authority tax-authority-system-acc_unq; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE INDEX "method-usage_idx" ON public.method USING btree (usage);
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority-system-acc_unq" UNIQUE (system_acc);
 
 
 --
--- Name: trans-acc_idx; Type: INDEX; Schema: public; Owner: -
+-- Name: tax_authority tax-authority_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE INDEX "trans-acc_idx" ON public.trans USING btree (acc, "time");
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority_pkey" PRIMARY KEY (id);
 
 
 --
--- Name: acc acc-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: trans trans_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.acc
-    ADD CONSTRAINT "acc-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT trans_pkey PRIMARY KEY (id);
 
 
 --
--- Name: auth_token auth-token-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: trans_status trans-status_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.auth_token
-    ADD CONSTRAINT "auth-token-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE ONLY public.trans_status
+    ADD CONSTRAINT "trans-status_pkey" PRIMARY KEY (id);
 
 
 --
--- Name: client_confirm confirmation-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: acc-client-currency_idx; Type: INDEX; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.client_confirm
-    ADD CONSTRAINT "confirmation-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+CREATE UNIQUE INDEX "acc-client-currency_idx" ON public.acc USING btree (client, currency) WHERE (status <> 4);
 
 
 --
--- Name: method source-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: acc-client-rev_idx; Type: INDEX; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.method
-    ADD CONSTRAINT "source-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+CREATE INDEX "acc-client-rev_idx" ON public.acc USING btree (client, revision);
 
 
 --
--- Name: trans trans-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: acc-client_idx; Type: INDEX; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.trans
-    ADD CONSTRAINT "trans-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+CREATE UNIQUE INDEX "acc-client_idx" ON public.acc USING btree (client, id);
 
 
 --
--- Name: trans trans-method_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: acc-interest_idx; Type: INDEX; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.trans
-    ADD CONSTRAINT "trans-method_ref" FOREIGN KEY (method) REFERENCES public.method(id) ON DELETE CASCADE;
+CREATE INDEX "acc-interest_idx" ON public.acc USING btree (interest_accrued) WHERE (status <> 4);
+
+
+--
+-- Name: acc-overdraft_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "acc-overdraft_idx" ON public.acc USING btree (overdraft_accrued) WHERE (status <> 4);
+
+
+--
+-- Name: bank-line-review_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "bank-line-review_idx" ON public.bank_line USING btree ("time") WHERE (status = 1);
+
+
+--
+-- Name: client-confirmed-id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "client-confirmed-id_idx" ON public.client USING btree (confirmed, id);
+
+
+--
+-- Name: client-email-confirmed_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "client-email-confirmed_idx" ON public.client USING btree (confirmed, email);
+
+
+--
+-- Name: confirmation-time_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "confirmation-time_idx" ON public.client_confirm USING btree ("time");
+
+
+--
+-- Name: exchange-quote-expiry_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "exchange-quote-expiry_idx" ON public.exchange_quote USING btree (expiry);
+
+
+--
+-- Name: fee-rule-operation_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "fee-rule-operation_idx" ON public.fee_rule USING btree (method, direction, currency, min_value);
+
+
+--
+-- Name: hold-acc_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "hold-acc_idx" ON public.hold USING btree (acc, "time");
+
+
+--
+-- Name: hold-expiry_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "hold-expiry_idx" ON public.hold USING btree (expiry) WHERE (status = 1);
+
+
+--
+-- Name: idempotency-key-time_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "idempotency-key-time_idx" ON public.idempotency_key USING btree ("time");
+
+
+--
+-- Name: method-type_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "method-type_idx" ON public.method USING btree (type);
+
+
+--
+-- Name: method-usage_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "method-usage_idx" ON public.method USING btree (usage);
+
+
+--
+-- Name: money-request-payee_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "money-request-payee_idx" ON public.money_request USING btree (payee);
+
+
+--
+-- Name: money-request-payer_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "money-request-payer_idx" ON public.money_request USING btree (payer);
+
+
+--
+-- Name: posting-acc_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "posting-acc_idx" ON public.posting USING btree (acc);
+
+
+--
+-- Name: posting-journal_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "posting-journal_idx" ON public.posting USING btree (journal);
+
+
+--
+-- Name: reconcile-report-acc_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "reconcile-report-acc_idx" ON public.reconcile_report USING btree (acc, "time");
+
+
+--
+-- Name: reconcile-report-time_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "reconcile-report-time_idx" ON public.reconcile_report USING btree ("time");
+
+
+--
+-- Name: schedule-acc_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "schedule-acc_idx" ON public.schedule USING btree (acc);
+
+
+--
+-- Name: schedule-next-time_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "schedule-next-time_idx" ON public.schedule USING btree (next_time) WHERE (next_time IS NOT NULL);
+
+
+--
+-- Name: system-acc-type-currency_unq; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX "system-acc-type-currency_unq" ON public.system_acc USING btree (type, currency) WHERE (type <> 2);
+
+
+--
+-- Name: trans-acc_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-acc_idx" ON public.trans USING btree (acc, "time", id);
+
+
+--
+-- Name: trans-acc-status_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-acc-status_idx" ON public.trans USING btree (acc, status, "time", id);
+
+
+--
+-- Name: trans-fee-of_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-fee-of_idx" ON public.trans USING btree (fee_of);
+
+
+--
+-- Name: trans-journal_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-journal_idx" ON public.trans USING btree (journal);
+
+
+--
+-- Name: trans-refund-of_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-refund-of_idx" ON public.trans USING btree (refund_of);
+
+
+--
+-- Name: trans-search_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-search_idx" ON public.trans USING gin (search);
+
+
+--
+-- Name: trans-status-trans_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX "trans-status-trans_idx" ON public.trans_status USING btree (trans, "time", id);
+
+
+--
+-- Name: posting posting-balance_chk; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE CONSTRAINT TRIGGER "posting-balance_chk" AFTER INSERT OR UPDATE ON public.posting DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE public.check_journal_balance();
+
+
+--
+-- Name: trans trans-search_set; Type: TRIGGER; Schema: public; Owner: -
+--
+
+CREATE TRIGGER "trans-search_set" BEFORE INSERT OR UPDATE OF method_arg, status_reason ON public.trans FOR EACH ROW EXECUTE PROCEDURE public.set_trans_search();
+
+
+--
+-- Name: acc acc-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.acc
+    ADD CONSTRAINT "acc-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: acc_limit acc-limit-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.acc_limit
+    ADD CONSTRAINT "acc-limit-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: acc_limit_request acc-limit-request-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.acc_limit_request
+    ADD CONSTRAINT "acc-limit-request-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: acc_limit_request acc-limit-request-confirmation_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.acc_limit_request
+    ADD CONSTRAINT "acc-limit-request-confirmation_ref" FOREIGN KEY (confirmation) REFERENCES public.client_confirm(id) ON DELETE CASCADE;
+
+
+--
+-- Name: auth_token auth-token-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.auth_token
+    ADD CONSTRAINT "auth-token-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE NOT VALID;
+
+
+--
+-- Name: bank_line bank-line-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id);
+
+
+--
+-- Name: bank_line bank-line-trans_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line-trans_ref" FOREIGN KEY (trans) REFERENCES public.trans(id);
+
+
+--
+-- Name: client_confirm confirmation-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.client_confirm
+    ADD CONSTRAINT "confirmation-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: exchange_quote exchange-quote-acc-from_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.exchange_quote
+    ADD CONSTRAINT "exchange-quote-acc-from_ref" FOREIGN KEY (acc_from) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: exchange_quote exchange-quote-acc-to_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.exchange_quote
+    ADD CONSTRAINT "exchange-quote-acc-to_ref" FOREIGN KEY (acc_to) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: exchange_quote exchange-quote-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.exchange_quote
+    ADD CONSTRAINT "exchange-quote-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: hold hold-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.hold
+    ADD CONSTRAINT "hold-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: hold hold-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.hold
+    ADD CONSTRAINT "hold-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: idempotency_key idempotency-key-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.idempotency_key
+    ADD CONSTRAINT "idempotency-key-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: method source-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.method
+    ADD CONSTRAINT "source-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: money_request money-request-payee-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request-payee-acc_ref" FOREIGN KEY (payee_acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: money_request money-request-payee_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request-payee_ref" FOREIGN KEY (payee) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: money_request money-request-payer-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request-payer-acc_ref" FOREIGN KEY (payer_acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: money_request money-request-payer_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request-payer_ref" FOREIGN KEY (payer) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: posting posting-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.posting
+    ADD CONSTRAINT "posting-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: posting posting-journal_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.posting
+    ADD CONSTRAINT "posting-journal_ref" FOREIGN KEY (journal) REFERENCES public.journal(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: posting posting-system-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.posting
+    ADD CONSTRAINT "posting-system-acc_ref" FOREIGN KEY (system_acc) REFERENCES public.system_acc(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: reconcile_report reconcile-report-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.reconcile_report
+    ADD CONSTRAINT "reconcile-report-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: schedule schedule-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT "schedule-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: schedule schedule-client_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT "schedule-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+
+--
+-- Name: schedule schedule-receiver_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT "schedule-receiver_ref" FOREIGN KEY (receiver) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+
+--
+-- Name: schedule schedule-tax-authority_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT "schedule-tax-authority_ref" FOREIGN KEY (tax_authority) REFERENCES public.tax_authority(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: tax_authority tax-authority-system-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority-system-acc_ref" FOREIGN KEY (system_acc) REFERENCES public.system_acc(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: trans trans-acc_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: trans trans-fee-of_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-fee-of_ref" FOREIGN KEY (fee_of) REFERENCES public.trans(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: trans trans-journal_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-journal_ref" FOREIGN KEY (journal) REFERENCES public.journal(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: trans trans-method_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-method_ref" FOREIGN KEY (method) REFERENCES public.method(id) ON DELETE CASCADE;
+
+
+--
+-- Name: trans trans-refund-of_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-refund-of_ref" FOREIGN KEY (refund_of) REFERENCES public.trans(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: trans_status trans-status-trans_ref; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.trans_status
+    ADD CONSTRAINT "trans-status-trans_ref" FOREIGN KEY (trans) REFERENCES public.trans(id) ON DELETE CASCADE;
 
 
 --
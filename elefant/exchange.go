@@ -0,0 +1,218 @@
+package elefant
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// exchangeRatePrecision is a number of decimal digits in the applied exchange
+// rate, it also is the scale of the rate in the DB.
+const exchangeRatePrecision = 10
+
+// ExchangeRate describes a rate to convert money from one currency into
+// another.
+type ExchangeRate struct {
+	From Currency
+	To   Currency
+	// Rate is an amount in To-currency for one unit in From-currency.
+	Rate *big.Rat
+	// Time is a rate publication time.
+	Time time.Time
+}
+
+func newExchangeRate(
+	from, to Currency, rate *big.Rat, time time.Time) *ExchangeRate {
+	return &ExchangeRate{
+		From: from,
+		To:   to,
+		Rate: normalizeExchangeRate(rate),
+		Time: time}
+}
+
+func normalizeExchangeRate(rate *big.Rat) *big.Rat {
+	result, _ := new(big.Rat).SetString(rate.FloatString(exchangeRatePrecision))
+	return result
+}
+
+// Convert converts money in From-currency into To-currency.
+func (rate *ExchangeRate) Convert(value Money) Money {
+	return convertMoney(value, rate.Rate, rate.To)
+}
+
+func convertMoney(value Money, rate *big.Rat, to Currency) Money {
+	return NewMoneyFromRat(
+		new(big.Rat).Mul(value.GetRat(), rate), to, RoundHalfEven)
+}
+
+// FormatExchangeRate formats exchange rate as a decimal string.
+func FormatExchangeRate(rate *big.Rat) string {
+	return rate.FloatString(exchangeRatePrecision)
+}
+
+func parseExchangeRate(source string) (*big.Rat, error) {
+	result, isValid := new(big.Rat).SetString(source)
+	if !isValid || result.Sign() <= 0 {
+		return nil, fmt.Errorf(`failed to parse exchange rate "%s"`, source)
+	}
+	return result, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExchangeRateProvider describes exchange rate source.
+type ExchangeRateProvider interface {
+	// GetRate returns actual rate to convert money from one currency into
+	// another. Returns ExchangeRateStaleError if the source doesn't have
+	// actual rates.
+	GetRate(from, to Currency) (*ExchangeRate, error)
+}
+
+// ExchangeRateStaleError is returned if the latest known rates are published
+// earlier than ExchangeRateMaxAge ago, so money is not converted by them.
+type ExchangeRateStaleError struct {
+	// Time is the latest known rates publication time.
+	Time time.Time
+}
+
+func (err *ExchangeRateStaleError) Error() string {
+	return fmt.Sprintf("exchange rates are published at %s and are stale",
+		err.Time.Format("2006-01-02"))
+}
+
+var exchangeRatesFile = "eurofxref-daily.xml" // set by builder
+
+// NewExchangeRateProvider creates exchange rate provider for the product.
+func NewExchangeRateProvider() (ExchangeRateProvider, error) {
+	return NewECBExchangeRateProvider(exchangeRatesFile)
+}
+
+// NewECBExchangeRateProvider creates exchange rate provider which reads rates
+// from the file in the ECB daily reference rates XML feed format
+// (eurofxref-daily.xml). The rates for the latest day in the file are used,
+// cross rates are calculated through EUR. The file is not refreshed by
+// the provider, it's downloaded by the builder, so rates become stale if
+// the product is not deployed again.
+func NewECBExchangeRateProvider(path string) (ExchangeRateProvider, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read exchange rates file "%s": "%v"`,
+			path, err)
+	}
+	result, err := parseECBExchangeRates(source)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse exchange rates file "%s": "%v"`,
+			path, err)
+	}
+	return result, nil
+}
+
+type ecbExchangeRateFeed struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+type ecbExchangeRateProvider struct {
+	time  time.Time
+	rates map[string]*big.Rat
+}
+
+func parseECBExchangeRates(source []byte) (*ecbExchangeRateProvider, error) {
+	feed := ecbExchangeRateFeed{}
+	if err := xml.Unmarshal(source, &feed); err != nil {
+		return nil, err
+	}
+
+	base, err := NewCurrency("EUR")
+	if err != nil {
+		return nil, err
+	}
+	result := &ecbExchangeRateProvider{}
+
+	hasDay := false
+	for _, day := range feed.Days {
+		dayTime, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse day "%s": "%v"`, day.Time, err)
+		}
+		if hasDay && !dayTime.After(result.time) {
+			continue
+		}
+		hasDay = true
+		result.time = dayTime
+		result.rates = map[string]*big.Rat{base.GetISO(): big.NewRat(1, 1)}
+		for _, rate := range day.Rates {
+			currency, err := NewCurrency(rate.Currency)
+			if err != nil {
+				// The feed has currencies which are not supported.
+				continue
+			}
+			if result.rates[currency.GetISO()], err = parseExchangeRate(
+				rate.Rate); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !hasDay {
+		return nil, fmt.Errorf("feed does not have rates")
+	}
+
+	return result, nil
+}
+
+func (provider *ecbExchangeRateProvider) GetRate(
+	from, to Currency) (*ExchangeRate, error) {
+	if time.Now().UTC().Sub(provider.time) > ExchangeRateMaxAge {
+		return nil, &ExchangeRateStaleError{Time: provider.time}
+	}
+	fromRate, hasFrom := provider.rates[from.GetISO()]
+	if !hasFrom {
+		return nil, fmt.Errorf(`there is no exchange rate for "%s"`,
+			from.GetISO())
+	}
+	toRate, hasTo := provider.rates[to.GetISO()]
+	if !hasTo {
+		return nil, fmt.Errorf(`there is no exchange rate for "%s"`, to.GetISO())
+	}
+	return newExchangeRate(
+			from, to, new(big.Rat).Quo(toRate, fromRate), provider.time),
+		nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExchangeQuoteID is an exchange quote unique ID.
+type ExchangeQuoteID = uuid.UUID
+
+func newExchangeQuoteID() ExchangeQuoteID { return uuid.New() }
+
+// ParseExchangeQuoteID parses exchange quote ID in string.
+func ParseExchangeQuoteID(source string) (ExchangeQuoteID, error) {
+	return uuid.Parse(source)
+}
+
+// ExchangeQuote describes exchange rate locked for a payment between accounts
+// for a short time.
+type ExchangeQuote struct {
+	ID           ExchangeQuoteID
+	Client       ClientID
+	From         AccountID
+	To           AccountID
+	Value        Money
+	CounterValue Money
+	Rate         *big.Rat
+	Expiry       time.Time
+}
+
+////////////////////////////////////////////////////////////////////////////////
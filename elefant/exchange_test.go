@@ -0,0 +1,164 @@
+package elefant
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func newExchangeTestCurrency(t *testing.T, iso string) Currency {
+	result, err := NewCurrency(iso)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestECBExchangeRateProviderFile(t *testing.T) {
+	provider, err := NewECBExchangeRateProvider("testdata/eurofxref-daily.xml")
+	if err != nil {
+		t.Fatalf(`Failed to read rates: "%v".`, err)
+	}
+	rates := provider.(*ecbExchangeRateProvider)
+	expectedTime := time.Date(2020, 6, 5, 0, 0, 0, 0, time.UTC)
+	if !rates.time.Equal(expectedTime) {
+		t.Errorf("Rates time is %s, but expected %s.", rates.time, expectedTime)
+	}
+	for _, test := range []struct {
+		currency string
+		expected string
+	}{
+		{"EUR", "1"},
+		{"USD", "1.1330"},
+		{"JPY", "123.83"},
+		{"GBP", "0.89448"},
+		{"ZAR", "19.0096"},
+		{"HRK", ""},
+	} {
+		rate, has := rates.rates[test.currency]
+		if test.expected == "" {
+			if has {
+				t.Errorf(`Withdrawn currency "%s" has rate.`, test.currency)
+			}
+			continue
+		}
+		if !has {
+			t.Errorf(`There is no rate for "%s".`, test.currency)
+			continue
+		}
+		expected, _ := new(big.Rat).SetString(test.expected)
+		if rate.Cmp(expected) != 0 {
+			t.Errorf(`Rate for "%s" is %s, but expected %s.`,
+				test.currency, FormatExchangeRate(rate), test.expected)
+		}
+	}
+	// The file has 32 currencies, BGN and HRK are withdrawn, and EUR is
+	// the base.
+	if len(rates.rates) != 31 {
+		t.Errorf("File has %d rates.", len(rates.rates))
+	}
+
+	// The rates in the file are stale now.
+	_, err = provider.GetRate(
+		newExchangeTestCurrency(t, "EUR"), newExchangeTestCurrency(t, "USD"))
+	staleErr, isStale := err.(*ExchangeRateStaleError)
+	if !isStale {
+		t.Fatalf(`Stale rates are returned with error "%v".`, err)
+	}
+	if !staleErr.Time.Equal(expectedTime) {
+		t.Errorf("Stale rates time is %s, but expected %s.",
+			staleErr.Time, expectedTime)
+	}
+
+	if _, err := NewECBExchangeRateProvider("testdata/none.xml"); err == nil {
+		t.Errorf("Not existing file is read.")
+	}
+}
+
+func TestECBExchangeRateProviderCrossRate(t *testing.T) {
+	provider, err := NewECBExchangeRateProvider("testdata/eurofxref-daily.xml")
+	if err != nil {
+		t.Fatalf(`Failed to read rates: "%v".`, err)
+	}
+	rates := provider.(*ecbExchangeRateProvider)
+	rates.time = time.Now().UTC()
+
+	usd := newExchangeTestCurrency(t, "USD")
+	jpy := newExchangeTestCurrency(t, "JPY")
+	rate, err := provider.GetRate(usd, jpy)
+	if err != nil {
+		t.Fatalf(`Failed to get rate: "%v".`, err)
+	}
+	// 123.83 / 1.1330 = 109.29390997352...
+	if FormatExchangeRate(rate.Rate) != "109.2939099735" {
+		t.Errorf("USD -> JPY rate is %s.", FormatExchangeRate(rate.Rate))
+	}
+	if value := rate.Convert(NewMoney(10000, usd)); value.String() != "10929" {
+		t.Errorf("100.00 USD is converted into %s JPY.", value)
+	}
+
+	// MDL is known currency, but the file doesn't have its rate.
+	mdl := newExchangeTestCurrency(t, "MDL")
+	if _, err := provider.GetRate(usd, mdl); err == nil {
+		t.Errorf("Rate for currency without rate is returned.")
+	}
+}
+
+func TestParseECBExchangeRates(t *testing.T) {
+	// The latest day is used, the feed has currencies which are not
+	// supported.
+	rates, err := parseECBExchangeRates([]byte(`
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time='2020-06-04'>
+			<Cube currency='USD' rate='1.1200'/>
+		</Cube>
+		<Cube time='2020-06-05'>
+			<Cube currency='USD' rate='1.1330'/>
+			<Cube currency='XXX' rate='2'/>
+		</Cube>
+		<Cube time='2020-06-03'>
+			<Cube currency='USD' rate='1.1100'/>
+			<Cube currency='GBP' rate='0.89'/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`))
+	if err != nil {
+		t.Fatalf(`Failed to parse rates: "%v".`, err)
+	}
+	if !rates.time.Equal(time.Date(2020, 6, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Rates time is %s.", rates.time)
+	}
+	if len(rates.rates) != 2 ||
+		rates.rates["USD"].Cmp(big.NewRat(1133, 1000)) != 0 {
+		t.Errorf("Rates are %v.", rates.rates)
+	}
+
+	for _, test := range []struct {
+		name   string
+		source string
+	}{
+		{"not XML", `rates`},
+		{"without days", `<Envelope><Cube></Cube></Envelope>`},
+		{
+			"invalid day",
+			`<Envelope><Cube><Cube time='5.6.2020'>` +
+				`<Cube currency='USD' rate='1.1330'/></Cube></Cube></Envelope>`},
+		{
+			"invalid rate",
+			`<Envelope><Cube><Cube time='2020-06-05'>` +
+				`<Cube currency='USD' rate='1,1330'/></Cube></Cube></Envelope>`},
+		{
+			"zero rate",
+			`<Envelope><Cube><Cube time='2020-06-05'>` +
+				`<Cube currency='USD' rate='0'/></Cube></Cube></Envelope>`},
+	} {
+		if _, err := parseECBExchangeRates([]byte(test.source)); err == nil {
+			t.Errorf(`Feed "%s" is parsed.`, test.name)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
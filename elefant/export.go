@@ -0,0 +1,225 @@
+package elefant
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	// TransExportFormatOFX is Open Financial Exchange format.
+	TransExportFormatOFX = "ofx"
+	// TransExportFormatQIF is Quicken Interchange Format.
+	TransExportFormatQIF = "qif"
+	// TransExportFormatCSV is comma-separated values with a header.
+	TransExportFormatCSV = "csv"
+)
+
+// TransExport writes account transactions in a personal-finance tool format
+// one by one, so the transactions don't have to be loaded all at once. The
+// transaction method name is used as the payee and the transaction status
+// reason - as the memo. Times are written in the export location.
+type TransExport interface {
+	GetContentType() string
+	GetFileExtension() string
+	// Begin writes the export header.
+	Begin() error
+	Write(*Trans) error
+	// End writes the export footer with the account booked balance at
+	// the period end.
+	End(closing Money) error
+}
+
+// NewTransExport creates account transactions export in the format for
+// the period, From is included and To is not.
+func NewTransExport(
+	format string,
+	acc Account,
+	from time.Time,
+	to time.Time,
+	location *time.Location,
+	writer io.Writer) (TransExport, error) {
+	base := transExport{
+		acc:      acc,
+		from:     from,
+		to:       to,
+		location: location,
+		writer:   writer}
+	switch format {
+	case TransExportFormatOFX:
+		return &ofxTransExport{transExport: base}, nil
+	case TransExportFormatQIF:
+		return &qifTransExport{transExport: base}, nil
+	case TransExportFormatCSV:
+		return &csvTransExport{
+			transExport: base,
+			csv:         csv.NewWriter(writer)}, nil
+	default:
+		return nil, fmt.Errorf(`unknown transaction export format "%s"`, format)
+	}
+}
+
+type transExport struct {
+	acc      Account
+	from     time.Time
+	to       time.Time
+	location *time.Location
+	writer   io.Writer
+}
+
+func (export *transExport) write(format string, args ...interface{}) error {
+	_, err := fmt.Fprintf(export.writer, format, args...)
+	return err
+}
+
+func (export *transExport) getMemo(trans *Trans) string {
+	if trans.StatusReason == nil {
+		return ""
+	}
+	// Memo is a single line in all formats.
+	return strings.Join(strings.Fields(*trans.StatusReason), " ")
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ofxPayeeMaxLen is the max length of the payee name in OFX.
+const ofxPayeeMaxLen = 32
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+type ofxTransExport struct{ transExport }
+
+func (*ofxTransExport) GetContentType() string   { return "application/x-ofx" }
+func (*ofxTransExport) GetFileExtension() string { return "ofx" }
+
+// formatTime returns time in OFX format with the zone offset in hours, like
+// "20200131235959.000[+5.5:IST]".
+func (export *ofxTransExport) formatTime(source time.Time) string {
+	source = source.In(export.location)
+	zone, offset := source.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s[%s%s:%s]", source.Format("20060102150405.000"), sign,
+		strconv.FormatFloat(float64(offset)/3600, 'f', -1, 64), zone)
+}
+
+func (export *ofxTransExport) Begin() error {
+	now := export.formatTime(time.Now())
+	return export.write(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE>
+</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS>
+<TRNUID>0</TRNUID>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS>
+<CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>ELEFANTPAY</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`,
+		now, export.acc.GetCurrency().GetISO(), export.acc.GetID(),
+		export.formatTime(export.from), export.formatTime(export.to))
+}
+
+func (export *ofxTransExport) Write(trans *Trans) error {
+	transType := "CREDIT"
+	if trans.Value.IsNegative() {
+		transType = "DEBIT"
+	}
+	payee := trans.Method.GetName()
+	if utf8.RuneCountInString(payee) > ofxPayeeMaxLen {
+		payee = string([]rune(payee)[:ofxPayeeMaxLen])
+	}
+	memo := ""
+	if source := export.getMemo(trans); source != "" {
+		memo = "<MEMO>" + ofxEscaper.Replace(source) + "</MEMO>"
+	}
+	return export.write(`<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME>%s</STMTTRN>
+`,
+		transType, export.formatTime(trans.Time), trans.Value, trans.ID,
+		ofxEscaper.Replace(payee), memo)
+}
+
+func (export *ofxTransExport) End(closing Money) error {
+	return export.write(`</BANKTRANLIST>
+<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>
+</STMTRS>
+</STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`,
+		closing, export.formatTime(export.to))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type qifTransExport struct{ transExport }
+
+func (*qifTransExport) GetContentType() string   { return "application/qif" }
+func (*qifTransExport) GetFileExtension() string { return "qif" }
+
+func (export *qifTransExport) Begin() error {
+	return export.write("!Type:Bank\n")
+}
+
+func (export *qifTransExport) Write(trans *Trans) error {
+	if err := export.write("D%s\nT%s\nP%s\n",
+		trans.Time.In(export.location).Format("01/02/2006"),
+		trans.Value,
+		strings.Join(strings.Fields(trans.Method.GetName()), " ")); err != nil {
+		return err
+	}
+	if memo := export.getMemo(trans); memo != "" {
+		if err := export.write("M%s\n", memo); err != nil {
+			return err
+		}
+	}
+	return export.write("^\n")
+}
+
+func (export *qifTransExport) End(Money) error { return nil }
+
+////////////////////////////////////////////////////////////////////////////////
+
+type csvTransExport struct {
+	transExport
+	csv *csv.Writer
+}
+
+func (*csvTransExport) GetContentType() string   { return "text/csv" }
+func (*csvTransExport) GetFileExtension() string { return "csv" }
+
+func (export *csvTransExport) Begin() error {
+	return export.csv.Write([]string{
+		"id", "time", "value", "currency", "payee", "memo", "status"})
+}
+
+func (export *csvTransExport) Write(trans *Trans) error {
+	return export.csv.Write([]string{
+		trans.ID.String(),
+		trans.Time.In(export.location).Format(time.RFC3339),
+		trans.Value.String(),
+		trans.Value.GetCurrency().GetISO(),
+		trans.Method.GetName(),
+		export.getMemo(trans),
+		trans.Status.String()})
+}
+
+func (export *csvTransExport) End(Money) error {
+	export.csv.Flush()
+	return export.csv.Error()
+}
+
+////////////////////////////////////////////////////////////////////////////////
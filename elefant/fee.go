@@ -0,0 +1,132 @@
+package elefant
+
+import (
+	"fmt"
+	"math/big"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// FeeDirection is a direction of the account balance change by an operation
+// which could be charged by a fee.
+type FeeDirection int16
+
+const (
+	// FeeDirectionIn is an operation which increases the account balance.
+	FeeDirectionIn FeeDirection = 1
+	// FeeDirectionOut is an operation which decreases the account balance.
+	FeeDirectionOut FeeDirection = 2
+)
+
+// ParseFeeDirection parses direction by its name.
+func ParseFeeDirection(source string) (FeeDirection, error) {
+	switch source {
+	case FeeDirectionIn.String():
+		return FeeDirectionIn, nil
+	case FeeDirectionOut.String():
+		return FeeDirectionOut, nil
+	default:
+		return 0, fmt.Errorf(`unknown direction "%s"`, source)
+	}
+}
+
+func (direction FeeDirection) String() string {
+	switch direction {
+	case FeeDirectionIn:
+		return "in"
+	case FeeDirectionOut:
+		return "out"
+	default:
+		return "unknown"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// FeeOperation describes an account operation by which fee rule is selected.
+type FeeOperation struct {
+	MethodType MethodType
+	Direction  FeeDirection
+}
+
+var (
+	// FeeOperationCardDeposit is a deposit by bank card.
+	FeeOperationCardDeposit = FeeOperation{
+		MethodType: methodTypeBankCard, Direction: FeeDirectionIn}
+	// FeeOperationAccountPayment is a payment to another account.
+	FeeOperationAccountPayment = FeeOperation{
+		MethodType: methodTypeAccount, Direction: FeeDirectionOut}
+	// FeeOperationAccountIncome is a payment from another account.
+	FeeOperationAccountIncome = FeeOperation{
+		MethodType: methodTypeAccount, Direction: FeeDirectionIn}
+	// FeeOperationTaxPayment is a tax payment.
+	FeeOperationTaxPayment = FeeOperation{
+		MethodType: methodTypeTax, Direction: FeeDirectionOut}
+)
+
+// NewFeeOperation creates fee operation for the transaction method.
+func NewFeeOperation(method Method, direction FeeDirection) FeeOperation {
+	return FeeOperation{MethodType: method.GetType(), Direction: direction}
+}
+
+func (operation FeeOperation) String() string {
+	return fmt.Sprintf("%d/%s", operation.MethodType, operation.Direction)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// FeeRule describes a fee for operations with the value in the rule amount
+// band, all values are in the operation currency.
+type FeeRule struct {
+	// MinValue is the min operation value in the band, inclusive.
+	MinValue Money
+	// MaxValue is the optional band value limit, exclusive.
+	MaxValue *Money
+
+	Fixed Money
+	// Percent is a part of the operation value, in percents.
+	Percent *big.Rat
+	Min     Money
+	// Max is an optional max fee.
+	Max *Money
+}
+
+// IsForValue returns true if the positive operation value is in the rule
+// band.
+func (rule *FeeRule) IsForValue(value Money) bool {
+	return value.Cmp(rule.MinValue) >= 0 &&
+		(rule.MaxValue == nil || value.Cmp(*rule.MaxValue) < 0)
+}
+
+// findFeeRule returns the rule for the value, or nil if there is no rule with
+// the value in its band. The narrowest band is used if bands are overlapped.
+func findFeeRule(rules []*FeeRule, value Money) *FeeRule {
+	var result *FeeRule
+	for _, rule := range rules {
+		if rule.IsForValue(value) &&
+			(result == nil || rule.MinValue.Cmp(result.MinValue) > 0) {
+			result = rule
+		}
+	}
+	return result
+}
+
+// Calc returns the fee for the positive operation value. The fee never
+// exceeds the value.
+func (rule *FeeRule) Calc(value Money) Money {
+	result := value.MulRat(
+		new(big.Rat).Quo(rule.Percent, big.NewRat(100, 1)), RoundHalfEven)
+	result = result.Add(rule.Fixed)
+	if result.Cmp(rule.Min) < 0 {
+		result = rule.Min
+	}
+	if rule.Max != nil && result.Cmp(*rule.Max) > 0 {
+		result = *rule.Max
+	}
+	if result.Cmp(value) > 0 {
+		result = value
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
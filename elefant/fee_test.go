@@ -0,0 +1,203 @@
+package elefant
+
+import (
+	"math/big"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestFeeRuleCalc(t *testing.T) {
+	eur := newMoneyTestCurrency(t, "EUR")
+	newMoney := func(units int64) *Money {
+		result := NewMoney(units, eur)
+		return &result
+	}
+
+	for _, test := range []struct {
+		name     string
+		fixed    int64
+		percent  *big.Rat
+		min      int64
+		max      *Money
+		value    int64
+		expected int64
+	}{
+		{name: "free", percent: new(big.Rat), value: 1000, expected: 0},
+		{
+			name:     "fixed",
+			fixed:    25,
+			percent:  new(big.Rat),
+			value:    1000,
+			expected: 25},
+		{
+			name:     "percent",
+			percent:  big.NewRat(15, 10),
+			value:    10000,
+			expected: 150},
+		{
+			name:     "percent and fixed",
+			fixed:    30,
+			percent:  big.NewRat(29, 10),
+			value:    10000,
+			expected: 320},
+		// 1.5% of 1.50 is 0.0225.
+		{
+			name:     "percent rounded down",
+			percent:  big.NewRat(15, 10),
+			value:    150,
+			expected: 2},
+		// 2.5% of 1.00 is 0.025, the half goes to the even.
+		{
+			name:     "percent half to even down",
+			percent:  big.NewRat(25, 10),
+			value:    100,
+			expected: 2},
+		// 2.5% of 3.00 is 0.075.
+		{
+			name:     "percent half to even up",
+			percent:  big.NewRat(25, 10),
+			value:    300,
+			expected: 8},
+		// 1.2345% of 1000.00 is 12.345.
+		{
+			name:     "percent with 4 digits",
+			percent:  big.NewRat(12345, 10000),
+			value:    100000,
+			expected: 1234},
+		{
+			name:     "min",
+			percent:  big.NewRat(1, 1),
+			min:      50,
+			value:    1000,
+			expected: 50},
+		{
+			name:     "min is not applied",
+			percent:  big.NewRat(1, 1),
+			min:      50,
+			value:    10000,
+			expected: 100},
+		{
+			name:     "max",
+			percent:  big.NewRat(1, 1),
+			max:      newMoney(500),
+			value:    100000,
+			expected: 500},
+		{
+			name:     "max is not applied",
+			percent:  big.NewRat(1, 1),
+			max:      newMoney(500),
+			value:    10000,
+			expected: 100},
+		{
+			name:     "max with fixed",
+			fixed:    100,
+			percent:  big.NewRat(1, 1),
+			max:      newMoney(500),
+			value:    45000,
+			expected: 500},
+		{
+			name:     "min equals max",
+			percent:  big.NewRat(1, 1),
+			min:      200,
+			max:      newMoney(200),
+			value:    100000,
+			expected: 200},
+		{
+			name:     "clamped to value by fixed",
+			fixed:    100,
+			percent:  new(big.Rat),
+			value:    40,
+			expected: 40},
+		{
+			name:     "clamped to value by min",
+			percent:  big.NewRat(1, 1),
+			min:      50,
+			value:    30,
+			expected: 30},
+		{
+			name:     "clamped to value by percent",
+			percent:  big.NewRat(150, 1),
+			value:    1000,
+			expected: 1000},
+		{
+			name:     "equals value",
+			fixed:    1,
+			percent:  new(big.Rat),
+			value:    1,
+			expected: 1},
+	} {
+		rule := &FeeRule{
+			Fixed:   NewMoney(test.fixed, eur),
+			Percent: test.percent,
+			Min:     NewMoney(test.min, eur),
+			Max:     test.max}
+		result := rule.Calc(NewMoney(test.value, eur))
+		if result.GetUnits() != test.expected {
+			t.Errorf(`Fee "%s" for %d is %d, but expected %d.`,
+				test.name, test.value, result.GetUnits(), test.expected)
+		}
+		if result.GetCurrency().GetISO() != eur.GetISO() {
+			t.Errorf(`Fee "%s" is in %s.`, test.name, result.GetCurrency().GetISO())
+		}
+	}
+}
+
+func TestFindFeeRule(t *testing.T) {
+	eur := newMoneyTestCurrency(t, "EUR")
+	newRule := func(min int64, max int64, fixed int64) *FeeRule {
+		result := &FeeRule{
+			MinValue: NewMoney(min, eur),
+			Fixed:    NewMoney(fixed, eur),
+			Percent:  new(big.Rat),
+			Min:      NewMoney(0, eur)}
+		if max != 0 {
+			maxValue := NewMoney(max, eur)
+			result.MaxValue = &maxValue
+		}
+		return result
+	}
+	// Bands: [1.00, 100.00), [100.00, 1000.00), [1000.00, ...) and
+	// the overlapping narrow band [500.00, 600.00).
+	rules := []*FeeRule{
+		newRule(100000, 0, 3),
+		newRule(100, 10000, 1),
+		newRule(50000, 60000, 4),
+		newRule(10000, 100000, 2),
+	}
+	for _, test := range []struct {
+		value    int64
+		expected int64
+	}{
+		{value: 99, expected: 0},
+		{value: 100, expected: 1},
+		{value: 9999, expected: 1},
+		{value: 10000, expected: 2},
+		{value: 49999, expected: 2},
+		{value: 50000, expected: 4},
+		{value: 59999, expected: 4},
+		{value: 60000, expected: 2},
+		{value: 99999, expected: 2},
+		{value: 100000, expected: 3},
+		{value: 1000000000, expected: 3},
+	} {
+		value := NewMoney(test.value, eur)
+		rule := findFeeRule(rules, value)
+		if rule == nil {
+			if test.expected != 0 {
+				t.Errorf("There is no rule for %s.", value)
+			}
+			continue
+		}
+		if rule.Fixed.GetUnits() != test.expected {
+			t.Errorf("Rule for %s has fee %s, but expected %d.",
+				value, rule.Fixed, test.expected)
+		}
+	}
+
+	if rule := findFeeRule(nil, NewMoney(100, eur)); rule != nil {
+		t.Errorf("Rule is found without rules.")
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
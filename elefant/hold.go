@@ -0,0 +1,112 @@
+package elefant
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// HoldID is a hold unique ID.
+type HoldID = uuid.UUID
+
+func newHoldID() HoldID { return uuid.New() }
+
+// ParseHoldID parses hold ID in string.
+func ParseHoldID(source string) (HoldID, error) {
+	return uuid.Parse(source)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// HoldStatus is a hold status.
+type HoldStatus int16
+
+const (
+	// HoldStatusAuthorized is a status of the hold which reserves funds.
+	HoldStatusAuthorized HoldStatus = 1
+	// HoldStatusCaptured is a status of the hold which reserved funds are
+	// withdrawn, fully or partially.
+	HoldStatusCaptured HoldStatus = 2
+	// HoldStatusVoided is a status of the hold released without withdrawal.
+	HoldStatusVoided HoldStatus = 3
+	// HoldStatusExpired is a status of the hold released by the expiry time.
+	HoldStatusExpired HoldStatus = 4
+)
+
+func parseHoldStatusFromDB(source int16) (HoldStatus, error) {
+	switch HoldStatus(source) {
+	case HoldStatusAuthorized,
+		HoldStatusCaptured,
+		HoldStatusVoided,
+		HoldStatusExpired:
+		return HoldStatus(source), nil
+	default:
+		return 0, fmt.Errorf(`failed to parse hold status from DB-value "%v"`,
+			source)
+	}
+}
+
+func (status HoldStatus) String() string {
+	switch status {
+	case HoldStatusAuthorized:
+		return "authorized"
+	case HoldStatusCaptured:
+		return "captured"
+	case HoldStatusVoided:
+		return "voided"
+	case HoldStatusExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Hold describes funds reserved on the account, which reduce the account
+// available balance but not the account balance until the capture.
+type Hold struct {
+	ID      HoldID
+	Account AccountID
+	Client  ClientID
+	Value   Money
+	// Captured is set only for the captured hold, it could be less than
+	// the value if the hold is captured partially.
+	Captured    *Money
+	Description string
+	Status      HoldStatus
+	Time        time.Time
+	Expiry      time.Time
+}
+
+// NewHold creates new authorized hold for the account.
+func NewHold(
+	acc Account,
+	value Money,
+	description string,
+	liveTime time.Duration) *Hold {
+	now := time.Now().UTC()
+	return &Hold{
+		ID:          newHoldID(),
+		Account:     acc.GetID(),
+		Client:      acc.GetClientID(),
+		Value:       value,
+		Description: description,
+		Status:      HoldStatusAuthorized,
+		Time:        now,
+		Expiry:      now.Add(liveTime)}
+}
+
+// GetStatus returns the actual status, which is expired for the authorized
+// hold with passed expiry time, even if it is not released by the expiry yet.
+func (hold *Hold) GetStatus(now time.Time) HoldStatus {
+	if hold.Status == HoldStatusAuthorized && !now.Before(hold.Expiry) {
+		return HoldStatusExpired
+	}
+	return hold.Status
+}
+
+////////////////////////////////////////////////////////////////////////////////
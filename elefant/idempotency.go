@@ -0,0 +1,20 @@
+package elefant
+
+import "time"
+
+// IdempotentResponse describes response for the request with idempotency key,
+// which is stored in the same DB transaction as the request changes, so retry
+// of the request with the same key gets the same response.
+type IdempotentResponse struct {
+	Client ClientID
+	Key    string
+	// RequestHash is a hash of the request, the key could not be reused for
+	// another request.
+	RequestHash string
+	// IsPending is set while the request is executed, the body has results
+	// of the executed part of the request.
+	IsPending  bool
+	StatusCode int
+	Body       string
+	Time       time.Time
+}
@@ -0,0 +1,222 @@
+package elefant
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// SystemAccountType is a house (system) ledger account type. System accounts
+// are the counterparts for client accounts in journals, there is one system
+// account for each type and currency, except tax payable accounts, which are
+// separate for each tax authority.
+type SystemAccountType int16
+
+const (
+	// SystemAccountCardClearing collects funds deposited by bank cards, which
+	// are not settled with the card acquirer yet.
+	SystemAccountCardClearing SystemAccountType = 1
+	// SystemAccountTaxPayable collects funds paid by clients for taxes, which
+	// are not transferred to the tax authority yet. Each authority has its own
+	// account, so it's posted only by the account ID.
+	SystemAccountTaxPayable SystemAccountType = 2
+	// SystemAccountExchange is the counterpart for currency conversions, it has
+	// a balance in each currency.
+	SystemAccountExchange SystemAccountType = 3
+	// SystemAccountFee collects fees charged from clients.
+	SystemAccountFee SystemAccountType = 4
+	// SystemAccountOverdraftInterest collects interest charged from clients for
+	// used overdrafts.
+	SystemAccountOverdraftInterest SystemAccountType = 5
+	// SystemAccountHoldSettlement collects funds captured by holds, which are
+	// not settled with the hold recipient yet.
+	SystemAccountHoldSettlement SystemAccountType = 6
+	// SystemAccountTransferClearing is the counterpart for deposits by bank
+	// transfers received to the collection account.
+	SystemAccountTransferClearing SystemAccountType = 7
+	// SystemAccountInterestExpense pays interest to clients for positive
+	// balances.
+	SystemAccountInterestExpense SystemAccountType = 8
+	// SystemAccountOpeningBalance is the counterpart for balances of accounts
+	// which are opened before the ledger, it's posted only by the migration.
+	SystemAccountOpeningBalance SystemAccountType = 9
+)
+
+// String returns system account type name.
+func (accType SystemAccountType) String() string {
+	switch accType {
+	case SystemAccountCardClearing:
+		return "card clearing"
+	case SystemAccountTaxPayable:
+		return "tax payable"
+	case SystemAccountExchange:
+		return "exchange"
+	case SystemAccountFee:
+		return "fee income"
+	case SystemAccountOverdraftInterest:
+		return "overdraft interest income"
+	case SystemAccountHoldSettlement:
+		return "hold settlement"
+	case SystemAccountTransferClearing:
+		return "transfer clearing"
+	case SystemAccountInterestExpense:
+		return "interest expense"
+	case SystemAccountOpeningBalance:
+		return "opening balance"
+	default:
+		return "unknown"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// SystemAccountID is a system account unique ID.
+type SystemAccountID = uuid.UUID
+
+func newSystemAccountID() SystemAccountID { return uuid.New() }
+
+type nullSystemAccountID struct {
+	SystemAccountID SystemAccountID
+	Valid           bool
+}
+
+// Value implements the driver Valuer interface.
+func (n nullSystemAccountID) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.SystemAccountID.String(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// JournalID is a ledger journal unique ID.
+type JournalID = uuid.UUID
+
+func newJournalID() JournalID { return uuid.New() }
+
+type nullJournalID struct {
+	JournalID JournalID
+	Valid     bool
+}
+
+// Scan implements the Scanner interface.
+func (n *nullJournalID) Scan(value interface{}) error {
+	var err error
+	n.JournalID, n.Valid, err = scanNullUUID(value)
+	return err
+}
+
+// Value implements the driver Valuer interface.
+func (n nullJournalID) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.JournalID.String(), nil
+}
+
+// PostingID is a journal posting unique ID.
+type PostingID = uuid.UUID
+
+func newPostingID() PostingID { return uuid.New() }
+
+// Posting describes one journal line which changes a client account balance
+// or a system account balance.
+type Posting struct {
+	// Account is set if the posting is for a client account.
+	Account *AccountID
+	// System is set if the posting is for a system account of the type in
+	// the value currency.
+	System SystemAccountType
+	// SystemID is set if the posting is for the system account with the ID,
+	// like a tax authority account.
+	SystemID *SystemAccountID
+	Value    Money
+}
+
+// Journal describes one money movement as a balanced set of postings: a sum
+// of all postings in each currency has to be zero.
+type Journal struct {
+	ID       JournalID
+	Time     time.Time
+	Postings []*Posting
+	// IsCharge is set if the journal is a house charge, like a fee or
+	// an interest, which is applied to frozen accounts too.
+	IsCharge bool
+}
+
+// NewJournal creates new empty journal.
+func NewJournal() *Journal {
+	return &Journal{ID: newJournalID(), Time: time.Now().UTC()}
+}
+
+// NewChargeJournal creates new empty journal for a house charge.
+func NewChargeJournal() *Journal {
+	result := NewJournal()
+	result.IsCharge = true
+	return result
+}
+
+// AddAccountPosting adds posting for the client account.
+func (journal *Journal) AddAccountPosting(acc AccountID, value Money) {
+	journal.Postings = append(journal.Postings,
+		&Posting{Account: &acc, Value: value})
+}
+
+// AddSystemPosting adds posting for the system account in the value currency.
+func (journal *Journal) AddSystemPosting(
+	accType SystemAccountType, value Money) {
+	journal.Postings = append(journal.Postings,
+		&Posting{System: accType, Value: value})
+}
+
+// AddSystemAccountPosting adds posting for the system account with the ID,
+// the account has to be in the value currency.
+func (journal *Journal) AddSystemAccountPosting(
+	acc SystemAccountID, value Money) {
+	journal.Postings = append(journal.Postings,
+		&Posting{SystemID: &acc, Value: value})
+}
+
+// Validate checks that journal is balanced and each posting has one account.
+func (journal *Journal) Validate() error {
+	if len(journal.Postings) < 2 {
+		return errors.New("journal has to have at least two postings")
+	}
+	sums := map[string]Money{}
+	for _, posting := range journal.Postings {
+		accounts := 0
+		for _, isSet := range []bool{
+			posting.Account != nil, posting.System != 0, posting.SystemID != nil} {
+			if isSet {
+				accounts++
+			}
+		}
+		if accounts != 1 {
+			return fmt.Errorf("journal posting has %d accounts", accounts)
+		}
+		if posting.Value.GetCurrency() == nil {
+			return errors.New("journal posting does not have currency")
+		}
+		iso := posting.Value.GetCurrency().GetISO()
+		if sum, has := sums[iso]; has {
+			sums[iso] = sum.Add(posting.Value)
+		} else {
+			sums[iso] = posting.Value
+		}
+	}
+	for _, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf(`journal is not balanced in "%s": %s`,
+				sum.GetCurrency().GetISO(), sum)
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
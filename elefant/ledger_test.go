@@ -0,0 +1,123 @@
+package elefant
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestJournalValidate(t *testing.T) {
+	eur := newMoneyTestCurrency(t, "EUR")
+	usd := newMoneyTestCurrency(t, "USD")
+	acc := uuid.New()
+	taxAcc := uuid.New()
+
+	for _, test := range []struct {
+		name    string
+		fill    func(*Journal)
+		isValid bool
+	}{
+		{
+			name: "balanced",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.AddSystemPosting(
+					SystemAccountCardClearing, NewMoney(1000, eur))
+			},
+			isValid: true},
+		{
+			name: "balanced by several postings",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1050, eur))
+				journal.AddSystemAccountPosting(taxAcc, NewMoney(1000, eur))
+				journal.AddSystemPosting(SystemAccountFee, NewMoney(50, eur))
+			},
+			isValid: true},
+		{
+			name: "unbalanced",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.AddSystemPosting(
+					SystemAccountCardClearing, NewMoney(999, eur))
+			},
+			isValid: false},
+		{
+			name: "unbalanced by one side",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(1000, eur))
+				journal.AddSystemPosting(
+					SystemAccountCardClearing, NewMoney(1000, eur))
+			},
+			isValid: false},
+		{
+			name:    "without postings",
+			fill:    func(*Journal) {},
+			isValid: false},
+		{
+			name: "single posting",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(0, eur))
+			},
+			isValid: false},
+		{
+			name: "two currencies balanced in each",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.AddSystemPosting(SystemAccountExchange, NewMoney(1000, eur))
+				journal.AddSystemPosting(SystemAccountExchange, NewMoney(-1133, usd))
+				journal.AddAccountPosting(uuid.New(), NewMoney(1133, usd))
+			},
+			isValid: true},
+		{
+			name: "two currencies balanced only in sum",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.AddAccountPosting(uuid.New(), NewMoney(1000, usd))
+			},
+			isValid: false},
+		{
+			name: "two currencies unbalanced in one",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.AddSystemPosting(SystemAccountExchange, NewMoney(1000, eur))
+				journal.AddSystemPosting(SystemAccountExchange, NewMoney(-1133, usd))
+				journal.AddAccountPosting(uuid.New(), NewMoney(1132, usd))
+			},
+			isValid: false},
+		{
+			name: "posting without account",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.Postings = append(journal.Postings,
+					&Posting{Value: NewMoney(1000, eur)})
+			},
+			isValid: false},
+		{
+			name: "posting with two accounts",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(-1000, eur))
+				journal.AddSystemAccountPosting(taxAcc, NewMoney(1000, eur))
+				journal.Postings[1].System = SystemAccountTaxPayable
+			},
+			isValid: false},
+		{
+			name: "posting without currency",
+			fill: func(journal *Journal) {
+				journal.AddAccountPosting(acc, NewMoney(0, eur))
+				journal.AddSystemPosting(SystemAccountFee, Money{})
+			},
+			isValid: false},
+	} {
+		journal := NewJournal()
+		test.fill(journal)
+		if err := journal.Validate(); test.isValid && err != nil {
+			t.Errorf(`Journal "%s" is not valid: "%v".`, test.name, err)
+		} else if !test.isValid && err == nil {
+			t.Errorf(`Journal "%s" is valid.`, test.name)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
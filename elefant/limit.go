@@ -0,0 +1,151 @@
+package elefant
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ClientTier is a client service level, which defines default account
+// spending limits.
+type ClientTier int16
+
+const (
+	// ClientTierStandard is a tier of a new client.
+	ClientTierStandard ClientTier = 1
+	// ClientTierPremium is a tier with increased limits.
+	ClientTierPremium ClientTier = 2
+	// ClientTierBusiness is a tier for business clients.
+	ClientTierBusiness ClientTier = 3
+)
+
+func parseClientTierFromDB(source int16) (ClientTier, error) {
+	switch ClientTier(source) {
+	case ClientTierStandard, ClientTierPremium, ClientTierBusiness:
+		return ClientTier(source), nil
+	default:
+		return 0, fmt.Errorf(`failed to parse client tier from DB-value "%v"`,
+			source)
+	}
+}
+
+func (tier ClientTier) String() string {
+	switch tier {
+	case ClientTierStandard:
+		return "standard"
+	case ClientTierPremium:
+		return "premium"
+	case ClientTierBusiness:
+		return "business"
+	default:
+		return "unknown"
+	}
+}
+
+// clientTierLimitsCurrency is the currency of the client tier default limits,
+// the limits for accounts in other currencies are converted by the actual
+// exchange rate.
+const clientTierLimitsCurrency = "EUR"
+
+// clientTierLimits is default account spending limits for client tiers in
+// clientTierLimitsCurrency major units: per transaction, daily and monthly.
+var clientTierLimits = map[ClientTier][3]int64{
+	ClientTierStandard: {1000, 2000, 10000},
+	ClientTierPremium:  {5000, 10000, 50000},
+	ClientTierBusiness: {50000, 100000, 1000000},
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// SpendingLimits describes max values which could be withdrawn from
+// an account, all values are in the account currency.
+type SpendingLimits struct {
+	PerTrans Money
+	Daily    Money
+	Monthly  Money
+}
+
+// NewSpendingLimitsForTier returns default spending limits for the client
+// tier account in the currency. Converted limits are rounded down to whole
+// major units, as limits set by clients.
+func NewSpendingLimitsForTier(
+	tier ClientTier,
+	currency Currency,
+	rates ExchangeRateProvider) (SpendingLimits, error) {
+	limits, has := clientTierLimits[tier]
+	if !has {
+		return SpendingLimits{}, fmt.Errorf(
+			`client tier "%s" does not have limits`, tier)
+	}
+	rate := big.NewRat(1, 1)
+	if currency.GetISO() != clientTierLimitsCurrency {
+		from, err := NewCurrency(clientTierLimitsCurrency)
+		if err != nil {
+			return SpendingLimits{}, err
+		}
+		exchangeRate, err := rates.GetRate(from, currency)
+		if err != nil {
+			return SpendingLimits{}, err
+		}
+		rate = exchangeRate.Rate
+	}
+	newMoney := func(value int64) Money {
+		major := roundRat(new(big.Rat).Mul(big.NewRat(value, 1), rate), RoundDown)
+		return NewMoneyFromRat(big.NewRat(major, 1), currency, RoundHalfEven)
+	}
+	return SpendingLimits{
+			PerTrans: newMoney(limits[0]),
+			Daily:    newMoney(limits[1]),
+			Monthly:  newMoney(limits[2])},
+		nil
+}
+
+// Validate checks that the limits are positive and consistent.
+func (limits SpendingLimits) Validate() error {
+	if !limits.PerTrans.IsPositive() ||
+		!limits.Daily.IsPositive() ||
+		!limits.Monthly.IsPositive() {
+		return errors.New("limits have to be positive")
+	}
+	if limits.PerTrans.Cmp(limits.Daily) > 0 {
+		return errors.New("per transaction limit could not exceed daily limit")
+	}
+	if limits.Daily.Cmp(limits.Monthly) > 0 {
+		return errors.New("daily limit could not exceed monthly limit")
+	}
+	return nil
+}
+
+// IsRaisedBy returns true if any of the next limits is greater than
+// the current.
+func (limits SpendingLimits) IsRaisedBy(next SpendingLimits) bool {
+	return next.PerTrans.Cmp(limits.PerTrans) > 0 ||
+		next.Daily.Cmp(limits.Daily) > 0 ||
+		next.Monthly.Cmp(limits.Monthly) > 0
+}
+
+// Check returns the failure reason if the positive value could not be
+// withdrawn with already spent values, or empty string.
+func (limits SpendingLimits) Check(value Money, spent Spending) string {
+	if value.Cmp(limits.PerTrans) > 0 {
+		return "per transaction spending limit exceeded"
+	}
+	if spent.Daily.Add(value).Cmp(limits.Daily) > 0 {
+		return "daily spending limit exceeded"
+	}
+	if spent.Monthly.Add(value).Cmp(limits.Monthly) > 0 {
+		return "monthly spending limit exceeded"
+	}
+	return ""
+}
+
+// Spending describes positive values already withdrawn from an account in
+// the current day and month.
+type Spending struct {
+	Daily   Money
+	Monthly Money
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -14,10 +14,15 @@ import (
 type MethodType int16
 
 const (
-	methodTypeBankCard MethodType = 0
-	methodTypeAccount  MethodType = 1
-	methodTypeTax      MethodType = 2
-	methodTypeLast     int64      = int64(methodTypeTax)
+	methodTypeBankCard     MethodType = 0
+	methodTypeAccount      MethodType = 1
+	methodTypeTax          MethodType = 2
+	methodTypeFee          MethodType = 3
+	methodTypeOverdraft    MethodType = 4
+	methodTypeHold         MethodType = 5
+	methodTypeBankTransfer MethodType = 6
+	methodTypeInterest     MethodType = 7
+	methodTypeLast         int64      = int64(methodTypeInterest)
 )
 
 func parseMethodType(source int64) (MethodType, error) {
@@ -27,6 +32,39 @@ func parseMethodType(source int64) (MethodType, error) {
 	return MethodType(source), nil
 }
 
+// ParseMethodTypeName parses method type by its name.
+func ParseMethodTypeName(source string) (MethodType, error) {
+	for i := int64(0); i <= methodTypeLast; i++ {
+		if MethodType(i).String() == source {
+			return MethodType(i), nil
+		}
+	}
+	return 0, fmt.Errorf(`unknown method type "%s"`, source)
+}
+
+func (typeID MethodType) String() string {
+	switch typeID {
+	case methodTypeBankCard:
+		return "bank card"
+	case methodTypeAccount:
+		return "account"
+	case methodTypeTax:
+		return "tax"
+	case methodTypeFee:
+		return "fee"
+	case methodTypeOverdraft:
+		return "overdraft interest"
+	case methodTypeHold:
+		return "hold"
+	case methodTypeBankTransfer:
+		return "bank transfer"
+	case methodTypeInterest:
+		return "interest"
+	default:
+		return "unknown"
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 type nullMethodType struct {
@@ -142,7 +180,7 @@ type bankCardMethod struct {
 }
 
 func (m *bankCardMethod) GetType() MethodType  { return methodTypeBankCard }
-func (m *bankCardMethod) GetTypeName() string  { return "bank card" }
+func (m *bankCardMethod) GetTypeName() string  { return m.GetType().String() }
 func (m *bankCardMethod) GetInfo() interface{} { return m.card }
 func (m *bankCardMethod) GetArg() interface{}  { return nil }
 func (m *bankCardMethod) GetName() string {
@@ -164,6 +202,10 @@ func (m *bankCardMethod) GetKey() string {
 // AccountMethod describes transaction method "between accounts".
 type AccountMethod interface {
 	Method
+	// GetAccountID returns the counterpart account ID.
+	GetAccountID() AccountID
+	// GetEmail returns the counterpart client email.
+	GetEmail() string
 }
 
 type accountMethodArg struct {
@@ -192,18 +234,44 @@ type accountMethod struct {
 	arg     accountMethodArg
 }
 
-func (m *accountMethod) GetTypeName() string  { return "account" }
+func (m *accountMethod) GetTypeName() string  { return m.GetType().String() }
 func (m *accountMethod) GetInfo() interface{} { return m.account }
 func (m *accountMethod) GetArg() interface{}  { return m.arg }
 func (m *accountMethod) GetType() MethodType  { return methodTypeAccount }
 func (m *accountMethod) GetKey() string       { return m.account.String() }
 func (m *accountMethod) GetName() string      { return m.arg.Email }
+func (m *accountMethod) GetAccountID() AccountID {
+	return m.account
+}
+func (m *accountMethod) GetEmail() string { return m.arg.Email }
 
 ////////////////////////////////////////////////////////////////////////////////
 
-// TaxMethod describes transaction method "taxes".
+// TaxMethod describes transaction method "taxes". The method is a saved payee
+// of the tax authority, methods stored before the authority registry don't
+// have the authority.
 type TaxMethod interface {
 	Method
+	// GetBill returns the paid tax bill.
+	GetBill() string
+	// GetAuthority returns the tax authority ID, or nil if the authority is
+	// unknown.
+	GetAuthority() *TaxAuthorityID
+	// GetAuthorityName returns the tax authority name, or empty string if
+	// the authority is unknown.
+	GetAuthorityName() string
+}
+
+type taxMethodInfo struct {
+	Authority TaxAuthorityID `json:"a"`
+	Name      string         `json:"n"`
+}
+
+func newTaxMethodInfo(authority *TaxAuthority) *taxMethodInfo {
+	if authority == nil {
+		return nil
+	}
+	return &taxMethodInfo{Authority: authority.ID, Name: authority.Name}
 }
 
 type taxMethodArg struct {
@@ -218,25 +286,220 @@ func newTaxMethod(
 	id MethodID,
 	client ClientID,
 	currency Currency,
+	info *taxMethodInfo,
 	arg taxMethodArg) TaxMethod {
 	return &taxMethod{
 		method: newMethod(id, client, currency),
+		info:   info,
 		arg:    arg}
 }
 
 type taxMethod struct {
 	method
-	arg taxMethodArg
+	info *taxMethodInfo
+	arg  taxMethodArg
 }
 
-func (m *taxMethod) GetType() MethodType  { return methodTypeTax }
-func (m *taxMethod) GetTypeName() string  { return "tax" }
-func (m *taxMethod) GetInfo() interface{} { return nil }
-func (m *taxMethod) GetKey() string       { return "" }
-func (m *taxMethod) GetArg() interface{}  { return m.arg }
+func (m *taxMethod) GetType() MethodType { return methodTypeTax }
+func (m *taxMethod) GetTypeName() string { return m.GetType().String() }
+func (m *taxMethod) GetInfo() interface{} {
+	if m.info == nil {
+		return nil
+	}
+	return m.info
+}
+func (m *taxMethod) GetKey() string {
+	if m.info == nil {
+		return ""
+	}
+	return m.info.Authority.String()
+}
+func (m *taxMethod) GetArg() interface{} { return m.arg }
 func (m *taxMethod) GetName() string {
-	return fmt.Sprintf(`tax bill "%s"`, m.arg.Bill)
+	if m.info == nil {
+		return fmt.Sprintf(`tax bill "%s"`, m.arg.Bill)
+	}
+	return fmt.Sprintf(`%s tax bill "%s"`, m.info.Name, m.arg.Bill)
 }
+func (m *taxMethod) GetBill() string { return m.arg.Bill }
+func (m *taxMethod) GetAuthority() *TaxAuthorityID {
+	if m.info == nil {
+		return nil
+	}
+	return &m.info.Authority
+}
+func (m *taxMethod) GetAuthorityName() string {
+	if m.info == nil {
+		return ""
+	}
+	return m.info.Name
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// FeeMethod describes transaction method "fee", which charges operation fee
+// from the account.
+type FeeMethod interface {
+	Method
+}
+
+func newFeeMethod(id MethodID, client ClientID, currency Currency) FeeMethod {
+	return &feeMethod{method: newMethod(id, client, currency)}
+}
+
+type feeMethod struct{ method }
+
+func (m *feeMethod) GetType() MethodType  { return methodTypeFee }
+func (m *feeMethod) GetTypeName() string  { return m.GetType().String() }
+func (m *feeMethod) GetInfo() interface{} { return nil }
+func (m *feeMethod) GetKey() string       { return "" }
+func (m *feeMethod) GetArg() interface{}  { return nil }
+func (m *feeMethod) GetName() string      { return m.GetTypeName() }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// OverdraftMethod describes transaction method "overdraft interest", which
+// charges interest for the used overdraft from the account.
+type OverdraftMethod interface {
+	Method
+}
+
+func newOverdraftMethod(
+	id MethodID, client ClientID, currency Currency) OverdraftMethod {
+	return &overdraftMethod{method: newMethod(id, client, currency)}
+}
+
+type overdraftMethod struct{ method }
+
+func (m *overdraftMethod) GetType() MethodType  { return methodTypeOverdraft }
+func (m *overdraftMethod) GetTypeName() string  { return m.GetType().String() }
+func (m *overdraftMethod) GetInfo() interface{} { return nil }
+func (m *overdraftMethod) GetKey() string       { return "" }
+func (m *overdraftMethod) GetArg() interface{}  { return nil }
+func (m *overdraftMethod) GetName() string      { return m.GetTypeName() }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// HoldMethod describes transaction method "hold", which withdraws funds
+// reserved by the captured hold.
+type HoldMethod interface {
+	Method
+	// GetHoldID returns the captured hold ID.
+	GetHoldID() HoldID
+}
+
+type holdMethodArg struct {
+	Hold        HoldID `json:"h"`
+	Description string `json:"d,omitempty"`
+}
+
+func newHoldMethodArg(hold *Hold) holdMethodArg {
+	return holdMethodArg{Hold: hold.ID, Description: hold.Description}
+}
+
+func newHoldMethod(
+	id MethodID,
+	client ClientID,
+	currency Currency,
+	arg holdMethodArg) HoldMethod {
+	return &holdMethod{
+		method: newMethod(id, client, currency),
+		arg:    arg}
+}
+
+type holdMethod struct {
+	method
+	arg holdMethodArg
+}
+
+func (m *holdMethod) GetType() MethodType  { return methodTypeHold }
+func (m *holdMethod) GetTypeName() string  { return m.GetType().String() }
+func (m *holdMethod) GetInfo() interface{} { return nil }
+func (m *holdMethod) GetKey() string       { return "" }
+func (m *holdMethod) GetArg() interface{}  { return m.arg }
+func (m *holdMethod) GetName() string {
+	if m.arg.Description != "" {
+		return m.arg.Description
+	}
+	return fmt.Sprintf(`hold "%s"`, m.arg.Hold)
+}
+func (m *holdMethod) GetHoldID() HoldID { return m.arg.Hold }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BankTransferMethod describes transaction method "bank transfer", which
+// deposits funds received by the bank transfer to the collection account.
+type BankTransferMethod interface {
+	Method
+	// GetReference returns the bank reference of the transfer.
+	GetReference() string
+	// GetDebtor returns the transfer sender name, if it's known.
+	GetDebtor() string
+}
+
+type bankTransferMethodArg struct {
+	Reference     string `json:"r"`
+	Debtor        string `json:"d,omitempty"`
+	DebtorAccount string `json:"a,omitempty"`
+}
+
+func newBankTransferMethodArg(line *BankLine) bankTransferMethodArg {
+	return bankTransferMethodArg{
+		Reference:     line.Reference,
+		Debtor:        line.Debtor,
+		DebtorAccount: line.DebtorAccount}
+}
+
+func newBankTransferMethod(
+	id MethodID,
+	client ClientID,
+	currency Currency,
+	arg bankTransferMethodArg) BankTransferMethod {
+	return &bankTransferMethod{
+		method: newMethod(id, client, currency),
+		arg:    arg}
+}
+
+type bankTransferMethod struct {
+	method
+	arg bankTransferMethodArg
+}
+
+func (m *bankTransferMethod) GetType() MethodType  { return methodTypeBankTransfer }
+func (m *bankTransferMethod) GetTypeName() string  { return m.GetType().String() }
+func (m *bankTransferMethod) GetInfo() interface{} { return nil }
+func (m *bankTransferMethod) GetKey() string       { return "" }
+func (m *bankTransferMethod) GetArg() interface{}  { return m.arg }
+func (m *bankTransferMethod) GetName() string {
+	if m.arg.Debtor != "" {
+		return m.GetTypeName() + " from " + m.arg.Debtor
+	}
+	return m.GetTypeName()
+}
+func (m *bankTransferMethod) GetReference() string { return m.arg.Reference }
+func (m *bankTransferMethod) GetDebtor() string    { return m.arg.Debtor }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// InterestMethod describes transaction method "interest", which pays
+// the interest accrued for positive balances to the account.
+type InterestMethod interface {
+	Method
+}
+
+func newInterestMethod(
+	id MethodID, client ClientID, currency Currency) InterestMethod {
+	return &interestMethod{method: newMethod(id, client, currency)}
+}
+
+type interestMethod struct{ method }
+
+func (m *interestMethod) GetType() MethodType  { return methodTypeInterest }
+func (m *interestMethod) GetTypeName() string  { return m.GetType().String() }
+func (m *interestMethod) GetInfo() interface{} { return nil }
+func (m *interestMethod) GetKey() string       { return "" }
+func (m *interestMethod) GetArg() interface{}  { return nil }
+func (m *interestMethod) GetName() string      { return m.GetTypeName() }
 
 ////////////////////////////////////////////////////////////////////////////////
 
@@ -270,12 +533,39 @@ func newMethodByType(
 		}
 	case methodTypeTax:
 		{
+			// Methods without the authority have "null" info.
+			var info *taxMethodInfo
+			if err := getInfo(&info); err != nil {
+				return nil, err
+			}
 			arg := taxMethodArg{}
 			if err := getArg(&arg); err != nil {
 				return nil, err
 			}
-			return newTaxMethod(id, client, currency, arg), nil
+			return newTaxMethod(id, client, currency, info, arg), nil
+		}
+	case methodTypeFee:
+		return newFeeMethod(id, client, currency), nil
+	case methodTypeOverdraft:
+		return newOverdraftMethod(id, client, currency), nil
+	case methodTypeHold:
+		{
+			arg := holdMethodArg{}
+			if err := getArg(&arg); err != nil {
+				return nil, err
+			}
+			return newHoldMethod(id, client, currency, arg), nil
+		}
+	case methodTypeBankTransfer:
+		{
+			arg := bankTransferMethodArg{}
+			if err := getArg(&arg); err != nil {
+				return nil, err
+			}
+			return newBankTransferMethod(id, client, currency, arg), nil
 		}
+	case methodTypeInterest:
+		return newInterestMethod(id, client, currency), nil
 	default:
 		return nil, fmt.Errorf(`method type "%v" is unknown`, typeID)
 	}
@@ -0,0 +1,39 @@
+--
+-- Converts account balances and transaction values from double precision
+-- major units to bigint currency minor units. Currencies have 2 minor units
+-- except those listed in the factor function (ISO 4217).
+--
+
+BEGIN;
+
+CREATE FUNCTION pg_temp.minor_units_factor(currency character(3))
+    RETURNS numeric
+    LANGUAGE sql IMMUTABLE
+    AS $$
+        SELECT CASE
+            WHEN currency IN (
+                'BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG',
+                'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 1
+            WHEN currency IN (
+                'BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1000
+            WHEN currency IN ('CLF', 'UYW') THEN 10000
+            ELSE 100
+        END
+    $$;
+
+ALTER TABLE public.acc
+    ALTER COLUMN balance TYPE bigint
+    USING round(balance::numeric * pg_temp.minor_units_factor(currency));
+
+-- Transaction doesn't have currency, it's the account currency.
+ALTER TABLE public.trans ADD COLUMN value_units bigint;
+UPDATE public.trans
+    SET value_units = round(
+        trans.value::numeric * pg_temp.minor_units_factor(acc.currency))
+    FROM public.acc
+    WHERE acc.id = trans.acc;
+ALTER TABLE public.trans DROP COLUMN value;
+ALTER TABLE public.trans RENAME COLUMN value_units TO value;
+ALTER TABLE public.trans ALTER COLUMN value SET NOT NULL;
+
+COMMIT;
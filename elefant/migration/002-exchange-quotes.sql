@@ -0,0 +1,40 @@
+--
+-- Cross-currency transfers are executed by the exchange rate quote, which
+-- the client accepts before the transfer. The transaction stores the rate and
+-- the value in the counter currency, transactions before the exchange don't
+-- have them.
+--
+
+BEGIN;
+
+CREATE TABLE public.exchange_quote (
+    id uuid NOT NULL,
+    client uuid NOT NULL,
+    acc_from uuid NOT NULL,
+    acc_to uuid NOT NULL,
+    value bigint NOT NULL,
+    currency character(3) NOT NULL,
+    counter_value bigint NOT NULL,
+    counter_currency character(3) NOT NULL,
+    rate numeric(20,10) NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    expiry timestamp without time zone NOT NULL
+);
+
+ALTER TABLE ONLY public.exchange_quote
+    ADD CONSTRAINT "exchange-quote_pkey" PRIMARY KEY (id);
+
+CREATE INDEX "exchange-quote-expiry_idx" ON public.exchange_quote USING btree (expiry);
+
+ALTER TABLE ONLY public.exchange_quote
+    ADD CONSTRAINT "exchange-quote-acc-from_ref" FOREIGN KEY (acc_from) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "exchange-quote-acc-to_ref" FOREIGN KEY (acc_to) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "exchange-quote-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+ALTER TABLE public.trans
+    ADD COLUMN exchange_rate numeric(20,10),
+    ADD COLUMN counter_value bigint,
+    ADD COLUMN counter_currency character(3),
+    ADD CONSTRAINT "trans-exchange_chk" CHECK ((((exchange_rate IS NULL) AND (counter_value IS NULL) AND (counter_currency IS NULL)) OR ((exchange_rate IS NOT NULL) AND (counter_value IS NOT NULL) AND (counter_currency IS NOT NULL))));
+
+COMMIT;
@@ -0,0 +1,128 @@
+--
+-- Account balances are posted by balanced journals to the double-entry
+-- ledger, house accounts are the counterparts of client accounts.
+--
+-- Ledger postings are never deleted, so accounts and clients with accounts
+-- are not deleted too (accounts are closed instead), the account and
+-- the transaction references are restricted as the posting reference is.
+--
+-- Accounts which are opened before the ledger have balances without
+-- postings. Each such account gets an opening journal, which posts
+-- the account balance against the opening balance house account, and
+-- the succeeded transactions before the ledger are linked to the journal, so
+-- the reconciliation compares the balance with them.
+--
+
+BEGIN;
+
+CREATE FUNCTION public.check_journal_balance() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM public.posting
+        WHERE posting.journal = NEW.journal
+        GROUP BY posting.currency
+        HAVING SUM(posting.value) <> 0) THEN
+        RAISE EXCEPTION 'journal % is not balanced', NEW.journal
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$;
+
+CREATE TABLE public.journal (
+    id uuid NOT NULL,
+    "time" timestamp without time zone NOT NULL
+);
+
+CREATE TABLE public.posting (
+    id uuid NOT NULL,
+    journal uuid NOT NULL,
+    acc uuid,
+    system_acc uuid,
+    value bigint NOT NULL,
+    currency character(3) NOT NULL,
+    CONSTRAINT "posting-target_chk" CHECK (((acc IS NULL) <> (system_acc IS NULL)))
+);
+
+CREATE TABLE public.system_acc (
+    id uuid NOT NULL,
+    type smallint NOT NULL,
+    currency character(3) NOT NULL,
+    balance bigint NOT NULL,
+    revision bigint NOT NULL
+);
+
+ALTER TABLE public.trans ADD COLUMN journal uuid;
+
+ALTER TABLE ONLY public.journal
+    ADD CONSTRAINT journal_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.posting
+    ADD CONSTRAINT posting_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.system_acc
+    ADD CONSTRAINT "system-acc-type-currency_unq" UNIQUE (type, currency);
+
+ALTER TABLE ONLY public.system_acc
+    ADD CONSTRAINT "system-acc_pkey" PRIMARY KEY (id);
+
+CREATE INDEX "posting-acc_idx" ON public.posting USING btree (acc);
+
+CREATE INDEX "posting-journal_idx" ON public.posting USING btree (journal);
+
+CREATE INDEX "trans-journal_idx" ON public.trans USING btree (journal);
+
+CREATE CONSTRAINT TRIGGER "posting-balance_chk" AFTER INSERT OR UPDATE ON public.posting DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE PROCEDURE public.check_journal_balance();
+
+ALTER TABLE ONLY public.posting
+    ADD CONSTRAINT "posting-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE RESTRICT,
+    ADD CONSTRAINT "posting-journal_ref" FOREIGN KEY (journal) REFERENCES public.journal(id) ON DELETE RESTRICT,
+    ADD CONSTRAINT "posting-system-acc_ref" FOREIGN KEY (system_acc) REFERENCES public.system_acc(id) ON DELETE RESTRICT;
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-journal_ref" FOREIGN KEY (journal) REFERENCES public.journal(id) ON DELETE RESTRICT;
+
+ALTER TABLE ONLY public.acc
+    DROP CONSTRAINT "acc-client_ref",
+    ADD CONSTRAINT "acc-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE RESTRICT;
+
+ALTER TABLE ONLY public.trans
+    DROP CONSTRAINT "trans-acc_ref",
+    ADD CONSTRAINT "trans-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE RESTRICT;
+
+CREATE TEMPORARY TABLE opening ON COMMIT DROP AS
+    SELECT acc.id AS acc, acc.currency, acc.balance, public.gen_random_uuid() AS journal
+    FROM public.acc
+    WHERE acc.balance <> 0;
+
+INSERT INTO public.journal(id, "time")
+    SELECT journal, now() AT TIME ZONE 'UTC' FROM opening;
+
+-- 9 is the opening balance house account type.
+INSERT INTO public.system_acc(id, type, currency, balance, revision)
+    SELECT public.gen_random_uuid(), 9, total.currency, -total.value, total.number
+    FROM (
+        SELECT currency, SUM(balance) AS value, COUNT(*) AS number
+        FROM opening
+        GROUP BY currency) total;
+
+INSERT INTO public.posting(id, journal, acc, system_acc, value, currency)
+    SELECT public.gen_random_uuid(), journal, acc, NULL, balance, currency
+    FROM opening;
+
+INSERT INTO public.posting(id, journal, acc, system_acc, value, currency)
+    SELECT public.gen_random_uuid(), opening.journal, NULL, system_acc.id,
+        -opening.balance, opening.currency
+    FROM opening
+        JOIN public.system_acc
+            ON system_acc.type = 9 AND system_acc.currency = opening.currency;
+
+-- 10101 is the succeeded transaction status.
+UPDATE public.trans
+    SET journal = opening.journal
+    FROM opening
+    WHERE trans.acc = opening.acc AND trans.status = 10101;
+
+COMMIT;
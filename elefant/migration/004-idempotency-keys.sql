@@ -0,0 +1,27 @@
+--
+-- Money-moving requests with the idempotency key return the stored response
+-- when they are repeated. Requests which are executed in several
+-- transactions reserve the key before the execution, the reserved key
+-- doesn't have the status code until the request is completed.
+--
+
+BEGIN;
+
+CREATE TABLE public.idempotency_key (
+    client uuid NOT NULL,
+    key character varying(255) NOT NULL,
+    request_hash character(64) NOT NULL,
+    status_code smallint,
+    body text NOT NULL,
+    "time" timestamp without time zone NOT NULL
+);
+
+ALTER TABLE ONLY public.idempotency_key
+    ADD CONSTRAINT "idempotency-key_pkey" PRIMARY KEY (client, key);
+
+CREATE INDEX "idempotency-key-time_idx" ON public.idempotency_key USING btree ("time");
+
+ALTER TABLE ONLY public.idempotency_key
+    ADD CONSTRAINT "idempotency-key-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+COMMIT;
@@ -0,0 +1,40 @@
+--
+-- Transaction status changes are stored in the history. The history could
+-- have the same status several times, so records are identified by
+-- the sequence. Existing transactions get the record with the current
+-- status at the transaction time, as new transactions get it at creation.
+--
+
+BEGIN;
+
+CREATE TABLE public.trans_status (
+    id bigint NOT NULL,
+    trans uuid NOT NULL,
+    status smallint NOT NULL,
+    status_reason text,
+    "time" timestamp without time zone NOT NULL
+);
+
+CREATE SEQUENCE public."trans-status_id_seq"
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE public."trans-status_id_seq" OWNED BY public.trans_status.id;
+
+ALTER TABLE ONLY public.trans_status ALTER COLUMN id SET DEFAULT nextval('public."trans-status_id_seq"'::regclass);
+
+ALTER TABLE ONLY public.trans_status
+    ADD CONSTRAINT "trans-status_pkey" PRIMARY KEY (id);
+
+CREATE INDEX "trans-status-trans_idx" ON public.trans_status USING btree (trans, "time", id);
+
+ALTER TABLE ONLY public.trans_status
+    ADD CONSTRAINT "trans-status-trans_ref" FOREIGN KEY (trans) REFERENCES public.trans(id) ON DELETE CASCADE;
+
+INSERT INTO public.trans_status(trans, status, status_reason, "time")
+    SELECT id, status, status_reason, "time" FROM public.trans;
+
+COMMIT;
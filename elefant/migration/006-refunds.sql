@@ -0,0 +1,14 @@
+--
+-- Refund transaction references the refunded payment.
+--
+
+BEGIN;
+
+ALTER TABLE public.trans ADD COLUMN refund_of uuid;
+
+CREATE INDEX "trans-refund-of_idx" ON public.trans USING btree (refund_of);
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-refund-of_ref" FOREIGN KEY (refund_of) REFERENCES public.trans(id) ON DELETE RESTRICT;
+
+COMMIT;
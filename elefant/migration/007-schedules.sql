@@ -0,0 +1,36 @@
+--
+-- Standing orders pay to the account or the bill by the schedule, which is
+-- executed by the scheduler job.
+--
+
+BEGIN;
+
+CREATE TABLE public.schedule (
+    id uuid NOT NULL,
+    client uuid NOT NULL,
+    acc uuid NOT NULL,
+    target smallint NOT NULL,
+    receiver uuid,
+    bill text,
+    value bigint NOT NULL,
+    recurrence character varying(255),
+    next_time timestamp without time zone,
+    last_run_time timestamp without time zone,
+    "time" timestamp without time zone NOT NULL,
+    CONSTRAINT "schedule-target_chk" CHECK ((((target = 1) AND (receiver IS NOT NULL) AND (bill IS NULL)) OR ((target = 2) AND (receiver IS NULL) AND (bill IS NOT NULL)))),
+    CONSTRAINT "schedule-value_chk" CHECK ((value > 0))
+);
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT schedule_pkey PRIMARY KEY (id);
+
+CREATE INDEX "schedule-acc_idx" ON public.schedule USING btree (acc);
+
+CREATE INDEX "schedule-next-time_idx" ON public.schedule USING btree (next_time) WHERE (next_time IS NOT NULL);
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT "schedule-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "schedule-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "schedule-receiver_ref" FOREIGN KEY (receiver) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+COMMIT;
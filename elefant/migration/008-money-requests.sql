@@ -0,0 +1,36 @@
+--
+-- Clients request money from other clients, the payer accepts or declines
+-- the request before the expiry.
+--
+
+BEGIN;
+
+CREATE TABLE public.money_request (
+    id uuid NOT NULL,
+    payee uuid NOT NULL,
+    payee_acc uuid NOT NULL,
+    payer uuid NOT NULL,
+    payer_acc uuid NOT NULL,
+    value bigint NOT NULL,
+    description character varying(255),
+    status smallint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    expiry timestamp without time zone NOT NULL,
+    CONSTRAINT "money-request-status_chk" CHECK (((status >= 1) AND (status <= 4))),
+    CONSTRAINT "money-request-value_chk" CHECK ((value > 0))
+);
+
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request_pkey" PRIMARY KEY (id);
+
+CREATE INDEX "money-request-payee_idx" ON public.money_request USING btree (payee);
+
+CREATE INDEX "money-request-payer_idx" ON public.money_request USING btree (payer);
+
+ALTER TABLE ONLY public.money_request
+    ADD CONSTRAINT "money-request-payee-acc_ref" FOREIGN KEY (payee_acc) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "money-request-payee_ref" FOREIGN KEY (payee) REFERENCES public.client(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "money-request-payer-acc_ref" FOREIGN KEY (payer_acc) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "money-request-payer_ref" FOREIGN KEY (payer) REFERENCES public.client(id) ON DELETE CASCADE;
+
+COMMIT;
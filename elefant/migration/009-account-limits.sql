@@ -0,0 +1,44 @@
+--
+-- Accounts have spending limits, the client changes them by the confirmation.
+-- Existing clients get the default tier. Existing accounts don't have
+-- limits, the tier limits are converted to the account currency by
+-- the exchange rates, so they are used until the client sets the limits.
+--
+
+BEGIN;
+
+ALTER TABLE public.client ADD COLUMN tier smallint DEFAULT 1 NOT NULL;
+
+CREATE TABLE public.acc_limit (
+    acc uuid NOT NULL,
+    per_trans bigint NOT NULL,
+    daily bigint NOT NULL,
+    monthly bigint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    CONSTRAINT "acc-limit-value_chk" CHECK (((per_trans > 0) AND (per_trans <= daily) AND (daily <= monthly)))
+);
+
+CREATE TABLE public.acc_limit_request (
+    confirmation uuid NOT NULL,
+    acc uuid NOT NULL,
+    per_trans bigint NOT NULL,
+    daily bigint NOT NULL,
+    monthly bigint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    CONSTRAINT "acc-limit-request-value_chk" CHECK (((per_trans > 0) AND (per_trans <= daily) AND (daily <= monthly)))
+);
+
+ALTER TABLE ONLY public.acc_limit
+    ADD CONSTRAINT "acc-limit_pkey" PRIMARY KEY (acc);
+
+ALTER TABLE ONLY public.acc_limit_request
+    ADD CONSTRAINT "acc-limit-request_pkey" PRIMARY KEY (confirmation);
+
+ALTER TABLE ONLY public.acc_limit
+    ADD CONSTRAINT "acc-limit-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+ALTER TABLE ONLY public.acc_limit_request
+    ADD CONSTRAINT "acc-limit-request-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "acc-limit-request-confirmation_ref" FOREIGN KEY (confirmation) REFERENCES public.client_confirm(id) ON DELETE CASCADE;
+
+COMMIT;
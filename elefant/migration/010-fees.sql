@@ -0,0 +1,38 @@
+--
+-- Fees are calculated by rules for the operation and the value band, and
+-- charged by the separate transaction, which references the charged
+-- transaction. There are no rules after the migration, so operations are
+-- free until rules are added.
+--
+
+BEGIN;
+
+CREATE TABLE public.fee_rule (
+    id uuid NOT NULL,
+    method smallint NOT NULL,
+    direction smallint NOT NULL,
+    currency character(3) NOT NULL,
+    min_value bigint DEFAULT 0 NOT NULL,
+    max_value bigint,
+    fixed bigint DEFAULT 0 NOT NULL,
+    percent numeric(9,4) DEFAULT 0 NOT NULL,
+    min_fee bigint DEFAULT 0 NOT NULL,
+    max_fee bigint,
+    CONSTRAINT "fee-rule-direction_chk" CHECK (((direction >= 1) AND (direction <= 2))),
+    CONSTRAINT "fee-rule-fee_chk" CHECK (((fixed >= 0) AND (percent >= (0)::numeric) AND (min_fee >= 0) AND ((max_fee IS NULL) OR (max_fee >= min_fee)))),
+    CONSTRAINT "fee-rule-value_chk" CHECK (((min_value >= 0) AND ((max_value IS NULL) OR (max_value > min_value))))
+);
+
+ALTER TABLE ONLY public.fee_rule
+    ADD CONSTRAINT "fee-rule_pkey" PRIMARY KEY (id);
+
+CREATE INDEX "fee-rule-operation_idx" ON public.fee_rule USING btree (method, direction, currency, min_value);
+
+ALTER TABLE public.trans ADD COLUMN fee_of uuid;
+
+CREATE INDEX "trans-fee-of_idx" ON public.trans USING btree (fee_of);
+
+ALTER TABLE ONLY public.trans
+    ADD CONSTRAINT "trans-fee-of_ref" FOREIGN KEY (fee_of) REFERENCES public.trans(id) ON DELETE RESTRICT;
+
+COMMIT;
@@ -0,0 +1,14 @@
+--
+-- Accounts could have the overdraft limit, the interest is accrued for each
+-- day by the end-of-day balance. Existing accounts don't have overdrafts and
+-- accrued interest.
+--
+
+BEGIN;
+
+ALTER TABLE public.acc
+    ADD COLUMN overdraft bigint DEFAULT 0 NOT NULL,
+    ADD COLUMN overdraft_accrued date,
+    ADD CONSTRAINT "acc-overdraft_chk" CHECK ((overdraft >= 0));
+
+COMMIT;
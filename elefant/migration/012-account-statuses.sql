@@ -0,0 +1,21 @@
+--
+-- Accounts could be frozen and closed, existing accounts are active.
+-- The client could open a new account in the currency of the closed
+-- account, so only not closed accounts are unique by the currency.
+--
+
+BEGIN;
+
+ALTER TABLE public.acc
+    ADD COLUMN status smallint DEFAULT 1 NOT NULL,
+    ADD CONSTRAINT "acc-status_chk" CHECK (((status >= 1) AND (status <= 4)));
+
+ALTER TABLE ONLY public.acc
+    DROP CONSTRAINT "acc-currency_unq";
+
+CREATE UNIQUE INDEX "acc-client-currency_idx" ON public.acc USING btree (client, currency) WHERE (status <> 4);
+
+-- Overdraft interest is accrued for all not closed accounts.
+CREATE INDEX "acc-overdraft_idx" ON public.acc USING btree (overdraft_accrued) WHERE (status <> 4);
+
+COMMIT;
@@ -0,0 +1,39 @@
+--
+-- Holds reserve account funds until they are captured, voided or expired.
+-- The account has the sum of the active holds, existing accounts don't have
+-- holds.
+--
+
+BEGIN;
+
+ALTER TABLE public.acc
+    ADD COLUMN held bigint DEFAULT 0 NOT NULL,
+    ADD CONSTRAINT "acc-held_chk" CHECK ((held >= 0));
+
+CREATE TABLE public.hold (
+    id uuid NOT NULL,
+    acc uuid NOT NULL,
+    client uuid NOT NULL,
+    value bigint NOT NULL,
+    captured bigint,
+    description character varying(255),
+    status smallint NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    expiry timestamp without time zone NOT NULL,
+    CONSTRAINT "hold-captured_chk" CHECK (((captured IS NULL) OR ((captured > 0) AND (captured <= value)))),
+    CONSTRAINT "hold-status_chk" CHECK (((status >= 1) AND (status <= 4))),
+    CONSTRAINT "hold-value_chk" CHECK ((value > 0))
+);
+
+ALTER TABLE ONLY public.hold
+    ADD CONSTRAINT hold_pkey PRIMARY KEY (id);
+
+CREATE INDEX "hold-acc_idx" ON public.hold USING btree (acc, "time");
+
+CREATE INDEX "hold-expiry_idx" ON public.hold USING btree (expiry) WHERE (status = 1);
+
+ALTER TABLE ONLY public.hold
+    ADD CONSTRAINT "hold-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE,
+    ADD CONSTRAINT "hold-client_ref" FOREIGN KEY (client) REFERENCES public.client(id) ON DELETE CASCADE;
+
+COMMIT;
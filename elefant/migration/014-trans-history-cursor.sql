@@ -0,0 +1,12 @@
+--
+-- Account history is paginated by the cursor with the transaction time and
+-- ID, as several transactions could have the same time.
+--
+
+BEGIN;
+
+DROP INDEX public."trans-acc_idx";
+
+CREATE INDEX "trans-acc_idx" ON public.trans USING btree (acc, "time", id);
+
+COMMIT;
@@ -0,0 +1,36 @@
+--
+-- Account history is filtered by the transaction status and the method type,
+-- and searched by the method details and the status reason. Generated
+-- columns are not supported by PostgreSQL 11, so the search vector is set by
+-- the trigger.
+--
+
+BEGIN;
+
+CREATE INDEX "method-type_idx" ON public.method USING btree (type);
+
+CREATE INDEX "trans-acc-status_idx" ON public.trans USING btree (acc, status, "time", id);
+
+CREATE FUNCTION public.set_trans_search() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    NEW.search := to_tsvector('simple',
+        COALESCE(NEW.method_arg ->> 'e', '') || ' ' ||
+        COALESCE(NEW.method_arg ->> 'b', '') || ' ' ||
+        COALESCE(NEW.method_arg ->> 'd', '') || ' ' ||
+        COALESCE(NEW.status_reason, ''));
+    RETURN NEW;
+END;
+$$;
+
+ALTER TABLE public.trans ADD COLUMN search tsvector;
+
+CREATE TRIGGER "trans-search_set" BEFORE INSERT OR UPDATE OF method_arg, status_reason ON public.trans FOR EACH ROW EXECUTE PROCEDURE public.set_trans_search();
+
+-- The trigger sets the search vector for existing transactions.
+UPDATE public.trans SET method_arg = method_arg;
+
+CREATE INDEX "trans-search_idx" ON public.trans USING gin (search);
+
+COMMIT;
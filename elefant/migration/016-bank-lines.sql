@@ -0,0 +1,41 @@
+--
+-- Imported bank statement lines are stored by the line key, so the same line
+-- isn't credited twice. Lines which are not matched with an account wait for
+-- the review.
+--
+
+BEGIN;
+
+CREATE TABLE public.bank_line (
+    id uuid NOT NULL,
+    key character(64) NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    value bigint NOT NULL,
+    currency character(3) NOT NULL,
+    reference character varying(255) NOT NULL,
+    remittance text NOT NULL,
+    debtor character varying(255) NOT NULL,
+    debtor_acc character varying(255) NOT NULL,
+    status smallint NOT NULL,
+    reason character varying(255),
+    acc uuid,
+    trans uuid,
+    import_time timestamp without time zone NOT NULL,
+    CONSTRAINT "bank-line-credited_chk" CHECK (((status <> 2) OR ((acc IS NOT NULL) AND (trans IS NOT NULL)))),
+    CONSTRAINT "bank-line-status_chk" CHECK (((status >= 1) AND (status <= 2))),
+    CONSTRAINT "bank-line-value_chk" CHECK ((value > 0))
+);
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line-key_unq" UNIQUE (key);
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line_pkey" PRIMARY KEY (id);
+
+CREATE INDEX "bank-line-review_idx" ON public.bank_line USING btree ("time") WHERE (status = 1);
+
+ALTER TABLE ONLY public.bank_line
+    ADD CONSTRAINT "bank-line-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id),
+    ADD CONSTRAINT "bank-line-trans_ref" FOREIGN KEY (trans) REFERENCES public.trans(id);
+
+COMMIT;
@@ -0,0 +1,31 @@
+--
+-- Reconciliation job reports accounts which balances don't match
+-- the ledger postings or the transactions.
+--
+
+BEGIN;
+
+CREATE TABLE public.reconcile_report (
+    run uuid NOT NULL,
+    acc uuid NOT NULL,
+    "time" timestamp without time zone NOT NULL,
+    currency character(3) NOT NULL,
+    balance bigint NOT NULL,
+    posted bigint NOT NULL,
+    trans_value bigint NOT NULL,
+    revision bigint NOT NULL,
+    trans_number bigint NOT NULL,
+    reason text NOT NULL
+);
+
+ALTER TABLE ONLY public.reconcile_report
+    ADD CONSTRAINT "reconcile-report_pkey" PRIMARY KEY (run, acc);
+
+CREATE INDEX "reconcile-report-acc_idx" ON public.reconcile_report USING btree (acc, "time");
+
+CREATE INDEX "reconcile-report-time_idx" ON public.reconcile_report USING btree ("time");
+
+ALTER TABLE ONLY public.reconcile_report
+    ADD CONSTRAINT "reconcile-report-acc_ref" FOREIGN KEY (acc) REFERENCES public.acc(id) ON DELETE CASCADE;
+
+COMMIT;
@@ -0,0 +1,29 @@
+--
+-- Interest is accrued daily on positive balances by the rate for
+-- the currency and the client tier. The account keeps the interest
+-- fraction, which is less than the currency minor unit, until it's paid.
+-- There are no rates after the migration, so interest isn't paid until
+-- rates are added.
+--
+
+BEGIN;
+
+ALTER TABLE public.acc
+    ADD COLUMN interest_pending numeric(30,12) DEFAULT 0 NOT NULL,
+    ADD COLUMN interest_accrued date,
+    ADD CONSTRAINT "acc-interest-pending_chk" CHECK ((interest_pending >= (0)::numeric));
+
+CREATE TABLE public.interest_rate (
+    currency character(3) NOT NULL,
+    tier smallint NOT NULL,
+    percent numeric(9,4) NOT NULL,
+    CONSTRAINT "interest-rate-percent_chk" CHECK ((percent >= (0)::numeric)),
+    CONSTRAINT "interest-rate-tier_chk" CHECK (((tier >= 1) AND (tier <= 3)))
+);
+
+ALTER TABLE ONLY public.interest_rate
+    ADD CONSTRAINT "interest-rate_pkey" PRIMARY KEY (currency, tier);
+
+CREATE INDEX "acc-interest_idx" ON public.acc USING btree (interest_accrued) WHERE (status <> 4);
+
+COMMIT;
@@ -0,0 +1,49 @@
+--
+-- Tax payments and bill standing orders are made to the registered tax
+-- authority, which validates the bill. Each authority has its own tax
+-- payable house account, so the sum, which has to be transferred to
+-- the authority, is known. Payments which are made before the registry stay
+-- on the shared tax payable house account of the currency.
+--
+-- A new authority has to be added with a new tax payable house account in
+-- the authority currency.
+--
+
+BEGIN;
+
+ALTER TABLE ONLY public.system_acc
+    DROP CONSTRAINT "system-acc-type-currency_unq";
+
+-- 2 is the tax payable house account type.
+CREATE UNIQUE INDEX "system-acc-type-currency_unq" ON public.system_acc USING btree (type, currency) WHERE (type <> 2);
+
+CREATE TABLE public.tax_authority (
+    id uuid NOT NULL,
+    name character varying(255) NOT NULL,
+    currency character(3) NOT NULL,
+    bill_format character varying(255) NOT NULL,
+    check_digit smallint,
+    system_acc uuid NOT NULL
+);
+
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority-name-currency_unq" UNIQUE (name, currency);
+
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority-system-acc_unq" UNIQUE (system_acc);
+
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority_pkey" PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.tax_authority
+    ADD CONSTRAINT "tax-authority-system-acc_ref" FOREIGN KEY (system_acc) REFERENCES public.system_acc(id) ON DELETE RESTRICT;
+
+ALTER TABLE public.schedule
+    ADD COLUMN tax_authority uuid,
+    DROP CONSTRAINT "schedule-target_chk",
+    ADD CONSTRAINT "schedule-target_chk" CHECK ((((target = 1) AND (receiver IS NOT NULL) AND (bill IS NULL) AND (tax_authority IS NULL)) OR ((target = 2) AND (receiver IS NULL) AND (bill IS NOT NULL))));
+
+ALTER TABLE ONLY public.schedule
+    ADD CONSTRAINT "schedule-tax-authority_ref" FOREIGN KEY (tax_authority) REFERENCES public.tax_authority(id) ON DELETE RESTRICT;
+
+COMMIT;
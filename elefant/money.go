@@ -0,0 +1,219 @@
+package elefant
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// RoundingMode describes how an amount which is not representable in currency
+// minor units has to be rounded.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest minor unit, ties go to the even
+	// neighbour (banker's rounding). It is the default rounding for
+	// calculated amounts as it does not accumulate drift.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest minor unit, ties go away from zero.
+	RoundHalfUp
+	// RoundDown truncates toward zero.
+	RoundDown
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Money describes an amount of money in currency minor units (cents for EUR).
+// The amount is never stored as a floating point value.
+type Money struct {
+	units    int64
+	currency Currency
+}
+
+// decimalFormat is the only accepted money value format, big.Rat also accepts
+// exponents and fractions, which are not money values.
+var decimalFormat = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// NewMoney creates new money value from minor units.
+func NewMoney(units int64, currency Currency) Money {
+	return Money{units: units, currency: currency}
+}
+
+// ParseMoney parses decimal money value (like "12.34") for given currency.
+// The value with more fractional digits than the currency minor units
+// allows is rejected, it is never rounded silently.
+func ParseMoney(source string, currency Currency) (Money, error) {
+	source = strings.TrimSpace(source)
+	if !decimalFormat.MatchString(source) {
+		return Money{}, fmt.Errorf(`value "%s" is not a decimal number`, source)
+	}
+	value, isValid := new(big.Rat).SetString(source)
+	if !isValid {
+		return Money{}, fmt.Errorf(`value "%s" is not a decimal number`, source)
+	}
+	value.Mul(value, minorUnitsScale(currency))
+	if !value.IsInt() {
+		return Money{}, fmt.Errorf(
+			`value "%s" has more fractional digits than %s allows (%d)`,
+			source, currency.GetISO(), currency.GetMinorUnits())
+	}
+	units := value.Num()
+	if !units.IsInt64() {
+		return Money{}, fmt.Errorf(`value "%s" is too large`, source)
+	}
+	return NewMoney(units.Int64(), currency), nil
+}
+
+// NewMoneyFromRat creates new money value from an exact amount in major
+// currency units rounding it to minor units by the given rule.
+func NewMoneyFromRat(
+	value *big.Rat, currency Currency, rounding RoundingMode) Money {
+	scaled := new(big.Rat).Mul(value, minorUnitsScale(currency))
+	return NewMoney(roundRat(scaled, rounding), currency)
+}
+
+func minorUnitsScale(currency Currency) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(
+		big.NewInt(10), big.NewInt(int64(getMinorUnits(currency))), nil))
+}
+
+// getMinorUnits returns the currency minor units, zero Money value doesn't
+// have currency and is presented without fractional digits.
+func getMinorUnits(currency Currency) int {
+	if currency == nil {
+		return 0
+	}
+	return currency.GetMinorUnits()
+}
+
+func getISO(currency Currency) string {
+	if currency == nil {
+		return ""
+	}
+	return currency.GetISO()
+}
+
+func roundRat(value *big.Rat, rounding RoundingMode) int64 {
+	quo, rem := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if rem.Sign() != 0 && rounding != RoundDown {
+		// Compares the double remainder with the denominator to find out on
+		// which side of the half the value is.
+		cmp := new(big.Int).Abs(new(big.Int).Lsh(rem, 1)).Cmp(value.Denom())
+		if cmp > 0 ||
+			(cmp == 0 &&
+				(rounding == RoundHalfUp || quo.Bit(0) == 1)) {
+			quo.Add(quo, big.NewInt(int64(value.Sign())))
+		}
+	}
+	if !quo.IsInt64() {
+		panic(fmt.Sprintf(`money value "%s" overflows`, value.String()))
+	}
+	return quo.Int64()
+}
+
+// GetUnits returns amount in currency minor units.
+func (money Money) GetUnits() int64 { return money.units }
+
+// GetCurrency returns money currency.
+func (money Money) GetCurrency() Currency { return money.currency }
+
+// GetRat returns exact amount in major currency units.
+func (money Money) GetRat() *big.Rat {
+	return new(big.Rat).Quo(
+		new(big.Rat).SetInt64(money.units), minorUnitsScale(money.currency))
+}
+
+// IsZero returns true if amount is zero.
+func (money Money) IsZero() bool { return money.units == 0 }
+
+// IsPositive returns true if amount is greater than zero.
+func (money Money) IsPositive() bool { return money.units > 0 }
+
+// IsNegative returns true if amount is less than zero.
+func (money Money) IsNegative() bool { return money.units < 0 }
+
+// Neg returns the same amount with the opposite sign.
+func (money Money) Neg() Money {
+	if money.units == math.MinInt64 {
+		panic(fmt.Sprintf(`money negation "%s" overflows`, money))
+	}
+	return NewMoney(-money.units, money.currency)
+}
+
+// Abs returns the amount without sign.
+func (money Money) Abs() Money {
+	if money.units < 0 {
+		return money.Neg()
+	}
+	return money
+}
+
+// Add returns the sum of two amounts in the same currency.
+func (money Money) Add(rhs Money) Money {
+	money.checkCurrency(rhs)
+	if (rhs.units > 0 && money.units > math.MaxInt64-rhs.units) ||
+		(rhs.units < 0 && money.units < math.MinInt64-rhs.units) {
+		panic(fmt.Sprintf(`money sum "%s" + "%s" overflows`, money, rhs))
+	}
+	return NewMoney(money.units+rhs.units, money.currency)
+}
+
+// Sub returns the difference of two amounts in the same currency.
+func (money Money) Sub(rhs Money) Money { return money.Add(rhs.Neg()) }
+
+// Cmp compares two amounts in the same currency and returns -1, 0 or +1.
+func (money Money) Cmp(rhs Money) int {
+	money.checkCurrency(rhs)
+	switch {
+	case money.units < rhs.units:
+		return -1
+	case money.units > rhs.units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// MulRat multiplies the amount by an exact factor and rounds the result to
+// minor units by the given rule.
+func (money Money) MulRat(factor *big.Rat, rounding RoundingMode) Money {
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(money.units), factor)
+	return NewMoney(roundRat(value, rounding), money.currency)
+}
+
+func (money Money) checkCurrency(rhs Money) {
+	if getISO(money.currency) != getISO(rhs.currency) {
+		panic(fmt.Sprintf(`money currency mismatch: "%s" and "%s"`,
+			getISO(money.currency), getISO(rhs.currency)))
+	}
+}
+
+// String returns the amount as a decimal string in major units ("-12.34").
+func (money Money) String() string {
+	exp := getMinorUnits(money.currency)
+	units := money.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+	}
+	abs := new(big.Int).Abs(big.NewInt(units)).String()
+	if exp == 0 {
+		return sign + abs
+	}
+	if len(abs) <= exp {
+		abs = strings.Repeat("0", exp-len(abs)+1) + abs
+	}
+	return sign + abs[:len(abs)-exp] + "." + abs[len(abs)-exp:]
+}
+
+// MarshalJSON implements the json.Marshaler interface. The amount is
+// serialized as an exact decimal number in major units.
+func (money Money) MarshalJSON() ([]byte, error) {
+	return []byte(money.String()), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
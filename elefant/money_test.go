@@ -0,0 +1,230 @@
+package elefant
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func newMoneyTestCurrency(t *testing.T, iso string) Currency {
+	result, err := NewCurrency(iso)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// expectMoneyPanic checks that the function panics.
+func expectMoneyPanic(t *testing.T, name string, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf(`%s doesn't panic.`, name)
+		}
+	}()
+	f()
+}
+
+func TestParseMoney(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		currency string
+		units    int64
+		expected string
+	}{
+		{"12.34", "EUR", 1234, "12.34"},
+		{" 12.34 ", "EUR", 1234, "12.34"},
+		{"12.3", "EUR", 1230, "12.30"},
+		{"12", "EUR", 1200, "12.00"},
+		{"0.01", "EUR", 1, "0.01"},
+		{"0", "EUR", 0, "0.00"},
+		{"-12.34", "EUR", -1234, "-12.34"},
+		{"-0.05", "EUR", -5, "-0.05"},
+		{"12.340", "EUR", 1234, "12.34"},
+		{"007.10", "EUR", 710, "7.10"},
+		{"123", "JPY", 123, "123"},
+		{"123.0", "JPY", 123, "123"},
+		{"1.234", "BHD", 1234, "1.234"},
+		{"0.0001", "CLF", 1, "0.0001"},
+		{"92233720368547758.07", "EUR", math.MaxInt64, "92233720368547758.07"},
+		{"-92233720368547758.08", "EUR", math.MinInt64, "-92233720368547758.08"},
+	} {
+		currency := newMoneyTestCurrency(t, test.currency)
+		result, err := ParseMoney(test.source, currency)
+		if err != nil {
+			t.Errorf(`Failed to parse "%s" %s: "%v".`,
+				test.source, test.currency, err)
+			continue
+		}
+		if result.GetUnits() != test.units ||
+			result.GetCurrency().GetISO() != test.currency {
+			t.Errorf(`"%s" %s is parsed as %d %s, but expected %d.`,
+				test.source, test.currency, result.GetUnits(),
+				result.GetCurrency().GetISO(), test.units)
+		}
+		if result.String() != test.expected {
+			t.Errorf(`"%s" %s is formatted as "%s", but expected "%s".`,
+				test.source, test.currency, result, test.expected)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, test := range []struct {
+		source   string
+		currency string
+	}{
+		// More fractional digits than the currency minor units.
+		{"12.345", "EUR"},
+		{"0.001", "EUR"},
+		{"-0.001", "EUR"},
+		{"1.5", "JPY"},
+		{"1.2345", "BHD"},
+		// Not decimal numbers.
+		{"", "EUR"},
+		{"abc", "EUR"},
+		{"1e3", "EUR"},
+		{"1/2", "EUR"},
+		{"1,50", "EUR"},
+		{".5", "EUR"},
+		{"5.", "EUR"},
+		{"+5", "EUR"},
+		{"--5", "EUR"},
+		{"5 EUR", "EUR"},
+		// Overflows int64 minor units.
+		{"92233720368547758.08", "EUR"},
+		{"-92233720368547758.09", "EUR"},
+		{"100000000000000000000", "JPY"},
+	} {
+		currency := newMoneyTestCurrency(t, test.currency)
+		if result, err := ParseMoney(test.source, currency); err == nil {
+			t.Errorf(`"%s" %s is parsed as %s.`,
+				test.source, test.currency, result)
+		}
+	}
+}
+
+func TestNewMoneyFromRatRounding(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		rounding RoundingMode
+		expected int64
+	}{
+		{"1.234", RoundHalfEven, 123},
+		{"1.236", RoundHalfEven, 124},
+		{"1.235", RoundHalfEven, 124},
+		{"1.245", RoundHalfEven, 124},
+		{"-1.235", RoundHalfEven, -124},
+		{"-1.245", RoundHalfEven, -124},
+		{"1.2350001", RoundHalfEven, 124},
+		{"1.2449999", RoundHalfEven, 124},
+		{"1.234", RoundHalfUp, 123},
+		{"1.235", RoundHalfUp, 124},
+		{"1.245", RoundHalfUp, 125},
+		{"-1.245", RoundHalfUp, -125},
+		{"-1.244", RoundHalfUp, -124},
+		{"1.239", RoundDown, 123},
+		{"-1.239", RoundDown, -123},
+		{"0.009", RoundDown, 0},
+		{"1/3", RoundHalfEven, 33},
+		{"2/3", RoundHalfEven, 67},
+		{"-2/3", RoundDown, -66},
+		{"1.23", RoundDown, 123},
+	} {
+		value, isValid := new(big.Rat).SetString(test.value)
+		if !isValid {
+			t.Fatalf(`Invalid test value "%s".`, test.value)
+		}
+		result := NewMoneyFromRat(
+			value, newMoneyTestCurrency(t, "EUR"), test.rounding)
+		if result.GetUnits() != test.expected {
+			t.Errorf(`"%s" with rounding %d is %d, but expected %d.`,
+				test.value, test.rounding, result.GetUnits(), test.expected)
+		}
+	}
+}
+
+func TestMoneyMulRat(t *testing.T) {
+	eur := newMoneyTestCurrency(t, "EUR")
+	for _, test := range []struct {
+		units    int64
+		factor   *big.Rat
+		rounding RoundingMode
+		expected int64
+	}{
+		// 2.5% of 1.00 is 0.025.
+		{100, big.NewRat(25, 1000), RoundHalfEven, 2},
+		{100, big.NewRat(25, 1000), RoundHalfUp, 3},
+		{100, big.NewRat(25, 1000), RoundDown, 2},
+		{300, big.NewRat(25, 1000), RoundHalfEven, 8},
+		{-100, big.NewRat(25, 1000), RoundHalfUp, -3},
+		{1000, big.NewRat(1, 3), RoundHalfEven, 333},
+		{1000, big.NewRat(-1, 1), RoundDown, -1000},
+	} {
+		result := NewMoney(test.units, eur).MulRat(test.factor, test.rounding)
+		if result.GetUnits() != test.expected {
+			t.Errorf(`%d * %s with rounding %d is %d, but expected %d.`,
+				test.units, test.factor, test.rounding, result.GetUnits(),
+				test.expected)
+		}
+	}
+}
+
+func TestMoneyOverflow(t *testing.T) {
+	eur := newMoneyTestCurrency(t, "EUR")
+	max := NewMoney(math.MaxInt64, eur)
+	min := NewMoney(math.MinInt64, eur)
+	one := NewMoney(1, eur)
+
+	if result := max.Add(one.Neg()).Add(one); result.Cmp(max) != 0 {
+		t.Errorf("Max value is calculated as %s.", result)
+	}
+	if result := min.Sub(one.Neg()); result.GetUnits() != math.MinInt64+1 {
+		t.Errorf("Min value is calculated as %s.", result)
+	}
+
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"Max + 1", func() { max.Add(one) }},
+		{"Min - 1", func() { min.Sub(one) }},
+		{"Min + -1", func() { min.Add(one.Neg()) }},
+		{"Max - -1", func() { max.Sub(one.Neg()) }},
+		{"Max - Min", func() { max.Sub(min) }},
+		{"-Min", func() { min.Neg() }},
+		{"|Min|", func() { min.Abs() }},
+		{"Max * 2", func() { max.MulRat(big.NewRat(2, 1), RoundDown) }},
+		{
+			"Rat overflow",
+			func() {
+				NewMoneyFromRat(
+					new(big.Rat).SetInt64(math.MaxInt64), eur, RoundHalfEven)
+			}},
+	} {
+		expectMoneyPanic(t, test.name, test.f)
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	eur := NewMoney(100, newMoneyTestCurrency(t, "EUR"))
+	usd := NewMoney(100, newMoneyTestCurrency(t, "USD"))
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"Add", func() { eur.Add(usd) }},
+		{"Sub", func() { eur.Sub(usd) }},
+		{"Cmp", func() { eur.Cmp(usd) }},
+		{"Add without currency", func() { eur.Add(Money{}) }},
+	} {
+		expectMoneyPanic(t, test.name, test.f)
+	}
+
+	if result := eur.Add(NewMoney(-100, eur.GetCurrency())); !result.IsZero() {
+		t.Errorf("Sum in the same currency is %s.", result)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,130 @@
+package elefant
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MoneyRequestID is a money request unique ID.
+type MoneyRequestID = uuid.UUID
+
+func newMoneyRequestID() MoneyRequestID { return uuid.New() }
+
+// ParseMoneyRequestID parses money request ID in string.
+func ParseMoneyRequestID(source string) (MoneyRequestID, error) {
+	return uuid.Parse(source)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MoneyRequestStatus is a money request status.
+type MoneyRequestStatus int16
+
+const (
+	// MoneyRequestStatusPending is a status of the request which waits for
+	// the payer decision.
+	MoneyRequestStatusPending MoneyRequestStatus = 1
+	// MoneyRequestStatusAccepted is a status of the request paid by the payer.
+	MoneyRequestStatusAccepted MoneyRequestStatus = 2
+	// MoneyRequestStatusDeclined is a status of the request declined by
+	// the payer.
+	MoneyRequestStatusDeclined MoneyRequestStatus = 3
+	// MoneyRequestStatusCancelled is a status of the request cancelled by
+	// the payee.
+	MoneyRequestStatusCancelled MoneyRequestStatus = 4
+	// MoneyRequestStatusExpired is a status of the pending request with passed
+	// expiry time, it's never stored.
+	MoneyRequestStatusExpired MoneyRequestStatus = 5
+)
+
+func parseMoneyRequestStatusFromDB(source int16) (MoneyRequestStatus, error) {
+	switch MoneyRequestStatus(source) {
+	case MoneyRequestStatusPending,
+		MoneyRequestStatusAccepted,
+		MoneyRequestStatusDeclined,
+		MoneyRequestStatusCancelled:
+		return MoneyRequestStatus(source), nil
+	default:
+		return 0, fmt.Errorf(
+			`failed to parse money request status from DB-value "%v"`, source)
+	}
+}
+
+func (status MoneyRequestStatus) String() string {
+	switch status {
+	case MoneyRequestStatusPending:
+		return "pending"
+	case MoneyRequestStatusAccepted:
+		return "accepted"
+	case MoneyRequestStatusDeclined:
+		return "declined"
+	case MoneyRequestStatusCancelled:
+		return "cancelled"
+	case MoneyRequestStatusExpired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MoneyRequest describes request from the payee to the payer to pay
+// the value to the payee account.
+type MoneyRequest struct {
+	ID           MoneyRequestID
+	Payee        ClientID
+	PayeeEmail   string
+	PayeeAccount AccountID
+	Payer        ClientID
+	PayerEmail   string
+	PayerAccount AccountID
+	// Value is in the currency of the both accounts.
+	Value       Money
+	Description string
+	// Status is a stored status, use GetStatus to get the actual status.
+	Status MoneyRequestStatus
+	Time   time.Time
+	Expiry time.Time
+}
+
+// NewMoneyRequest creates new pending money request from the payee account
+// to the payer account in the same currency.
+func NewMoneyRequest(
+	payee Client,
+	payeeAccount AccountID,
+	payer Client,
+	payerAccount AccountID,
+	value Money,
+	description string,
+	liveTime time.Duration) *MoneyRequest {
+	now := time.Now().UTC()
+	return &MoneyRequest{
+		ID:           newMoneyRequestID(),
+		Payee:        payee.GetID(),
+		PayeeEmail:   payee.GetEmail(),
+		PayeeAccount: payeeAccount,
+		Payer:        payer.GetID(),
+		PayerEmail:   payer.GetEmail(),
+		PayerAccount: payerAccount,
+		Value:        value,
+		Description:  description,
+		Status:       MoneyRequestStatusPending,
+		Time:         now,
+		Expiry:       now.Add(liveTime)}
+}
+
+// GetStatus returns the actual status, which is expired for the pending
+// request with passed expiry time.
+func (request *MoneyRequest) GetStatus(now time.Time) MoneyRequestStatus {
+	if request.Status == MoneyRequestStatusPending && !now.Before(request.Expiry) {
+		return MoneyRequestStatusExpired
+	}
+	return request.Status
+}
+
+////////////////////////////////////////////////////////////////////////////////
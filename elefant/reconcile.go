@@ -0,0 +1,57 @@
+package elefant
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ReconcileRunID is a reconciliation run unique ID, which groups
+// the discrepancies found by the run in the report.
+type ReconcileRunID = uuid.UUID
+
+// NewReconcileRunID creates new reconciliation run ID.
+func NewReconcileRunID() ReconcileRunID { return uuid.New() }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// AccountReconciliation has the account balance and revision with the values
+// which are calculated from the ledger and the transactions at the same time.
+type AccountReconciliation struct {
+	Account AccountID
+	Balance Money
+	// Posted is the sum of the account postings in the ledger.
+	Posted Money
+	// TransValue is the sum of the account transactions which are posted to
+	// the ledger, including refunded and reversed later.
+	TransValue    Money
+	Revision      int64
+	NumberOfTrans int64
+	Time          time.Time
+}
+
+// GetDiscrepancies returns the list of found discrepancies, or empty list if
+// the account is reconciled.
+func (r *AccountReconciliation) GetDiscrepancies() []string {
+	result := []string{}
+	if r.Balance.GetUnits() != r.Posted.GetUnits() {
+		result = append(result, fmt.Sprintf(
+			"balance %s is not equal to ledger postings %s", r.Balance, r.Posted))
+	}
+	if r.Balance.GetUnits() != r.TransValue.GetUnits() {
+		result = append(result, fmt.Sprintf(
+			"balance %s is not equal to transactions %s", r.Balance, r.TransValue))
+	}
+	// The revision starts from 1 and is incremented by each balance change,
+	// but also by holds and status changes, so it could be only greater.
+	if r.Revision < r.NumberOfTrans+1 {
+		result = append(result, fmt.Sprintf(
+			"revision %d is less than %d transactions", r.Revision, r.NumberOfTrans))
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,321 @@
+package elefant
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ScheduleID is a standing order unique ID.
+type ScheduleID = uuid.UUID
+
+func newScheduleID() ScheduleID { return uuid.New() }
+
+// ParseScheduleID parses standing order ID in string.
+func ParseScheduleID(source string) (ScheduleID, error) {
+	return uuid.Parse(source)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ScheduleTarget is a standing order payment type.
+type ScheduleTarget int16
+
+const (
+	// ScheduleTargetAccount is a payment to another account.
+	ScheduleTargetAccount ScheduleTarget = 1
+	// ScheduleTargetTax is a tax payment.
+	ScheduleTargetTax ScheduleTarget = 2
+)
+
+// ParseScheduleTarget parses standing order payment type name.
+func ParseScheduleTarget(source string) (ScheduleTarget, error) {
+	for _, target := range []ScheduleTarget{
+		ScheduleTargetAccount,
+		ScheduleTargetTax} {
+		if target.String() == source {
+			return target, nil
+		}
+	}
+	return 0, fmt.Errorf(`standing order target "%s" is unknown`, source)
+}
+
+func parseScheduleTargetFromDB(source int16) (ScheduleTarget, error) {
+	switch ScheduleTarget(source) {
+	case ScheduleTargetAccount, ScheduleTargetTax:
+		return ScheduleTarget(source), nil
+	default:
+		return 0, fmt.Errorf(
+			`failed to parse standing order target from DB-value "%v"`, source)
+	}
+}
+
+// String returns standing order payment type name.
+func (target ScheduleTarget) String() string {
+	switch target {
+	case ScheduleTargetAccount:
+		return "account"
+	case ScheduleTargetTax:
+		return "tax"
+	default:
+		return "unknown"
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ScheduleRecurrence is a cron-like recurrence rule in UTC with five fields:
+// minute (0-59), hour (0-23), day of month (1-31), month (1-12) and day of
+// week (0-6, 0 and 7 are Sunday). Each field is "*", a number, a range "1-5",
+// a step "*/15" or "1-30/2", or a list of them separated by comma. If both
+// day fields are restricted - the rule fires on any of them, as cron does.
+type ScheduleRecurrence struct {
+	source   string
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	anyDay   bool
+	anyWeek  bool
+}
+
+// scheduleRecurrenceSearchLimit is the period in which the next fire time
+// is searched, rules which never fire (like "0 0 30 2 *") are rejected.
+const scheduleRecurrenceSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseScheduleRecurrence parses cron-like recurrence rule.
+func ParseScheduleRecurrence(source string) (*ScheduleRecurrence, error) {
+	fields := strings.Fields(source)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf(
+			`recurrence "%s" has to have 5 fields, but has %d`, source, len(fields))
+	}
+	result := &ScheduleRecurrence{source: strings.Join(fields, " ")}
+	var err error
+	if result.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf(`failed to parse recurrence "%s" minute: "%v"`,
+			source, err)
+	}
+	if result.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf(`failed to parse recurrence "%s" hour: "%v"`,
+			source, err)
+	}
+	if result.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf(`failed to parse recurrence "%s" day: "%v"`,
+			source, err)
+	}
+	if result.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf(`failed to parse recurrence "%s" month: "%v"`,
+			source, err)
+	}
+	if result.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf(`failed to parse recurrence "%s" day of week: "%v"`,
+			source, err)
+	}
+	if result.weekdays&(1<<7) != 0 {
+		result.weekdays |= 1
+	}
+	result.anyDay = fields[2] == "*"
+	result.anyWeek = fields[4] == "*"
+	if result.Next(time.Now().UTC()).IsZero() {
+		return nil, fmt.Errorf(`recurrence "%s" never fires`, source)
+	}
+	return result, nil
+}
+
+func parseCronField(source string, min, max int) (uint64, error) {
+	var result uint64
+	for _, item := range strings.Split(source, ",") {
+		step := 1
+		if pos := strings.Index(item, "/"); pos >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[pos+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf(`step "%s" is invalid`, item)
+			}
+			item = item[:pos]
+		}
+		begin, end := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if begin, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf(`value "%s" is invalid`, item)
+			}
+			end = begin
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf(`value "%s" is invalid`, item)
+				}
+			} else if step != 1 {
+				end = max
+			}
+			if begin < min || end > max || begin > end {
+				return 0, fmt.Errorf(`value "%s" is out of range %d-%d`,
+					item, min, max)
+			}
+		}
+		for i := begin; i <= end; i += step {
+			result |= 1 << uint(i)
+		}
+	}
+	return result, nil
+}
+
+// String returns recurrence rule source.
+func (recurrence *ScheduleRecurrence) String() string {
+	return recurrence.source
+}
+
+// Next returns the first fire time after the time, or zero time if the rule
+// doesn't fire in the search period.
+func (recurrence *ScheduleRecurrence) Next(after time.Time) time.Time {
+	result := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := result.Add(scheduleRecurrenceSearchLimit)
+	for result.Before(limit) {
+		if !hasCronBit(recurrence.months, int(result.Month())) {
+			result = time.Date(
+				result.Year(), result.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !recurrence.isDayMatched(result) {
+			result = time.Date(
+				result.Year(), result.Month(), result.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !hasCronBit(recurrence.hours, result.Hour()) {
+			result = result.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !hasCronBit(recurrence.minutes, result.Minute()) {
+			result = result.Add(time.Minute)
+			continue
+		}
+		return result
+	}
+	return time.Time{}
+}
+
+func (recurrence *ScheduleRecurrence) isDayMatched(day time.Time) bool {
+	isDay := hasCronBit(recurrence.days, day.Day())
+	isWeekday := hasCronBit(recurrence.weekdays, int(day.Weekday()))
+	if !recurrence.anyDay && !recurrence.anyWeek {
+		return isDay || isWeekday
+	}
+	return isDay && isWeekday
+}
+
+func hasCronBit(set uint64, value int) bool { return set&(1<<uint(value)) != 0 }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Schedule describes standing order: payment from the account which is
+// executed once at the time or recurrently by the rule.
+type Schedule struct {
+	ID      ScheduleID
+	Client  ClientID
+	Account AccountID
+	Target  ScheduleTarget
+	// Receiver is set only for payment to another account.
+	Receiver *AccountID
+	// Bill and TaxAuthority are set only for tax payment, TaxAuthority is nil
+	// for tax payment which is stored before the authority registry.
+	Bill         string
+	TaxAuthority *TaxAuthorityID
+	Value        Money
+	// Recurrence is nil for one-off standing order.
+	Recurrence *ScheduleRecurrence
+	// NextTime is nil if the standing order is completed.
+	NextTime    *time.Time
+	LastRunTime *time.Time
+}
+
+// NewSchedule creates new standing order for the account, the standing order
+// has to be set by Set before storing.
+func NewSchedule(client ClientID, account AccountID) *Schedule {
+	return &Schedule{ID: newScheduleID(), Client: client, Account: account}
+}
+
+// Set changes standing order payment and execution time.
+func (schedule *Schedule) Set(
+	target ScheduleTarget,
+	receiver *AccountID,
+	bill *TaxBill,
+	value Money,
+	nextTime *time.Time,
+	recurrence *ScheduleRecurrence) error {
+	var billReference string
+	var taxAuthority *TaxAuthorityID
+	switch target {
+	case ScheduleTargetAccount:
+		if receiver == nil || bill != nil {
+			return errors.New(
+				"payment to account has to have receiver and doesn't have bill")
+		}
+		if *receiver == schedule.Account {
+			return fmt.Errorf(`account "%s" is the sender and the receiver`,
+				*receiver)
+		}
+	case ScheduleTargetTax:
+		if receiver != nil || bill == nil {
+			return errors.New(
+				"tax payment has to have bill and doesn't have receiver")
+		}
+		if bill.Authority.Currency.GetISO() != value.GetCurrency().GetISO() {
+			return fmt.Errorf(`%s doesn't accept payments in %s`,
+				bill.Authority.Name, value.GetCurrency().GetISO())
+		}
+		billReference = bill.Reference
+		taxAuthority = &bill.Authority.ID
+	}
+	if !value.IsPositive() {
+		return errors.New("value has to be positive")
+	}
+	if (nextTime == nil) == (recurrence == nil) {
+		return errors.New("standing order has to have time or recurrence")
+	}
+	now := time.Now().UTC()
+	if nextTime != nil {
+		if !nextTime.After(now) {
+			return fmt.Errorf(`time "%s" is not in the future`, nextTime)
+		}
+		utc := nextTime.UTC()
+		nextTime = &utc
+	} else {
+		next := recurrence.Next(now)
+		nextTime = &next
+	}
+	schedule.Target = target
+	schedule.Receiver = receiver
+	schedule.Bill = billReference
+	schedule.TaxAuthority = taxAuthority
+	schedule.Value = value
+	schedule.Recurrence = recurrence
+	schedule.NextTime = nextTime
+	return nil
+}
+
+// Complete marks the run as executed and sets the next run time.
+func (schedule *Schedule) Complete(runTime time.Time) {
+	schedule.LastRunTime = &runTime
+	if schedule.Recurrence == nil {
+		schedule.NextTime = nil
+		return
+	}
+	// The next time is calculated from the run time, so runs missed while
+	// the scheduler was late are not executed again.
+	next := schedule.Recurrence.Next(runTime)
+	if next.IsZero() {
+		schedule.NextTime = nil
+		return
+	}
+	schedule.NextTime = &next
+}
+
+////////////////////////////////////////////////////////////////////////////////
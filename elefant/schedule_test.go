@@ -0,0 +1,123 @@
+package elefant
+
+import (
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func newScheduleTestTime(t *testing.T, source string) time.Time {
+	result, err := time.Parse("2006-01-02 15:04", source)
+	if err != nil {
+		t.Fatalf(`Failed to parse test time "%s": "%v".`, source, err)
+	}
+	return result
+}
+
+func TestScheduleRecurrenceNext(t *testing.T) {
+	// 2026-10-17 is Saturday.
+	for _, test := range []struct {
+		recurrence string
+		after      string
+		expected   string
+	}{
+		{"0 9 * * *", "2026-10-17 10:00", "2026-10-18 09:00"},
+		{"0 9 * * *", "2026-10-17 08:59", "2026-10-17 09:00"},
+		{"0 9 * * *", "2026-10-17 09:00", "2026-10-18 09:00"},
+		{"*/15 * * * *", "2026-10-17 10:07", "2026-10-17 10:15"},
+		{"5-10/5 * * * *", "2026-10-17 10:07", "2026-10-17 10:10"},
+		{"0 8,20 * * *", "2026-10-17 09:00", "2026-10-17 20:00"},
+		{"59 23 31 12 *", "2026-10-17 10:00", "2026-12-31 23:59"},
+		{"30 8 1 * *", "2026-10-17 10:00", "2026-11-01 08:30"},
+		// November doesn't have the 31st.
+		{"0 12 31 * *", "2026-10-31 12:00", "2026-12-31 12:00"},
+		// Only the day of month is restricted.
+		{"0 0 20 * *", "2026-10-17 10:00", "2026-10-20 00:00"},
+		// Only the day of week is restricted.
+		{"0 0 * * 5", "2026-10-17 10:00", "2026-10-23 00:00"},
+		{"0 0 * * 1-5", "2026-10-17 10:00", "2026-10-19 00:00"},
+		// Sunday is 0 and 7.
+		{"0 0 * * 0", "2026-10-17 10:00", "2026-10-18 00:00"},
+		{"0 0 * * 7", "2026-10-17 10:00", "2026-10-18 00:00"},
+		// Both day fields are restricted, so any of them fires.
+		{"0 0 20 * 5", "2026-10-17 10:00", "2026-10-20 00:00"},
+		{"0 0 25 * 5", "2026-10-17 10:00", "2026-10-23 00:00"},
+		{"0 0 13 * 5", "2026-11-10 00:00", "2026-11-13 00:00"},
+		// February 29 is only in leap years.
+		{"0 0 29 2 *", "2026-10-17 10:00", "2028-02-29 00:00"},
+		{"0 0 29 2 *", "2028-02-29 00:00", "2032-02-29 00:00"},
+		{"0 0 29 2 2", "2026-10-17 10:00", "2027-02-02 00:00"},
+	} {
+		recurrence, err := ParseScheduleRecurrence(test.recurrence)
+		if err != nil {
+			t.Errorf(`Failed to parse recurrence "%s": "%v".`,
+				test.recurrence, err)
+			continue
+		}
+		after := newScheduleTestTime(t, test.after)
+		expected := newScheduleTestTime(t, test.expected)
+		if result := recurrence.Next(after); !result.Equal(expected) {
+			t.Errorf(`Recurrence "%s" after %s fires at %s, but expected %s.`,
+				test.recurrence, after, result, expected)
+		}
+	}
+}
+
+func TestParseScheduleRecurrenceInvalid(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1- * * * *",
+		"1,,2 * * * *",
+		// Never fires.
+		"0 0 30 2 *",
+		"0 0 31 4,6,9,11 *",
+	} {
+		if recurrence, err := ParseScheduleRecurrence(source); err == nil {
+			t.Errorf(`Invalid recurrence "%s" is parsed as "%s".`,
+				source, recurrence)
+		}
+	}
+}
+
+func TestScheduleComplete(t *testing.T) {
+	recurrence, err := ParseScheduleRecurrence("0 9 * * *")
+	if err != nil {
+		t.Fatalf(`Failed to parse recurrence: "%v".`, err)
+	}
+	// The scheduler is late for two days, but the missed runs are not
+	// executed again.
+	runTime := newScheduleTestTime(t, "2026-10-17 10:00")
+	schedule := &Schedule{Recurrence: recurrence}
+	schedule.Complete(runTime)
+	if schedule.LastRunTime == nil || !schedule.LastRunTime.Equal(runTime) {
+		t.Errorf(`Last run time is %v, but expected %s.`,
+			schedule.LastRunTime, runTime)
+	}
+	expected := newScheduleTestTime(t, "2026-10-18 09:00")
+	if schedule.NextTime == nil || !schedule.NextTime.Equal(expected) {
+		t.Errorf(`Next time is %v, but expected %s.`, schedule.NextTime, expected)
+	}
+
+	oneOff := &Schedule{NextTime: &runTime}
+	oneOff.Complete(runTime)
+	if oneOff.NextTime != nil {
+		t.Errorf(`One-off standing order has next time %s.`, oneOff.NextTime)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
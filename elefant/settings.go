@@ -22,5 +22,24 @@ const ClientConfirmationCodeLiveTime = time.Duration(60) * time.Minute
 // code resending.
 const ClientConfirmationCodeResendTime = time.Duration(3) * time.Minute
 
+// ExchangeQuoteLiveTime is a live time duration for the locked exchange rate.
+const ExchangeQuoteLiveTime = time.Duration(60) * time.Second
+
+// ExchangeRateMaxAge is a max time from the rates day to the conversion, rates
+// are not published on weekends and TARGET holidays, so the rate of Thursday
+// before Easter is used until Tuesday afternoon.
+const ExchangeRateMaxAge = time.Duration(6*24) * time.Hour
+
+// MoneyRequestLiveTime is a time in which the payer could accept money
+// request.
+const MoneyRequestLiveTime = time.Duration(7*24) * time.Hour
+
+// HoldLiveTime is a time after which not captured hold is released.
+const HoldLiveTime = time.Duration(7*24) * time.Hour
+
+// OverdraftAnnualInterestPercent is a part of the used overdraft which is
+// charged as interest for a year, in percents.
+const OverdraftAnnualInterestPercent = 18
+
 // IsDev returns true if build is not production.
 func IsDev() bool { return Version == "dev" }
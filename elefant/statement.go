@@ -0,0 +1,61 @@
+package elefant
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// StatementID is a statement unique ID, it's generated for each export.
+type StatementID = uuid.UUID
+
+func newStatementID() StatementID { return uuid.New() }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Statement is an account statement for a period, it has posted transactions
+// and booked balances at the period bounds.
+type Statement struct {
+	ID      StatementID
+	Account Account
+	Owner   Client
+	// From and To are the period bounds, From is included and To is not.
+	From time.Time
+	To   time.Time
+	// Opening is the booked balance at the period start, Closing - at the end.
+	Opening Money
+	Closing Money
+	// Trans are posted transactions in chronological order.
+	Trans []*Trans
+	Time  time.Time
+}
+
+// NewStatement creates new account statement.
+func NewStatement(
+	acc Account,
+	owner Client,
+	from time.Time,
+	to time.Time,
+	opening Money,
+	closing Money,
+	trans []*Trans) *Statement {
+	return &Statement{
+		ID:      newStatementID(),
+		Account: acc,
+		Owner:   owner,
+		From:    from,
+		To:      to,
+		Opening: opening,
+		Closing: closing,
+		Trans:   trans,
+		Time:    time.Now().UTC()}
+}
+
+// GetLastDay returns the last day which is included in the period.
+func (statement *Statement) GetLastDay() time.Time {
+	return statement.To.Add(-time.Nanosecond)
+}
+
+////////////////////////////////////////////////////////////////////////////////
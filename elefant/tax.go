@@ -0,0 +1,236 @@
+package elefant
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TaxAuthorityID is a tax authority unique ID.
+type TaxAuthorityID = uuid.UUID
+
+// ParseTaxAuthorityID parses tax authority ID in string.
+func ParseTaxAuthorityID(source string) (TaxAuthorityID, error) {
+	return uuid.Parse(source)
+}
+
+type nullTaxAuthorityID struct {
+	TaxAuthorityID TaxAuthorityID
+	Valid          bool
+}
+
+// Scan implements the Scanner interface.
+func (n *nullTaxAuthorityID) Scan(value interface{}) error {
+	var err error
+	n.TaxAuthorityID, n.Valid, err = scanNullUUID(value)
+	return err
+}
+
+// Value implements the driver Valuer interface.
+func (n nullTaxAuthorityID) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.TaxAuthorityID.String(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TaxBillCheckDigit is a tax bill check digit algorithm.
+type TaxBillCheckDigit int16
+
+const (
+	// TaxBillCheckDigitMod97 is ISO 7064 MOD 97-10 with the check digits at
+	// the end: letters are replaced by numbers from 10 (A) to 35 (Z),
+	// the remainder of the whole number by 97 has to be 1. The creditor
+	// reference (ISO 11649) starts with "RF" and the check digits, so they
+	// are moved to the end before the check.
+	TaxBillCheckDigitMod97 TaxBillCheckDigit = 1
+	// TaxBillCheckDigitLuhn is the Luhn algorithm, the bill has only digits
+	// and the last digit is the check digit.
+	TaxBillCheckDigitLuhn TaxBillCheckDigit = 2
+	// TaxBillCheckDigitMod11 is the weighted modulus 11 with weights from 2 to
+	// 7 from the right, the bill has only digits and the last digit is
+	// the check digit.
+	TaxBillCheckDigitMod11 TaxBillCheckDigit = 3
+)
+
+func parseTaxBillCheckDigitFromDB(source int16) (TaxBillCheckDigit, error) {
+	switch TaxBillCheckDigit(source) {
+	case TaxBillCheckDigitMod97, TaxBillCheckDigitLuhn, TaxBillCheckDigitMod11:
+		return TaxBillCheckDigit(source), nil
+	default:
+		return 0, fmt.Errorf(
+			`failed to parse tax bill check digit from DB-value "%v"`, source)
+	}
+}
+
+// String returns tax bill check digit algorithm name.
+func (algorithm TaxBillCheckDigit) String() string {
+	switch algorithm {
+	case TaxBillCheckDigitMod97:
+		return "mod 97"
+	case TaxBillCheckDigitLuhn:
+		return "luhn"
+	case TaxBillCheckDigitMod11:
+		return "mod 11"
+	default:
+		return "unknown"
+	}
+}
+
+// Check checks the normalized bill check digit.
+func (algorithm TaxBillCheckDigit) Check(bill string) error {
+	switch algorithm {
+	case TaxBillCheckDigitMod97:
+		return checkTaxBillMod97(bill)
+	case TaxBillCheckDigitLuhn:
+		return checkTaxBillLuhn(bill)
+	case TaxBillCheckDigitMod11:
+		return checkTaxBillMod11(bill)
+	default:
+		return fmt.Errorf(`check digit algorithm "%d" is unknown`, algorithm)
+	}
+}
+
+func checkTaxBillMod97(bill string) error {
+	if len(bill) > 4 && strings.HasPrefix(bill, "RF") {
+		bill = bill[4:] + bill[:4]
+	}
+	remainder := 0
+	for _, symbol := range bill {
+		switch {
+		case symbol >= '0' && symbol <= '9':
+			remainder = (remainder*10 + int(symbol-'0')) % 97
+		case symbol >= 'A' && symbol <= 'Z':
+			remainder = (remainder*100 + int(symbol-'A') + 10) % 97
+		default:
+			return fmt.Errorf(`symbol "%c" is not allowed`, symbol)
+		}
+	}
+	if remainder != 1 {
+		return errors.New("check digits are invalid")
+	}
+	return nil
+}
+
+// readTaxBillDigits returns the bill digits, the bill has to have at least
+// one digit and the check digit.
+func readTaxBillDigits(bill string) ([]int, error) {
+	if len(bill) < 2 {
+		return nil, errors.New("bill is too short")
+	}
+	result := make([]int, len(bill))
+	for i, symbol := range bill {
+		if symbol < '0' || symbol > '9' {
+			return nil, fmt.Errorf(`symbol "%c" is not a digit`, symbol)
+		}
+		result[i] = int(symbol - '0')
+	}
+	return result, nil
+}
+
+func checkTaxBillLuhn(bill string) error {
+	digits, err := readTaxBillDigits(bill)
+	if err != nil {
+		return err
+	}
+	sum := 0
+	for i := range digits {
+		digit := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	if sum%10 != 0 {
+		return errors.New("check digit is invalid")
+	}
+	return nil
+}
+
+func checkTaxBillMod11(bill string) error {
+	digits, err := readTaxBillDigits(bill)
+	if err != nil {
+		return err
+	}
+	payload := digits[:len(digits)-1]
+	sum := 0
+	for i := range payload {
+		sum += payload[len(payload)-1-i] * (2 + i%6)
+	}
+	check := 11 - sum%11
+	switch check {
+	case 11:
+		check = 0
+	case 10:
+		// Such bills are not issued as the check digit has to be one digit.
+		return errors.New("check digit is impossible")
+	}
+	if check != digits[len(digits)-1] {
+		return errors.New("check digit is invalid")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TaxAuthority describes a tax authority, which receives tax payments.
+type TaxAuthority struct {
+	ID       TaxAuthorityID
+	Name     string
+	Currency Currency
+	// BillFormat matches the whole normalized bill.
+	BillFormat *regexp.Regexp
+	// CheckDigit is nil if the authority bills don't have a check digit.
+	CheckDigit *TaxBillCheckDigit
+	// Account is the tax payable house account which collects the authority
+	// payments until they are transferred to the authority.
+	Account SystemAccountID
+}
+
+func newTaxAuthorityBillFormat(source string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + source + `)$`)
+}
+
+// NormalizeTaxBill removes spaces from the bill and converts it to upper case,
+// as clients often copy the bill with separators from the paper.
+func NormalizeTaxBill(source string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(source)), "")
+}
+
+// NewBill validates the bill by the authority format and check digit.
+func (authority *TaxAuthority) NewBill(source string) (*TaxBill, error) {
+	bill := NormalizeTaxBill(source)
+	if bill == "" {
+		return nil, errors.New("bill is empty")
+	}
+	if !authority.BillFormat.MatchString(bill) {
+		return nil, fmt.Errorf(`bill "%s" doesn't match %s format`,
+			bill, authority.Name)
+	}
+	if authority.CheckDigit != nil {
+		if err := authority.CheckDigit.Check(bill); err != nil {
+			return nil, fmt.Errorf(`bill "%s" failed %s check: "%v"`,
+				bill, authority.CheckDigit, err)
+		}
+	}
+	return &TaxBill{Authority: authority, Reference: bill}, nil
+}
+
+// TaxBill is a validated tax bill of the authority.
+type TaxBill struct {
+	Authority *TaxAuthority
+	// Reference is the normalized bill, which is sent to the authority.
+	Reference string
+}
+
+////////////////////////////////////////////////////////////////////////////////
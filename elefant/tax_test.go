@@ -0,0 +1,147 @@
+package elefant
+
+import (
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestTaxBillCheckDigit(t *testing.T) {
+	for _, test := range []struct {
+		algorithm TaxBillCheckDigit
+		bill      string
+		isValid   bool
+	}{
+		// ISO 11649 creditor reference.
+		{TaxBillCheckDigitMod97, "RF18539007547034", true},
+		{TaxBillCheckDigitMod97, "RF18539007547043", false},
+		{TaxBillCheckDigitMod97, "RF19539007547034", false},
+		{TaxBillCheckDigitMod97, "RF18", false},
+		// Check digits at the end.
+		{TaxBillCheckDigitMod97, "123456789092", true},
+		{TaxBillCheckDigitMod97, "123456789093", false},
+		{TaxBillCheckDigitMod97, "213456789092", false},
+		{TaxBillCheckDigitMod97, "RF18-539007547034", false},
+		{TaxBillCheckDigitMod97, "", false},
+
+		{TaxBillCheckDigitLuhn, "79927398713", true},
+		{TaxBillCheckDigitLuhn, "79927398710", false},
+		{TaxBillCheckDigitLuhn, "79927398731", false},
+		{TaxBillCheckDigitLuhn, "00", true},
+		{TaxBillCheckDigitLuhn, "7992739871A", false},
+		{TaxBillCheckDigitLuhn, "0", false},
+		{TaxBillCheckDigitLuhn, "", false},
+
+		{TaxBillCheckDigitMod11, "1234567892", true},
+		{TaxBillCheckDigitMod11, "12345674", true},
+		{TaxBillCheckDigitMod11, "987654321018", true},
+		// The check digit is 0 for the remainder 0.
+		{TaxBillCheckDigitMod11, "0000000000", true},
+		{TaxBillCheckDigitMod11, "140", true},
+		{TaxBillCheckDigitMod11, "1234567891", false},
+		{TaxBillCheckDigitMod11, "2134567892", false},
+		// The check digit would be 10.
+		{TaxBillCheckDigitMod11, "60", false},
+		{TaxBillCheckDigitMod11, "61", false},
+		{TaxBillCheckDigitMod11, "123456789X", false},
+		{TaxBillCheckDigitMod11, "2", false},
+
+		{TaxBillCheckDigit(0), "79927398713", false},
+		{TaxBillCheckDigit(4), "79927398713", false},
+	} {
+		err := test.algorithm.Check(test.bill)
+		if test.isValid && err != nil {
+			t.Errorf(`Bill "%s" failed %s check: "%v".`,
+				test.bill, test.algorithm, err)
+		} else if !test.isValid && err == nil {
+			t.Errorf(`Bill "%s" passed %s check.`, test.bill, test.algorithm)
+		}
+	}
+}
+
+func TestParseTaxBillCheckDigitFromDB(t *testing.T) {
+	for _, algorithm := range []TaxBillCheckDigit{
+		TaxBillCheckDigitMod97, TaxBillCheckDigitLuhn, TaxBillCheckDigitMod11,
+	} {
+		result, err := parseTaxBillCheckDigitFromDB(int16(algorithm))
+		if err != nil || result != algorithm {
+			t.Errorf(`Check digit %s is parsed as %s: "%v".`,
+				algorithm, result, err)
+		}
+	}
+	for _, source := range []int16{0, 4, -1} {
+		if result, err := parseTaxBillCheckDigitFromDB(source); err == nil {
+			t.Errorf(`Check digit %d is parsed as %s.`, source, result)
+		}
+	}
+}
+
+func TestTaxAuthorityNewBill(t *testing.T) {
+	newAuthority := func(
+		format string, checkDigit *TaxBillCheckDigit) *TaxAuthority {
+		billFormat, err := newTaxAuthorityBillFormat(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &TaxAuthority{
+			Name:       "Test authority",
+			BillFormat: billFormat,
+			CheckDigit: checkDigit}
+	}
+	mod97 := TaxBillCheckDigitMod97
+	luhn := TaxBillCheckDigitLuhn
+	creditorReference := newAuthority(`RF[0-9]{2}[0-9A-Z]{1,21}`, &mod97)
+	// The format matches the whole bill, even if it has alternatives.
+	luhnBill := newAuthority(`[0-9]{11}|[0-9]{13}`, &luhn)
+	withoutCheckDigit := newAuthority(`[A-Z]{2}[0-9]{6}`, nil)
+
+	for _, test := range []struct {
+		authority *TaxAuthority
+		source    string
+		// expected is empty if the bill is invalid.
+		expected string
+	}{
+		{creditorReference, "RF18539007547034", "RF18539007547034"},
+		{creditorReference, "rf18 5390 0754 7034", "RF18539007547034"},
+		{creditorReference, "\tRF18 5390\n0754 7034 ", "RF18539007547034"},
+		{creditorReference, "RF18 5390 0754 7043", ""},
+		{creditorReference, "123456789092", ""},
+		{creditorReference, "XRF18539007547034", ""},
+		{creditorReference, "RF18539007547034-", ""},
+		{creditorReference, "", ""},
+		{creditorReference, "   ", ""},
+
+		{luhnBill, "7992 7398 713", "79927398713"},
+		{luhnBill, "7992739871", ""},
+		{luhnBill, "79927398710", ""},
+		{luhnBill, "0079927398713", "0079927398713"},
+		{luhnBill, "079927398713", ""},
+		{luhnBill, "X79927398713", ""},
+
+		{withoutCheckDigit, "ab 123456", "AB123456"},
+		{withoutCheckDigit, "AB1234567", ""},
+		{withoutCheckDigit, "A1234567", ""},
+	} {
+		bill, err := test.authority.NewBill(test.source)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf(`Bill "%s" is accepted as "%s".`, test.source, bill.Reference)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf(`Bill "%s" is not accepted: "%v".`, test.source, err)
+			continue
+		}
+		if bill.Reference != test.expected || bill.Authority != test.authority {
+			t.Errorf(`Bill "%s" is accepted as "%s", but expected "%s".`,
+				test.source, bill.Reference, test.expected)
+		}
+	}
+
+	if _, err := newTaxAuthorityBillFormat(`[0-9`); err == nil {
+		t.Errorf("Invalid bill format is compiled.")
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -1,8 +1,12 @@
 package elefant
 
 import (
+	"database/sql/driver"
 	"fmt"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,6 +36,14 @@ func (n *nullTransID) Scan(value interface{}) error {
 	return err
 }
 
+// Value implements the driver Valuer interface.
+func (n nullTransID) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.TransID.String(), nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // TransStatus is transaction status enumeration.
@@ -42,31 +54,97 @@ const (
 	TransStatusSuccess TransStatus = 10101
 	// TransStatusFailed means transaction execution failed by error.
 	TransStatusFailed TransStatus = 10102
+	// TransStatusPending means transaction is accepted, but its execution is
+	// not started yet (the API responds with 202 for it).
+	TransStatusPending TransStatus = 10103
+	// TransStatusProcessing means transaction execution is started, but not
+	// finished yet.
+	TransStatusProcessing TransStatus = 10104
+	// TransStatusReversed means successful transaction is reversed by
+	// the system (for example, by the card chargeback).
+	TransStatusReversed TransStatus = 10105
+	// TransStatusRefunded means successful transaction is refunded by
+	// the counterpart.
+	TransStatusRefunded TransStatus = 10106
+	// TransStatusCancelled means transaction is cancelled before execution.
+	TransStatusCancelled TransStatus = 10107
 )
 
+// transStatusTransitions is the transaction state machine: the list of
+// statuses in which transaction could be moved from the status.
+var transStatusTransitions = map[TransStatus][]TransStatus{
+	TransStatusPending: {
+		TransStatusProcessing,
+		TransStatusSuccess,
+		TransStatusFailed,
+		TransStatusCancelled},
+	TransStatusProcessing: {TransStatusSuccess, TransStatusFailed},
+	TransStatusSuccess:    {TransStatusReversed, TransStatusRefunded},
+	TransStatusFailed:     {},
+	TransStatusReversed:   {},
+	TransStatusRefunded:   {},
+	TransStatusCancelled:  {},
+}
+
 func parseTransStatus(source int64) (TransStatus, error) {
-	switch source {
-	case int64(TransStatusSuccess), int64(TransStatusFailed):
+	if _, has := transStatusTransitions[TransStatus(source)]; has {
 		return TransStatus(source), nil
-	default:
-		break
 	}
 	return 0, fmt.Errorf(`failed to parse transaction status from value "%v"`,
 		source)
 }
 
+// ParseTransStatusName parses transaction status by its name.
+func ParseTransStatusName(source string) (TransStatus, error) {
+	for status := range transStatusTransitions {
+		if status.String() == source {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf(`unknown transaction status "%s"`, source)
+}
+
 // String converts transaction to string.
 func (status TransStatus) String() string {
 	switch status {
+	case TransStatusPending:
+		return "pending"
+	case TransStatusProcessing:
+		return "processing"
 	case TransStatusSuccess:
+		// The name is kept from the API before the state machine, as clients
+		// already use it.
 		return "success"
 	case TransStatusFailed:
 		return "failed"
+	case TransStatusReversed:
+		return "reversed"
+	case TransStatusRefunded:
+		return "refunded"
+	case TransStatusCancelled:
+		return "cancelled"
 	default:
 		return "unknown"
 	}
 }
 
+// IsFinal returns true if transaction could not be moved from the status.
+func (status TransStatus) IsFinal() bool {
+	return len(transStatusTransitions[status]) == 0
+}
+
+// CheckTransition returns error if transaction could not be moved from
+// the status into the next status.
+func (status TransStatus) CheckTransition(next TransStatus) error {
+	for _, allowed := range transStatusTransitions[status] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return fmt.Errorf(`transaction could not be moved from status "%s" to "%s"`,
+		status, next)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 type nullTransStatus struct {
@@ -98,25 +176,51 @@ func (n *nullTransStatus) Scan(source interface{}) error {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// TransExchange describes currency conversion applied to the transaction
+// between accounts in different currencies.
+type TransExchange struct {
+	// Rate is an applied rate to convert the sender account currency into the
+	// receiver account currency.
+	Rate *big.Rat
+	// CounterValue is the transaction value on the counterpart account side in
+	// the counterpart account currency.
+	CounterValue Money
+}
+
+// NewTransExchange creates currency conversion description for the
+// transaction.
+func NewTransExchange(rate *big.Rat, counterValue Money) *TransExchange {
+	return &TransExchange{Rate: rate, CounterValue: counterValue}
+}
+
 // Trans describes account transaction.
 type Trans struct {
 	ID           TransID
-	Value        float64
+	Value        Money
 	Time         time.Time
 	Method       Method
 	Account      Account
 	Status       TransStatus
 	StatusReason *string
+	// Exchange is set only if transaction converted currency.
+	Exchange *TransExchange
+	// Journal is set only if transaction changed balance.
+	Journal *JournalID
+	// RefundOf is set only if transaction is a refund of another transaction.
+	RefundOf *TransID
+	// FeeOf is set only if transaction is a fee for another transaction.
+	FeeOf *TransID
 }
 
 func newTrans(
 	id TransID,
-	value float64,
+	value Money,
 	time time.Time,
 	method Method,
 	account Account,
 	status TransStatus,
-	statusReason *string) *Trans {
+	statusReason *string,
+	exchange *TransExchange) *Trans {
 	return &Trans{
 		ID:           id,
 		Value:        value,
@@ -124,7 +228,76 @@ func newTrans(
 		Method:       method,
 		Account:      account,
 		Status:       status,
-		StatusReason: statusReason}
+		StatusReason: statusReason,
+		Exchange:     exchange}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TransCursor is a position in the account history. The history is ordered by
+// the transaction time and ID, so the position is stable even if several
+// transactions have the same time.
+type TransCursor struct {
+	Time time.Time
+	ID   TransID
+}
+
+// NewTransCursor creates cursor which points to the transaction.
+func NewTransCursor(trans *Trans) TransCursor {
+	return TransCursor{Time: trans.Time, ID: trans.ID}
+}
+
+// ParseTransCursor parses transaction cursor in string.
+func ParseTransCursor(source string) (TransCursor, error) {
+	parts := strings.SplitN(source, "_", 2)
+	if len(parts) != 2 {
+		return TransCursor{}, fmt.Errorf(`cursor "%s" has invalid format`, source)
+	}
+	nanoseconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return TransCursor{}, fmt.Errorf(`failed to parse cursor time "%s": "%v"`,
+			parts[0], err)
+	}
+	id, err := ParseTransID(parts[1])
+	if err != nil {
+		return TransCursor{}, fmt.Errorf(`failed to parse cursor ID "%s": "%v"`,
+			parts[1], err)
+	}
+	return TransCursor{Time: time.Unix(0, nanoseconds).UTC(), ID: id}, nil
+}
+
+func (cursor TransCursor) String() string {
+	return fmt.Sprintf("%d_%s", cursor.Time.UnixNano(), cursor.ID)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TransFilter describes account history filter, each set field narrows
+// the history.
+type TransFilter struct {
+	// From and To limit the transaction time, From is included and To is not.
+	From *time.Time
+	To   *time.Time
+	// MinValue and MaxValue limit the absolute transaction value, both are
+	// included.
+	MinValue *Money
+	MaxValue *Money
+	// Direction selects only income or only outcome transactions.
+	Direction  *FeeDirection
+	MethodType *MethodType
+	Status     *TransStatus
+	// Query is a free text which is searched in the counterpart email, the tax
+	// bill, the hold description and the transaction notes.
+	Query string
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TransStatusChange describes one record in the transaction status history.
+type TransStatusChange struct {
+	Status       TransStatus
+	StatusReason *string
+	Time         time.Time
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1,41 +1,235 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/palchukovsky/elefantpay-aws/elefant"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
 
-type accountBalanceLambda struct{ accountLambda }
+type accountBalanceLambda struct {
+	accountLambda
+	// rates converts money between currencies and the client tier default
+	// limits into the account currency.
+	rates elefant.ExchangeRateProvider
+}
 
 func newAccountBalanceLambda() accountBalanceLambda {
 	return accountBalanceLambda{accountLambda: newAccountLambda()}
 }
 
+func (lambda *accountBalanceLambda) Init() error {
+	if err := lambda.accountLambda.Init(); err != nil {
+		return err
+	}
+	var err error
+	lambda.rates, err = elefant.NewExchangeRateProvider()
+	return err
+}
+
+// parseMoneyValue parses a positive request amount in the account currency.
+func parseMoneyValue(
+	source json.Number,
+	currency elefant.Currency) (*elefant.Money, *httpResponse, error) {
+	result, err := elefant.ParseMoney(source.String(), currency)
+	if err != nil {
+		response, err := newHTTPResponseBadParam(err.Error(),
+			`failed to parse value "%s": "%v"`, source, err)
+		return nil, response, err
+	}
+	if !result.IsPositive() {
+		response, err := newHTTPResponseBadParam("value must be positive",
+			`value has invalid value "%s"`, source)
+		return nil, response, err
+	}
+	return &result, nil, nil
+}
+
+func (lambda *accountBalanceLambda) findClientAccount(
+	accID elefant.AccountID,
+	clientID elefant.ClientID,
+	db elefant.DBTrans) (elefant.Account, *httpResponse, error) {
+	acc, err := db.FindClientAccount(accID, clientID)
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			`failed to find account "%s" for client "%s": "%v"`,
+			accID, clientID, err)
+	}
+	if acc == nil {
+		response, err := newHTTPResponseEmptyError(http.StatusBadRequest,
+			`client "%s" does not have account "%s"`, clientID, accID)
+		return nil, response, err
+	}
+	return acc, nil, nil
+}
+
+// postJournal posts the balanced journal and returns updated accounts.
+// Returns not nil response if the account status doesn't allow the journal,
+// then the DB transaction has to be rolled back.
+func (lambda *accountBalanceLambda) postJournal(
+	journal *elefant.Journal,
+	db elefant.DBTrans) (
+	map[elefant.AccountID]elefant.Account, *httpResponse, error) {
+	result, err := db.PostJournal(journal)
+	if err != nil {
+		if statusErr, isStatusErr := err.(*elefant.AccountStatusError); isStatusErr {
+			response, err := newHTTPResponseAccountStatus(statusErr)
+			return nil, response, err
+		}
+		return nil, nil, fmt.Errorf(`failed to post journal "%s": "%v"`,
+			journal.ID, err)
+	}
+	return result, nil, nil
+}
+
+// newHTTPResponseAccountStatus creates response for the operation which is
+// not allowed by the account status.
+func newHTTPResponseAccountStatus(
+	err *elefant.AccountStatusError) (*httpResponse, error) {
+	statusCode := http.StatusForbidden
+	elefant.Log.Warn(`Response with error code %d: "%s".`, statusCode, err)
+	return newHTTPResponse(statusCode,
+		&errorResponse{Message: elefant.CapitalizeString(err.Error())})
+}
+
+// commitHook is executed in the DB transaction just before the commit with
+// the response for the balance update. Returns not nil response if
+// the transaction could not be committed.
+type commitHook func(*httpResponse, elefant.DBTrans) (*httpResponse, error)
+
+// commit executes the hook and commits the transaction. Returns not nil
+// response if the transaction could not be committed.
+func (lambda *accountBalanceLambda) commit(
+	beforeCommit commitHook,
+	response *httpResponse,
+	db elefant.DBTrans) (*httpResponse, error) {
+	conflict, err := beforeCommit(response, db)
+	if conflict != nil || err != nil {
+		return conflict, err
+	}
+	return nil, db.Commit()
+}
+
+// storeFailedTrans stores failed transaction with the reason and commits it
+// with the payment required response. If transResult is not nil, it's set to
+// the failed transaction before the commit hook, so the hook could use it.
 func (lambda *accountBalanceLambda) storeFailedTrans(
 	acc elefant.Account,
 	method elefant.Method,
-	value float64,
+	value elefant.Money,
+	exchange *elefant.TransExchange,
 	reason string,
-	db elefant.DBTrans) (*httpResponse, error) {
+	beforeCommit commitHook,
+	db elefant.DBTrans,
+	transResult **elefant.Trans) (*httpResponse, error) {
 	trans, err := db.StoreTransWithReason(
-		elefant.TransStatusFailed, reason, acc, method, value)
+		elefant.TransStatusFailed, reason, acc, method, value, exchange)
 	if err != nil {
 		return nil, err
 	}
-	if err := db.Commit(); err != nil {
+	if transResult != nil {
+		*transResult = trans
+	}
+	response, err := newHTTPResponsePaymentRequired(reason)
+	if err != nil {
 		return nil, err
 	}
-	return newHTTPResponseEmptyError(http.StatusPaymentRequired,
-		fmtTransLog(trans))
+	if conflict, err := lambda.commit(
+		beforeCommit, response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	elefant.Log.Warn(`Response with error code %d: "%s".`,
+		response.StatusCode, fmtTransLog(trans))
+	return response, nil
+}
+
+// newHTTPResponsePaymentRequired creates response for the operation which is
+// failed by the reason, like insufficient funds.
+func newHTTPResponsePaymentRequired(reason string) (*httpResponse, error) {
+	return newHTTPResponse(http.StatusPaymentRequired,
+		&errorResponse{Message: elefant.CapitalizeString(reason)})
+}
+
+// feeCharge is a fee posted to the account by the separated journal.
+type feeCharge struct {
+	journal elefant.JournalID
+	value   elefant.Money
+}
+
+// findFee returns the fee for the operation with the positive value, or zero
+// if the operation is free.
+func (lambda *accountBalanceLambda) findFee(
+	operation elefant.FeeOperation,
+	value elefant.Money,
+	db elefant.DBTrans) (elefant.Money, error) {
+	rule, err := db.FindFeeRule(operation, value)
+	if err != nil {
+		return elefant.Money{}, fmt.Errorf(
+			`failed to find fee rule for operation "%s": "%v"`, operation, err)
+	}
+	if rule == nil {
+		return elefant.NewMoney(0, value.GetCurrency()), nil
+	}
+	return rule.Calc(value), nil
+}
+
+// postFee posts the fee journal for the operation with the positive value
+// and returns the account updated by the journal. Returns nil fee if
+// the operation is free.
+func (lambda *accountBalanceLambda) postFee(
+	acc elefant.Account,
+	operation elefant.FeeOperation,
+	value elefant.Money,
+	db elefant.DBTrans) (elefant.Account, *feeCharge, *httpResponse, error) {
+	fee, err := lambda.findFee(operation, value, db)
+	if err != nil || fee.IsZero() {
+		return acc, nil, nil, err
+	}
+	journal := elefant.NewChargeJournal()
+	journal.AddAccountPosting(acc.GetID(), fee.Neg())
+	journal.AddSystemPosting(elefant.SystemAccountFee, fee)
+	accounts, response, err := lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return nil, nil, response, err
+	}
+	return accounts[acc.GetID()],
+		&feeCharge{journal: journal.ID, value: fee},
+		nil, nil
 }
 
+// storeFee stores the fee transaction for the charged transaction.
+func (lambda *accountBalanceLambda) storeFee(
+	fee *feeCharge, charged *elefant.Trans, db elefant.DBTrans) error {
+	method, err := db.GetFeeMethod(charged.Account)
+	if err != nil {
+		return fmt.Errorf(`failed to get fee method: "%v"`, err)
+	}
+	trans, err := db.StoreTrans(elefant.TransStatusSuccess, fee.journal,
+		charged.Account, method, fee.value.Neg(), nil)
+	if err != nil {
+		return err
+	}
+	if err := db.SetTransFeeOf(trans.ID, charged.ID); err != nil {
+		return fmt.Errorf(`failed to link fee "%s" with transaction "%s": "%v"`,
+			trans.ID, charged.ID, err)
+	}
+	return nil
+}
+
+// deposit stores successful transaction for the account which balance
+// already is updated by the posted journal and, if chargeFee is set, charges
+// the operation fee.
 func (lambda *accountBalanceLambda) deposit(
 	acc elefant.Account,
-	delta float64,
+	delta elefant.Money,
+	exchange *elefant.TransExchange,
+	journal elefant.JournalID,
+	chargeFee bool,
 	getMethod func() (elefant.Method, error),
 	db elefant.DBTrans,
 	trans **elefant.Trans) (*httpResponse, error) {
@@ -43,41 +237,78 @@ func (lambda *accountBalanceLambda) deposit(
 	if err != nil {
 		return nil, err
 	}
-	*trans, err = db.StoreTrans(elefant.TransStatusSuccess, acc, method, delta)
-	return nil, err
+	var fee *feeCharge
+	if chargeFee {
+		var response *httpResponse
+		acc, fee, response, err = lambda.postFee(acc,
+			elefant.NewFeeOperation(method, elefant.FeeDirectionIn), delta, db)
+		if response != nil || err != nil {
+			return response, err
+		}
+	}
+	*trans, err = db.StoreTrans(
+		elefant.TransStatusSuccess, journal, acc, method, delta, exchange)
+	if err != nil || fee == nil {
+		return nil, err
+	}
+	return nil, lambda.storeFee(fee, *trans, db)
 }
 
+// withdraw charges the operation fee, if isPayment is set, checks
+// the account available balance with the overdraft, which already is updated
+// by the posted journal, and, if isPayment is set, the account spending
+// limits, and stores successful transaction, or, if there are no enough funds
+// or the limit is exceeded, rollbacks the journals and stores failed
+// transaction. Refunds are not payments, so they are not limited and not
+// charged. If beforeCommit is nil, the failed transaction is not stored and
+// the DB transaction is not rolled back, so the withdrawal could be a part of
+// a batch, which has to be rolled back by the caller at the failure.
+// The stored transaction, successful or failed, is set into transResult.
 func (lambda *accountBalanceLambda) withdraw(
-	accID elefant.AccountID,
-	clientID elefant.ClientID,
-	delta float64,
+	acc elefant.Account,
+	delta elefant.Money,
+	exchange *elefant.TransExchange,
+	journal elefant.JournalID,
+	isPayment bool,
 	getMethod func(elefant.Account, elefant.DBTrans) (elefant.Method, error),
+	beforeCommit commitHook,
 	db elefant.DBTrans,
-	transResult **elefant.Trans,
-	clientResult *elefant.Client) (*httpResponse, error) {
-
-	delta = -delta
+	transResult **elefant.Trans) (*httpResponse, error) {
 
-	client, acc, err := db.UpdateClientAccountBalance(accID, clientID, delta)
+	method, err := getMethod(acc, db)
 	if err != nil {
-		return nil, fmt.Errorf(
-			`failed to update account "%s" balance for client "%s" with delta %f: "%v"`,
-			accID, clientID, delta, err)
-	}
-	if acc == nil {
-		return newHTTPResponseEmptyError(http.StatusBadRequest,
-			`client "%s" does not have account "%s"`, clientID, accID)
-	}
-	if clientResult != nil {
-		*clientResult = client
+		return nil, err
 	}
 
-	var method elefant.Method
-	if method, err = getMethod(acc, db); err != nil {
-		return nil, err
+	var fee *feeCharge
+	if isPayment {
+		var response *httpResponse
+		acc, fee, response, err = lambda.postFee(acc,
+			elefant.NewFeeOperation(method, elefant.FeeDirectionOut), delta, db)
+		if response != nil || err != nil {
+			return response, err
+		}
 	}
 
-	if acc.GetBalance() < 0 {
+	reason := ""
+	if acc.GetAvailableBalance().IsNegative() {
+		reason = "insufficient funds"
+	} else if isPayment {
+		// The account is locked by the posted journal, so concurrent withdraws
+		// are checked one by one.
+		var response *httpResponse
+		reason, response, err = lambda.checkSpendingLimits(acc, delta, db)
+		if response != nil || err != nil {
+			return response, err
+		}
+	}
+	delta = delta.Neg()
+	if reason != "" && beforeCommit == nil {
+		elefant.Log.Warn(`Withdrawal %s from account "%s" is failed: "%s".`,
+			delta, acc.GetID(), reason)
+		return newHTTPResponsePaymentRequired(reason)
+	}
+	if reason != "" {
 		db.Rollback()
 		failedTransDb, err := lambda.db.Begin()
 		if err != nil {
@@ -87,14 +318,96 @@ func (lambda *accountBalanceLambda) withdraw(
 		if method, err = getMethod(acc, failedTransDb); err != nil {
 			return nil, err
 		}
-		return lambda.storeFailedTrans(
-			acc, method, delta, "insufficient funds", failedTransDb)
+		return lambda.storeFailedTrans(acc, method, delta, exchange,
+			reason, beforeCommit, failedTransDb, transResult)
 	}
 
 	*transResult, err = db.StoreTrans(elefant.TransStatusSuccess,
-		acc, method, delta)
+		journal, acc, method, delta, exchange)
+	if err != nil || fee == nil {
+		return nil, err
+	}
 
-	return nil, err
+	return nil, lambda.storeFee(fee, *transResult, db)
+}
+
+// checkSpendingLimits returns the failure reason if the value could not be
+// withdrawn from the account by the account spending limits.
+func (lambda *accountBalanceLambda) checkSpendingLimits(
+	acc elefant.Account,
+	value elefant.Money,
+	db elefant.DBTrans) (string, *httpResponse, error) {
+	limits, response, err := lambda.getAccountLimits(acc, db)
+	if response != nil || err != nil {
+		return "", response, err
+	}
+	spending, err := db.GetAccountSpending(acc, time.Now())
+	if err != nil {
+		return "", nil, fmt.Errorf(`failed to get account "%s" spending: "%v"`,
+			acc.GetID(), err)
+	}
+	return limits.Check(value, spending), nil, nil
+}
+
+// getAccountLimits returns the account spending limits, or the client tier
+// default limits in the account currency if the account limits are not set.
+// The account limits are set at the account creation, so the default limits
+// are converted only for accounts created without actual exchange rates.
+func (lambda *accountBalanceLambda) getAccountLimits(
+	acc elefant.Account,
+	db elefant.DBTrans) (elefant.SpendingLimits, *httpResponse, error) {
+	limits, tier, err := db.GetAccountLimits(acc)
+	if err != nil {
+		return elefant.SpendingLimits{}, nil, fmt.Errorf(
+			`failed to get account "%s" limits: "%v"`, acc.GetID(), err)
+	}
+	if limits != nil {
+		return *limits, nil, nil
+	}
+	result, err := elefant.NewSpendingLimitsForTier(
+		tier, acc.GetCurrency(), lambda.rates)
+	if staleErr, isStale := err.(*elefant.ExchangeRateStaleError); isStale {
+		// Limits are not converted by old rates, the client has to retry later.
+		elefant.Log.Error(`Failed to get client tier "%s" limits in %s: "%v".`,
+			tier, acc.GetCurrency().GetISO(), staleErr)
+		response, err := newHTTPResponse(http.StatusServiceUnavailable,
+			&errorResponse{Message: elefant.CapitalizeString(staleErr.Error())})
+		return elefant.SpendingLimits{}, response, err
+	}
+	if err != nil {
+		return elefant.SpendingLimits{}, nil, fmt.Errorf(
+			`failed to get client tier "%s" limits in %s: "%v"`,
+			tier, acc.GetCurrency().GetISO(), err)
+	}
+	return result, nil, nil
+}
+
+// setAccountTierLimits sets the client tier default limits, converted into
+// the currency of the new account, as the account limits. If the limits
+// could not be converted now, the account is created without limits.
+func setAccountTierLimits(
+	acc elefant.Account,
+	rates elefant.ExchangeRateProvider,
+	db elefant.DBTrans) error {
+	_, tier, err := db.GetAccountLimits(acc)
+	if err != nil {
+		return fmt.Errorf(`failed to get account "%s" client tier: "%v"`,
+			acc.GetID(), err)
+	}
+	limits, err := elefant.NewSpendingLimitsForTier(
+		tier, acc.GetCurrency(), rates)
+	if err != nil {
+		elefant.Log.Warn(
+			`Account "%s" is created without limits, failed to get client tier `+
+				`"%s" limits in %s: "%v".`,
+			acc.GetID(), tier, acc.GetCurrency().GetISO(), err)
+		return nil
+	}
+	if err := db.SetAccountLimits(acc.GetID(), limits); err != nil {
+		return fmt.Errorf(`failed to set account "%s" limits: "%v"`,
+			acc.GetID(), err)
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -107,8 +420,8 @@ type bankCard struct {
 }
 
 type addMoneyAction struct {
-	Value  float64  `json:"value"`
-	Source bankCard `json:"source"`
+	Value  json.Number `json:"value"`
+	Source bankCard    `json:"source"`
 }
 
 type accountDepositLambda struct{ accountBalanceLambda }
@@ -121,6 +434,8 @@ func (*accountDepositLambda) CreateRequest() interface{} {
 	return &addMoneyAction{}
 }
 
+func (*accountDepositLambda) IsIdempotent() bool { return true }
+
 func (lambda *accountDepositLambda) Run(
 	lambdaRequest LambdaRequest) (*httpResponse, error) {
 
@@ -131,10 +446,6 @@ func (lambda *accountDepositLambda) Run(
 	clientID := lambdaRequest.GetClientID()
 
 	request := lambdaRequest.GetRequest().(*addMoneyAction)
-	if request.Value <= 0 {
-		return newHTTPResponseBadParam("value must be positive",
-			`value has invalid value "%v"`, request.Value)
-	}
 	card := &elefant.BankCard{
 		Number:         request.Source.Number,
 		ValidThruMonth: request.Source.ValidThruMonth,
@@ -147,53 +458,119 @@ func (lambda *accountDepositLambda) Run(
 	}
 	defer db.Rollback()
 
-	var acc elefant.Account
-	_, acc, err = db.UpdateClientAccountBalance(accID, clientID, request.Value)
-	if err != nil {
-		return nil, fmt.Errorf(
-			`failed to update account "%s" balance for client "%s" with delta %f: "%v"`,
-			accID, clientID, request.Value, err)
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
 	}
-	if acc == nil {
-		return newHTTPResponseEmptyError(http.StatusBadRequest,
-			`client "%s" does not have account "%s"`, clientID, accID)
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	var value *elefant.Money
+	value, response, err = parseMoneyValue(request.Value, acc.GetCurrency())
+	if response != nil || err != nil {
+		return response, err
 	}
 
+	journal := elefant.NewJournal()
+	journal.AddSystemPosting(elefant.SystemAccountCardClearing, value.Neg())
+	journal.AddAccountPosting(accID, *value)
+	var accounts map[elefant.AccountID]elefant.Account
+	accounts, response, err = lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	acc = accounts[accID]
+
 	var trans *elefant.Trans
-	var response *httpResponse
 	response, err = lambda.deposit(
-		acc, request.Value,
+		acc, *value, nil, journal.ID, true,
 		func() (elefant.Method, error) { return db.GetBankCardMethod(acc, card) },
 		db, &trans)
 	if response != nil || err != nil {
 		return response, err
 	}
 
-	if err := db.Commit(); err != nil {
+	if response, err = newHTTPResponseEmpty(http.StatusAccepted); err != nil {
 		return nil, err
 	}
+	if conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
 	elefant.Log.Info(fmtTransLog(trans))
-	return newHTTPResponseEmpty(http.StatusAccepted)
+	return response, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
 type accountPaymentAccountOrder struct {
-	Value   float64 `json:"value"`
-	Account string  `json:"account"`
+	Value   json.Number `json:"value"`
+	Account string      `json:"account"`
+	// Quote is an optional exchange quote ID with the locked rate for
+	// the payment to account in another currency.
+	Quote string `json:"quote,omitempty"`
 }
 
-type accountPaymentToAccountLambda struct{ accountBalanceLambda }
+// readAccountPaymentOrder validates payment order to another account and
+// returns sender and receiver accounts with the value in the sender currency.
+func (lambda *accountBalanceLambda) readAccountPaymentOrder(
+	accFromID elefant.AccountID,
+	clientID elefant.ClientID,
+	request *accountPaymentAccountOrder,
+	db elefant.DBTrans) (
+	elefant.Account, elefant.Account, *elefant.Money, *httpResponse, error) {
+
+	accToID, err := elefant.ParseAccountID(request.Account)
+	if err != nil {
+		response, err := newHTTPResponseBadParam("invalid receiver account ID",
+			`failed to parse receiver account ID "%s": "%v"`, request.Account, err)
+		return nil, nil, nil, response, err
+	}
+	if accToID == accFromID {
+		response, err := newHTTPResponseBadParam("invalid receiver account ID",
+			`account "%s" is the sender and the receiver`, accToID)
+		return nil, nil, nil, response, err
+	}
+
+	accFrom, response, err := lambda.findClientAccount(accFromID, clientID, db)
+	if response != nil || err != nil {
+		return nil, nil, nil, response, err
+	}
+	var value *elefant.Money
+	value, response, err = parseMoneyValue(request.Value, accFrom.GetCurrency())
+	if response != nil || err != nil {
+		return nil, nil, nil, response, err
+	}
+
+	var accTo elefant.Account
+	if accTo, err = db.FindAccount(accToID); err != nil {
+		return nil, nil, nil, nil,
+			fmt.Errorf(`failed to find account "%s": "%v"`, accToID, err)
+	}
+	if accTo == nil {
+		response, err := newHTTPResponseEmptyError(http.StatusNotFound,
+			`receiver account ID "%s" is not existent`, accToID)
+		return nil, nil, nil, response, err
+	}
+
+	return accFrom, accTo, value, nil, nil
+}
+
+type accountPaymentToAccountLambda struct{ accountExchangeLambda }
 
 func (*lambdaFactory) NewAccountPaymentToAccountLambda() lambdaImpl {
 	return &accountPaymentToAccountLambda{
-		accountBalanceLambda: newAccountBalanceLambda()}
+		accountExchangeLambda: newAccountExchangeLambda()}
 }
 
 func (*accountPaymentToAccountLambda) CreateRequest() interface{} {
 	return &accountPaymentAccountOrder{}
 }
 
+func (*accountPaymentToAccountLambda) IsIdempotent() bool { return true }
+
 func (lambda *accountPaymentToAccountLambda) Run(
 	lambdaRequest LambdaRequest) (*httpResponse, error) {
 
@@ -204,16 +581,6 @@ func (lambda *accountPaymentToAccountLambda) Run(
 	clientID := lambdaRequest.GetClientID()
 
 	request := lambdaRequest.GetRequest().(*accountPaymentAccountOrder)
-	if request.Value <= 0 {
-		return newHTTPResponseBadParam("value must be positive",
-			`value has invalid value "%v"`, request.Value)
-	}
-
-	accToID, err := elefant.ParseAccountID(request.Account)
-	if err != nil {
-		return newHTTPResponseBadParam("invalid receiver account ID",
-			`failed to parse receiver account ID "%s": "%v"`, request.Account, err)
-	}
 
 	db, err := lambda.db.Begin()
 	if err != nil {
@@ -221,58 +588,199 @@ func (lambda *accountPaymentToAccountLambda) Run(
 	}
 	defer db.Rollback()
 
-	var clientTo elefant.Client
-	var accTo elefant.Account
-	clientTo, accTo, err = db.UpdateAccountBalance(accToID, request.Value)
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	accFrom, accTo, value, response, err := lambda.readAccountPaymentOrder(
+		accFromID, clientID, request, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	return lambda.pay(accFrom, accTo, *value, request.Quote,
+		lambdaRequest.StoreIdempotentResponse, db)
+}
+
+// pay executes payment between accounts and commits the DB transaction.
+// If the quote is empty - the actual exchange rate is used for the payment to
+// account in another currency.
+func (lambda *accountPaymentToAccountLambda) pay(
+	accFrom elefant.Account,
+	accTo elefant.Account,
+	value elefant.Money,
+	quote string,
+	beforeCommit commitHook,
+	db elefant.DBTrans) (*httpResponse, error) {
+
+	trans, response, err := lambda.transfer(
+		accFrom, accTo, value, quote, beforeCommit, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	if response, err = newHTTPResponseEmpty(http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	if conflict, err := lambda.commit(
+		beforeCommit, response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	for _, trans := range trans {
+		elefant.Log.Info(fmtTransLog(trans))
+	}
+	return response, nil
+}
+
+// transfer executes payment between accounts like pay, but doesn't commit
+// the DB transaction. Returns the sender and the receiver transactions.
+func (lambda *accountPaymentToAccountLambda) transfer(
+	accFrom elefant.Account,
+	accTo elefant.Account,
+	value elefant.Money,
+	quote string,
+	beforeCommit commitHook,
+	db elefant.DBTrans) ([]*elefant.Trans, *httpResponse, error) {
+
+	accFromID := accFrom.GetID()
+	accToID := accTo.GetID()
+
+	valueTo := value
+	var exchangeFrom *elefant.TransExchange
+	var exchangeTo *elefant.TransExchange
+	if accFrom.GetCurrency().GetISO() != accTo.GetCurrency().GetISO() {
+		var rate *big.Rat
+		var response *httpResponse
+		var err error
+		rate, valueTo, response, err = lambda.getPaymentExchange(
+			quote, accFrom, accTo, value, db)
+		if response != nil || err != nil {
+			return nil, response, err
+		}
+		exchangeFrom = elefant.NewTransExchange(rate, valueTo.Neg())
+		exchangeTo = elefant.NewTransExchange(rate, value)
+	} else if quote != "" {
+		response, err := newHTTPResponseBadParam(
+			"quote is applicable only for payment to account in another currency",
+			`quote "%s" provided for accounts "%s" and "%s" in the same currency`,
+			quote, accFromID, accToID)
+		return nil, response, err
+	}
+
+	clientFrom, err := db.GetClient(accFrom.GetClientID())
 	if err != nil {
-		return nil, fmt.Errorf(
-			`failed to update account "%s" balance with delta %f: "%v"`,
-			accToID, request.Value, err)
+		return nil, nil, fmt.Errorf(`failed to get sender client "%s": "%v"`,
+			accFrom.GetClientID(), err)
 	}
-	if accTo == nil {
-		return newHTTPResponseEmptyError(http.StatusNotFound,
-			`receiver account ID "%s" is not existent`, accToID)
+	clientTo, err := db.GetClient(accTo.GetClientID())
+	if err != nil {
+		return nil, nil, fmt.Errorf(`failed to get receiver client "%s": "%v"`,
+			accTo.GetClientID(), err)
 	}
 
-	var clientFrom elefant.Client
+	journal := elefant.NewJournal()
+	journal.AddAccountPosting(accFromID, value.Neg())
+	if exchangeFrom != nil {
+		journal.AddSystemPosting(elefant.SystemAccountExchange, value)
+		journal.AddSystemPosting(elefant.SystemAccountExchange, valueTo.Neg())
+	}
+	journal.AddAccountPosting(accToID, valueTo)
+	accounts, response, err := lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	accFrom = accounts[accFromID]
+	accTo = accounts[accToID]
+
 	var transFrom *elefant.Trans
-	var response *httpResponse
-	response, err = lambda.withdraw(accFromID, clientID, request.Value,
-		func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
+	response, err = lambda.withdraw(accFrom, value, exchangeFrom, journal.ID,
+		true, func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
 			return db.GetAccountMethod(acc, accToID, clientTo.GetEmail())
-		}, db, &transFrom, &clientFrom)
+		}, beforeCommit, db, &transFrom)
 	if response != nil || err != nil {
-		return response, err
-	}
-	if transFrom.Account.GetCurrency().GetISO() != accTo.GetCurrency().GetISO() {
-		return newHTTPResponseBadParam("invalid receiver account ID",
-			`account-sender "%s" has currency "%s", but account-receiver "%s" - "%s"`,
-			transFrom.Account.GetID(), transFrom.Account.GetCurrency().GetISO(),
-			accTo.GetID(), accTo.GetCurrency().GetISO())
+		return nil, response, err
 	}
 
 	var transTo *elefant.Trans
-	response, err = lambda.deposit(accTo, request.Value,
-		func() (elefant.Method, error) {
+	response, err = lambda.deposit(accTo, valueTo, exchangeTo, journal.ID,
+		true, func() (elefant.Method, error) {
 			return db.GetAccountMethod(accTo, accFromID, clientFrom.GetEmail())
 		}, db, &transTo)
 	if response != nil || err != nil {
-		return response, err
+		return nil, response, err
 	}
 
-	if err := db.Commit(); err != nil {
-		return nil, err
-	}
-	elefant.Log.Info(fmtTransLog(transFrom))
-	elefant.Log.Info(fmtTransLog(transTo))
-	return newHTTPResponseEmpty(http.StatusAccepted)
+	return []*elefant.Trans{transFrom, transTo}, nil, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
 type accountPaymentTaxOrder struct {
-	Value float64 `json:"value"`
-	Bill  string  `json:"bill"`
+	Value json.Number `json:"value"`
+	// Authority is the tax authority ID.
+	Authority string `json:"authority"`
+	Bill      string `json:"bill"`
+}
+
+// accountTaxReceipt confirms the tax payment, the reference is the normalized
+// bill, which is sent to the authority.
+type accountTaxReceipt struct {
+	Trans         string        `json:"trans"`
+	Time          time.Time     `json:"time"`
+	Value         elefant.Money `json:"value"`
+	Authority     string        `json:"authority"`
+	AuthorityName string        `json:"authorityName"`
+	Reference     string        `json:"reference"`
+}
+
+// parseTaxAuthorityID parses the tax authority ID from the request.
+func parseTaxAuthorityID(
+	source string) (*elefant.TaxAuthorityID, *httpResponse, error) {
+	if source == "" {
+		response, err := newHTTPResponseBadParam("tax authority is not set",
+			"tax authority is not set")
+		return nil, response, err
+	}
+	result, err := elefant.ParseTaxAuthorityID(source)
+	if err != nil {
+		response, err := newHTTPResponseBadParam("invalid tax authority ID",
+			`failed to parse tax authority ID "%s": "%v"`, source, err)
+		return nil, response, err
+	}
+	return &result, nil, nil
+}
+
+// readTaxBill finds the tax authority and validates the bill by
+// the authority rules.
+func readTaxBill(
+	authorityID elefant.TaxAuthorityID,
+	bill string,
+	currency elefant.Currency,
+	db elefant.DBTrans) (*elefant.TaxBill, *httpResponse, error) {
+	authority, err := db.FindTaxAuthority(authorityID)
+	if err != nil {
+		return nil, nil, fmt.Errorf(`failed to find tax authority "%s": "%v"`,
+			authorityID, err)
+	}
+	if authority == nil {
+		response, err := newHTTPResponseEmptyError(http.StatusNotFound,
+			`tax authority ID "%s" is not existent`, authorityID)
+		return nil, response, err
+	}
+	if authority.Currency.GetISO() != currency.GetISO() {
+		message := fmt.Sprintf("%s doesn't accept payments in %s",
+			authority.Name, currency.GetISO())
+		response, err := newHTTPResponseBadParam(message, "%s", message)
+		return nil, response, err
+	}
+	result, err := authority.NewBill(bill)
+	if err != nil {
+		response, err := newHTTPResponseBadParam(err.Error(),
+			`failed to validate tax bill "%s": "%v"`, bill, err)
+		return nil, response, err
+	}
+	return result, nil, nil
 }
 
 type accountPaymentTaxLambda struct{ accountBalanceLambda }
@@ -286,6 +794,8 @@ func (*accountPaymentTaxLambda) CreateRequest() interface{} {
 	return &accountPaymentTaxOrder{}
 }
 
+func (*accountPaymentTaxLambda) IsIdempotent() bool { return true }
+
 func (lambda *accountPaymentTaxLambda) Run(
 	lambdaRequest LambdaRequest) (*httpResponse, error) {
 
@@ -296,10 +806,6 @@ func (lambda *accountPaymentTaxLambda) Run(
 	clientID := lambdaRequest.GetClientID()
 
 	request := lambdaRequest.GetRequest().(*accountPaymentTaxOrder)
-	if request.Value <= 0 {
-		return newHTTPResponseBadParam("value must be positive",
-			`value has invalid value "%v"`, request.Value)
-	}
 
 	db, err := lambda.db.Begin()
 	if err != nil {
@@ -307,21 +813,111 @@ func (lambda *accountPaymentTaxLambda) Run(
 	}
 	defer db.Rollback()
 
-	var trans *elefant.Trans
-	var response *httpResponse
-	response, err = lambda.withdraw(accID, clientID, request.Value,
-		func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
-			return db.GetTaxMethod(acc, request.Bill)
-		}, db, &trans, nil)
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	acc, value, bill, response, err := lambda.readAccountPaymentTaxOrder(
+		accID, clientID, request, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	return lambda.pay(acc, *value, bill,
+		lambdaRequest.StoreIdempotentResponse, db)
+}
+
+// readAccountPaymentTaxOrder validates tax payment order and returns
+// the account with the value in the account currency and the validated bill,
+// so invalid bill is rejected before the withdrawal.
+func (lambda *accountPaymentTaxLambda) readAccountPaymentTaxOrder(
+	accID elefant.AccountID,
+	clientID elefant.ClientID,
+	request *accountPaymentTaxOrder,
+	db elefant.DBTrans) (
+	elefant.Account, *elefant.Money, *elefant.TaxBill, *httpResponse, error) {
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return nil, nil, nil, response, err
+	}
+	value, response, err := parseMoneyValue(request.Value, acc.GetCurrency())
+	if response != nil || err != nil {
+		return nil, nil, nil, response, err
+	}
+	authorityID, response, err := parseTaxAuthorityID(request.Authority)
+	if response != nil || err != nil {
+		return nil, nil, nil, response, err
+	}
+	bill, response, err := readTaxBill(
+		*authorityID, request.Bill, acc.GetCurrency(), db)
+	if response != nil || err != nil {
+		return nil, nil, nil, response, err
+	}
+	return acc, value, bill, nil, nil
+}
+
+// pay executes tax payment and commits the DB transaction.
+func (lambda *accountPaymentTaxLambda) pay(
+	acc elefant.Account,
+	value elefant.Money,
+	bill *elefant.TaxBill,
+	beforeCommit commitHook,
+	db elefant.DBTrans) (*httpResponse, error) {
+
+	trans, response, err := lambda.transfer(acc, value, bill, beforeCommit, db)
 	if response != nil || err != nil {
 		return response, err
 	}
 
-	if err := db.Commit(); err != nil {
+	response, err = newHTTPResponse(http.StatusAccepted, &accountTaxReceipt{
+		Trans:         trans.ID.String(),
+		Time:          trans.Time,
+		Value:         value,
+		Authority:     bill.Authority.ID.String(),
+		AuthorityName: bill.Authority.Name,
+		Reference:     bill.Reference})
+	if err != nil {
 		return nil, err
 	}
+	if conflict, err := lambda.commit(
+		beforeCommit, response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
 	elefant.Log.Info(fmtTransLog(trans))
-	return newHTTPResponseEmpty(http.StatusAccepted)
+	return response, nil
+}
+
+// transfer executes tax payment like pay, but doesn't commit the DB
+// transaction. The payment is collected by the authority house account.
+func (lambda *accountPaymentTaxLambda) transfer(
+	acc elefant.Account,
+	value elefant.Money,
+	bill *elefant.TaxBill,
+	beforeCommit commitHook,
+	db elefant.DBTrans) (*elefant.Trans, *httpResponse, error) {
+
+	accID := acc.GetID()
+
+	journal := elefant.NewJournal()
+	journal.AddAccountPosting(accID, value.Neg())
+	journal.AddSystemAccountPosting(bill.Authority.Account, value)
+	accounts, response, err := lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	acc = accounts[accID]
+
+	var trans *elefant.Trans
+	response, err = lambda.withdraw(acc, value, nil, journal.ID, true,
+		func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
+			return db.GetTaxMethod(acc, bill.Authority, bill.Reference)
+		}, beforeCommit, db, &trans)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	return trans, nil, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountCloseOrder struct {
+	// SweepTo is an optional own account ID to which the account funds are
+	// transferred before closing, it's required if the account has funds.
+	SweepTo string `json:"sweepTo,omitempty"`
+}
+
+type accountCloseLambda struct{ accountExchangeLambda }
+
+func (*lambdaFactory) NewAccountCloseLambda() lambdaImpl {
+	return &accountCloseLambda{accountExchangeLambda: newAccountExchangeLambda()}
+}
+
+func (*accountCloseLambda) CreateRequest() interface{} {
+	return &accountCloseOrder{}
+}
+
+func (*accountCloseLambda) IsIdempotent() bool { return true }
+
+func (lambda *accountCloseLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	request := lambdaRequest.GetRequest().(*accountCloseOrder)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	if acc.GetStatus() != elefant.AccountStatusActive {
+		return newHTTPResponseAccountStatus(&elefant.AccountStatusError{
+			Account: accID, Status: acc.GetStatus()})
+	}
+
+	if held := acc.GetHeld(); held.IsPositive() {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`account "%s" with held %s could not be closed`, accID, held)
+	}
+
+	var sweep []*elefant.Trans
+	switch balance := acc.GetBalance(); {
+	case balance.IsNegative():
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`account "%s" with negative balance %s could not be closed`,
+			accID, balance)
+	case balance.IsPositive():
+		if request.SweepTo == "" {
+			return newHTTPResponseBadParam(
+				"account has funds, sweep account is required",
+				`sweep account is not provided for account "%s" with balance %s`,
+				accID, balance)
+		}
+		sweep, response, err = lambda.sweep(acc, request.SweepTo,
+			lambdaRequest.StoreIdempotentResponse, db)
+		if response != nil || err != nil {
+			return response, err
+		}
+	}
+
+	isClosed, err := db.CloseAccount(accID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to close account "%s": "%v"`, accID, err)
+	}
+	if !isClosed {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`account "%s" balance is changed while closing`, accID)
+	}
+	// Standing orders could not be executed for the closed account.
+	if err := db.DeleteAccountSchedules(accID); err != nil {
+		return nil, fmt.Errorf(
+			`failed to delete account "%s" standing orders: "%v"`, accID, err)
+	}
+
+	if response, err = newHTTPResponseNoContent(); err != nil {
+		return nil, err
+	}
+	if conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	for _, trans := range sweep {
+		elefant.Log.Info(fmtTransLog(trans))
+	}
+	elefant.Log.Info(`Closed account "%s" of client "%s".`, accID, clientID)
+	return response, nil
+}
+
+// sweep transfers all account funds to another client account. The fee is
+// not charged and the spending limits are not checked.
+func (lambda *accountCloseLambda) sweep(
+	acc elefant.Account,
+	accToIDSource string,
+	beforeCommit commitHook,
+	db elefant.DBTrans) ([]*elefant.Trans, *httpResponse, error) {
+
+	accID := acc.GetID()
+	accToID, err := elefant.ParseAccountID(accToIDSource)
+	if err != nil {
+		response, err := newHTTPResponseBadParam("invalid sweep account ID",
+			`failed to parse sweep account ID "%s": "%v"`, accToIDSource, err)
+		return nil, response, err
+	}
+	if accToID == accID {
+		response, err := newHTTPResponseBadParam("invalid sweep account ID",
+			`account "%s" is swept to itself`, accID)
+		return nil, response, err
+	}
+	accTo, response, err := lambda.findClientAccount(
+		accToID, acc.GetClientID(), db)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	client, err := db.GetClient(acc.GetClientID())
+	if err != nil {
+		return nil, nil, fmt.Errorf(`failed to get client "%s": "%v"`,
+			acc.GetClientID(), err)
+	}
+
+	value := acc.GetBalance()
+	valueTo := value
+	var exchangeFrom *elefant.TransExchange
+	var exchangeTo *elefant.TransExchange
+	if acc.GetCurrency().GetISO() != accTo.GetCurrency().GetISO() {
+		var rate *big.Rat
+		rate, valueTo, response, err = lambda.getPaymentExchange(
+			"", acc, accTo, value, db)
+		if response != nil || err != nil {
+			return nil, response, err
+		}
+		exchangeFrom = elefant.NewTransExchange(rate, valueTo.Neg())
+		exchangeTo = elefant.NewTransExchange(rate, value)
+	}
+
+	journal := elefant.NewJournal()
+	journal.AddAccountPosting(accID, value.Neg())
+	if exchangeFrom != nil {
+		journal.AddSystemPosting(elefant.SystemAccountExchange, value)
+		journal.AddSystemPosting(elefant.SystemAccountExchange, valueTo.Neg())
+	}
+	journal.AddAccountPosting(accToID, valueTo)
+	accounts, response, err := lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	acc = accounts[accID]
+	accTo = accounts[accToID]
+
+	var transFrom *elefant.Trans
+	response, err = lambda.withdraw(acc, value, exchangeFrom, journal.ID,
+		false, func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
+			return db.GetAccountMethod(acc, accToID, client.GetEmail())
+		}, beforeCommit, db, &transFrom)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+
+	var transTo *elefant.Trans
+	response, err = lambda.deposit(accTo, valueTo, exchangeTo, journal.ID,
+		false, func() (elefant.Method, error) {
+			return db.GetAccountMethod(accTo, accID, client.GetEmail())
+		}, db, &transTo)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+
+	return []*elefant.Trans{transFrom, transTo}, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountExchangeLambda struct{ accountBalanceLambda }
+
+func newAccountExchangeLambda() accountExchangeLambda {
+	return accountExchangeLambda{accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (lambda *accountExchangeLambda) getRate(
+	from, to elefant.Account) (*elefant.ExchangeRate, *httpResponse, error) {
+	rate, err := lambda.rates.GetRate(from.GetCurrency(), to.GetCurrency())
+	if staleErr, isStale := err.(*elefant.ExchangeRateStaleError); isStale {
+		// Money is not converted by old rates, the client has to retry later.
+		elefant.Log.Error(`Failed to get exchange rate for "%s" -> "%s": "%v".`,
+			from.GetCurrency().GetISO(), to.GetCurrency().GetISO(), staleErr)
+		response, err := newHTTPResponse(http.StatusServiceUnavailable,
+			&errorResponse{Message: elefant.CapitalizeString(staleErr.Error())})
+		return nil, response, err
+	}
+	if err != nil {
+		response, err := newHTTPResponseBadParam(
+			"there is no exchange rate for the receiver account currency",
+			`failed to get exchange rate for "%s" -> "%s": "%v"`,
+			from.GetCurrency().GetISO(), to.GetCurrency().GetISO(), err)
+		return nil, response, err
+	}
+	return rate, nil, nil
+}
+
+// getPaymentExchange returns exchange rate and the value in the receiver
+// currency for the payment between accounts in different currencies. If the
+// quote is provided - the locked rate is used, otherwise the actual rate.
+func (lambda *accountExchangeLambda) getPaymentExchange(
+	quoteIDSource string,
+	from elefant.Account,
+	to elefant.Account,
+	value elefant.Money,
+	db elefant.DBTrans) (*big.Rat, elefant.Money, *httpResponse, error) {
+
+	if quoteIDSource == "" {
+		rate, response, err := lambda.getRate(from, to)
+		if response != nil || err != nil {
+			return nil, value, response, err
+		}
+		return rate.Rate, rate.Convert(value), nil, nil
+	}
+
+	quoteID, err := elefant.ParseExchangeQuoteID(quoteIDSource)
+	if err != nil {
+		response, err := newHTTPResponseBadParam("quote ID has invalid format",
+			`failed to parse quote ID "%s": "%v"`, quoteIDSource, err)
+		return nil, value, response, err
+	}
+	quote, err := db.AcceptExchangeQuote(quoteID, from.GetClientID())
+	if err != nil {
+		return nil, value, nil,
+			fmt.Errorf(`failed to accept exchange quote "%s": "%v"`, quoteID, err)
+	}
+	if quote == nil {
+		response, err := newHTTPResponseEmptyError(http.StatusGone,
+			`exchange quote "%s" is not existent or expired`, quoteID)
+		return nil, value, response, err
+	}
+	if quote.From != from.GetID() ||
+		quote.To != to.GetID() ||
+		quote.Value.GetCurrency().GetISO() != value.GetCurrency().GetISO() ||
+		quote.Value.Cmp(value) != 0 {
+		response, err := newHTTPResponseBadParam(
+			"quote is issued for another payment",
+			`exchange quote "%s" is issued for %s "%s" -> "%s", but not %s "%s" -> "%s"`,
+			quoteID, quote.Value, quote.From, quote.To,
+			value, from.GetID(), to.GetID())
+		return nil, value, response, err
+	}
+	return quote.Rate, quote.CounterValue, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountExchangeQuote struct {
+	Quote        string        `json:"quote"`
+	Rate         json.Number   `json:"rate"`
+	Value        elefant.Money `json:"value"`
+	CounterValue elefant.Money `json:"counterValue"`
+	Expiry       time.Time     `json:"expiry"`
+}
+
+type accountPaymentToAccountQuoteLambda struct{ accountExchangeLambda }
+
+func (*lambdaFactory) NewAccountPaymentToAccountQuoteLambda() lambdaImpl {
+	return &accountPaymentToAccountQuoteLambda{
+		accountExchangeLambda: newAccountExchangeLambda()}
+}
+
+func (*accountPaymentToAccountQuoteLambda) CreateRequest() interface{} {
+	return &accountPaymentAccountOrder{}
+}
+
+func (lambda *accountPaymentToAccountQuoteLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accFromID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	request := lambdaRequest.GetRequest().(*accountPaymentAccountOrder)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	accFrom, accTo, value, response, err := lambda.readAccountPaymentOrder(
+		accFromID, clientID, request, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	if accFrom.GetCurrency().GetISO() == accTo.GetCurrency().GetISO() {
+		return newHTTPResponseBadParam(
+			"receiver account has the same currency",
+			`quote requested for accounts "%s" and "%s" in the same currency`,
+			accFrom.GetID(), accTo.GetID())
+	}
+
+	var rate *elefant.ExchangeRate
+	rate, response, err = lambda.getRate(accFrom, accTo)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	var quote *elefant.ExchangeQuote
+	quote, err = db.CreateExchangeQuote(
+		accFrom, accTo, *value, rate, elefant.ExchangeQuoteLiveTime)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to create exchange quote: "%v"`, err)
+	}
+
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(
+		`Created exchange quote "%s" %s -> %s for "%s" -> "%s" with rate %s.`,
+		quote.ID, quote.Value, quote.CounterValue, quote.From, quote.To,
+		elefant.FormatExchangeRate(quote.Rate))
+	return newHTTPResponse(http.StatusCreated, &accountExchangeQuote{
+		Quote:        quote.ID.String(),
+		Rate:         json.Number(elefant.FormatExchangeRate(quote.Rate)),
+		Value:        quote.Value,
+		CounterValue: quote.CounterValue,
+		Expiry:       quote.Expiry})
+}
+
+////////////////////////////////////////////////////////////////////////////////
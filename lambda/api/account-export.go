@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountExportLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewAccountExportLambda() lambdaImpl {
+	return &accountExportLambda{accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*accountExportLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountExportLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := request.GetClientID()
+
+	format, err := request.ReadQueryArgString("format")
+	if err != nil {
+		return newHTTPResponseBadParam("format is not provided",
+			`failed to get format: "%v"`, err)
+	}
+
+	// The transaction time is stored in UTC, so the period and the exported
+	// times are converted into the client time zone.
+	location := time.UTC
+	if source, err := request.ReadQueryArgString("tz"); err == nil {
+		if location, err = time.LoadLocation(source); err != nil {
+			return newHTTPResponseBadParam("unknown time zone",
+				`failed to load time zone "%s": "%v"`, source, err)
+		}
+	}
+
+	from, to, response, err := readQueryPeriod(
+		request, location, accountExportMaxDays)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	var after *elefant.TransCursor
+	if source, err := request.ReadQueryArgString("after"); err == nil {
+		cursor, err := elefant.ParseTransCursor(source)
+		if err != nil {
+			return newHTTPResponseBadParam("cursor has invalid format", "%v", err)
+		}
+		after = &cursor
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	body := &strings.Builder{}
+	export, err := elefant.NewTransExport(format, acc, from, to, location, body)
+	if err != nil {
+		return newHTTPResponseBadParam("unknown format", "%v", err)
+	}
+	if err := export.Begin(); err != nil {
+		return nil, fmt.Errorf(`failed to begin export: "%v"`, err)
+	}
+	// The export is returned in the response body, so the whole page is held
+	// in memory, and the page size is limited to fit the response limit.
+	var last *elefant.Trans
+	numberOfTrans := 0
+	hasMore, err := db.ForEachAccountExportTrans(
+		accID, from.UTC(), to.UTC(), after, accountExportPageSize,
+		func(trans *elefant.Trans) error {
+			last = trans
+			numberOfTrans++
+			return export.Write(trans)
+		})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to export account "%s" transactions: "%v"`,
+			accID, err)
+	}
+	closing, err := db.GetAccountBalanceAt(acc, to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" closing balance: "%v"`,
+			accID, err)
+	}
+	if err := export.End(closing); err != nil {
+		return nil, fmt.Errorf(`failed to end export: "%v"`, err)
+	}
+
+	elefant.Log.Info(`Exported %d actions of account "%s" in %s.`,
+		numberOfTrans, accID, format)
+	headers := map[string]string{
+		"Content-Type": export.GetContentType(),
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s_%s.%s"`,
+			accID, from.Format(queryDateFormat), export.GetFileExtension())}
+	if !hasMore {
+		return newHTTPResponseWithBody(http.StatusOK, body.String(), headers)
+	}
+	headers[ExportCursorHeaderName] = elefant.NewTransCursor(last).String()
+	return newHTTPResponseWithBody(
+		http.StatusPartialContent, body.String(), headers)
+}
+
+////////////////////////////////////////////////////////////////////////////////
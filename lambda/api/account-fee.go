@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// feeOperations is operations for which the fee could be calculated before
+// execution.
+var feeOperations = map[string]elefant.FeeOperation{
+	"deposit":         elefant.FeeOperationCardDeposit,
+	"payment-account": elefant.FeeOperationAccountPayment,
+	"payment-tax":     elefant.FeeOperationTaxPayment,
+}
+
+type accountFee struct {
+	Value elefant.Money `json:"value"`
+	Fee   elefant.Money `json:"fee"`
+	// Total is the account balance change by the operation with the fee.
+	Total elefant.Money `json:"total"`
+}
+
+type accountFeeLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewAccountFeeLambda() lambdaImpl {
+	return &accountFeeLambda{accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*accountFeeLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountFeeLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	operationName, err := request.ReadQueryArgString("operation")
+	if err != nil {
+		return newHTTPResponseBadParam("operation is not provided",
+			`failed to get operation: "%v"`, err)
+	}
+	operation, has := feeOperations[operationName]
+	if !has {
+		return newHTTPResponseBadParam("operation is unknown",
+			`operation "%s" is unknown`, operationName)
+	}
+	valueSource, err := request.ReadQueryArgString("value")
+	if err != nil {
+		return newHTTPResponseBadParam("value is not provided",
+			`failed to get value: "%v"`, err)
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(
+		accID, request.GetClientID(), db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	value, response, err := parseMoneyValue(
+		json.Number(valueSource), acc.GetCurrency())
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	fee, err := lambda.findFee(operation, *value, db)
+	if err != nil {
+		return nil, err
+	}
+	total := value.Add(fee)
+	if operation.Direction == elefant.FeeDirectionIn {
+		total = value.Sub(fee)
+	}
+	return newHTTPResponse(http.StatusOK,
+		&accountFee{Value: *value, Fee: fee, Total: total})
+}
+
+////////////////////////////////////////////////////////////////////////////////
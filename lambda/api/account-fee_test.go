@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// feeTestDB finds fee rules and posts journals as the DB does, other DB
+// methods are not implemented.
+type feeTestDB struct {
+	elefant.DBTrans
+	acc      elefant.Account
+	rules    map[elefant.FeeOperation][]*elefant.FeeRule
+	journals []*elefant.Journal
+}
+
+func (db *feeTestDB) Begin() (elefant.DBTrans, error) { return db, nil }
+func (*feeTestDB) Rollback()                          {}
+
+func (db *feeTestDB) FindClientAccount(
+	elefant.AccountID, elefant.ClientID) (elefant.Account, error) {
+	return db.acc, nil
+}
+
+func (db *feeTestDB) FindFeeRule(
+	operation elefant.FeeOperation,
+	value elefant.Money) (*elefant.FeeRule, error) {
+	for _, rule := range db.rules[operation] {
+		if rule.IsForValue(value) {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+func (db *feeTestDB) PostJournal(
+	journal *elefant.Journal) (map[elefant.AccountID]elefant.Account, error) {
+	if err := journal.Validate(); err != nil {
+		return nil, err
+	}
+	db.journals = append(db.journals, journal)
+	return map[elefant.AccountID]elefant.Account{db.acc.GetID(): db.acc}, nil
+}
+
+func newFeeTestRule(
+	t *testing.T,
+	currency elefant.Currency,
+	minValue, maxValue string,
+	fixed string,
+	percent *big.Rat,
+	minFee, maxFee string) *elefant.FeeRule {
+	parse := func(source string) *elefant.Money {
+		if source == "" {
+			return nil
+		}
+		result, err := elefant.ParseMoney(source, currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+	result := &elefant.FeeRule{
+		MinValue: *parse(minValue),
+		MaxValue: parse(maxValue),
+		Fixed:    *parse(fixed),
+		Percent:  percent,
+		Min:      *parse(minFee),
+		Max:      parse(maxFee)}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAccountFeeDryRun(t *testing.T) {
+	setTestLog(t)
+	acc := newLimitTestAccount(t, "EUR")
+	currency := acc.GetCurrency()
+	db := &feeTestDB{
+		acc: acc,
+		rules: map[elefant.FeeOperation][]*elefant.FeeRule{
+			elefant.FeeOperationCardDeposit: {
+				newFeeTestRule(t, currency, "0", "", "0", big.NewRat(15, 10),
+					"0.50", ""),
+			},
+			elefant.FeeOperationAccountPayment: {
+				newFeeTestRule(t, currency, "0", "100", "0.30", new(big.Rat),
+					"0", ""),
+				newFeeTestRule(t, currency, "100", "", "0", big.NewRat(5, 10),
+					"0", "5"),
+			},
+		}}
+	lambda := &accountFeeLambda{accountBalanceLambda: accountBalanceLambda{
+		accountLambda: accountLambda{db: db}}}
+
+	for _, test := range []struct {
+		operation string
+		value     string
+		fee       string
+		total     string
+	}{
+		{"deposit", "10.00", "0.50", "9.50"},
+		{"deposit", "100.00", "1.50", "98.50"},
+		{"deposit", "0.30", "0.30", "0.00"},
+		{"payment-account", "99.99", "0.30", "100.29"},
+		{"payment-account", "100.00", "0.50", "100.50"},
+		{"payment-account", "100.10", "0.50", "100.60"},
+		{"payment-account", "2000.00", "5.00", "2005.00"},
+		{"payment-tax", "50.00", "0.00", "50.00"},
+	} {
+		request := &lambdaRequest{Request: &httpRequest{
+			PathParameters: map[string]string{"accountId": acc.GetID().String()},
+			QueryStringParameters: map[string]string{
+				"operation": test.operation,
+				"value":     test.value},
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Authorizer: map[string]interface{}{
+					"principalId": uuid.New().String()}}}}
+		response, err := lambda.Run(request)
+		if err != nil || response.StatusCode != http.StatusOK {
+			t.Fatalf(`Fee for "%s" %s is not calculated: %v "%v".`,
+				test.operation, test.value, response, err)
+		}
+		result := struct {
+			Value json.Number `json:"value"`
+			Fee   json.Number `json:"fee"`
+			Total json.Number `json:"total"`
+		}{}
+		if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Value.String() != test.value ||
+			result.Fee.String() != test.fee ||
+			result.Total.String() != test.total {
+			t.Errorf(`Fee for "%s" %s is %s, total %s, but expected %s, %s.`,
+				test.operation, test.value, result.Fee, result.Total,
+				test.fee, test.total)
+		}
+
+		// The operation charges the same fee as the dry run returns.
+		value, err := elefant.ParseMoney(test.value, currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.journals = nil
+		_, fee, response, err := lambda.postFee(
+			acc, feeOperations[test.operation], value, db)
+		if response != nil || err != nil {
+			t.Fatalf(`Failed to post fee: %v "%v".`, response, err)
+		}
+		if fee == nil {
+			if test.fee != "0.00" || len(db.journals) != 0 {
+				t.Errorf(`Fee for "%s" %s is not charged.`,
+					test.operation, test.value)
+			}
+			continue
+		}
+		if fee.value.String() != test.fee || len(db.journals) != 1 {
+			t.Errorf(`Charged fee for "%s" %s is %s, but dry run is %s.`,
+				test.operation, test.value, fee.value, test.fee)
+			continue
+		}
+		for _, posting := range db.journals[0].Postings {
+			expected := fee.value
+			if posting.Account != nil {
+				expected = expected.Neg()
+			} else if posting.System != elefant.SystemAccountFee {
+				t.Errorf(`Fee is posted to "%s".`, posting.System)
+			}
+			if posting.Value.Cmp(expected) != 0 {
+				t.Errorf(`Fee for "%s" %s is posted as %s, but expected %s.`,
+					test.operation, test.value, posting.Value, expected)
+			}
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type holdOrder struct {
+	Value       json.Number `json:"value"`
+	Description string      `json:"description,omitempty"`
+}
+
+type holdCaptureOrder struct {
+	// Value is an optional value to capture, the full hold value is captured
+	// if it's not set.
+	Value json.Number `json:"value,omitempty"`
+}
+
+type holdInfo struct {
+	ID    string        `json:"id"`
+	Value elefant.Money `json:"value"`
+	// Captured is set only for the captured hold.
+	Captured    *elefant.Money `json:"captured,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Status      string         `json:"status"`
+	Time        time.Time      `json:"time"`
+	Expiry      time.Time      `json:"expiry"`
+}
+
+func newHoldInfo(hold *elefant.Hold, now time.Time) *holdInfo {
+	return &holdInfo{
+		ID:          hold.ID.String(),
+		Value:       hold.Value,
+		Captured:    hold.Captured,
+		Description: hold.Description,
+		Status:      hold.GetStatus(now).String(),
+		Time:        hold.Time,
+		Expiry:      hold.Expiry}
+}
+
+// holdDescriptionMaxLen is the max length of the hold description.
+const holdDescriptionMaxLen = 255
+
+type accountHoldLambda struct{ accountBalanceLambda }
+
+func newAccountHoldLambda() accountHoldLambda {
+	return accountHoldLambda{accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+// lockHold finds and locks authorized not expired hold of the client account.
+func (lambda *accountHoldLambda) lockHold(
+	request LambdaRequest,
+	db elefant.DBTrans) (elefant.Account, *elefant.Hold, *httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		response, err := newHTTPResponseBadParam(
+			"account ID has invalid format", "%v", err)
+		return nil, nil, response, err
+	}
+	id, err := request.ReadPathArgHoldID()
+	if err != nil {
+		response, err := newHTTPResponseBadParam(
+			"hold ID has invalid format", "%v", err)
+		return nil, nil, response, err
+	}
+	clientID := request.GetClientID()
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return nil, nil, response, err
+	}
+
+	hold, err := db.LockClientHold(id, accID, clientID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf(`failed to find hold "%s": "%v"`, id, err)
+	}
+	if hold == nil {
+		response, err := newHTTPResponseEmptyError(http.StatusNotFound,
+			`account "%s" does not have hold "%s"`, accID, id)
+		return nil, nil, response, err
+	}
+	if status := hold.GetStatus(time.Now().UTC()); status !=
+		elefant.HoldStatusAuthorized {
+		response, err := newHTTPResponseEmptyError(http.StatusConflict,
+			`hold "%s" is %s`, id, status)
+		return nil, nil, response, err
+	}
+	return acc, hold, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountHoldCreateLambda struct{ accountHoldLambda }
+
+func (*lambdaFactory) NewAccountHoldCreateLambda() lambdaImpl {
+	return &accountHoldCreateLambda{accountHoldLambda: newAccountHoldLambda()}
+}
+
+func (*accountHoldCreateLambda) CreateRequest() interface{} {
+	return &holdOrder{}
+}
+
+func (*accountHoldCreateLambda) IsIdempotent() bool { return true }
+
+func (lambda *accountHoldCreateLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	order := lambdaRequest.GetRequest().(*holdOrder)
+	if len(order.Description) > holdDescriptionMaxLen {
+		return newHTTPResponseBadParam("description is too long",
+			`description has %d symbols`, len(order.Description))
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	value, response, err := parseMoneyValue(order.Value, acc.GetCurrency())
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	hold := elefant.NewHold(acc, *value, order.Description,
+		elefant.HoldLiveTime)
+	if acc, err = db.AuthorizeHold(hold); err != nil {
+		if statusErr, isStatusErr := err.(*elefant.AccountStatusError); isStatusErr {
+			return newHTTPResponseAccountStatus(statusErr)
+		}
+		return nil, fmt.Errorf(`failed to authorize hold on account "%s": "%v"`,
+			accID, err)
+	}
+
+	reason := ""
+	if acc.GetAvailableBalance().IsNegative() {
+		reason = "insufficient funds"
+	} else {
+		// The hold is checked by the limits at the authorization, as it's not
+		// checked again at the capture.
+		reason, response, err = lambda.checkSpendingLimits(acc, *value, db)
+		if response != nil || err != nil {
+			return response, err
+		}
+	}
+	if reason != "" {
+		response, err := newHTTPResponsePaymentRequired(reason)
+		if err != nil {
+			return nil, err
+		}
+		elefant.Log.Warn(`Response with error code %d: hold on account "%s" `+
+			`for %s is not authorized: "%s".`,
+			response.StatusCode, accID, value, reason)
+		return response, nil
+	}
+
+	response, err = newHTTPResponse(http.StatusCreated,
+		newHoldInfo(hold, hold.Time))
+	if err != nil {
+		return nil, err
+	}
+	if conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	elefant.Log.Info(`Authorized hold "%s" on account "%s" for %s.`,
+		hold.ID, accID, hold.Value)
+	return response, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountHoldListLambda struct{ accountHoldLambda }
+
+func (*lambdaFactory) NewAccountHoldListLambda() lambdaImpl {
+	return &accountHoldListLambda{accountHoldLambda: newAccountHoldLambda()}
+}
+
+func (*accountHoldListLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountHoldListLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	_, response, err := lambda.findClientAccount(
+		accID, lambdaRequest.GetClientID(), db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	holds, err := db.GetAccountHolds(accID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" holds: "%v"`,
+			accID, err)
+	}
+	now := time.Now().UTC()
+	result := make([]*holdInfo, len(holds))
+	for i, hold := range holds {
+		result[i] = newHoldInfo(hold, now)
+	}
+	return newHTTPResponse(http.StatusOK, result)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountHoldCaptureLambda struct{ accountHoldLambda }
+
+func (*lambdaFactory) NewAccountHoldCaptureLambda() lambdaImpl {
+	return &accountHoldCaptureLambda{accountHoldLambda: newAccountHoldLambda()}
+}
+
+func (*accountHoldCaptureLambda) CreateRequest() interface{} {
+	return &holdCaptureOrder{}
+}
+
+func (*accountHoldCaptureLambda) IsIdempotent() bool { return true }
+
+func (lambda *accountHoldCaptureLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	order := lambdaRequest.GetRequest().(*holdCaptureOrder)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	acc, hold, response, err := lambda.lockHold(lambdaRequest, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	accID := acc.GetID()
+
+	value := hold.Value
+	if order.Value != "" {
+		var partial *elefant.Money
+		partial, response, err = parseMoneyValue(order.Value, acc.GetCurrency())
+		if response != nil || err != nil {
+			return response, err
+		}
+		if partial.Cmp(hold.Value) > 0 {
+			return newHTTPResponseBadParam("value exceeds the hold value",
+				`value %s exceeds hold "%s" value %s`, partial, hold.ID, hold.Value)
+		}
+		value = *partial
+	}
+
+	// The reserved value has to be released before the withdrawal to not
+	// reduce the available balance twice.
+	isCaptured, err := db.CaptureHold(hold.ID, value)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to capture hold "%s": "%v"`, hold.ID, err)
+	}
+	if !isCaptured {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`hold "%s" is released while capturing`, hold.ID)
+	}
+
+	journal := elefant.NewJournal()
+	journal.AddAccountPosting(accID, value.Neg())
+	journal.AddSystemPosting(elefant.SystemAccountHoldSettlement, value)
+	accounts, response, err := lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	acc = accounts[accID]
+
+	// The fee and the limits are not applied as the hold is already checked
+	// at the authorization.
+	var trans *elefant.Trans
+	response, err = lambda.withdraw(acc, value, nil, journal.ID, false,
+		func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
+			return db.GetHoldMethod(acc, hold)
+		}, lambdaRequest.StoreIdempotentResponse, db, &trans)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	if response, err = newHTTPResponseEmpty(http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	if conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	elefant.Log.Info(`Captured hold "%s" for %s of %s.`,
+		hold.ID, value, hold.Value)
+	elefant.Log.Info(fmtTransLog(trans))
+	return response, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountHoldVoidLambda struct{ accountHoldLambda }
+
+func (*lambdaFactory) NewAccountHoldVoidLambda() lambdaImpl {
+	return &accountHoldVoidLambda{accountHoldLambda: newAccountHoldLambda()}
+}
+
+func (*accountHoldVoidLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountHoldVoidLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	_, hold, response, err := lambda.lockHold(lambdaRequest, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	isVoided, err := db.VoidHold(hold.ID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to void hold "%s": "%v"`, hold.ID, err)
+	}
+	if !isVoided {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`hold "%s" is released while voiding`, hold.ID)
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(`Voided hold "%s" for %s.`, hold.ID, hold.Value)
+	return newHTTPResponseNoContent()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// HoldExpiry releases holds which are not captured or voided in time.
+type HoldExpiry interface {
+	// Run releases all authorized holds which have passed expiry time.
+	Run() error
+}
+
+// NewHoldExpiry creates new hold expiry.
+func NewHoldExpiry() HoldExpiry {
+	result := &holdExpiry{accountLambda: newAccountLambda()}
+	if err := result.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init hold expiry: "%v".`, err)
+	}
+	return result
+}
+
+type holdExpiry struct{ accountLambda }
+
+func (job *holdExpiry) Run() error {
+	db, err := job.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer db.Rollback()
+
+	count, err := db.ExpireHolds(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf(`failed to expire holds: "%v"`, err)
+	}
+	if err := db.Commit(); err != nil {
+		return err
+	}
+
+	elefant.Log.Info("Released %d expired holds.", count)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
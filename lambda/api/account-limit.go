@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountLimits struct {
+	PerTrans json.Number `json:"perTrans"`
+	Daily    json.Number `json:"daily"`
+	Monthly  json.Number `json:"monthly"`
+}
+
+type accountLimitsInfo struct {
+	PerTrans     elefant.Money `json:"perTrans"`
+	Daily        elefant.Money `json:"daily"`
+	Monthly      elefant.Money `json:"monthly"`
+	SpentDaily   elefant.Money `json:"spentDaily"`
+	SpentMonthly elefant.Money `json:"spentMonthly"`
+}
+
+type accountLimitLambda struct{ accountBalanceLambda }
+
+func newAccountLimitLambda() accountLimitLambda {
+	return accountLimitLambda{accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+// respondLimits returns the account limits and the spending.
+func (lambda *accountLimitLambda) respondLimits(
+	acc elefant.Account,
+	limits elefant.SpendingLimits,
+	db elefant.DBTrans) (*httpResponse, error) {
+	spending, err := db.GetAccountSpending(acc, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" spending: "%v"`,
+			acc.GetID(), err)
+	}
+	return newHTTPResponse(http.StatusOK, &accountLimitsInfo{
+		PerTrans:     limits.PerTrans,
+		Daily:        limits.Daily,
+		Monthly:      limits.Monthly,
+		SpentDaily:   spending.Daily,
+		SpentMonthly: spending.Monthly})
+}
+
+func (lambda *accountLimitLambda) findAccount(
+	request LambdaRequest,
+	db elefant.DBTrans) (elefant.Account, *httpResponse, error) {
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		response, err := newHTTPResponseBadParam(
+			"account ID has invalid format", "%v", err)
+		return nil, response, err
+	}
+	return lambda.findClientAccount(accID, request.GetClientID(), db)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountLimitInfoLambda struct{ accountLimitLambda }
+
+func (*lambdaFactory) NewAccountLimitInfoLambda() lambdaImpl {
+	return &accountLimitInfoLambda{accountLimitLambda: newAccountLimitLambda()}
+}
+
+func (*accountLimitInfoLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountLimitInfoLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findAccount(request, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	limits, response, err := lambda.getAccountLimits(acc, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	return lambda.respondLimits(acc, limits, db)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountLimitUpdateLambda struct{ accountLimitLambda }
+
+func (*lambdaFactory) NewAccountLimitUpdateLambda() lambdaImpl {
+	return &accountLimitUpdateLambda{accountLimitLambda: newAccountLimitLambda()}
+}
+
+func (*accountLimitUpdateLambda) CreateRequest() interface{} {
+	return &accountLimits{}
+}
+
+func (lambda *accountLimitUpdateLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	request := lambdaRequest.GetRequest().(*accountLimits)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findAccount(lambdaRequest, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	var limits elefant.SpendingLimits
+	for _, field := range []struct {
+		source json.Number
+		result *elefant.Money
+	}{
+		{source: request.PerTrans, result: &limits.PerTrans},
+		{source: request.Daily, result: &limits.Daily},
+		{source: request.Monthly, result: &limits.Monthly}} {
+		value, response, err := parseMoneyValue(field.source, acc.GetCurrency())
+		if response != nil || err != nil {
+			return response, err
+		}
+		*field.result = *value
+	}
+	if err := limits.Validate(); err != nil {
+		return newHTTPResponseBadParam(err.Error(),
+			`failed to validate account "%s" limits: "%v"`, acc.GetID(), err)
+	}
+
+	current, response, err := lambda.getAccountLimits(acc, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	if !current.IsRaisedBy(limits) {
+		if err := db.SetAccountLimits(acc.GetID(), limits); err != nil {
+			return nil, fmt.Errorf(`failed to set account "%s" limits: "%v"`,
+				acc.GetID(), err)
+		}
+		if response, err = lambda.respondLimits(acc, limits, db); err != nil {
+			return nil, err
+		}
+		if err := db.Commit(); err != nil {
+			return nil, err
+		}
+		elefant.Log.Info(`Account "%s" limits are lowered.`, acc.GetID())
+		return response, nil
+	}
+
+	// Raised limits are applied only after confirmation by the code sent to
+	// the client email.
+	client, err := db.GetClient(acc.GetClientID())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get client "%s": "%v"`,
+			acc.GetClientID(), err)
+	}
+	confirmationID, twoFaCode, err := db.CreateClientConfirmation(
+		client.GetID(), gen2faCode)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to create confirmation: "%v"`, err)
+	}
+	err = db.CreateAccountLimitsRequest(confirmationID, acc.GetID(), limits)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to create account "%s" limits request: "%v"`, acc.GetID(), err)
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	err = sendTextEmail(client, "Account limits confirmation",
+		fmt.Sprintf(
+			"Use code %s to confirm new %s account limits: %s per transaction, "+
+				"%s daily and %s monthly.\n\nThe code is valid for %s.",
+			twoFaCode, acc.GetCurrency().GetISO(),
+			limits.PerTrans, limits.Daily, limits.Monthly,
+			elefant.ClientConfirmationCodeLiveTime))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to send confirmation code: "%v"`, err)
+	}
+	elefant.Log.Info(`Sent code for account "%s" limits confirmation "%s".`,
+		acc.GetID(), confirmationID)
+
+	return newHTTPResponse(http.StatusAccepted,
+		newClientConfirmRequest(confirmationID))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountLimitConfirmLambda struct{ accountLimitLambda }
+
+func (*lambdaFactory) NewAccountLimitConfirmLambda() lambdaImpl {
+	return &accountLimitConfirmLambda{accountLimitLambda: newAccountLimitLambda()}
+}
+
+func (*accountLimitConfirmLambda) CreateRequest() interface{} {
+	return &clientConfirmation{}
+}
+
+func (lambda *accountLimitConfirmLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	request := lambdaRequest.GetRequest().(*clientConfirmation)
+	confirmID, err := elefant.ParseConfirmationID(request.ID)
+	if err != nil {
+		return newHTTPResponseBadParam("confirmation ID is invalid",
+			`failed to parse confirmation ID "%s": "%v"`, request.ID, err)
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findAccount(lambdaRequest, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	limits, err := db.LockAccountLimitsRequest(confirmID, acc)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to find account "%s" limits request "%s": "%v"`,
+			acc.GetID(), confirmID, err)
+	}
+	if limits == nil {
+		return newHTTPResponseEmptyError(http.StatusNotFound,
+			`account "%s" does not have limits request "%s"`,
+			acc.GetID(), confirmID)
+	}
+
+	// The limits request is deleted with the accepted confirmation.
+	clientID, err := db.AcceptClientConfirmation(confirmID, request.Token)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to accept client confirmation "%s": "%v"`,
+			confirmID, err)
+	}
+	if clientID == nil || *clientID != lambdaRequest.GetClientID() {
+		// Has to be committed to complete the process even if no client found.
+		if err := db.Commit(); err != nil {
+			return nil, err
+		}
+		return newHTTPResponseEmptyError(http.StatusNotFound,
+			`wrong token "%s" provided for confirmation "%s"`,
+			request.Token, request.ID)
+	}
+
+	if err := db.SetAccountLimits(acc.GetID(), *limits); err != nil {
+		return nil, fmt.Errorf(`failed to set account "%s" limits: "%v"`,
+			acc.GetID(), err)
+	}
+	if response, err = lambda.respondLimits(acc, *limits, db); err != nil {
+		return nil, err
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(`Account "%s" limits are raised by confirmation "%s".`,
+		acc.GetID(), confirmID)
+	return response, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
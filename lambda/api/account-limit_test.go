@@ -0,0 +1,124 @@
+package api
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// limitTestDB stores account limits as the DB does, other DB methods are not
+// implemented.
+type limitTestDB struct {
+	elefant.DBTrans
+	limits map[elefant.AccountID]elefant.SpendingLimits
+}
+
+func (db *limitTestDB) GetAccountLimits(
+	acc elefant.Account) (*elefant.SpendingLimits, elefant.ClientTier, error) {
+	result, has := db.limits[acc.GetID()]
+	if !has {
+		return nil, elefant.ClientTierStandard, nil
+	}
+	return &result, elefant.ClientTierStandard, nil
+}
+
+func (db *limitTestDB) SetAccountLimits(
+	acc elefant.AccountID, limits elefant.SpendingLimits) error {
+	db.limits[acc] = limits
+	return nil
+}
+
+// limitTestRates returns the fixed rate, or the stale error if the rate is
+// not set.
+type limitTestRates struct{ rate *big.Rat }
+
+func (rates *limitTestRates) GetRate(
+	from, to elefant.Currency) (*elefant.ExchangeRate, error) {
+	if rates.rate == nil {
+		return nil, &elefant.ExchangeRateStaleError{
+			Time: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+	}
+	return &elefant.ExchangeRate{From: from, To: to, Rate: rates.rate}, nil
+}
+
+// limitTestAccount has only ID and currency, other account methods are not
+// implemented.
+type limitTestAccount struct {
+	elefant.Account
+	id       elefant.AccountID
+	currency elefant.Currency
+}
+
+func (acc *limitTestAccount) GetID() elefant.AccountID { return acc.id }
+func (acc *limitTestAccount) GetCurrency() elefant.Currency {
+	return acc.currency
+}
+
+func newLimitTestAccount(t *testing.T, iso string) elefant.Account {
+	currency, err := elefant.NewCurrency(iso)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &limitTestAccount{id: uuid.New(), currency: currency}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAccountTierLimitsStaleRates(t *testing.T) {
+	setTestLog(t)
+	db := &limitTestDB{limits: map[elefant.AccountID]elefant.SpendingLimits{}}
+	rates := &limitTestRates{}
+	lambda := &accountBalanceLambda{rates: rates}
+	acc := newLimitTestAccount(t, "USD")
+
+	// The account is created without limits, payments are responded with 503
+	// until the rates are received.
+	if err := setAccountTierLimits(acc, rates, db); err != nil {
+		t.Fatalf(`Failed to set account tier limits: "%v".`, err)
+	}
+	if len(db.limits) != 0 {
+		t.Fatalf("Limits are set by stale rates.")
+	}
+	_, response, err := lambda.checkSpendingLimits(
+		acc, elefant.NewMoney(100, acc.GetCurrency()), db)
+	if err != nil {
+		t.Fatalf(`Failed to check limits: "%v".`, err)
+	}
+	if response == nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Limits by stale rates are responded with %v.", response)
+	}
+
+	// The created account has limits converted by the actual rate, which are
+	// not converted again.
+	rates.rate = big.NewRat(3, 2)
+	if err := setAccountTierLimits(acc, rates, db); err != nil {
+		t.Fatalf(`Failed to set account tier limits: "%v".`, err)
+	}
+	rates.rate = nil
+	limits, response, err := lambda.getAccountLimits(acc, db)
+	if response != nil || err != nil {
+		t.Fatalf(`Failed to get limits: %v "%v".`, response, err)
+	}
+	for _, test := range []struct {
+		name     string
+		value    elefant.Money
+		expected string
+	}{
+		{"per transaction", limits.PerTrans, "1500.00"},
+		{"daily", limits.Daily, "3000.00"},
+		{"monthly", limits.Monthly, "15000.00"},
+	} {
+		if test.value.String() != test.expected {
+			t.Errorf(`%s limit is "%s", but expected "%s".`,
+				test.name, test.value, test.expected)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,342 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountPaymentBatchOrder struct {
+	// Atomic executes all items in one DB transaction, so no item is executed
+	// if any item is failed. Otherwise each item is executed separately and
+	// the result is returned for each item.
+	Atomic bool                       `json:"atomic"`
+	Items  []*accountPaymentBatchItem `json:"items"`
+}
+
+// accountPaymentBatchItem has to have only one payment.
+type accountPaymentBatchItem struct {
+	Account *accountPaymentAccountOrder `json:"account,omitempty"`
+	Tax     *accountPaymentTaxOrder     `json:"tax,omitempty"`
+}
+
+type accountPaymentBatchResult struct {
+	Items []*accountPaymentBatchItemResult `json:"items"`
+}
+
+type accountPaymentBatchItemResult struct {
+	// Status is the HTTP status code with which the item is executed by
+	// the single payment.
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newAccountPaymentBatchItemResult(
+	response *httpResponse) *accountPaymentBatchItemResult {
+	return &accountPaymentBatchItemResult{
+		Status: response.StatusCode,
+		Error:  readHTTPResponseError(response)}
+}
+
+type accountPaymentBatchLambda struct {
+	accountPaymentToAccountLambda
+	taxPayment accountPaymentTaxLambda
+}
+
+func (*lambdaFactory) NewAccountPaymentBatchLambda() lambdaImpl {
+	return &accountPaymentBatchLambda{
+		accountPaymentToAccountLambda: accountPaymentToAccountLambda{
+			accountExchangeLambda: newAccountExchangeLambda()}}
+}
+
+func (lambda *accountPaymentBatchLambda) Init() error {
+	if err := lambda.accountPaymentToAccountLambda.Init(); err != nil {
+		return err
+	}
+	// The tax payment uses the same DB connection.
+	lambda.taxPayment = accountPaymentTaxLambda{
+		accountBalanceLambda: lambda.accountBalanceLambda}
+	return nil
+}
+
+func (*accountPaymentBatchLambda) CreateRequest() interface{} {
+	return &accountPaymentBatchOrder{}
+}
+
+func (*accountPaymentBatchLambda) IsIdempotent() bool { return true }
+
+func (lambda *accountPaymentBatchLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+
+	request := lambdaRequest.GetRequest().(*accountPaymentBatchOrder)
+	if len(request.Items) == 0 {
+		return newHTTPResponseBadParam("batch has no payments",
+			`batch for account "%s" has no items`, accID)
+	}
+	if len(request.Items) > paymentBatchMaxItems {
+		return newHTTPResponseBadParam(
+			fmt.Sprintf("batch could not have more than %d payments",
+				paymentBatchMaxItems),
+			`batch for account "%s" has %d items`, accID, len(request.Items))
+	}
+
+	if request.Atomic {
+		return lambda.runAtomic(accID, request.Items, lambdaRequest)
+	}
+	return lambda.runEach(accID, request.Items, lambdaRequest)
+}
+
+// runAtomic executes all items in one DB transaction and commits it only if
+// all items are executed.
+func (lambda *accountPaymentBatchLambda) runAtomic(
+	accID elefant.AccountID,
+	items []*accountPaymentBatchItem,
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	trans := []*elefant.Trans{}
+	result := &accountPaymentBatchResult{
+		Items: make([]*accountPaymentBatchItemResult, len(items))}
+	for i, item := range items {
+		// Without the commit hook nothing is committed by the item, even
+		// the failed transaction.
+		itemTrans, response, err := lambda.execute(
+			accID, lambdaRequest.GetClientID(), item, nil, db)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to execute batch item %d: "%v"`, i+1, err)
+		}
+		if response != nil {
+			message := readHTTPResponseError(response)
+			if message == "" {
+				message = http.StatusText(response.StatusCode)
+			}
+			elefant.Log.Warn(
+				`Batch for account "%s" is rejected by item %d with status %d.`,
+				accID, i+1, response.StatusCode)
+			return newHTTPResponse(response.StatusCode, &errorResponse{
+				Message: fmt.Sprintf("Item %d: %s", i+1,
+					elefant.CapitalizeString(message))})
+		}
+		trans = append(trans, itemTrans...)
+		result.Items[i] = &accountPaymentBatchItemResult{
+			Status: http.StatusAccepted}
+	}
+
+	response, err := newHTTPResponse(http.StatusAccepted, result)
+	if err != nil {
+		return nil, err
+	}
+	if conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	for _, trans := range trans {
+		elefant.Log.Info(fmtTransLog(trans))
+	}
+	elefant.Log.Info(`Executed batch of %d payments from account "%s".`,
+		len(items), accID)
+	return response, nil
+}
+
+// runEach executes each item in its own DB transaction and returns the result
+// for each item. The idempotency key is reserved before the first item, and
+// the result is stored with each executed item, so the batch is not executed
+// again even if it's interrupted.
+func (lambda *accountPaymentBatchLambda) runEach(
+	accID elefant.AccountID,
+	items []*accountPaymentBatchItem,
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	result := &accountPaymentBatchResult{
+		Items: make([]*accountPaymentBatchItemResult, 0, len(items))}
+	if response, err := lambda.reserveIdempotencyKey(
+		result, lambdaRequest); response != nil || err != nil {
+		return response, err
+	}
+
+	executed := 0
+	for i, item := range items {
+		// The item result is stored in the same DB transaction as the payment.
+		storeResult := func(
+			response *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+			pending, err := newHTTPResponse(http.StatusOK,
+				&accountPaymentBatchResult{
+					Items: append(result.Items,
+						newAccountPaymentBatchItemResult(response))})
+			if err != nil {
+				return nil, err
+			}
+			return lambdaRequest.StorePendingIdempotentResponse(pending, db)
+		}
+		var itemResult *accountPaymentBatchItemResult
+		response, err := lambda.executeEach(
+			accID, lambdaRequest.GetClientID(), item, storeResult)
+		if err != nil {
+			// The item is not executed, other items are executed anyway.
+			elefant.Log.Error(`Failed to execute batch item %d: "%v".`, i+1, err)
+			itemResult = &accountPaymentBatchItemResult{
+				Status: http.StatusInternalServerError,
+				Error:  "Payment is not executed by internal error."}
+		} else {
+			itemResult = newAccountPaymentBatchItemResult(response)
+		}
+		result.Items = append(result.Items, itemResult)
+		if itemResult.Status == http.StatusAccepted {
+			executed++
+		}
+	}
+
+	response, err := newHTTPResponse(http.StatusOK, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := lambda.storeIdempotentResponse(
+		response, lambdaRequest); err != nil {
+		// Payments are already executed, so the result is returned anyway.
+		elefant.Log.Error(`Failed to store batch result: "%v".`, err)
+	}
+
+	elefant.Log.Info(`Executed %d of %d batch payments from account "%s".`,
+		executed, len(items), accID)
+	return response, nil
+}
+
+// reserveIdempotencyKey returns the stored response if the key is already
+// used, otherwise stores the pending empty result in its own DB transaction.
+func (lambda *accountPaymentBatchLambda) reserveIdempotencyKey(
+	result *accountPaymentBatchResult,
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+	pending, err := newHTTPResponse(http.StatusOK, result)
+	if err != nil {
+		return nil, err
+	}
+	if response, err := lambdaRequest.ReserveIdempotencyKey(
+		pending, db); response != nil || err != nil {
+		return response, err
+	}
+	return nil, db.Commit()
+}
+
+// storeIdempotentResponse replaces the pending result by the batch result.
+func (lambda *accountPaymentBatchLambda) storeIdempotentResponse(
+	response *httpResponse,
+	lambdaRequest LambdaRequest) error {
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer db.Rollback()
+
+	conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db)
+	if err != nil {
+		return err
+	}
+	if conflict != nil {
+		return errors.New("pending result is already replaced")
+	}
+	return nil
+}
+
+// executeEach executes the item in its own DB transaction, like the single
+// payment. Returns the item response.
+func (lambda *accountPaymentBatchLambda) executeEach(
+	accID elefant.AccountID,
+	clientID elefant.ClientID,
+	item *accountPaymentBatchItem,
+	beforeCommit commitHook) (*httpResponse, error) {
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	_, response, err := lambda.execute(accID, clientID, item, beforeCommit, db)
+	return response, err
+}
+
+// execute validates the item as the single payment and executes it. If
+// beforeCommit is nil - the item is executed in the DB transaction without
+// the commit and the transactions are returned, otherwise the item is
+// committed and only the response is returned.
+func (lambda *accountPaymentBatchLambda) execute(
+	accID elefant.AccountID,
+	clientID elefant.ClientID,
+	item *accountPaymentBatchItem,
+	beforeCommit commitHook,
+	db elefant.DBTrans) ([]*elefant.Trans, *httpResponse, error) {
+
+	switch {
+	case item.Account != nil && item.Tax == nil:
+		accFrom, accTo, value, response, err := lambda.readAccountPaymentOrder(
+			accID, clientID, item.Account, db)
+		if response != nil || err != nil {
+			return nil, response, err
+		}
+		if beforeCommit != nil {
+			response, err := lambda.pay(accFrom, accTo, *value, item.Account.Quote,
+				beforeCommit, db)
+			return nil, response, err
+		}
+		return lambda.transfer(accFrom, accTo, *value, item.Account.Quote, nil, db)
+
+	case item.Tax != nil && item.Account == nil:
+		acc, value, bill, response, err :=
+			lambda.taxPayment.readAccountPaymentTaxOrder(
+				accID, clientID, item.Tax, db)
+		if response != nil || err != nil {
+			return nil, response, err
+		}
+		if beforeCommit != nil {
+			response, err := lambda.taxPayment.pay(acc, *value, bill,
+				beforeCommit, db)
+			return nil, response, err
+		}
+		trans, response, err := lambda.taxPayment.transfer(
+			acc, *value, bill, nil, db)
+		if response != nil || err != nil {
+			return nil, response, err
+		}
+		return []*elefant.Trans{trans}, nil, nil
+
+	default:
+		response, err := newHTTPResponseBadParam(
+			"batch item has to have one payment to account or tax payment",
+			`batch item for account "%s" has no or several payments`, accID)
+		return nil, response, err
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
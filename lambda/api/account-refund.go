@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountTransRefundOrder struct {
+	// Value is an optional refund value, if it's not set - the whole not
+	// refunded yet transaction value is refunded.
+	Value json.Number `json:"value,omitempty"`
+}
+
+type accountTransRefundLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewAccountTransRefundLambda() lambdaImpl {
+	return &accountTransRefundLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*accountTransRefundLambda) CreateRequest() interface{} {
+	return &accountTransRefundOrder{}
+}
+
+func (*accountTransRefundLambda) IsIdempotent() bool { return true }
+
+func (lambda *accountTransRefundLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	transID, err := lambdaRequest.ReadPathArgTransID()
+	if err != nil {
+		return newHTTPResponseBadParam(
+			"transaction ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	request := lambdaRequest.GetRequest().(*accountTransRefundOrder)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	trans, counterTrans, response, err := lambda.readRefundTrans(
+		transID, accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	value, counterValue, isFull, response, err := lambda.readRefundValue(
+		request, trans, counterTrans, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	accFrom := trans.Account
+	accTo := counterTrans.Account
+	clientFrom, err := db.GetClient(accFrom.GetClientID())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get refund sender client "%s": "%v"`,
+			accFrom.GetClientID(), err)
+	}
+	clientTo, err := db.GetClient(accTo.GetClientID())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get refund receiver client "%s": "%v"`,
+			accTo.GetClientID(), err)
+	}
+
+	var exchangeFrom *elefant.TransExchange
+	var exchangeTo *elefant.TransExchange
+	journal := elefant.NewJournal()
+	journal.AddAccountPosting(accFrom.GetID(), value.Neg())
+	if trans.Exchange != nil {
+		// The refund is converted back by the rate of the original payment.
+		rate := new(big.Rat).Inv(trans.Exchange.Rate)
+		exchangeFrom = elefant.NewTransExchange(rate, counterValue.Neg())
+		exchangeTo = elefant.NewTransExchange(rate, value)
+		journal.AddSystemPosting(elefant.SystemAccountExchange, value)
+		journal.AddSystemPosting(elefant.SystemAccountExchange, counterValue.Neg())
+	}
+	journal.AddAccountPosting(accTo.GetID(), counterValue)
+	accounts, response, err := lambda.postJournal(journal, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	accFrom = accounts[accFrom.GetID()]
+	accTo = accounts[accTo.GetID()]
+
+	var refundFrom *elefant.Trans
+	// Refund returns received money, so it's not limited as spending and
+	// it's not charged. The failed refund is linked with the payment as
+	// the successful one, so the payment shows the refund attempt.
+	storeFailed := func(
+		response *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+		if err := db.SetTransRefundOf(refundFrom.ID, trans.ID); err != nil {
+			return nil, fmt.Errorf(
+				`failed to link failed refund "%s" with transaction "%s": "%v"`,
+				refundFrom.ID, trans.ID, err)
+		}
+		return lambdaRequest.StoreIdempotentResponse(response, db)
+	}
+	response, err = lambda.withdraw(accFrom, value, exchangeFrom, journal.ID,
+		false, func(acc elefant.Account, db elefant.DBTrans) (elefant.Method, error) {
+			return db.GetAccountMethod(acc, accTo.GetID(), clientTo.GetEmail())
+		}, storeFailed, db, &refundFrom)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	var refundTo *elefant.Trans
+	response, err = lambda.deposit(accTo, counterValue, exchangeTo, journal.ID,
+		false, func() (elefant.Method, error) {
+			return db.GetAccountMethod(accTo, accFrom.GetID(), clientFrom.GetEmail())
+		}, db, &refundTo)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	for _, link := range []struct{ refund, original *elefant.Trans }{
+		{refund: refundFrom, original: trans},
+		{refund: refundTo, original: counterTrans}} {
+		refund, original := link.refund, link.original
+		if err := db.SetTransRefundOf(refund.ID, original.ID); err != nil {
+			return nil, fmt.Errorf(
+				`failed to link refund "%s" with transaction "%s": "%v"`,
+				refund.ID, original.ID, err)
+		}
+		if !isFull {
+			continue
+		}
+		err := db.UpdateTransStatus(original.ID, elefant.TransStatusRefunded, nil)
+		if err != nil {
+			return nil, fmt.Errorf(
+				`failed to update transaction "%s" status: "%v"`, original.ID, err)
+		}
+	}
+
+	if response, err = newHTTPResponseEmpty(http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	if conflict, err := lambda.commit(lambdaRequest.StoreIdempotentResponse,
+		response, db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	elefant.Log.Info(fmtTransLog(refundFrom))
+	elefant.Log.Info(fmtTransLog(refundTo))
+	return response, nil
+}
+
+// readRefundTrans returns the received payment to refund and the payment
+// transaction on the payment sender account.
+func (lambda *accountTransRefundLambda) readRefundTrans(
+	id elefant.TransID,
+	acc elefant.AccountID,
+	client elefant.ClientID,
+	db elefant.DBTrans) (*elefant.Trans, *elefant.Trans, *httpResponse, error) {
+
+	trans, err := db.FindClientTrans(id, acc, client)
+	if err != nil {
+		return nil, nil, nil,
+			fmt.Errorf(`failed to find transaction "%s": "%v"`, id, err)
+	}
+	if trans == nil {
+		response, err := newHTTPResponseEmptyError(http.StatusNotFound,
+			`client "%s" does not have transaction "%s" on account "%s"`,
+			client, id, acc)
+		return nil, nil, response, err
+	}
+
+	method, isAccount := trans.Method.(elefant.AccountMethod)
+	if !isAccount || !trans.Value.IsPositive() || trans.RefundOf != nil {
+		response, err := newHTTPResponseBadParam(
+			"only payment received from another account could be refunded",
+			`transaction "%s" is not payment received from another account`, id)
+		return nil, nil, response, err
+	}
+	if trans.Status != elefant.TransStatusSuccess {
+		response, err := newHTTPResponseEmptyError(http.StatusConflict,
+			`transaction "%s" with status "%s" could not be refunded`,
+			id, trans.Status)
+		return nil, nil, response, err
+	}
+
+	// The transaction is locked to not refund it concurrently twice.
+	if err := db.LockTrans(trans.ID); err != nil {
+		return nil, nil, nil,
+			fmt.Errorf(`failed to lock transaction "%s": "%v"`, id, err)
+	}
+
+	counterTrans, err := db.FindJournalCounterTrans(trans)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf(
+			`failed to find counterpart for transaction "%s": "%v"`, id, err)
+	}
+	if counterTrans == nil ||
+		counterTrans.Account.GetID() != method.GetAccountID() {
+		return nil, nil, nil,
+			fmt.Errorf(`transaction "%s" does not have counterpart`, id)
+	}
+
+	return trans, counterTrans, nil, nil
+}
+
+// readRefundValue returns the refund value in the received payment currency
+// and in the payment sender currency, and the flag is the payment refunded
+// completely.
+func (lambda *accountTransRefundLambda) readRefundValue(
+	request *accountTransRefundOrder,
+	trans *elefant.Trans,
+	counterTrans *elefant.Trans,
+	db elefant.DBTrans) (
+	elefant.Money, elefant.Money, bool, *httpResponse, error) {
+
+	var value elefant.Money
+
+	refunded, err := db.GetTransRefunded(trans)
+	if err != nil {
+		return value, value, false, nil, fmt.Errorf(
+			`failed to get refunded value of transaction "%s": "%v"`, trans.ID, err)
+	}
+	remaining := trans.Value.Sub(refunded)
+	if !remaining.IsPositive() {
+		response, err := newHTTPResponseEmptyError(http.StatusConflict,
+			`transaction "%s" is already refunded`, trans.ID)
+		return value, value, false, response, err
+	}
+
+	value = remaining
+	if request.Value != "" {
+		requested, response, err := parseMoneyValue(
+			request.Value, trans.Value.GetCurrency())
+		if response != nil || err != nil {
+			return value, value, false, response, err
+		}
+		if requested.Cmp(remaining) > 0 {
+			response, err := newHTTPResponseBadParam(
+				"value exceeds not refunded transaction value",
+				`refund value %s exceeds not refunded value %s of transaction "%s"`,
+				requested, remaining, trans.ID)
+			return value, value, false, response, err
+		}
+		value = *requested
+	}
+	isFull := value.Cmp(remaining) == 0
+
+	if trans.Exchange == nil {
+		return value, value, isFull, nil, nil
+	}
+
+	counterRefunded, err := db.GetTransRefunded(counterTrans)
+	if err != nil {
+		return value, value, false, nil, fmt.Errorf(
+			`failed to get refunded value of transaction "%s": "%v"`,
+			counterTrans.ID, err)
+	}
+	counterRemaining := counterTrans.Value.Sub(counterRefunded).Neg()
+	if isFull {
+		return value, counterRemaining, isFull, nil, nil
+	}
+	// Partial refund is converted proportionally to the payment, but the sum
+	// of all refunds never exceeds the payment.
+	counterValue := counterTrans.Value.Neg().MulRat(
+		big.NewRat(value.GetUnits(), trans.Value.GetUnits()),
+		elefant.RoundHalfEven)
+	if counterValue.Cmp(counterRemaining) > 0 {
+		counterValue = counterRemaining
+	}
+	return value, counterValue, isFull, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
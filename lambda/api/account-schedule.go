@@ -0,0 +1,345 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountScheduleOrder struct {
+	Target string `json:"target"`
+	// Account is the receiver account ID for the payment to another account.
+	Account string `json:"account,omitempty"`
+	// Authority and Bill are the tax authority ID and the bill for the tax
+	// payment.
+	Authority string      `json:"authority,omitempty"`
+	Bill      string      `json:"bill,omitempty"`
+	Value     json.Number `json:"value"`
+	// Time is set for one-off standing order.
+	Time *time.Time `json:"time,omitempty"`
+	// Recurrence is set for recurrent standing order.
+	Recurrence string `json:"recurrence,omitempty"`
+}
+
+type accountSchedule struct {
+	ID          string        `json:"id"`
+	Target      string        `json:"target"`
+	Account     string        `json:"account,omitempty"`
+	Authority   string        `json:"authority,omitempty"`
+	Bill        string        `json:"bill,omitempty"`
+	Value       elefant.Money `json:"value"`
+	Recurrence  string        `json:"recurrence,omitempty"`
+	NextTime    *time.Time    `json:"nextTime,omitempty"`
+	LastRunTime *time.Time    `json:"lastRunTime,omitempty"`
+}
+
+func newAccountSchedule(schedule *elefant.Schedule) *accountSchedule {
+	result := &accountSchedule{
+		ID:          schedule.ID.String(),
+		Target:      schedule.Target.String(),
+		Bill:        schedule.Bill,
+		Value:       schedule.Value,
+		NextTime:    schedule.NextTime,
+		LastRunTime: schedule.LastRunTime}
+	if schedule.Receiver != nil {
+		result.Account = schedule.Receiver.String()
+	}
+	if schedule.TaxAuthority != nil {
+		result.Authority = schedule.TaxAuthority.String()
+	}
+	if schedule.Recurrence != nil {
+		result.Recurrence = schedule.Recurrence.String()
+	}
+	return result
+}
+
+type accountScheduleLambda struct{ accountBalanceLambda }
+
+func newAccountScheduleLambda() accountScheduleLambda {
+	return accountScheduleLambda{accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+// setSchedule validates standing order request and applies it to
+// the standing order.
+func (lambda *accountScheduleLambda) setSchedule(
+	schedule *elefant.Schedule,
+	acc elefant.Account,
+	request *accountScheduleOrder,
+	db elefant.DBTrans) (*httpResponse, error) {
+
+	target, err := elefant.ParseScheduleTarget(request.Target)
+	if err != nil {
+		return newHTTPResponseBadParam(err.Error(), "%v", err)
+	}
+
+	var receiver *elefant.AccountID
+	if request.Account != "" {
+		receiverID, err := elefant.ParseAccountID(request.Account)
+		if err != nil {
+			return newHTTPResponseBadParam("invalid receiver account ID",
+				`failed to parse receiver account ID "%s": "%v"`,
+				request.Account, err)
+		}
+		receiverAcc, err := db.FindAccount(receiverID)
+		if err != nil {
+			return nil,
+				fmt.Errorf(`failed to find account "%s": "%v"`, receiverID, err)
+		}
+		if receiverAcc == nil {
+			return newHTTPResponseEmptyError(http.StatusNotFound,
+				`receiver account ID "%s" is not existent`, receiverID)
+		}
+		receiver = &receiverID
+	}
+
+	value, response, err := parseMoneyValue(request.Value, acc.GetCurrency())
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	var bill *elefant.TaxBill
+	if request.Authority != "" || request.Bill != "" {
+		authorityID, response, err := parseTaxAuthorityID(request.Authority)
+		if response != nil || err != nil {
+			return response, err
+		}
+		bill, response, err = readTaxBill(
+			*authorityID, request.Bill, acc.GetCurrency(), db)
+		if response != nil || err != nil {
+			return response, err
+		}
+	}
+
+	var recurrence *elefant.ScheduleRecurrence
+	if request.Recurrence != "" {
+		recurrence, err = elefant.ParseScheduleRecurrence(request.Recurrence)
+		if err != nil {
+			return newHTTPResponseBadParam(err.Error(), "%v", err)
+		}
+	}
+
+	err = schedule.Set(
+		target, receiver, bill, *value, request.Time, recurrence)
+	if err != nil {
+		return newHTTPResponseBadParam(err.Error(),
+			`failed to set standing order "%s": "%v"`, schedule.ID, err)
+	}
+
+	return nil, nil
+}
+
+func (lambda *accountScheduleLambda) readScheduleArgs(
+	request LambdaRequest,
+	withSchedule bool) (
+	elefant.AccountID, elefant.ScheduleID, *httpResponse, error) {
+	var scheduleID elefant.ScheduleID
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		response, err := newHTTPResponseBadParam(
+			"account ID has invalid format", "%v", err)
+		return accID, scheduleID, response, err
+	}
+	if withSchedule {
+		if scheduleID, err = request.ReadPathArgScheduleID(); err != nil {
+			response, err := newHTTPResponseBadParam(
+				"standing order ID has invalid format", "%v", err)
+			return accID, scheduleID, response, err
+		}
+	}
+	return accID, scheduleID, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountScheduleListLambda struct{ accountScheduleLambda }
+
+func (*lambdaFactory) NewAccountScheduleListLambda() lambdaImpl {
+	return &accountScheduleListLambda{
+		accountScheduleLambda: newAccountScheduleLambda()}
+}
+
+func (*accountScheduleListLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountScheduleListLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, _, response, err := lambda.readScheduleArgs(request, false)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	schedules, err := db.GetAccountSchedules(accID, request.GetClientID())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*accountSchedule, len(schedules))
+	for i, schedule := range schedules {
+		result[i] = newAccountSchedule(schedule)
+	}
+	return newHTTPResponse(http.StatusOK, result)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountScheduleCreateLambda struct{ accountScheduleLambda }
+
+func (*lambdaFactory) NewAccountScheduleCreateLambda() lambdaImpl {
+	return &accountScheduleCreateLambda{
+		accountScheduleLambda: newAccountScheduleLambda()}
+}
+
+func (*accountScheduleCreateLambda) CreateRequest() interface{} {
+	return &accountScheduleOrder{}
+}
+
+func (lambda *accountScheduleCreateLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, _, response, err := lambda.readScheduleArgs(lambdaRequest, false)
+	if response != nil || err != nil {
+		return response, err
+	}
+	clientID := lambdaRequest.GetClientID()
+	request := lambdaRequest.GetRequest().(*accountScheduleOrder)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	schedule := elefant.NewSchedule(clientID, accID)
+	if response, err = lambda.setSchedule(
+		schedule, acc, request, db); response != nil || err != nil {
+		return response, err
+	}
+	if err := db.CreateSchedule(schedule); err != nil {
+		return nil, fmt.Errorf(`failed to create standing order: "%v"`, err)
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	return newHTTPResponse(http.StatusCreated, newAccountSchedule(schedule))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountScheduleUpdateLambda struct{ accountScheduleLambda }
+
+func (*lambdaFactory) NewAccountScheduleUpdateLambda() lambdaImpl {
+	return &accountScheduleUpdateLambda{
+		accountScheduleLambda: newAccountScheduleLambda()}
+}
+
+func (*accountScheduleUpdateLambda) CreateRequest() interface{} {
+	return &accountScheduleOrder{}
+}
+
+func (lambda *accountScheduleUpdateLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, scheduleID, response, err := lambda.readScheduleArgs(
+		lambdaRequest, true)
+	if response != nil || err != nil {
+		return response, err
+	}
+	clientID := lambdaRequest.GetClientID()
+	request := lambdaRequest.GetRequest().(*accountScheduleOrder)
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	schedule, err := db.FindClientSchedule(scheduleID, accID, clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find standing order "%s": "%v"`,
+			scheduleID, err)
+	}
+	if schedule == nil {
+		return newHTTPResponseEmptyError(http.StatusNotFound,
+			`client "%s" does not have standing order "%s" for account "%s"`,
+			clientID, scheduleID, accID)
+	}
+
+	if response, err = lambda.setSchedule(
+		schedule, acc, request, db); response != nil || err != nil {
+		return response, err
+	}
+	if _, err := db.UpdateSchedule(schedule); err != nil {
+		return nil, fmt.Errorf(`failed to update standing order "%s": "%v"`,
+			scheduleID, err)
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	return newHTTPResponse(http.StatusOK, newAccountSchedule(schedule))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountScheduleDeleteLambda struct{ accountScheduleLambda }
+
+func (*lambdaFactory) NewAccountScheduleDeleteLambda() lambdaImpl {
+	return &accountScheduleDeleteLambda{
+		accountScheduleLambda: newAccountScheduleLambda()}
+}
+
+func (*accountScheduleDeleteLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountScheduleDeleteLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, scheduleID, response, err := lambda.readScheduleArgs(request, true)
+	if response != nil || err != nil {
+		return response, err
+	}
+	clientID := request.GetClientID()
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	isDeleted, err := db.DeleteSchedule(scheduleID, accID, clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to delete standing order "%s": "%v"`,
+			scheduleID, err)
+	}
+	if !isDeleted {
+		return newHTTPResponseEmptyError(http.StatusNotFound,
+			`client "%s" does not have standing order "%s" for account "%s"`,
+			clientID, scheduleID, accID)
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	return newHTTPResponseNoContent()
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountStatementFormat struct {
+	contentType string
+	marshal     func(*elefant.Statement) ([]byte, error)
+}
+
+// accountStatementFormats has supported statement formats by the names which
+// are used in the request query.
+var accountStatementFormats = map[string]accountStatementFormat{
+	"camt053": {
+		contentType: "application/xml",
+		marshal:     (*elefant.Statement).MarshalCamt053},
+}
+
+const accountStatementDefaultFormat = "camt053"
+
+func getAccountStatementFormatNames() string {
+	result := make([]string, 0, len(accountStatementFormats))
+	for name := range accountStatementFormats {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return strings.Join(result, ", ")
+}
+
+// readQueryPeriod reads required period from the query dates, the to-date is
+// included, so the returned period end is the next day start in the location.
+func readQueryPeriod(
+	request LambdaRequest,
+	location *time.Location,
+	maxDays int) (time.Time, time.Time, *httpResponse, error) {
+
+	from, response, err := readQueryDate(request, "from", location)
+	if response != nil || err != nil {
+		return time.Time{}, time.Time{}, response, err
+	}
+	to, response, err := readQueryDate(request, "to", location)
+	if response != nil || err != nil {
+		return time.Time{}, time.Time{}, response, err
+	}
+	if from == nil || to == nil {
+		response, err := newHTTPResponseBadParam("period is not provided",
+			"from-date or to-date is not provided")
+		return time.Time{}, time.Time{}, response, err
+	}
+	end := to.AddDate(0, 0, 1)
+	if !from.Before(end) || from.AddDate(0, 0, maxDays).Before(end) {
+		response, err := newHTTPResponseBadParam(
+			fmt.Sprintf("period has to be from 1 to %d days", maxDays),
+			`period from %s to %s is invalid`, from, to)
+		return time.Time{}, time.Time{}, response, err
+	}
+	return *from, end, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountStatementLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewAccountStatementLambda() lambdaImpl {
+	return &accountStatementLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*accountStatementLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountStatementLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := request.GetClientID()
+
+	formatName := accountStatementDefaultFormat
+	if source, err := request.ReadQueryArgString("format"); err == nil {
+		formatName = source
+	}
+	format, has := accountStatementFormats[formatName]
+	if !has {
+		return newHTTPResponseBadParam(
+			fmt.Sprintf("unknown format, supported: %s",
+				getAccountStatementFormatNames()),
+			`unknown statement format "%s"`, formatName)
+	}
+
+	from, to, response, err := readQueryPeriod(
+		request, time.UTC, accountStatementMaxDays)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	client, err := db.GetClient(clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get client "%s": "%v"`, clientID, err)
+	}
+
+	opening, err := db.GetAccountBalanceAt(acc, from)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" opening balance: "%v"`,
+			accID, err)
+	}
+	closing, err := db.GetAccountBalanceAt(acc, to)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" closing balance: "%v"`,
+			accID, err)
+	}
+	trans, err := db.GetAccountStatementTrans(accID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to get account "%s" statement transactions: "%v"`, accID, err)
+	}
+
+	statement := elefant.NewStatement(
+		acc, client, from, to, opening, closing, trans)
+	body, err := format.marshal(statement)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to export statement "%s" in %s: "%v"`,
+			statement.ID, formatName, err)
+	}
+
+	elefant.Log.Info(
+		`Exported statement "%s" in %s for account "%s" with %d actions.`,
+		statement.ID, formatName, accID, len(trans))
+	return newHTTPResponseWithBody(http.StatusOK, string(body),
+		map[string]string{"Content-Type": format.contentType})
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type taxAuthority struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	// BillFormat is the regular expression for the normalized bill, which is
+	// the bill without spaces in upper case.
+	BillFormat string `json:"billFormat"`
+	// CheckDigit is the bill check digit algorithm, it's not set if
+	// the authority bills don't have a check digit.
+	CheckDigit string `json:"checkDigit,omitempty"`
+}
+
+func newTaxAuthority(authority *elefant.TaxAuthority) *taxAuthority {
+	result := &taxAuthority{
+		ID:         authority.ID.String(),
+		Name:       authority.Name,
+		Currency:   authority.Currency.GetISO(),
+		BillFormat: authority.BillFormat.String()}
+	if authority.CheckDigit != nil {
+		result.CheckDigit = authority.CheckDigit.String()
+	}
+	return result
+}
+
+// taxPayee is the tax authority which already received payments from
+// the client, payees are sorted by the last payment.
+type taxPayee struct {
+	Authority     string `json:"authority"`
+	AuthorityName string `json:"authorityName"`
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountPaymentTaxAuthorityListLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewAccountPaymentTaxAuthorityListLambda() lambdaImpl {
+	return &accountPaymentTaxAuthorityListLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*accountPaymentTaxAuthorityListLambda) CreateRequest() interface{} {
+	return nil
+}
+
+func (lambda *accountPaymentTaxAuthorityListLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(
+		accID, request.GetClientID(), db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	authorities, err := db.GetTaxAuthorities(acc.GetCurrency())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get tax authorities for %s: "%v"`,
+			acc.GetCurrency().GetISO(), err)
+	}
+	result := make([]*taxAuthority, len(authorities))
+	for i, authority := range authorities {
+		result[i] = newTaxAuthority(authority)
+	}
+	return newHTTPResponse(http.StatusOK, result)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountPaymentTaxPayeeListLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewAccountPaymentTaxPayeeListLambda() lambdaImpl {
+	return &accountPaymentTaxPayeeListLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*accountPaymentTaxPayeeListLambda) CreateRequest() interface{} {
+	return nil
+}
+
+func (lambda *accountPaymentTaxPayeeListLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(
+		accID, request.GetClientID(), db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	payees, err := db.GetTaxPayees(acc)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" tax payees: "%v"`,
+			accID, err)
+	}
+	result := make([]*taxPayee, len(payees))
+	for i, payee := range payees {
+		result[i] = &taxPayee{
+			Authority:     payee.GetAuthority().String(),
+			AuthorityName: payee.GetAuthorityName()}
+	}
+	return newHTTPResponse(http.StatusOK, result)
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountTransDetails struct {
+	accountAction
+	Method  *accountTransMethod   `json:"method"`
+	History []*accountTransStatus `json:"history"`
+	// Linked are refunds and fees of the action on the same account.
+	Linked []*accountAction `json:"linked"`
+}
+
+// accountTransMethod has only masked method details, bank card number is
+// presented only by the name.
+type accountTransMethod struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Account and Email are set only for the payment between accounts and
+	// describe the counterpart.
+	Account string `json:"account,omitempty"`
+	Email   string `json:"email,omitempty"`
+	// Authority and Bill are set only for the tax payment, Authority is not
+	// set for the payment before the authority registry.
+	Authority string `json:"authority,omitempty"`
+	Bill      string `json:"bill,omitempty"`
+	// Hold is set only for the captured hold.
+	Hold string `json:"hold,omitempty"`
+}
+
+func newAccountTransMethod(method elefant.Method) *accountTransMethod {
+	result := &accountTransMethod{
+		Type: method.GetTypeName(),
+		Name: method.GetName()}
+	switch method := method.(type) {
+	case elefant.AccountMethod:
+		result.Account = method.GetAccountID().String()
+		result.Email = method.GetEmail()
+	case elefant.TaxMethod:
+		result.Bill = method.GetBill()
+		if authority := method.GetAuthority(); authority != nil {
+			result.Authority = authority.String()
+		}
+	case elefant.HoldMethod:
+		result.Hold = method.GetHoldID().String()
+	}
+	return result
+}
+
+type accountTransStatus struct {
+	State string    `json:"state"`
+	Notes string    `json:"notes,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+func newAccountTransStatuses(
+	history []*elefant.TransStatusChange) []*accountTransStatus {
+	result := make([]*accountTransStatus, len(history))
+	for i, change := range history {
+		result[i] = &accountTransStatus{
+			State: change.Status.String(),
+			Time:  change.Time}
+		if change.StatusReason != nil {
+			result[i].Notes = *change.StatusReason
+		}
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type accountTransLambda struct{ accountLambda }
+
+func (*lambdaFactory) NewAccountTransLambda() lambdaImpl {
+	return &accountTransLambda{accountLambda: newAccountLambda()}
+}
+
+func (*accountTransLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *accountTransLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	accID, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	transID, err := request.ReadPathArgTransID()
+	if err != nil {
+		return newHTTPResponseBadParam(
+			"transaction ID has invalid format", "%v", err)
+	}
+	clientID := request.GetClientID()
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	trans, err := db.FindClientTrans(transID, accID, clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find transaction "%s": "%v"`,
+			transID, err)
+	}
+	if trans == nil || trans.Account.GetClientID() != clientID {
+		return newHTTPResponseEmptyError(http.StatusNotFound,
+			`client "%s" does not have transaction "%s" on account "%s"`,
+			clientID, transID, accID)
+	}
+
+	history, err := db.GetTransStatusHistory(transID)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to get transaction "%s" status history: "%v"`, transID, err)
+	}
+	linked, err := db.GetLinkedTrans(trans)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to get transaction "%s" linked transactions: "%v"`,
+			transID, err)
+	}
+
+	return newHTTPResponse(http.StatusOK, &accountTransDetails{
+		accountAction: *newAccountAction(trans),
+		Method:        newAccountTransMethod(trans.Method),
+		History:       newAccountTransStatuses(history),
+		Linked:        newAccountActions(linked)})
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/palchukovsky/elefantpay-aws/elefant"
@@ -31,6 +35,7 @@ func (*accountListLambda) CreateRequest() interface{} { return nil }
 
 type accountInfo struct {
 	Currency string `json:"currency"`
+	Status   string `json:"status"`
 }
 
 func (lambda *accountListLambda) Run(
@@ -49,6 +54,7 @@ func (lambda *accountListLambda) Run(
 	for _, acc := range accounts {
 		result[acc.GetID().String()] = &accountInfo{
 			Currency: acc.GetCurrency().GetISO(),
+			Status:   acc.GetStatus().String(),
 		}
 	}
 	return newHTTPResponse(http.StatusOK, result)
@@ -56,6 +62,83 @@ func (lambda *accountListLambda) Run(
 
 ////////////////////////////////////////////////////////////////////////////////
 
+type accountCreation struct {
+	Currency string `json:"currency"`
+}
+
+type accountCreated struct {
+	Account  string `json:"account"`
+	Currency string `json:"currency"`
+}
+
+type accountCreateLambda struct {
+	accountLambda
+	// rates converts the client tier default limits into the account currency.
+	rates elefant.ExchangeRateProvider
+}
+
+func (*lambdaFactory) NewAccountCreateLambda() lambdaImpl {
+	return &accountCreateLambda{accountLambda: newAccountLambda()}
+}
+
+func (lambda *accountCreateLambda) Init() error {
+	if err := lambda.accountLambda.Init(); err != nil {
+		return err
+	}
+	var err error
+	lambda.rates, err = elefant.NewExchangeRateProvider()
+	return err
+}
+
+func (*accountCreateLambda) CreateRequest() interface{} {
+	return &accountCreation{}
+}
+
+func (lambda *accountCreateLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+	request := lambdaRequest.GetRequest().(*accountCreation)
+	clientID := lambdaRequest.GetClientID()
+
+	currency, err := elefant.NewCurrency(request.Currency)
+	if err != nil {
+		return newHTTPResponseBadParam(err.Error(),
+			`failed to parse currency: "%v"`, err)
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	var acc elefant.Account
+	if acc, err = db.CreateAccount(currency, clientID); err != nil {
+		return nil, fmt.Errorf(
+			`failed to create account in "%s" for client "%s": "%v"`,
+			currency.GetISO(), clientID, err)
+	}
+	if acc == nil {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`client "%s" already has account in "%s"`,
+			clientID, currency.GetISO())
+	}
+	if err := setAccountTierLimits(acc, lambda.rates, db); err != nil {
+		return nil, err
+	}
+
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(`Created new client account "%s" (%s) for client "%s".`,
+		acc.GetID(), acc.GetCurrency().GetISO(), clientID)
+	return newHTTPResponse(http.StatusCreated, &accountCreated{
+		Account:  acc.GetID().String(),
+		Currency: acc.GetCurrency().GetISO()})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
 type accountInfoLambda struct{ accountLambda }
 
 func (*lambdaFactory) NewAccountInfoLambda() lambdaImpl {
@@ -63,18 +146,37 @@ func (*lambdaFactory) NewAccountInfoLambda() lambdaImpl {
 }
 
 type accountDetails struct {
-	Currency string           `json:"currency"`
-	Balance  float64          `json:"balance"`
-	Revision int64            `json:"revision"`
-	History  []*accountAction `json:"history"`
+	Currency string `json:"currency"`
+	Status   string `json:"status"`
+	// Balance is the booked balance, which includes only posted transactions.
+	Balance   elefant.Money `json:"balance"`
+	Overdraft elefant.Money `json:"overdraft"`
+	// Held is the value reserved by authorized holds.
+	Held elefant.Money `json:"held"`
+	// Available is the balance with the unused overdraft and without the held
+	// value.
+	Available elefant.Money    `json:"available"`
+	Revision  int64            `json:"revision"`
+	History   []*accountAction `json:"history"`
 }
 
 type accountAction struct {
-	Time    time.Time `json:"time"`
-	Value   float64   `json:"value"`
-	Subject string    `json:"subject"`
-	State   string    `json:"state"`
-	Notes   string    `json:"notes"`
+	ID       string                 `json:"id"`
+	Time     time.Time              `json:"time"`
+	Value    elefant.Money          `json:"value"`
+	Subject  string                 `json:"subject"`
+	State    string                 `json:"state"`
+	Notes    string                 `json:"notes"`
+	Exchange *accountActionExchange `json:"exchange,omitempty"`
+	// RefundOf is an ID of the refunded action, if the action is a refund.
+	RefundOf string `json:"refundOf,omitempty"`
+	// FeeOf is an ID of the charged action, if the action is a fee.
+	FeeOf string `json:"feeOf,omitempty"`
+}
+
+type accountActionExchange struct {
+	Rate         json.Number   `json:"rate"`
+	CounterValue elefant.Money `json:"counterValue"`
 }
 
 func (*accountInfoLambda) CreateRequest() interface{} { return nil }
@@ -101,7 +203,8 @@ func (lambda *accountInfoLambda) Run(
 
 	var acc elefant.Account
 	var trans []*elefant.Trans
-	acc, trans, err = db.FindAccountUpdate(id, request.GetClientID(), revision)
+	acc, trans, err = db.FindAccountUpdate(
+		id, request.GetClientID(), revision, accountInfoHistorySize)
 	if err != nil {
 		return nil, err
 	}
@@ -109,21 +212,22 @@ func (lambda *accountInfoLambda) Run(
 		return newHTTPResponseNoContent()
 	}
 
-	history := make([]*accountAction, len(trans))
-	for i := 0; i < len(trans); i++ {
-		history[i] = lambda.exportTrans(trans[i])
-	}
-
 	return newHTTPResponse(http.StatusOK, &accountDetails{
-		Currency: acc.GetCurrency().GetISO(),
-		Balance:  acc.GetBalance(),
-		Revision: acc.GetRevision(),
-		History:  history})
+		Currency:  acc.GetCurrency().GetISO(),
+		Status:    acc.GetStatus().String(),
+		Balance:   acc.GetBalance(),
+		Overdraft: acc.GetOverdraft(),
+		Held:      acc.GetHeld(),
+		Available: acc.GetAvailableBalance(),
+		Revision:  acc.GetRevision(),
+		History:   newAccountActions(trans)})
 }
 
-func (lambda *accountInfoLambda) exportTrans(
-	trans *elefant.Trans) *accountAction {
+////////////////////////////////////////////////////////////////////////////////
+
+func newAccountAction(trans *elefant.Trans) *accountAction {
 	result := &accountAction{
+		ID:      trans.ID.String(),
 		Time:    trans.Time,
 		Value:   trans.Value,
 		Subject: trans.Method.GetName(),
@@ -131,6 +235,25 @@ func (lambda *accountInfoLambda) exportTrans(
 	if trans.StatusReason != nil {
 		result.Notes = *trans.StatusReason
 	}
+	if trans.RefundOf != nil {
+		result.RefundOf = trans.RefundOf.String()
+	}
+	if trans.FeeOf != nil {
+		result.FeeOf = trans.FeeOf.String()
+	}
+	if trans.Exchange != nil {
+		result.Exchange = &accountActionExchange{
+			Rate:         json.Number(elefant.FormatExchangeRate(trans.Exchange.Rate)),
+			CounterValue: trans.Exchange.CounterValue}
+	}
+	return result
+}
+
+func newAccountActions(trans []*elefant.Trans) []*accountAction {
+	result := make([]*accountAction, len(trans))
+	for i, trans := range trans {
+		result[i] = newAccountAction(trans)
+	}
 	return result
 }
 
@@ -144,8 +267,180 @@ func (*lambdaFactory) NewAccountHistoryLambda() lambdaImpl {
 
 func (*accountHistoryLambda) CreateRequest() interface{} { return nil }
 
-func (*accountHistoryLambda) Run(LambdaRequest) (*httpResponse, error) {
-	return newHTTPResponseEmpty(http.StatusNotImplemented)
+func (lambda *accountHistoryLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+
+	id, err := request.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := request.GetClientID()
+
+	var before *elefant.TransCursor
+	if source, err := request.ReadQueryArgString("before"); err == nil {
+		cursor, err := elefant.ParseTransCursor(source)
+		if err != nil {
+			return newHTTPResponseBadParam("cursor has invalid format", "%v", err)
+		}
+		before = &cursor
+	}
+	limit := accountHistoryPageSize
+	if source, err := request.ReadQueryArgString("limit"); err == nil {
+		if limit, err = strconv.Atoi(source); err != nil ||
+			limit < 1 || limit > accountHistoryPageMaxSize {
+			return newHTTPResponseBadParam(
+				fmt.Sprintf("limit has to be from 1 to %d", accountHistoryPageMaxSize),
+				`limit has invalid value "%s"`, source)
+		}
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, err := db.FindClientAccount(id, clientID)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to find account "%s" for client "%s": "%v"`, id, clientID, err)
+	}
+	if acc == nil {
+		return newHTTPResponseEmptyError(http.StatusBadRequest,
+			`client "%s" does not have account "%s"`, clientID, id)
+	}
+
+	filter, response, err := readAccountHistoryFilter(request, acc)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	trans, hasMore, err := db.GetAccountHistory(
+		id, clientID, filter, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get account "%s" history: "%v"`, id, err)
+	}
+	history := newAccountActions(trans)
+	if !hasMore {
+		return newHTTPResponse(http.StatusOK, history)
+	}
+	return newHTTPResponseWithHeaders(http.StatusPartialContent, history,
+		map[string]string{
+			HistoryCursorHeaderName: elefant.NewTransCursor(
+				trans[len(trans)-1]).String()})
+}
+
+// readQueryDate reads optional date from the query, the date is the day start
+// in the location. Returns nil if the date is not provided.
+func readQueryDate(
+	request LambdaRequest,
+	name string,
+	location *time.Location) (*time.Time, *httpResponse, error) {
+	source, err := request.ReadQueryArgString(name)
+	if err != nil {
+		return nil, nil, nil
+	}
+	date, err := time.ParseInLocation(queryDateFormat, source, location)
+	if err != nil {
+		response, err := newHTTPResponseBadParam(
+			fmt.Sprintf("%s-date has invalid format", name),
+			`failed to parse %s-date "%s": "%v"`, name, source, err)
+		return nil, response, err
+	}
+	return &date, nil, nil
+}
+
+// readAccountHistoryFilter reads optional history filter from the query, money
+// values are parsed in the account currency.
+func readAccountHistoryFilter(
+	request LambdaRequest,
+	acc elefant.Account) (*elefant.TransFilter, *httpResponse, error) {
+
+	result := &elefant.TransFilter{}
+
+	var err error
+	var response *httpResponse
+	result.From, response, err = readQueryDate(request, "from", time.UTC)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	to, response, err := readQueryDate(request, "to", time.UTC)
+	if response != nil || err != nil {
+		return nil, response, err
+	}
+	if to != nil {
+		// The to-date is included.
+		*to = to.AddDate(0, 0, 1)
+		result.To = to
+	}
+	if result.From != nil && result.To != nil && !result.From.Before(*result.To) {
+		response, err := newHTTPResponseBadParam(
+			"from-date could not be after to-date",
+			`from-date %s is after to-date %s`, result.From, result.To)
+		return nil, response, err
+	}
+
+	readValue := func(name string) (*elefant.Money, *httpResponse, error) {
+		source, err := request.ReadQueryArgString(name)
+		if err != nil {
+			return nil, nil, nil
+		}
+		value, err := elefant.ParseMoney(source, acc.GetCurrency())
+		if err != nil || value.IsNegative() {
+			response, err := newHTTPResponseBadParam(
+				fmt.Sprintf("%s-value has invalid format", name),
+				`failed to parse %s-value "%s": "%v"`, name, source, err)
+			return nil, response, err
+		}
+		return &value, nil, nil
+	}
+	if result.MinValue, response, err = readValue("min"); response != nil ||
+		err != nil {
+		return nil, response, err
+	}
+	if result.MaxValue, response, err = readValue("max"); response != nil ||
+		err != nil {
+		return nil, response, err
+	}
+	if result.MinValue != nil && result.MaxValue != nil &&
+		result.MinValue.Cmp(*result.MaxValue) > 0 {
+		response, err := newHTTPResponseBadParam(
+			"min-value could not be greater than max-value",
+			`min-value %s is greater than max-value %s`,
+			result.MinValue, result.MaxValue)
+		return nil, response, err
+	}
+
+	if source, err := request.ReadQueryArgString("direction"); err == nil {
+		direction, err := elefant.ParseFeeDirection(source)
+		if err != nil {
+			response, err := newHTTPResponseBadParam("unknown direction", "%v", err)
+			return nil, response, err
+		}
+		result.Direction = &direction
+	}
+	if source, err := request.ReadQueryArgString("type"); err == nil {
+		methodType, err := elefant.ParseMethodTypeName(source)
+		if err != nil {
+			response, err := newHTTPResponseBadParam(
+				"unknown method type", "%v", err)
+			return nil, response, err
+		}
+		result.MethodType = &methodType
+	}
+	if source, err := request.ReadQueryArgString("status"); err == nil {
+		status, err := elefant.ParseTransStatusName(source)
+		if err != nil {
+			response, err := newHTTPResponseBadParam("unknown status", "%v", err)
+			return nil, response, err
+		}
+		result.Status = &status
+	}
+	if source, err := request.ReadQueryArgString("q"); err == nil {
+		result.Query = strings.TrimSpace(source)
+	}
+
+	return result, nil, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -169,13 +464,18 @@ func (lambda *accountFindLambda) Run(request LambdaRequest) (*httpResponse, erro
 		return newHTTPResponseBadParam("currency is not provided",
 			`failed to get currency: "%v"`, err)
 	}
+	var currency elefant.Currency
+	if currency, err = elefant.NewCurrency(currencyCode); err != nil {
+		return newHTTPResponseBadParam(err.Error(),
+			`failed to parse currency: "%v"`, err)
+	}
 	var db elefant.DBTrans
 	if db, err = lambda.db.Begin(); err != nil {
 		return nil, err
 	}
+	defer db.Rollback()
 	var result *elefant.AccountID
-	result, err = db.FindAccountByEmail(
-		email, elefant.NewCurrency(currencyCode))
+	result, err = db.FindAccountByEmail(email, currency)
 	if err != nil {
 		return nil, err
 	}
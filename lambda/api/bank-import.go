@@ -0,0 +1,221 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+	"github.com/palchukovsky/elefantpay-aws/elefant/bankimport"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BankImportResult is the numbers of imported bank statement lines.
+type BankImportResult struct {
+	Credited int
+	Review   int
+	// Duplicated lines already are imported before and are skipped.
+	Duplicated int
+}
+
+// BankImport credits incoming transfers from the collection account bank
+// statement to client accounts.
+type BankImport interface {
+	// Import stores the lines, credits lines which are matched with client
+	// accounts and puts other lines into the review queue. Each line is
+	// imported in a separate DB transaction, lines which already are imported
+	// are skipped, so the same statement could be imported several times.
+	Import([]*elefant.BankLine) (*BankImportResult, error)
+	// GetReview returns lines from the review queue.
+	GetReview() ([]*elefant.BankLine, error)
+	// Credit credits the line from the review queue to the account.
+	Credit(elefant.BankLineID, elefant.AccountID) (*elefant.Trans, error)
+}
+
+// NewBankImport creates new bank statement import.
+func NewBankImport() BankImport {
+	result := &bankImport{accountBalanceLambda: newAccountBalanceLambda()}
+	if err := result.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init bank import: "%v".`, err)
+	}
+	return result
+}
+
+type bankImport struct{ accountBalanceLambda }
+
+func (job *bankImport) Import(
+	lines []*elefant.BankLine) (*BankImportResult, error) {
+	result := &BankImportResult{}
+	for _, line := range lines {
+		isStored, err := job.importLine(line)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case !isStored:
+			result.Duplicated++
+		case line.Status == elefant.BankLineStatusCredited:
+			result.Credited++
+		default:
+			result.Review++
+		}
+	}
+	elefant.Log.Info(
+		"Imported %d bank statement lines: %d credited, %d to review, %d duplicated.",
+		len(lines), result.Credited, result.Review, result.Duplicated)
+	return result, nil
+}
+
+// importLine stores the line and credits it if the line is matched. Returns
+// false if the line already is imported.
+func (job *bankImport) importLine(line *elefant.BankLine) (bool, error) {
+	db, err := job.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer db.Rollback()
+
+	acc, reason, err := bankimport.Match(line, db)
+	if err != nil {
+		return false, fmt.Errorf(`failed to match bank line "%s": "%v"`,
+			line.Reference, err)
+	}
+	line.Reason = reason
+
+	// The line is stored for review before crediting, so it also checks that
+	// the line is not imported yet.
+	isStored, err := db.StoreBankLine(line)
+	if err != nil {
+		return false, fmt.Errorf(`failed to store bank line "%s": "%v"`,
+			line.Reference, err)
+	}
+	if !isStored {
+		return false, nil
+	}
+
+	var trans *elefant.Trans
+	if acc != nil {
+		if trans, err = job.credit(line, acc, db); err != nil {
+			return false, err
+		}
+	}
+
+	if err := db.Commit(); err != nil {
+		return false, err
+	}
+
+	if trans == nil {
+		elefant.Log.Warn(`Bank line "%s" "%s" (%s) is queued for review: "%s".`,
+			line.ID, line.Reference, line.Value, line.Reason)
+		return true, nil
+	}
+	elefant.Log.Info(fmtTransLog(trans))
+	return true, nil
+}
+
+func (job *bankImport) GetReview() ([]*elefant.BankLine, error) {
+	db, err := job.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+	result, err := db.GetBankLinesForReview()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get bank lines for review: "%v"`, err)
+	}
+	return result, nil
+}
+
+func (job *bankImport) Credit(
+	lineID elefant.BankLineID, accID elefant.AccountID) (*elefant.Trans, error) {
+	db, err := job.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	line, err := db.LockBankLine(lineID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find bank line "%s": "%v"`, lineID, err)
+	}
+	if line == nil {
+		return nil, fmt.Errorf(`bank line "%s" does not exist`, lineID)
+	}
+	if line.Status != elefant.BankLineStatusReview {
+		return nil, fmt.Errorf(`bank line "%s" is %s`, lineID, line.Status)
+	}
+	acc, err := db.FindAccount(accID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account "%s": "%v"`, accID, err)
+	}
+	if acc == nil {
+		return nil, fmt.Errorf(`account "%s" does not exist`, accID)
+	}
+	if acc.GetCurrency().GetISO() != line.Value.GetCurrency().GetISO() {
+		return nil, fmt.Errorf(`account "%s" currency %s is not %s`,
+			accID, acc.GetCurrency().GetISO(), line.Value.GetCurrency().GetISO())
+	}
+
+	trans, err := job.credit(line, acc, db)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(fmtTransLog(trans))
+	return trans, nil
+}
+
+// credit deposits the line value from the transfer clearing to the account,
+// charges the deposit fee and marks the stored line as credited.
+func (job *bankImport) credit(
+	line *elefant.BankLine,
+	acc elefant.Account,
+	db elefant.DBTrans) (*elefant.Trans, error) {
+	accID := acc.GetID()
+
+	journal := elefant.NewJournal()
+	journal.AddSystemPosting(elefant.SystemAccountTransferClearing,
+		line.Value.Neg())
+	journal.AddAccountPosting(accID, line.Value)
+	accounts, response, err := job.postJournal(journal, db)
+	if err != nil {
+		return nil, err
+	}
+	if response != nil {
+		return nil, fmt.Errorf(`account "%s" doesn't allow deposit: "%s"`,
+			accID, readHTTPResponseError(response))
+	}
+	acc = accounts[accID]
+
+	var trans *elefant.Trans
+	response, err = job.deposit(acc, line.Value, nil, journal.ID, true,
+		func() (elefant.Method, error) {
+			return db.GetBankTransferMethod(acc, line)
+		},
+		db, &trans)
+	if err != nil {
+		return nil, err
+	}
+	if response != nil {
+		return nil, fmt.Errorf(`failed to charge account "%s" deposit fee: "%s"`,
+			accID, readHTTPResponseError(response))
+	}
+
+	isCredited, err := db.CreditBankLine(line.ID, accID, trans.ID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to credit bank line "%s": "%v"`,
+			line.ID, err)
+	}
+	if !isCredited {
+		return nil, fmt.Errorf(`bank line "%s" is not in review`, line.ID)
+	}
+	line.Status = elefant.BankLineStatusCredited
+	line.Reason = ""
+	line.Account = &accID
+	line.Trans = &trans.ID
+	return trans, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -39,6 +39,8 @@ type clientRegistration struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Currency is an optional ISO code of the first client account currency.
+	Currency string `json:"currency,omitempty"`
 }
 
 type clientInfo struct {
@@ -85,6 +87,42 @@ func gen2faCode() string {
 	return result
 }
 
+// sendEmail sends the email by SendGrid.
+func sendEmail(m *mail.SGMailV3) error {
+	request := sendgrid.GetRequest(
+		elefant.SendGridAPIKey, "/v3/mail/send", "https://api.sendgrid.com")
+	request.Method = "POST"
+	request.Body = mail.GetRequestBody(m)
+	response, err := sendgrid.API(request)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf(`statis code "%d", response: "%s", headers: "%s"`,
+			response.StatusCode, response.Body, response.Headers)
+	}
+	return nil
+}
+
+// sendTextEmail sends the email with plain text to the client.
+func sendTextEmail(to elefant.Client, subject, text string) error {
+	m := mail.NewV3Mail()
+	m.SetFrom(mail.NewEmail(elefant.EmailFromName, elefant.EmailFromAddress))
+	m.Subject = subject
+
+	p := mail.NewPersonalization()
+	p.AddTos(mail.NewEmail(to.GetName(), to.GetEmail()))
+	m.AddPersonalizations(p)
+
+	m.AddContent(mail.NewContent("text/plain", text))
+
+	if err := sendEmail(m); err != nil {
+		return fmt.Errorf(`failed to send email "%s" on "%s": "%v"`,
+			subject, to.GetEmail(), err)
+	}
+	return nil
+}
+
 func send2faCode(
 	confirmationID elefant.ConfirmationID,
 	twoFaCode string,
@@ -109,23 +147,11 @@ func send2faCode(
 
 	m.AddPersonalizations(p)
 
-	request := sendgrid.GetRequest(
-		elefant.SendGridAPIKey, "/v3/mail/send", "https://api.sendgrid.com")
-	request.Method = "POST"
-	request.Body = mail.GetRequestBody(m)
-	response, err := sendgrid.API(request)
-	if err != nil {
+	if err := sendEmail(m); err != nil {
 		return fmt.Errorf(
 			`failed to send 2FA confirmation code for user "%s" on email "%s": "%v"`,
 			client.GetID(), client.GetEmail(), err)
 	}
-	if response.StatusCode != http.StatusAccepted {
-		return fmt.Errorf(
-			`failed to send 2FA confirmation code for user "%s" on email "%s": `+
-				` statis code "%d", response: "%s", headers: "%s"`,
-			client.GetID(), client.GetEmail(),
-			response.StatusCode, response.Body, response.Headers)
-	}
 
 	elefant.Log.Info(
 		`Sent 2FA-code "%s" for confirmation "%s" for user "%s" on email "%s".`,
@@ -136,7 +162,11 @@ func send2faCode(
 
 ////////////////////////////////////////////////////////////////////////////////
 
-type clientCreateLambda struct{ clientLambda }
+type clientCreateLambda struct {
+	clientLambda
+	// rates converts the client tier default limits into the account currency.
+	rates elefant.ExchangeRateProvider
+}
 
 func (*lambdaFactory) NewClientCreateLambda() lambdaImpl {
 	return &clientCreateLambda{clientLambda: newClientLambda()}
@@ -156,6 +186,15 @@ func (lambda *clientCreateLambda) Run(
 			`failed to validate password: too small (%d symbols)`,
 			len(request.Password))
 	}
+	currencyCode := request.Currency
+	if currencyCode == "" {
+		currencyCode = defaultAccountCurrency
+	}
+	currency, err := elefant.NewCurrency(currencyCode)
+	if err != nil {
+		return newHTTPResponseBadParam(err.Error(),
+			`failed to get account currency: "%v"`, err)
+	}
 
 	httpRequest := *lambdaRequest.GetHTTPRequest()
 	httpRequest.Body = "" // to remove secure info.
@@ -166,7 +205,8 @@ func (lambda *clientCreateLambda) Run(
 	}
 	defer db.Rollback()
 
-	client, accounts, err := lambda.createClient(request, &httpRequest, db)
+	client, accounts, err := lambda.createClient(
+		request, currency, &httpRequest, db)
 	if err != nil {
 		return nil,
 			fmt.Errorf(`failed to store new client record for request "%v": "%s"`,
@@ -204,12 +244,22 @@ func (lambda *clientCreateLambda) Run(
 		newClientConfirmRequest(confirmationID))
 }
 
+func (lambda *clientCreateLambda) Init() error {
+	if err := lambda.clientLambda.Init(); err != nil {
+		return err
+	}
+	var err error
+	lambda.rates, err = elefant.NewExchangeRateProvider()
+	return err
+}
+
 func (*clientCreateLambda) CreateRequest() interface{} {
 	return &clientRegistration{}
 }
 
 func (lambda *clientCreateLambda) createClient(
 	request *clientRegistration,
+	currency elefant.Currency,
 	httpRequest interface{},
 	db elefant.DBTrans) (elefant.Client, []elefant.Account, error) {
 
@@ -224,11 +274,19 @@ func (lambda *clientCreateLambda) createClient(
 	}
 
 	var acc elefant.Account
-	acc, err = db.CreateAccount(elefant.NewCurrency("EUR"), client.GetID())
+	acc, err = db.CreateAccount(currency, client.GetID())
 	if err != nil {
 		return nil, nil, fmt.Errorf(`failed to create new account record: "%v"`,
 			err)
 	}
+	if acc == nil {
+		return nil, nil, fmt.Errorf(
+			`new client "%s" already has account in "%s"`,
+			client.GetID(), currency.GetISO())
+	}
+	if err := setAccountTierLimits(acc, lambda.rates, db); err != nil {
+		return nil, nil, err
+	}
 
 	return client, []elefant.Account{acc}, nil
 }
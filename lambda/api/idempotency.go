@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// IdempotencyExpiry deletes responses for idempotency keys after the key TTL.
+type IdempotencyExpiry interface {
+	// Run deletes responses which are stored before the key TTL, so the keys
+	// could be used again.
+	Run() error
+}
+
+// NewIdempotencyExpiry creates new idempotency key expiry.
+func NewIdempotencyExpiry() IdempotencyExpiry {
+	result := &idempotencyExpiry{accountLambda: newAccountLambda()}
+	if err := result.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init idempotency key expiry: "%v".`, err)
+	}
+	return result
+}
+
+type idempotencyExpiry struct{ accountLambda }
+
+func (job *idempotencyExpiry) Run() error {
+	db, err := job.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer db.Rollback()
+
+	count, err := db.PurgeIdempotentResponses(
+		time.Now().UTC().Add(-idempotencyKeyTTL))
+	if err != nil {
+		return fmt.Errorf(`failed to purge idempotent responses: "%v"`, err)
+	}
+	if err := db.Commit(); err != nil {
+		return err
+	}
+
+	elefant.Log.Info("Purged %d idempotent responses.", count)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BalanceInterest accrues interest for positive balances.
+type BalanceInterest interface {
+	// Run accrues the interest for each day up to the previous day by
+	// the end-of-day balance into the account pending interest, and pays
+	// the pending interest to the account after the last day of the month.
+	// The interest is accrued only once for the day, so it could be run
+	// several times.
+	Run() error
+}
+
+// NewBalanceInterest creates new balance interest accrual.
+func NewBalanceInterest() BalanceInterest {
+	result := &balanceInterest{accountBalanceLambda: newAccountBalanceLambda()}
+	if err := result.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init balance interest: "%v".`, err)
+	}
+	return result
+}
+
+type balanceInterest struct{ accountBalanceLambda }
+
+func (job *balanceInterest) Run() error {
+	now := time.Now().UTC()
+	lastDay := time.Date(
+		now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	count := 0
+	// Failed accounts are skipped till the next run, so they don't block
+	// other accounts.
+	failed := []elefant.AccountID{}
+	for {
+		accID, err := job.accrueNext(lastDay, failed)
+		if err != nil {
+			if accID == nil {
+				return err
+			}
+			elefant.Log.Error(
+				`Failed to accrue balance interest for account "%s": "%v".`,
+				*accID, err)
+			failed = append(failed, *accID)
+			continue
+		}
+		if accID == nil {
+			break
+		}
+		count++
+	}
+	elefant.Log.Info(
+		"Accrued balance interest for %d account days up to %s, failed %d.",
+		count, lastDay.Format("2006-01-02"), len(failed))
+	return nil
+}
+
+// accrueNext accrues the interest for the next not accrued day of the next
+// account. Returns the account ID, or nil if there is no account to accrue.
+// The error with the account ID is the account accrual error.
+func (job *balanceInterest) accrueNext(
+	lastDay time.Time,
+	skip []elefant.AccountID) (*elefant.AccountID, error) {
+
+	db, err := job.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, day, err := db.LockInterestAccount(lastDay, skip)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account to accrue interest: "%v"`,
+			err)
+	}
+	if acc == nil {
+		return nil, nil
+	}
+	accID := acc.GetID()
+	if err := job.accrue(acc, day, db); err != nil {
+		return &accID, err
+	}
+	return &accID, nil
+}
+
+// accrue accrues the interest for the day by the account end-of-day balance
+// and pays the pending interest after the last day of the month.
+func (job *balanceInterest) accrue(
+	acc elefant.Account, day time.Time, db elefant.DBTrans) error {
+	accID := acc.GetID()
+
+	rate, err := db.FindAccountInterestRate(acc)
+	if err != nil {
+		return fmt.Errorf(
+			`failed to find account "%s" interest rate: "%v"`, accID, err)
+	}
+	// The day is stored even without the interest, so the account is not
+	// selected again for the day.
+	interest := new(big.Rat)
+	if rate != nil {
+		balance, err := db.GetAccountBalanceAt(acc, day.AddDate(0, 0, 1))
+		if err != nil {
+			return fmt.Errorf(
+				`failed to get account "%s" end-of-day balance: "%v"`, accID, err)
+		}
+		interest = elefant.CalcDailyInterest(balance, rate)
+	}
+	pending, err := db.AccrueAccountInterest(accID, day, interest)
+	if err != nil {
+		return fmt.Errorf(
+			`failed to store account "%s" interest accrual: "%v"`, accID, err)
+	}
+
+	var trans *elefant.Trans
+	// The interest is paid after the last day of the month in minor units,
+	// the rest stays pending.
+	if day.AddDate(0, 0, 1).Day() == 1 {
+		paid := elefant.NewMoneyFromRat(
+			pending, acc.GetCurrency(), elefant.RoundDown)
+		if paid.IsPositive() {
+			if trans, err = job.pay(acc, paid, db); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := db.Commit(); err != nil {
+		return err
+	}
+
+	if trans != nil {
+		elefant.Log.Info(fmtTransLog(trans))
+	}
+	return nil
+}
+
+// pay capitalizes the pending interest by the transaction.
+func (job *balanceInterest) pay(
+	acc elefant.Account,
+	value elefant.Money,
+	db elefant.DBTrans) (*elefant.Trans, error) {
+	accID := acc.GetID()
+	// Interest is a house posting, so it's paid to frozen accounts too.
+	journal := elefant.NewChargeJournal()
+	journal.AddSystemPosting(elefant.SystemAccountInterestExpense, value.Neg())
+	journal.AddAccountPosting(accID, value)
+	accounts, response, err := job.postJournal(journal, db)
+	if err != nil {
+		return nil, err
+	}
+	if response != nil {
+		// Closed accounts are not accrued.
+		return nil, fmt.Errorf(`account "%s" status doesn't allow interest`,
+			accID)
+	}
+	acc = accounts[accID]
+	method, err := db.GetInterestMethod(acc)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get interest method: "%v"`, err)
+	}
+	trans, err := db.StoreTrans(elefant.TransStatusSuccess,
+		journal.ID, acc, method, value, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.CapitalizeAccountInterest(accID, value); err != nil {
+		return nil, fmt.Errorf(
+			`failed to store account "%s" interest capitalization: "%v"`,
+			accID, err)
+	}
+	return trans, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	aws "github.com/aws/aws-lambda-go/lambda"
 	"github.com/palchukovsky/elefantpay-aws/elefant"
@@ -35,6 +39,13 @@ type lambdaImpl interface {
 	Run(LambdaRequest) (*httpResponse, error)
 }
 
+// idempotentLambdaImpl is implemented by lambdas which store the response for
+// the request idempotency key. Requests with the key to other lambdas are
+// rejected, as the key would be ignored.
+type idempotentLambdaImpl interface {
+	IsIdempotent() bool
+}
+
 type lambdaFactory struct{}
 
 func newLambdaFactory() *lambdaFactory { return &lambdaFactory{} }
@@ -69,9 +80,34 @@ type LambdaRequest interface {
 	ReadAuthToken() elefant.AuthTokenID
 
 	ReadPathArgAccountID() (elefant.AccountID, error)
+	ReadPathArgTransID() (elefant.TransID, error)
+	ReadPathArgScheduleID() (elefant.ScheduleID, error)
+	ReadPathArgMoneyRequestID() (elefant.MoneyRequestID, error)
+	ReadPathArgHoldID() (elefant.HoldID, error)
 
 	ReadQueryArgInt64(name string) (int64, error)
 	ReadQueryArgString(name string) (string, error)
+
+	// FindIdempotentResponse returns stored response if the request has
+	// idempotency key which already is used. Returns nil if the request has to
+	// be executed.
+	FindIdempotentResponse(elefant.DBTrans) (*httpResponse, error)
+	// StoreIdempotentResponse stores the response for the request idempotency
+	// key in the transaction which executes the request. Returns not nil
+	// response if the transaction could not be committed.
+	StoreIdempotentResponse(
+		*httpResponse, elefant.DBTrans) (*httpResponse, error)
+	// ReserveIdempotencyKey stores the pending response for the request
+	// idempotency key before the request execution, for requests which are
+	// executed in several transactions. Returns not nil response if the key is
+	// already used.
+	ReserveIdempotencyKey(*httpResponse, elefant.DBTrans) (*httpResponse, error)
+	// StorePendingIdempotentResponse replaces the pending response for
+	// the request idempotency key by the result of the executed part of
+	// the request. Returns not nil response if the transaction could not be
+	// committed.
+	StorePendingIdempotentResponse(
+		*httpResponse, elefant.DBTrans) (*httpResponse, error)
 }
 
 type lambdaRequest struct {
@@ -79,8 +115,9 @@ type lambdaRequest struct {
 	Response    *httpResponse
 	ResponseErr error
 
-	implRequest interface{}
-	clientID    *elefant.ClientID
+	implRequest    interface{}
+	clientID       *elefant.ClientID
+	idempotencyKey *string
 }
 
 func (request *lambdaRequest) dumpRequest() {
@@ -116,6 +153,46 @@ func (request *lambdaRequest) parseBody(
 	return nil, nil
 }
 
+func (request *lambdaRequest) readHeader(name string) (string, bool) {
+	for key, value := range request.Request.Headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func (request *lambdaRequest) readIdempotencyKey(
+	impl lambdaImpl) (*httpResponse, error) {
+	key, has := request.readHeader(IdempotencyKeyHeaderName)
+	if !has {
+		return nil, nil
+	}
+	if idempotent, is := impl.(idempotentLambdaImpl); !is ||
+		!idempotent.IsIdempotent() {
+		return newHTTPResponseBadParam("idempotency key is not supported",
+			`idempotency key "%s" is provided for request without idempotency`,
+			key)
+	}
+	key = strings.TrimSpace(key)
+	if len(key) == 0 || len(key) > idempotencyKeyMaxLen {
+		return newHTTPResponseBadParam("idempotency key has invalid format",
+			`idempotency key "%s" has invalid length`, key)
+	}
+	request.idempotencyKey = &key
+	return nil, nil
+}
+
+func (request *lambdaRequest) getRequestHash() string {
+	hash := sha256.New()
+	hash.Write([]byte(request.Request.RequestContext.HTTPMethod))
+	hash.Write([]byte{0})
+	hash.Write([]byte(request.Request.Path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(request.Request.Body))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 func (request *lambdaRequest) updateResponseHeaders() {
 	if request.Response == nil {
 		return
@@ -167,6 +244,10 @@ func (request *lambdaRequest) Execute(impl lambdaImpl) {
 	}()
 
 	request.implRequest = impl.CreateRequest()
+	request.Response, request.ResponseErr = request.readIdempotencyKey(impl)
+	if request.Response != nil || request.ResponseErr != nil {
+		return
+	}
 	switch request.Request.RequestContext.HTTPMethod {
 	case http.MethodPost, http.MethodPut:
 		request.Response, request.ResponseErr = request.parseBody(
@@ -230,6 +311,48 @@ func (request *lambdaRequest) ReadPathArgAccountID() (elefant.AccountID, error)
 	return result, nil
 }
 
+func (request *lambdaRequest) ReadPathArgTransID() (elefant.TransID, error) {
+	arg := request.Request.PathParameters["transId"]
+	result, err := elefant.ParseTransID(arg)
+	if err != nil {
+		return result, fmt.Errorf(`failed to parse transaction ID "%s": "%v"`,
+			arg, err)
+	}
+	return result, nil
+}
+
+func (request *lambdaRequest) ReadPathArgScheduleID() (
+	elefant.ScheduleID, error) {
+	arg := request.Request.PathParameters["scheduleId"]
+	result, err := elefant.ParseScheduleID(arg)
+	if err != nil {
+		return result, fmt.Errorf(`failed to parse standing order ID "%s": "%v"`,
+			arg, err)
+	}
+	return result, nil
+}
+
+func (request *lambdaRequest) ReadPathArgMoneyRequestID() (
+	elefant.MoneyRequestID, error) {
+	arg := request.Request.PathParameters["requestId"]
+	result, err := elefant.ParseMoneyRequestID(arg)
+	if err != nil {
+		return result, fmt.Errorf(`failed to parse money request ID "%s": "%v"`,
+			arg, err)
+	}
+	return result, nil
+}
+
+func (request *lambdaRequest) ReadPathArgHoldID() (elefant.HoldID, error) {
+	arg := request.Request.PathParameters["holdId"]
+	result, err := elefant.ParseHoldID(arg)
+	if err != nil {
+		return result, fmt.Errorf(`failed to parse hold ID "%s": "%v"`,
+			arg, err)
+	}
+	return result, nil
+}
+
 func (request *lambdaRequest) ReadQueryArgInt64(name string) (int64, error) {
 	str, has := request.Request.QueryStringParameters[name]
 	if !has {
@@ -249,3 +372,92 @@ func (request *lambdaRequest) ReadQueryArgString(name string) (string, error) {
 	}
 	return result, nil
 }
+
+func (request *lambdaRequest) FindIdempotentResponse(
+	db elefant.DBTrans) (*httpResponse, error) {
+	if request.idempotencyKey == nil {
+		return nil, nil
+	}
+	key := *request.idempotencyKey
+	stored, err := db.FindIdempotentResponse(request.GetClientID(), key)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find response for idempotency key "%s": "%v"`,
+			key, err)
+	}
+	if stored == nil {
+		return nil, nil
+	}
+	if stored.RequestHash != request.getRequestHash() {
+		return newHTTPResponseEmptyError(http.StatusUnprocessableEntity,
+			`idempotency key "%s" is already used for another request`, key)
+	}
+	if stored.IsPending {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`request with idempotency key "%s" is executed concurrently`, key)
+	}
+	elefant.Log.Debug(`Replaying response for idempotency key "%s".`, key)
+	return newHTTPResponseWithBody(stored.StatusCode, stored.Body,
+		map[string]string{IdempotentReplayedHeaderName: "true"})
+}
+
+func (request *lambdaRequest) newIdempotentResponse(
+	response *httpResponse, isPending bool) *elefant.IdempotentResponse {
+	return &elefant.IdempotentResponse{
+		Client:      request.GetClientID(),
+		Key:         *request.idempotencyKey,
+		RequestHash: request.getRequestHash(),
+		IsPending:   isPending,
+		StatusCode:  response.StatusCode,
+		Body:        response.Body,
+		Time:        time.Now().UTC()}
+}
+
+func (request *lambdaRequest) ReserveIdempotencyKey(
+	response *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+	if request.idempotencyKey == nil {
+		return nil, nil
+	}
+	key := *request.idempotencyKey
+	isReserved, err := db.ReserveIdempotencyKey(
+		request.newIdempotentResponse(response, true))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to reserve idempotency key "%s": "%v"`,
+			key, err)
+	}
+	if !isReserved {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`request with idempotency key "%s" is executed concurrently`, key)
+	}
+	return nil, nil
+}
+
+func (request *lambdaRequest) StorePendingIdempotentResponse(
+	response *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+	return request.storeIdempotentResponse(response, true, db)
+}
+
+func (request *lambdaRequest) StoreIdempotentResponse(
+	response *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+	return request.storeIdempotentResponse(response, false, db)
+}
+
+func (request *lambdaRequest) storeIdempotentResponse(
+	response *httpResponse,
+	isPending bool,
+	db elefant.DBTrans) (*httpResponse, error) {
+	if request.idempotencyKey == nil {
+		return nil, nil
+	}
+	key := *request.idempotencyKey
+	isStored, err := db.StoreIdempotentResponse(
+		request.newIdempotentResponse(response, isPending))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to store response for idempotency key "%s": "%v"`,
+			key, err)
+	}
+	if !isStored {
+		return newHTTPResponseEmptyError(http.StatusConflict,
+			`request with idempotency key "%s" is executed concurrently`, key)
+	}
+	return nil, nil
+}
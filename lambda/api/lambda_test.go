@@ -0,0 +1,308 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type testLog struct{ t *testing.T }
+
+func (*testLog) CheckExit() {}
+func (log *testLog) Debug(format string, args ...interface{}) {
+	log.t.Logf("Debug: "+format, args...)
+}
+func (log *testLog) Info(format string, args ...interface{}) {
+	log.t.Logf("Info: "+format, args...)
+}
+func (log *testLog) Warn(format string, args ...interface{}) {
+	log.t.Logf("Warn: "+format, args...)
+}
+func (log *testLog) Error(format string, args ...interface{}) {
+	log.t.Logf("Error: "+format, args...)
+}
+func (log *testLog) Err(err error) { log.t.Logf("Error: %v", err) }
+func (log *testLog) Panic(format string, args ...interface{}) {
+	log.t.Fatalf("Panic: "+format, args...)
+}
+
+func setTestLog(t *testing.T) {
+	prev := elefant.Log
+	elefant.Log = &testLog{t: t}
+	t.Cleanup(func() { elefant.Log = prev })
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// idempotencyTestDB stores idempotent responses as the DB does, other DB
+// methods are not implemented.
+type idempotencyTestDB struct {
+	elefant.DBTrans
+	responses map[string]elefant.IdempotentResponse
+}
+
+func newIdempotencyTestDB() *idempotencyTestDB {
+	return &idempotencyTestDB{
+		responses: map[string]elefant.IdempotentResponse{}}
+}
+
+func (*idempotencyTestDB) getKey(client elefant.ClientID, key string) string {
+	return client.String() + "/" + key
+}
+
+func (db *idempotencyTestDB) FindIdempotentResponse(
+	client elefant.ClientID, key string) (*elefant.IdempotentResponse, error) {
+	result, has := db.responses[db.getKey(client, key)]
+	if !has {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+func (db *idempotencyTestDB) ReserveIdempotencyKey(
+	response *elefant.IdempotentResponse) (bool, error) {
+	key := db.getKey(response.Client, response.Key)
+	if _, has := db.responses[key]; has {
+		return false, nil
+	}
+	db.responses[key] = *response
+	return true, nil
+}
+
+func (db *idempotencyTestDB) StoreIdempotentResponse(
+	response *elefant.IdempotentResponse) (bool, error) {
+	key := db.getKey(response.Client, response.Key)
+	if stored, has := db.responses[key]; has &&
+		(!stored.IsPending || stored.RequestHash != response.RequestHash) {
+		return false, nil
+	}
+	db.responses[key] = *response
+	return true, nil
+}
+
+// idempotencyTestLambda executes the request as payment lambdas do: replays
+// the stored response or executes the request and stores the response.
+type idempotencyTestLambda struct {
+	db           *idempotencyTestDB
+	isIdempotent bool
+	executed     int
+}
+
+func (*idempotencyTestLambda) Init() error                { return nil }
+func (*idempotencyTestLambda) CreateRequest() interface{} { return &struct{}{} }
+func (lambda *idempotencyTestLambda) IsIdempotent() bool {
+	return lambda.isIdempotent
+}
+
+func (lambda *idempotencyTestLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+	if response, err := request.FindIdempotentResponse(
+		lambda.db); response != nil || err != nil {
+		return response, err
+	}
+	lambda.executed++
+	response, err := newHTTPResponseWithBody(
+		http.StatusAccepted, `{"n":1}`, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	if conflict, err := request.StoreIdempotentResponse(
+		response, lambda.db); conflict != nil || err != nil {
+		return conflict, err
+	}
+	return response, nil
+}
+
+func newIdempotencyTestRequest(
+	client elefant.ClientID, key *string, body string) *lambdaRequest {
+	result := &lambdaRequest{Request: &httpRequest{
+		Path:    "/account/payment",
+		Body:    body,
+		Headers: map[string]string{},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			HTTPMethod: http.MethodPost,
+			Authorizer: map[string]interface{}{
+				"principalId": client.String()}}}}
+	if key != nil {
+		result.Request.Headers[IdempotencyKeyHeaderName] = *key
+	}
+	return result
+}
+
+func executeIdempotencyTestRequest(
+	t *testing.T,
+	lambda *idempotencyTestLambda,
+	client elefant.ClientID,
+	key *string,
+	body string) *httpResponse {
+	request := newIdempotencyTestRequest(client, key, body)
+	request.Execute(lambda)
+	if request.ResponseErr != nil {
+		t.Fatalf(`Request failed: "%v".`, request.ResponseErr)
+	}
+	if request.Response == nil {
+		t.Fatalf("Request doesn't have response.")
+	}
+	return request.Response
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestIdempotencyKeyReplay(t *testing.T) {
+	setTestLog(t)
+	lambda := &idempotencyTestLambda{
+		db: newIdempotencyTestDB(), isIdempotent: true}
+	client := uuid.New()
+	key := "key-1"
+
+	first := executeIdempotencyTestRequest(t, lambda, client, &key, `{}`)
+	if first.StatusCode != http.StatusAccepted {
+		t.Fatalf("First request is responded with %d.", first.StatusCode)
+	}
+	if _, has := first.Headers[IdempotentReplayedHeaderName]; has {
+		t.Errorf("First request response is marked as replayed.")
+	}
+
+	retry := executeIdempotencyTestRequest(t, lambda, client, &key, `{}`)
+	if retry.StatusCode != first.StatusCode || retry.Body != first.Body {
+		t.Errorf(`Retry is responded with %d "%s", but expected %d "%s".`,
+			retry.StatusCode, retry.Body, first.StatusCode, first.Body)
+	}
+	if retry.Headers[IdempotentReplayedHeaderName] != "true" {
+		t.Errorf("Retry response is not marked as replayed.")
+	}
+	if lambda.executed != 1 {
+		t.Errorf("Request is executed %d times.", lambda.executed)
+	}
+
+	// The key is unique only for the client.
+	executeIdempotencyTestRequest(t, lambda, uuid.New(), &key, `{}`)
+	if lambda.executed != 2 {
+		t.Errorf("Request of another client is not executed.")
+	}
+
+	// Requests without the key are executed each time.
+	executeIdempotencyTestRequest(t, lambda, client, nil, `{}`)
+	executeIdempotencyTestRequest(t, lambda, client, nil, `{}`)
+	if lambda.executed != 4 {
+		t.Errorf("Requests without key are executed %d times.",
+			lambda.executed-2)
+	}
+}
+
+func TestIdempotencyKeyAnotherRequest(t *testing.T) {
+	setTestLog(t)
+	lambda := &idempotencyTestLambda{
+		db: newIdempotencyTestDB(), isIdempotent: true}
+	client := uuid.New()
+	key := "key-1"
+
+	executeIdempotencyTestRequest(t, lambda, client, &key, `{"value":"1"}`)
+	response := executeIdempotencyTestRequest(
+		t, lambda, client, &key, `{"value":"2"}`)
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Key reuse for another request is responded with %d.",
+			response.StatusCode)
+	}
+	if lambda.executed != 1 {
+		t.Errorf("Request is executed %d times.", lambda.executed)
+	}
+}
+
+func TestIdempotencyKeyPending(t *testing.T) {
+	setTestLog(t)
+	db := newIdempotencyTestDB()
+	lambda := &idempotencyTestLambda{db: db, isIdempotent: true}
+	client := uuid.New()
+	key := "key-1"
+
+	// The request with several DB transactions reserves the key before
+	// the execution.
+	executing := newIdempotencyTestRequest(client, &key, `{}`)
+	if response, err := executing.readIdempotencyKey(lambda); response != nil ||
+		err != nil {
+		t.Fatalf(`Failed to read key: %v "%v".`, response, err)
+	}
+	pending, err := newHTTPResponseWithBody(http.StatusOK, `[]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, err := executing.ReserveIdempotencyKey(
+		pending, db); response != nil || err != nil {
+		t.Fatalf(`Failed to reserve key: %v "%v".`, response, err)
+	}
+	if response, err := executing.ReserveIdempotencyKey(
+		pending, db); err != nil || response == nil ||
+		response.StatusCode != http.StatusConflict {
+		t.Errorf(`Key is reserved twice: %v "%v".`, response, err)
+	}
+
+	retry := executeIdempotencyTestRequest(t, lambda, client, &key, `{}`)
+	if retry.StatusCode != http.StatusConflict {
+		t.Errorf("Retry of executing request is responded with %d.",
+			retry.StatusCode)
+	}
+	if lambda.executed != 0 {
+		t.Errorf("Executing request is executed again.")
+	}
+
+	// The executed part replaces the pending response, the key stays pending.
+	if response, err := executing.StorePendingIdempotentResponse(
+		pending, db); response != nil || err != nil {
+		t.Fatalf(`Failed to store pending response: %v "%v".`, response, err)
+	}
+	done, err := newHTTPResponseWithBody(http.StatusOK, `[{"n":1}]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response, err := executing.StoreIdempotentResponse(
+		done, db); response != nil || err != nil {
+		t.Fatalf(`Failed to store response: %v "%v".`, response, err)
+	}
+
+	retry = executeIdempotencyTestRequest(t, lambda, client, &key, `{}`)
+	if retry.StatusCode != done.StatusCode || retry.Body != done.Body {
+		t.Errorf(`Retry is responded with %d "%s", but expected %d "%s".`,
+			retry.StatusCode, retry.Body, done.StatusCode, done.Body)
+	}
+	if lambda.executed != 0 {
+		t.Errorf("Executed request is executed again.")
+	}
+}
+
+func TestIdempotencyKeyInvalid(t *testing.T) {
+	setTestLog(t)
+	client := uuid.New()
+	for _, test := range []struct {
+		name         string
+		key          string
+		isIdempotent bool
+	}{
+		{name: "not supported", key: "key-1", isIdempotent: false},
+		{name: "empty", key: " ", isIdempotent: true},
+		{
+			name:         "too long",
+			key:          strings.Repeat("k", idempotencyKeyMaxLen+1),
+			isIdempotent: true},
+	} {
+		lambda := &idempotencyTestLambda{
+			db: newIdempotencyTestDB(), isIdempotent: test.isIdempotent}
+		key := test.key
+		response := executeIdempotencyTestRequest(t, lambda, client, &key, `{}`)
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf(`Key "%s" is responded with %d.`,
+				test.name, response.StatusCode)
+		}
+		if lambda.executed != 0 {
+			t.Errorf(`Request with key "%s" is executed.`, test.name)
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,443 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/badoux/checkmail"
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type moneyRequestOrder struct {
+	// Email is the payer email.
+	Email       string      `json:"email"`
+	Value       json.Number `json:"value"`
+	Description string      `json:"description,omitempty"`
+}
+
+type moneyRequest struct {
+	ID string `json:"id"`
+	// Direction is "incoming" if the client is the payer, or "outgoing"
+	// if the client is the payee.
+	Direction string `json:"direction"`
+	// Account is the client account in the request.
+	Account string `json:"account"`
+	// Email is the other side email.
+	Email       string        `json:"email"`
+	Value       elefant.Money `json:"value"`
+	Description string        `json:"description,omitempty"`
+	Status      string        `json:"status"`
+	Time        time.Time     `json:"time"`
+	Expiry      time.Time     `json:"expiry"`
+}
+
+func newMoneyRequest(
+	request *elefant.MoneyRequest,
+	client elefant.ClientID,
+	now time.Time) *moneyRequest {
+	result := &moneyRequest{
+		ID:          request.ID.String(),
+		Value:       request.Value,
+		Description: request.Description,
+		Status:      request.GetStatus(now).String(),
+		Time:        request.Time,
+		Expiry:      request.Expiry}
+	if request.Payer == client {
+		result.Direction = "incoming"
+		result.Account = request.PayerAccount.String()
+		result.Email = request.PayeeEmail
+	} else {
+		result.Direction = "outgoing"
+		result.Account = request.PayeeAccount.String()
+		result.Email = request.PayerEmail
+	}
+	return result
+}
+
+// moneyRequestDescriptionMaxLen is the max length of the money request
+// description.
+const moneyRequestDescriptionMaxLen = 255
+
+// notifyMoneyRequest sends notification about the money request, failure is
+// only logged as the request is already stored.
+func notifyMoneyRequest(
+	request *elefant.MoneyRequest,
+	to elefant.Client,
+	from elefant.Client,
+	status elefant.MoneyRequestStatus) {
+
+	var subject string
+	var text string
+	switch status {
+	case elefant.MoneyRequestStatusPending:
+		subject = "Money request"
+		text = fmt.Sprintf("%s (%s) requests %s from you.",
+			from.GetName(), from.GetEmail(), request.Value)
+		if request.Description != "" {
+			text += fmt.Sprintf("\n\n%s", request.Description)
+		}
+		text += fmt.Sprintf("\n\nThe request expires at %s.",
+			request.Expiry.Format(time.RFC1123))
+	case elefant.MoneyRequestStatusAccepted:
+		subject = "Money request is paid"
+		text = fmt.Sprintf("%s (%s) paid your request for %s.",
+			from.GetName(), from.GetEmail(), request.Value)
+	case elefant.MoneyRequestStatusDeclined:
+		subject = "Money request is declined"
+		text = fmt.Sprintf("%s (%s) declined your request for %s.",
+			from.GetName(), from.GetEmail(), request.Value)
+	case elefant.MoneyRequestStatusCancelled:
+		subject = "Money request is cancelled"
+		text = fmt.Sprintf("%s (%s) cancelled the request for %s.",
+			from.GetName(), from.GetEmail(), request.Value)
+	default:
+		elefant.Log.Error(`Money request "%s" status "%s" has no notification.`,
+			request.ID, status)
+		return
+	}
+
+	if err := sendTextEmail(to, subject, text); err != nil {
+		elefant.Log.Error(
+			`Failed to send money request notification for user "%s": "%v".`,
+			to.GetID(), err)
+		return
+	}
+	elefant.Log.Info(
+		`Sent money request "%s" notification "%s" for user "%s" on email "%s".`,
+		request.ID, status, to.GetID(), to.GetEmail())
+}
+
+// lockMoneyRequest finds and locks pending money request in which the client
+// is the payer, or the payee if isPayer is false.
+func lockMoneyRequest(
+	id elefant.MoneyRequestID,
+	client elefant.ClientID,
+	isPayer bool,
+	db elefant.DBTrans) (*elefant.MoneyRequest, *httpResponse, error) {
+
+	request, err := db.LockClientMoneyRequest(id, client)
+	if err != nil {
+		return nil, nil,
+			fmt.Errorf(`failed to find money request "%s": "%v"`, id, err)
+	}
+	if request == nil || (request.Payer == client) != isPayer {
+		response, err := newHTTPResponseEmptyError(http.StatusNotFound,
+			`client "%s" does not have money request "%s"`, client, id)
+		return nil, response, err
+	}
+	if status := request.GetStatus(time.Now().UTC()); status !=
+		elefant.MoneyRequestStatusPending {
+		response, err := newHTTPResponseEmptyError(http.StatusConflict,
+			`money request "%s" is %s`, id, status)
+		return nil, response, err
+	}
+	return request, nil, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type moneyRequestCreateLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewMoneyRequestCreateLambda() lambdaImpl {
+	return &moneyRequestCreateLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*moneyRequestCreateLambda) CreateRequest() interface{} {
+	return &moneyRequestOrder{}
+}
+
+func (lambda *moneyRequestCreateLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	accID, err := lambdaRequest.ReadPathArgAccountID()
+	if err != nil {
+		return newHTTPResponseBadParam("account ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	order := lambdaRequest.GetRequest().(*moneyRequestOrder)
+	if err := checkmail.ValidateFormat(order.Email); err != nil {
+		return newHTTPResponseBadParam("email has invalid format",
+			`failed to validate email: "%v"`, order.Email)
+	}
+	if len(order.Description) > moneyRequestDescriptionMaxLen {
+		return newHTTPResponseBadParam("description is too long",
+			`description has %d symbols`, len(order.Description))
+	}
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, response, err := lambda.findClientAccount(accID, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	value, response, err := parseMoneyValue(order.Value, acc.GetCurrency())
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	payerAccID, err := db.FindAccountByEmail(order.Email, acc.GetCurrency())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account by email "%s": "%v"`,
+			order.Email, err)
+	}
+	if payerAccID == nil {
+		return newHTTPResponseEmptyError(http.StatusNotFound,
+			`there is no account in "%s" for email "%s"`,
+			acc.GetCurrency().GetISO(), order.Email)
+	}
+	payerAcc, err := db.FindAccount(*payerAccID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account "%s": "%v"`,
+			*payerAccID, err)
+	}
+	if payerAcc.GetClientID() == clientID {
+		return newHTTPResponseBadParam("money could not be requested from yourself",
+			`client "%s" requests money from itself`, clientID)
+	}
+
+	payee, err := db.GetClient(clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get payee client "%s": "%v"`,
+			clientID, err)
+	}
+	payer, err := db.GetClient(payerAcc.GetClientID())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get payer client "%s": "%v"`,
+			payerAcc.GetClientID(), err)
+	}
+
+	request := elefant.NewMoneyRequest(payee, accID, payer, payerAcc.GetID(),
+		*value, order.Description, elefant.MoneyRequestLiveTime)
+	if err := db.CreateMoneyRequest(request); err != nil {
+		return nil, fmt.Errorf(`failed to create money request: "%v"`, err)
+	}
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(`Created money request "%s" for %s from "%s" to "%s".`,
+		request.ID, request.Value, request.PayerAccount, request.PayeeAccount)
+	notifyMoneyRequest(request, payer, payee, request.Status)
+
+	return newHTTPResponse(http.StatusCreated,
+		newMoneyRequest(request, clientID, request.Time))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type moneyRequestListLambda struct{ accountBalanceLambda }
+
+func (*lambdaFactory) NewMoneyRequestListLambda() lambdaImpl {
+	return &moneyRequestListLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*moneyRequestListLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *moneyRequestListLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	clientID := lambdaRequest.GetClientID()
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	requests, err := db.GetClientMoneyRequests(clientID)
+	if err != nil {
+		return nil, fmt.Errorf(
+			`failed to get money requests for client "%s": "%v"`, clientID, err)
+	}
+	now := time.Now().UTC()
+	result := make([]*moneyRequest, len(requests))
+	for i, request := range requests {
+		result[i] = newMoneyRequest(request, clientID, now)
+	}
+	return newHTTPResponse(http.StatusOK, result)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type moneyRequestAcceptLambda struct{ accountPaymentToAccountLambda }
+
+func (*lambdaFactory) NewMoneyRequestAcceptLambda() lambdaImpl {
+	return &moneyRequestAcceptLambda{
+		accountPaymentToAccountLambda: accountPaymentToAccountLambda{
+			accountExchangeLambda: newAccountExchangeLambda()}}
+}
+
+func (*moneyRequestAcceptLambda) CreateRequest() interface{} { return nil }
+
+func (lambda *moneyRequestAcceptLambda) Run(
+	lambdaRequest LambdaRequest) (*httpResponse, error) {
+
+	id, err := lambdaRequest.ReadPathArgMoneyRequestID()
+	if err != nil {
+		return newHTTPResponseBadParam(
+			"money request ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	if response, err := lambdaRequest.FindIdempotentResponse(
+		db); response != nil || err != nil {
+		return response, err
+	}
+
+	request, response, err := lockMoneyRequest(id, clientID, true, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+
+	accFrom, response, err := lambda.findClientAccount(
+		request.PayerAccount, clientID, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	accTo, err := db.FindAccount(request.PayeeAccount)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account "%s": "%v"`,
+			request.PayeeAccount, err)
+	}
+	payer, err := db.GetClient(clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get payer client "%s": "%v"`,
+			clientID, err)
+	}
+	payee, err := db.GetClient(request.Payee)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get payee client "%s": "%v"`,
+			request.Payee, err)
+	}
+
+	response, err = lambda.pay(accFrom, accTo, request.Value, "",
+		func(response *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+			// The request stays pending if the payment is failed.
+			if response.StatusCode == http.StatusAccepted {
+				err := db.UpdateMoneyRequestStatus(
+					request.ID, elefant.MoneyRequestStatusAccepted)
+				if err != nil {
+					return nil, fmt.Errorf(
+						`failed to update money request "%s" status: "%v"`,
+						request.ID, err)
+				}
+			}
+			return lambdaRequest.StoreIdempotentResponse(response, db)
+		},
+		db)
+	if err != nil || response.StatusCode != http.StatusAccepted {
+		return response, err
+	}
+
+	elefant.Log.Info(`Money request "%s" is accepted.`, request.ID)
+	notifyMoneyRequest(request, payee, payer, elefant.MoneyRequestStatusAccepted)
+	return response, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+type moneyRequestResolveLambda struct{ accountBalanceLambda }
+
+func newMoneyRequestResolveLambda() moneyRequestResolveLambda {
+	return moneyRequestResolveLambda{
+		accountBalanceLambda: newAccountBalanceLambda()}
+}
+
+func (*moneyRequestResolveLambda) CreateRequest() interface{} { return nil }
+
+// resolve moves pending money request into the final status without payment
+// and notifies the other side.
+func (lambda *moneyRequestResolveLambda) resolve(
+	lambdaRequest LambdaRequest,
+	isPayer bool,
+	status elefant.MoneyRequestStatus) (*httpResponse, error) {
+
+	id, err := lambdaRequest.ReadPathArgMoneyRequestID()
+	if err != nil {
+		return newHTTPResponseBadParam(
+			"money request ID has invalid format", "%v", err)
+	}
+	clientID := lambdaRequest.GetClientID()
+
+	db, err := lambda.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	request, response, err := lockMoneyRequest(id, clientID, isPayer, db)
+	if response != nil || err != nil {
+		return response, err
+	}
+	if err := db.UpdateMoneyRequestStatus(request.ID, status); err != nil {
+		return nil, fmt.Errorf(`failed to update money request "%s" status: "%v"`,
+			request.ID, err)
+	}
+	request.Status = status
+
+	to := request.Payer
+	if isPayer {
+		to = request.Payee
+	}
+	toClient, err := db.GetClient(to)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get client "%s": "%v"`, to, err)
+	}
+	fromClient, err := db.GetClient(clientID)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get client "%s": "%v"`, clientID, err)
+	}
+
+	if err := db.Commit(); err != nil {
+		return nil, err
+	}
+
+	elefant.Log.Info(`Money request "%s" is %s.`, request.ID, status)
+	notifyMoneyRequest(request, toClient, fromClient, status)
+
+	return newHTTPResponse(http.StatusOK,
+		newMoneyRequest(request, clientID, time.Now().UTC()))
+}
+
+type moneyRequestDeclineLambda struct{ moneyRequestResolveLambda }
+
+func (*lambdaFactory) NewMoneyRequestDeclineLambda() lambdaImpl {
+	return &moneyRequestDeclineLambda{
+		moneyRequestResolveLambda: newMoneyRequestResolveLambda()}
+}
+
+func (lambda *moneyRequestDeclineLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+	return lambda.resolve(request, true, elefant.MoneyRequestStatusDeclined)
+}
+
+type moneyRequestCancelLambda struct{ moneyRequestResolveLambda }
+
+func (*lambdaFactory) NewMoneyRequestCancelLambda() lambdaImpl {
+	return &moneyRequestCancelLambda{
+		moneyRequestResolveLambda: newMoneyRequestResolveLambda()}
+}
+
+func (lambda *moneyRequestCancelLambda) Run(
+	request LambdaRequest) (*httpResponse, error) {
+	return lambda.resolve(request, false, elefant.MoneyRequestStatusCancelled)
+}
+
+////////////////////////////////////////////////////////////////////////////////
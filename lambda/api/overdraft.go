@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// OverdraftInterest accrues interest for used overdrafts.
+type OverdraftInterest interface {
+	// Run accrues the interest for each day up to the previous day by
+	// the end-of-day balance for each account with the used overdraft.
+	// The interest is accrued only once for the day, so it could be run
+	// several times.
+	Run() error
+}
+
+// NewOverdraftInterest creates new overdraft interest accrual.
+func NewOverdraftInterest() OverdraftInterest {
+	result := &overdraftInterest{accountBalanceLambda: newAccountBalanceLambda()}
+	if err := result.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init overdraft interest: "%v".`, err)
+	}
+	return result
+}
+
+type overdraftInterest struct{ accountBalanceLambda }
+
+func (job *overdraftInterest) Run() error {
+	now := time.Now().UTC()
+	lastDay := time.Date(
+		now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	count := 0
+	// Failed accounts are skipped till the next run, so they don't block
+	// other accounts.
+	failed := []elefant.AccountID{}
+	for {
+		accID, err := job.accrueNext(lastDay, failed)
+		if err != nil {
+			if accID == nil {
+				return err
+			}
+			elefant.Log.Error(
+				`Failed to accrue overdraft interest for account "%s": "%v".`,
+				*accID, err)
+			failed = append(failed, *accID)
+			continue
+		}
+		if accID == nil {
+			break
+		}
+		count++
+	}
+	elefant.Log.Info(
+		"Accrued overdraft interest for %d account days up to %s, failed %d.",
+		count, lastDay.Format("2006-01-02"), len(failed))
+	return nil
+}
+
+// accrueNext accrues the interest for the next not accrued day of the next
+// account. Returns the account ID, or nil if there is no account to accrue.
+// The error with the account ID is the account accrual error.
+func (job *overdraftInterest) accrueNext(
+	lastDay time.Time,
+	skip []elefant.AccountID) (*elefant.AccountID, error) {
+
+	db, err := job.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	acc, day, err := db.LockOverdraftAccount(lastDay, skip)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account with overdraft: "%v"`,
+			err)
+	}
+	if acc == nil {
+		return nil, nil
+	}
+	accID := acc.GetID()
+	if err := job.accrue(acc, day, db); err != nil {
+		return &accID, err
+	}
+	return &accID, nil
+}
+
+// accrue accrues the interest for the day by the account end-of-day balance.
+func (job *overdraftInterest) accrue(
+	acc elefant.Account, day time.Time, db elefant.DBTrans) error {
+	accID := acc.GetID()
+
+	balance, err := db.GetAccountBalanceAt(acc, day.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf(
+			`failed to get account "%s" end-of-day balance: "%v"`, accID, err)
+	}
+
+	var trans *elefant.Trans
+	// The interest is zero for not used or too small overdraft, so only
+	// the day is stored.
+	if interest := elefant.CalcOverdraftInterest(
+		balance); !interest.IsZero() {
+		journal := elefant.NewChargeJournal()
+		journal.AddAccountPosting(accID, interest.Neg())
+		journal.AddSystemPosting(elefant.SystemAccountOverdraftInterest, interest)
+		accounts, response, err := job.postJournal(journal, db)
+		if err != nil {
+			return err
+		}
+		if response != nil {
+			// Charges are not allowed only for closed accounts, which are not
+			// accrued.
+			return fmt.Errorf(
+				`account "%s" status doesn't allow overdraft interest`, accID)
+		}
+		acc = accounts[accID]
+		method, err := db.GetOverdraftMethod(acc)
+		if err != nil {
+			return fmt.Errorf(`failed to get overdraft method: "%v"`, err)
+		}
+		trans, err = db.StoreTrans(elefant.TransStatusSuccess,
+			journal.ID, acc, method, interest.Neg(), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := db.SetAccountOverdraftAccrued(accID, day); err != nil {
+		return fmt.Errorf(
+			`failed to store account "%s" overdraft accrual: "%v"`, accID, err)
+	}
+	if err := db.Commit(); err != nil {
+		return err
+	}
+
+	if trans != nil {
+		elefant.Log.Info(fmtTransLog(trans))
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ReconcileResult is the result of the ledger reconciliation run.
+type ReconcileResult struct {
+	Run              elefant.ReconcileRunID
+	NumberOfAccounts int
+	// Discrepancies is the number of accounts with discrepancies.
+	Discrepancies int
+}
+
+// Reconciliation checks that the account balances are equal to the account
+// ledger postings and transactions, and that the account revisions are not
+// less than the number of the account transactions.
+type Reconciliation interface {
+	// Run checks all accounts, logs alerts for the accounts with
+	// discrepancies and, if isDryRun is not set, stores the discrepancies in
+	// the report. The dry run doesn't change the DB.
+	Run(isDryRun bool) (*ReconcileResult, error)
+}
+
+// NewReconciliation creates new ledger reconciliation.
+func NewReconciliation() Reconciliation {
+	result := &reconciliation{accountLambda: newAccountLambda()}
+	if err := result.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init reconciliation: "%v".`, err)
+	}
+	return result
+}
+
+type reconciliation struct{ accountLambda }
+
+func (job *reconciliation) Run(isDryRun bool) (*ReconcileResult, error) {
+	result := &ReconcileResult{Run: elefant.NewReconcileRunID()}
+	var after *elefant.AccountID
+	for {
+		last, err := job.checkNext(after, isDryRun, result)
+		if err != nil {
+			return result, err
+		}
+		if last == nil {
+			break
+		}
+		after = last
+	}
+	mode := ""
+	if isDryRun {
+		mode = " (dry run)"
+	}
+	elefant.Log.Info(
+		`Reconciliation "%s"%s checked %d accounts, found %d discrepancies.`,
+		result.Run, mode, result.NumberOfAccounts, result.Discrepancies)
+	return result, nil
+}
+
+// checkNext checks the next accounts batch after the account in one DB
+// transaction. Returns the last checked account, or nil if there are no
+// more accounts.
+func (job *reconciliation) checkNext(
+	after *elefant.AccountID,
+	isDryRun bool,
+	result *ReconcileResult) (*elefant.AccountID, error) {
+	db, err := job.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Rollback()
+
+	accounts, err := db.GetAccountReconciliations(after, reconcileBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to get accounts to reconcile: "%v"`, err)
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	for _, acc := range accounts {
+		result.NumberOfAccounts++
+		discrepancies := acc.GetDiscrepancies()
+		if len(discrepancies) == 0 {
+			continue
+		}
+		result.Discrepancies++
+		reason := strings.Join(discrepancies, "; ")
+		elefant.Log.Error(`Account "%s" is not reconciled by "%s": %s.`,
+			acc.Account, result.Run, reason)
+		if isDryRun {
+			continue
+		}
+		err := db.StoreReconcileDiscrepancy(result.Run, acc, reason)
+		if err != nil {
+			return nil, fmt.Errorf(
+				`failed to store account "%s" discrepancy: "%v"`, acc.Account, err)
+		}
+	}
+
+	if !isDryRun {
+		if err := db.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return &accounts[len(accounts)-1].Account, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
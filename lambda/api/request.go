@@ -74,3 +74,13 @@ func newHTTPResponseBadParam(
 func newHTTPResponseNoContent() (*httpResponse, error) {
 	return newHTTPResponseWithBody(http.StatusNoContent, "", map[string]string{})
 }
+
+// readHTTPResponseError returns the error message from the response body, or
+// empty string if the response has no error message.
+func readHTTPResponseError(response *httpResponse) string {
+	var result errorResponse
+	if err := json.Unmarshal([]byte(response.Body), &result); err != nil {
+		return ""
+	}
+	return result.Message
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/palchukovsky/elefantpay-aws/elefant"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Scheduler executes due standing orders.
+type Scheduler interface {
+	// Run executes standing orders which have to be executed by now.
+	Run() error
+}
+
+// NewScheduler creates new standing orders executor.
+func NewScheduler() Scheduler {
+	result := &scheduler{
+		accountPayment: accountPaymentToAccountLambda{
+			accountExchangeLambda: newAccountExchangeLambda()},
+		taxPayment: accountPaymentTaxLambda{
+			accountBalanceLambda: newAccountBalanceLambda()}}
+	if err := result.accountPayment.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init payment to account: "%v".`, err)
+	}
+	if err := result.taxPayment.Init(); err != nil {
+		elefant.Log.Panic(`Failed to init tax payment: "%v".`, err)
+	}
+	return result
+}
+
+type scheduler struct {
+	accountPayment accountPaymentToAccountLambda
+	taxPayment     accountPaymentTaxLambda
+}
+
+func (scheduler *scheduler) Run() error {
+	now := time.Now().UTC()
+	count := 0
+	for ; count < schedulerRunLimit; count++ {
+		isExecuted, err := scheduler.runNext(now)
+		if err != nil {
+			return err
+		}
+		if !isExecuted {
+			break
+		}
+	}
+	elefant.Log.Info("Executed %d standing orders.", count)
+	return nil
+}
+
+// runNext executes the next due standing order. Returns false if there is
+// no standing order to execute.
+func (scheduler *scheduler) runNext(now time.Time) (bool, error) {
+	db, err := scheduler.taxPayment.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer db.Rollback()
+
+	schedule, err := db.LockDueSchedule(now)
+	if err != nil {
+		return false, fmt.Errorf(`failed to find due standing order: "%v"`, err)
+	}
+	if schedule == nil {
+		return false, nil
+	}
+
+	// The complete hook changes the standing order, so the failed run is
+	// stored from the copy.
+	failed := *schedule
+	response, err := scheduler.execute(schedule, now, db)
+	if err != nil {
+		// The failed transaction is stored for the client, and the standing
+		// order is skipped till the next run, so its failure doesn't block
+		// other standing orders.
+		db.Rollback()
+		elefant.Log.Error(`Failed to execute standing order "%s": "%v".`,
+			schedule.ID, err)
+		if err := scheduler.fail(
+			failed, now, schedulerInternalErrorReason); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	switch {
+	case response.StatusCode == http.StatusAccepted:
+		elefant.Log.Info(`Standing order "%s" is executed.`, schedule.ID)
+	case response.StatusCode == http.StatusPaymentRequired:
+		// The failed transaction with the reason is stored.
+		elefant.Log.Warn(`Standing order "%s" is failed.`, schedule.ID)
+	case response.StatusCode == http.StatusConflict:
+		// The run is already stored by another scheduler.
+		elefant.Log.Warn(`Standing order "%s" is already executed.`, schedule.ID)
+	default:
+		// The payment is rejected before any changes, so nothing is committed.
+		db.Rollback()
+		reason := readHTTPResponseError(response)
+		if reason == "" {
+			reason = fmt.Sprintf("payment rejected with status %d",
+				response.StatusCode)
+		}
+		if err := scheduler.fail(failed, now, reason); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// execute executes the standing order payment, the standing order run is
+// stored in the same DB transaction as the payment, or as the failed
+// transaction if there are no enough funds.
+func (scheduler *scheduler) execute(
+	schedule *elefant.Schedule,
+	now time.Time,
+	db elefant.DBTrans) (*httpResponse, error) {
+
+	acc, err := db.FindClientAccount(schedule.Account, schedule.Client)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to find account "%s": "%v"`,
+			schedule.Account, err)
+	}
+	if acc == nil {
+		return nil, fmt.Errorf(`client "%s" does not have account "%s"`,
+			schedule.Client, schedule.Account)
+	}
+
+	complete := scheduler.newCompleteHook(schedule, now)
+
+	switch schedule.Target {
+	case elefant.ScheduleTargetAccount:
+		accTo, err := db.FindAccount(*schedule.Receiver)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to find account "%s": "%v"`,
+				*schedule.Receiver, err)
+		}
+		if accTo == nil {
+			return newHTTPResponseEmptyError(http.StatusNotFound,
+				`receiver account ID "%s" is not existent`, *schedule.Receiver)
+		}
+		return scheduler.accountPayment.pay(
+			acc, accTo, schedule.Value, "", complete, db)
+	case elefant.ScheduleTargetTax:
+		if schedule.TaxAuthority == nil {
+			// Standing order is stored before the authority registry, so it's
+			// rejected until the client sets the authority.
+			return newHTTPResponseBadParam("tax authority is not set",
+				`standing order "%s" does not have tax authority`, schedule.ID)
+		}
+		bill, response, err := readTaxBill(*schedule.TaxAuthority, schedule.Bill,
+			acc.GetCurrency(), db)
+		if response != nil || err != nil {
+			return response, err
+		}
+		return scheduler.taxPayment.pay(acc, schedule.Value, bill, complete, db)
+	default:
+		return nil, fmt.Errorf(`standing order target "%s" is not supported`,
+			schedule.Target)
+	}
+}
+
+// newCompleteHook creates the hook which stores the standing order run
+// before the payment commit. The run is stored only once, so if the standing
+// order is executed concurrently - only one payment is committed.
+func (scheduler *scheduler) newCompleteHook(
+	schedule *elefant.Schedule, now time.Time) commitHook {
+	prevNextTime := *schedule.NextTime
+	return func(_ *httpResponse, db elefant.DBTrans) (*httpResponse, error) {
+		schedule.Complete(now)
+		isCompleted, err := db.CompleteSchedule(schedule, prevNextTime)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to complete standing order "%s": "%v"`,
+				schedule.ID, err)
+		}
+		if !isCompleted {
+			return newHTTPResponseEmptyError(http.StatusConflict,
+				`standing order "%s" run at %s is already completed`,
+				schedule.ID, prevNextTime)
+		}
+		return nil, nil
+	}
+}
+
+// fail stores the failed standing order run with the reason, or skips
+// the run if even the failed transaction could not be stored.
+func (scheduler *scheduler) fail(
+	schedule elefant.Schedule, now time.Time, reason string) error {
+	// The complete hook changes the standing order, so each attempt uses
+	// its own copy.
+	stored := schedule
+	if err := scheduler.storeFailed(&stored, now, reason); err != nil {
+		if err := scheduler.skipFailed(&schedule, now, err); err != nil {
+			return fmt.Errorf(`failed to skip failed standing order "%s": "%v"`,
+				schedule.ID, err)
+		}
+	}
+	return nil
+}
+
+// skipFailed stores the standing order run without payment in the new DB
+// transaction, if even the failed transaction could not be stored, so
+// the standing order will be executed at the next time.
+func (scheduler *scheduler) skipFailed(
+	schedule *elefant.Schedule, now time.Time, storeErr error) error {
+
+	elefant.Log.Error(
+		`Failed to store failed transaction for standing order "%s": "%v".`,
+		schedule.ID, storeErr)
+
+	db, err := scheduler.taxPayment.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer db.Rollback()
+
+	prevNextTime := *schedule.NextTime
+	schedule.Complete(now)
+	isCompleted, err := db.CompleteSchedule(schedule, prevNextTime)
+	if err != nil {
+		return err
+	}
+	if !isCompleted {
+		elefant.Log.Warn(`Standing order "%s" is already executed.`, schedule.ID)
+		return nil
+	}
+	return db.Commit()
+}
+
+// storeFailed stores failed transaction with the reason and the standing
+// order run in the new DB transaction, so the client sees the failure in
+// the account history.
+func (scheduler *scheduler) storeFailed(
+	schedule *elefant.Schedule, now time.Time, reason string) error {
+
+	db, err := scheduler.taxPayment.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer db.Rollback()
+
+	acc, err := db.FindClientAccount(schedule.Account, schedule.Client)
+	if err != nil {
+		return fmt.Errorf(`failed to find account "%s": "%v"`,
+			schedule.Account, err)
+	}
+	if acc == nil {
+		return fmt.Errorf(`client "%s" does not have account "%s"`,
+			schedule.Client, schedule.Account)
+	}
+
+	var method elefant.Method
+	switch schedule.Target {
+	case elefant.ScheduleTargetAccount:
+		var email string
+		if accTo, err := db.FindAccount(*schedule.Receiver); err != nil {
+			return fmt.Errorf(`failed to find account "%s": "%v"`,
+				*schedule.Receiver, err)
+		} else if accTo != nil {
+			clientTo, err := db.GetClient(accTo.GetClientID())
+			if err != nil {
+				return fmt.Errorf(`failed to get receiver client "%s": "%v"`,
+					accTo.GetClientID(), err)
+			}
+			email = clientTo.GetEmail()
+		}
+		method, err = db.GetAccountMethod(acc, *schedule.Receiver, email)
+	case elefant.ScheduleTargetTax:
+		var authority *elefant.TaxAuthority
+		if schedule.TaxAuthority != nil {
+			authority, err = db.FindTaxAuthority(*schedule.TaxAuthority)
+			if err != nil {
+				return fmt.Errorf(`failed to find tax authority "%s": "%v"`,
+					*schedule.TaxAuthority, err)
+			}
+		}
+		method, err = db.GetTaxMethod(acc, authority, schedule.Bill)
+	}
+	if err != nil {
+		return fmt.Errorf(`failed to get payment method: "%v"`, err)
+	}
+
+	response, err := scheduler.taxPayment.storeFailedTrans(acc, method,
+		schedule.Value.Neg(), nil, reason,
+		scheduler.newCompleteHook(schedule, now), db, nil)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusConflict {
+		elefant.Log.Warn(`Standing order "%s" is already executed.`, schedule.ID)
+		return nil
+	}
+	elefant.Log.Warn(`Standing order "%s" is failed: "%s".`,
+		schedule.ID, reason)
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
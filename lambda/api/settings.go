@@ -1,4 +1,78 @@
 package api
 
+import "time"
+
 // AuthTokenHeaderName is the name of an auth_token header.
 const AuthTokenHeaderName = "Auth-Token"
+
+// defaultAccountCurrency is an ISO code of the currency for the first client
+// account if the client didn't choose another one at registration.
+const defaultAccountCurrency = "EUR"
+
+// HistoryCursorHeaderName is the name of a response header with the cursor
+// for the next page of the account history.
+const HistoryCursorHeaderName = "History-Cursor"
+
+// ExportCursorHeaderName is the name of a response header with the cursor
+// for the next page of the account export.
+const ExportCursorHeaderName = "Export-Cursor"
+
+// IdempotencyKeyHeaderName is the name of a request header with a client key
+// to execute POST request only once.
+const IdempotencyKeyHeaderName = "Idempotency-Key"
+
+// IdempotentReplayedHeaderName is the name of a response header which is set
+// if the response is replayed for the request with already used idempotency
+// key.
+const IdempotentReplayedHeaderName = "Idempotent-Replayed"
+
+// idempotencyKeyMaxLen is the max length of the idempotency key.
+const idempotencyKeyMaxLen = 255
+
+// idempotencyKeyTTL is the period while the response for the idempotency key
+// is stored, after it the key could be used again.
+const idempotencyKeyTTL = 24 * time.Hour
+
+// schedulerRunLimit is the max number of standing orders executed by one
+// scheduler run.
+const schedulerRunLimit = 100
+
+// schedulerInternalErrorReason is the failed transaction reason for
+// the standing order which is not executed by an internal error, the error
+// itself is logged, but not shown to the client.
+const schedulerInternalErrorReason = "payment is not executed by internal error"
+
+// paymentBatchMaxItems is the max number of payments in one payment batch.
+const paymentBatchMaxItems = 100
+
+// accountInfoHistorySize is the number of the last actions in the account
+// info.
+const accountInfoHistorySize = 5
+
+// accountHistoryPageSize is the default number of actions in the account
+// history page.
+const accountHistoryPageSize = 50
+
+// accountHistoryPageMaxSize is the max number of actions in the account
+// history page which could be requested.
+const accountHistoryPageMaxSize = 200
+
+// queryDateFormat is the format of dates in the request query.
+const queryDateFormat = "2006-01-02"
+
+// accountStatementMaxDays is the max number of days in the account statement
+// period.
+const accountStatementMaxDays = 366
+
+// accountExportMaxDays is the max number of days in the account transactions
+// export period.
+const accountExportMaxDays = 366
+
+// accountExportPageSize is the max number of actions in the account export
+// response. The export is returned in the response body, so the page has to
+// fit into the Lambda response payload limit (6 MB).
+const accountExportPageSize = 5000
+
+// reconcileBatchSize is the number of accounts checked by the ledger
+// reconciliation in one DB transaction.
+const reconcileBatchSize = 500
@@ -7,7 +7,7 @@ import (
 )
 
 func fmtTransLog(trans *elefant.Trans) string {
-	result := fmt.Sprintf(`Trans "%s" "%s" (%d): "%s"(%s, %s) -> %f -> "%s"/"%s"`,
+	result := fmt.Sprintf(`Trans "%s" "%s" (%d): "%s"(%s, %s) -> %s -> "%s"/"%s"`,
 		trans.ID, trans.Status.String(), trans.Status, trans.Method.GetID(),
 		trans.Method.GetTypeName(), trans.Method.GetName(), trans.Value,
 		trans.Account.GetClientID(), trans.Account.GetID())